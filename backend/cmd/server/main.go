@@ -2,68 +2,219 @@ package main
 
 import (
 	"context"
-	"log"
+	"flag"
+	"io/fs"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
-	"path/filepath"
+	"sync"
 	"syscall"
 	"time"
 
+	"navplane/internal/buildinfo"
 	"navplane/internal/config"
 	"navplane/internal/database"
+	"navplane/internal/errreport"
 	"navplane/internal/handler"
+	"navplane/internal/logging"
+	"navplane/internal/metrics"
+	"navplane/internal/middleware"
+	"navplane/internal/openai"
 	"navplane/internal/org"
+	"navplane/internal/requestlog"
+	"navplane/internal/servertls"
+	"navplane/internal/tracing"
+	"navplane/internal/webhook"
+	"navplane/migrations"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			os.Exit(runMigrate(os.Args[2:]))
+		case "seed":
+			os.Exit(runSeed(os.Args[2:]))
+		}
+	}
+
+	validate := flag.Bool("validate-config", false, "check configuration, database connectivity and migrations, then exit")
+	flag.Parse()
+	if *validate {
+		if !validateConfig(os.Stdout) {
+			os.Exit(1)
+		}
+		return
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("failed to load configuration: %v", err)
+		fatal("failed to load configuration", err)
 	}
 
-	// Connect to database
-	db, err := database.Connect(cfg.Database)
+	logger := logging.New(os.Stdout, cfg.Log.Level, cfg.Log.Format)
+	slog.SetDefault(logger)
+
+	openai.SetLimits(openai.Limits{
+		MaxDepth:       cfg.RequestLimits.MaxJSONDepth,
+		MaxExtraFields: cfg.RequestLimits.MaxExtraFields,
+		MaxExtraBytes:  cfg.RequestLimits.MaxExtraBytes,
+	})
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Environment)
 	if err != nil {
-		log.Fatalf("failed to connect to database: %v", err)
+		fatal("failed to initialize tracing", err)
+	}
+
+	flushErrorReports, err := errreport.Setup(cfg.Environment)
+	if err != nil {
+		fatal("failed to initialize error reporting", err)
+	}
+
+	if !cfg.Provider.Configured() {
+		slog.Warn("PROVIDER_BASE_URL/PROVIDER_API_KEY are not set - proxy requests from orgs without a BYOK key will be rejected")
+	}
+	if !cfg.Anthropic.Configured() {
+		slog.Warn("ANTHROPIC_API_KEY is not set - /v1/messages requests will be rejected")
+	}
+	if cfg.AdminAPIKey == "" {
+		slog.Warn("ADMIN_API_KEY is not set - all /admin requests will be rejected")
+	}
+	if cfg.APIKeyPepper == "" {
+		slog.Warn("API_KEY_PEPPER is not set - org API keys are stored as unkeyed SHA-256 hashes")
+	}
+
+	// Connect to database, waiting for it to come up; a shutdown signal
+	// during the wait exits instead of being held until the budget runs out
+	connectCtx, stopConnect := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	db, err := database.Connect(connectCtx, cfg.Database)
+	stopConnect()
+	if err != nil {
+		fatal("failed to connect to database", err)
 	}
 	defer func() {
 		if err := db.Close(); err != nil {
-			log.Printf("error closing database connection: %v", err)
+			slog.Error("error closing database connection", "error", err)
 		}
 	}()
-	log.Println("database connection established")
+	slog.Info("database connection established",
+		"max_open_conns", cfg.Database.MaxOpenConns,
+		"max_idle_conns", cfg.Database.MaxIdleConns,
+		"conn_max_lifetime_s", cfg.Database.ConnMaxLifetime,
+		"conn_max_idle_time_s", cfg.Database.ConnMaxIdleTime,
+	)
+	metrics.RegisterDB(db.DB, "navplane")
+	if replica := db.Replica(); replica != nil {
+		metrics.RegisterDB(replica, "navplane_replica")
+		slog.Info("read replica configured for authentication lookups")
+	}
 
-	// Run migrations
-	migrationsPath := getMigrationsPath()
-	if err := db.MigrateUp(migrationsPath); err != nil {
-		log.Fatalf("failed to run migrations: %v", err)
+	// Run migrations, unless they are managed with `navplane migrate`
+	migrationFS, migrationsFrom := migrationSource()
+	if cfg.Database.AutoMigrate {
+		if err := db.MigrateUp(migrationFS); err != nil {
+			fatal("failed to run migrations", err)
+		}
 	}
-	version, dirty, err := db.MigrateVersion(migrationsPath)
-	if err != nil {
-		log.Printf("WARNING: failed to get migration version: %v", err)
-	} else if dirty {
-		log.Printf("WARNING: database is in dirty state at version %d - a previous migration failed and manual intervention is required", version)
-	} else {
-		log.Printf("database migrations complete (version: %d)", version)
+	status, err := db.MigrateStatus(migrationFS)
+	switch {
+	case err != nil:
+		slog.Warn("failed to get migration status", "error", err)
+	case status.Dirty:
+		slog.Warn("database is in dirty state - fix the failed migration and run `navplane migrate force <version>`", "version", status.Version)
+	case status.Pending > 0:
+		slog.Warn("database has pending migrations - run `navplane migrate up`", "version", status.Version, "pending", status.Pending)
+	default:
+		slog.Info("database migrations complete", "version", status.Version, "auto_migrate", cfg.Database.AutoMigrate, "source", migrationsFrom)
 	}
 
+	// Initialize webhook delivery
+	webhookManager := webhook.NewManager(webhook.NewDatastore(db.DB))
+	dispatcher := webhook.NewDispatcher(webhookManager, nil, webhook.DefaultDispatcherConfig())
+	dispatcher.Start()
+
 	// Initialize org manager
-	orgDatastore := org.NewDatastore(db.DB)
+	orgDatastore := org.NewDatastoreWithReader(db.DB, db.Reader())
 	orgManager := org.NewManager(orgDatastore)
+	orgManager.SetAPIKeyPepper(cfg.APIKeyPepper)
+	orgManager.SetNotifier(dispatcher)
+
+	// Initialize request log persistence and retention
+	requestLogDatastore := requestlog.NewDatastore(db.DB)
+	requestLogManager := requestlog.NewManager(requestLogDatastore)
+	requestLogWriter := requestlog.NewWriter(requestLogDatastore, cfg.RequestLog.BufferSize)
+	retentionCtx, stopRetention := context.WithCancel(context.Background())
+	defer stopRetention()
+	if cfg.RequestLog.RetentionDays > 0 {
+		retention := time.Duration(cfg.RequestLog.RetentionDays) * 24 * time.Hour
+		go requestLogManager.RunRetention(retentionCtx, retention, time.Hour)
+	}
+	go orgManager.RunRotatedKeySweep(retentionCtx, time.Hour)
+	go orgManager.RunKeyUseWriter(retentionCtx)
 
 	// Set up routes with dependencies
+	streams := handler.NewStreamTracker()
 	deps := &handler.Deps{
-		Config:     cfg,
-		OrgManager: orgManager,
+		Config:            cfg,
+		OrgManager:        orgManager,
+		WebhookManager:    webhookManager,
+		RequestLogManager: requestLogManager,
+		RequestLogWriter:  requestLogWriter,
+		ReadinessChecks: []handler.ReadinessCheck{
+			{Name: "database", Check: db.Health},
+		},
+		Streams: streams,
 	}
 
+	// With ADMIN_LISTEN_ADDR set, the admin API, metrics and build info get
+	// their own server so the public listener can't reach them
+	newServer := func(addr string, mux *http.ServeMux) *http.Server {
+		return &http.Server{
+			Addr:              addr,
+			ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+			IdleTimeout:       cfg.Server.IdleTimeout,
+			Handler:           middleware.RealIP(cfg.Server.TrustedProxies)(middleware.RequestLogger(logger, cfg.Log)(otelhttp.NewHandler(middleware.Metrics(middleware.Recover(logger)(mux)), "http.server"))),
+		}
+	}
 	mux := http.NewServeMux()
-	handler.RegisterRoutes(mux, deps)
+	servers := []*http.Server{newServer(cfg.Server.ListenAddr, mux)}
+	if cfg.Server.AdminListenAddr == "" {
+		handler.RegisterRoutes(mux, deps)
+	} else {
+		handler.RegisterProxyRoutes(mux, deps)
+		adminMux := http.NewServeMux()
+		handler.RegisterAdminRoutes(adminMux, deps)
+		servers = append(servers, newServer(cfg.Server.AdminListenAddr, adminMux))
+	}
+
+	// Native TLS termination, with certificate reload on SIGHUP
+	if cfg.TLS.Enabled() {
+		certReloader, err := servertls.NewCertReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			fatal("failed to load TLS certificate", err)
+		}
+		tlsConfig, err := servertls.NewConfig(cfg.TLS, certReloader)
+		if err != nil {
+			fatal("failed to configure TLS", err)
+		}
+		for _, server := range servers {
+			server.TLSConfig = tlsConfig
+		}
 
-	server := &http.Server{
-		Addr:    ":" + cfg.Port,
-		Handler: mux,
+		reload := make(chan os.Signal, 1)
+		signal.Notify(reload, syscall.SIGHUP)
+		go func() {
+			for range reload {
+				if err := certReloader.Reload(); err != nil {
+					slog.Error("TLS certificate reload failed, keeping the current certificate", "error", err)
+					continue
+				}
+				slog.Info("TLS certificate reloaded")
+			}
+		}()
 	}
 
 	// Channel to listen for shutdown signals
@@ -71,64 +222,102 @@ func main() {
 	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
 
 	// Channel to signal server errors
-	serverErr := make(chan error, 1)
+	serverErr := make(chan error, len(servers))
 
-	// Start server in a goroutine
-	go func() {
-		log.Printf("NavPlane server starting on :%s (env: %s)", cfg.Port, cfg.Environment)
-		serverErr <- server.ListenAndServe()
-	}()
+	// Start servers in goroutines
+	build := buildinfo.Get()
+	slog.Info("NavPlane server starting", "addr", cfg.Server.ListenAddr, "admin_addr", cfg.Server.AdminListenAddr, "env", cfg.Environment, "tls", cfg.TLS.Enabled(),
+		"version", build.Version, "commit", build.Commit, "build_date", build.BuildDate)
+	if cfg.DebugEndpoints && cfg.Server.AdminListenAddr == "" && cfg.Environment != "development" {
+		slog.Warn("debug endpoints are enabled on the public listener; set ADMIN_LISTEN_ADDR to keep /debug/ internal")
+	}
+	for _, server := range servers {
+		go func() {
+			if server.TLSConfig != nil {
+				serverErr <- server.ListenAndServeTLS("", "") // Certificates come from TLSConfig.GetCertificate
+				return
+			}
+			serverErr <- server.ListenAndServe()
+		}()
+	}
 
 	// Block until we receive a shutdown signal or server error
 	select {
 	case err := <-serverErr:
 		if err != nil && err != http.ErrServerClosed {
-			log.Fatalf("server error: %v", err)
+			fatal("server error", err)
 		}
 	case sig := <-shutdown:
-		log.Printf("received signal %v, initiating graceful shutdown...", sig)
+		slog.Info("received signal, initiating graceful shutdown", "signal", sig.String())
 
 		// Create a context with timeout for the shutdown
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 		defer cancel()
 
-		// Attempt graceful shutdown
-		log.Println("waiting for in-flight requests to complete...")
-		if err := server.Shutdown(ctx); err != nil {
-			log.Printf("graceful shutdown failed: %v, forcing shutdown", err)
-			if err := server.Close(); err != nil {
-				log.Fatalf("forced shutdown failed: %v", err)
+		// Attempt graceful shutdown. Streams get their own, shorter deadline
+		// so they end with an error event rather than a dropped connection.
+		slog.Info("waiting for in-flight requests to complete", "active_streams", streams.Active())
+		drained := make(chan struct{})
+		go func() {
+			defer close(drained)
+			if cut := streams.Drain(ctx, cfg.Server.StreamDrainTimeout); cut > 0 {
+				slog.Warn("stream drain deadline passed, interrupted remaining streams", "streams", cut)
 			}
+		}()
+		shutdownServers(ctx, servers)
+		<-drained
+
+		slog.Info("flushing pending webhook deliveries")
+		if err := dispatcher.Close(ctx); err != nil {
+			slog.Warn("webhook dispatcher did not drain before shutdown deadline", "error", err)
 		}
 
-		log.Println("server shutdown complete")
+		stopRetention()
+		if err := requestLogWriter.Close(ctx); err != nil {
+			slog.Warn("request log writer did not drain before shutdown deadline", "error", err)
+		}
+
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Warn("failed to flush traces", "error", err)
+		}
+
+		flushErrorReports(5 * time.Second)
+
+		slog.Info("server shutdown complete")
 	}
 }
 
-// getMigrationsPath returns the path to the migrations directory.
-// It checks for the migrations folder relative to the executable or working directory.
-func getMigrationsPath() string {
-	// Check if MIGRATIONS_PATH env var is set
-	if path := os.Getenv("MIGRATIONS_PATH"); path != "" {
-		return path
+// shutdownServers gracefully shuts down all servers concurrently, sharing
+// ctx's deadline, and force-closes any that don't finish in time.
+func shutdownServers(ctx context.Context, servers []*http.Server) {
+	var wg sync.WaitGroup
+	for _, server := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := server.Shutdown(ctx); err != nil {
+				slog.Error("graceful shutdown failed, forcing shutdown", "addr", server.Addr, "error", err)
+				if err := server.Close(); err != nil {
+					fatal("forced shutdown failed", err)
+				}
+			}
+		}()
 	}
+	wg.Wait()
+}
 
-	// Try relative to working directory (for local development)
-	if _, err := os.Stat("migrations"); err == nil {
-		absPath, _ := filepath.Abs("migrations")
-		return absPath
-	}
+// fatal logs err and exits, replacing log.Fatalf now that logging is structured.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
 
-	// Try relative to executable (for Docker)
-	execPath, err := os.Executable()
-	if err == nil {
-		execDir := filepath.Dir(execPath)
-		migrationsPath := filepath.Join(execDir, "migrations")
-		if _, err := os.Stat(migrationsPath); err == nil {
-			return migrationsPath
-		}
+// migrationSource returns the migrations to run and a description for
+// logs: the directory named by MIGRATIONS_PATH when set (for iterating on
+// a migration without rebuilding), otherwise the copy embedded in the binary.
+func migrationSource() (fs.FS, string) {
+	if path := os.Getenv("MIGRATIONS_PATH"); path != "" {
+		return os.DirFS(path), path
 	}
-
-	// Default fallback
-	return "/app/migrations"
+	return migrations.FS, "embedded"
 }
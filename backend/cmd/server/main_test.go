@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestShutdownServers_StopsAll(t *testing.T) {
+	var servers []*http.Server
+	done := make(chan error, 2)
+	for range 2 {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("failed to listen: %v", err)
+		}
+		server := &http.Server{Addr: ln.Addr().String(), Handler: http.NotFoundHandler()}
+		servers = append(servers, server)
+		go func() { done <- server.Serve(ln) }()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdownServers(ctx, servers)
+
+	for range servers {
+		select {
+		case err := <-done:
+			if !errors.Is(err, http.ErrServerClosed) {
+				t.Errorf("expected ErrServerClosed, got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("server still running after shutdownServers")
+		}
+	}
+}
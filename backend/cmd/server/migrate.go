@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"navplane/internal/config"
+	"navplane/internal/database"
+)
+
+const migrateUsage = "usage: navplane migrate <up | down [n] | status | version | force <version>> [--yes]"
+
+// migrator is the subset of *database.DB the migrate subcommand drives.
+type migrator interface {
+	MigrateUp(migrations fs.FS) error
+	MigrateDown(migrations fs.FS, steps int) error
+	MigrateForce(migrations fs.FS, version int) error
+	MigrateStatus(migrations fs.FS) (database.MigrationStatus, error)
+}
+
+// runMigrate implements `navplane migrate ...` and returns the process exit
+// code. It uses the same configuration (DATABASE_URL, CONFIG_FILE,
+// MIGRATIONS_PATH) and embedded migrations as the server.
+func runMigrate(args []string) int {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load configuration: %v\n", err)
+		return 1
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	db, err := database.Connect(ctx, cfg.Database)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	migrationFS, _ := migrationSource()
+	if err := migrateCommand(db, migrationFS, args, os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// migrateCommand parses args and runs one migration command against m.
+// Destructive commands (down, force) ask for confirmation on in unless
+// --yes is given.
+func migrateCommand(m migrator, migrations fs.FS, args []string, in io.Reader, out io.Writer) error {
+	positional, yes, err := parseMigrateArgs(args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	cmd, rest := positional[0], positional[1:]
+	switch cmd {
+	case "up":
+		if len(rest) != 0 {
+			return errors.New(migrateUsage)
+		}
+		if err := m.MigrateUp(migrations); err != nil {
+			return err
+		}
+		return printMigrationStatus(m, migrations, out)
+
+	case "down":
+		steps := 1
+		if len(rest) > 1 {
+			return errors.New(migrateUsage)
+		}
+		if len(rest) == 1 {
+			steps, err = strconv.Atoi(rest[0])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid step count %q: must be a positive integer", rest[0])
+			}
+		}
+		status, err := m.MigrateStatus(migrations)
+		if err != nil {
+			return err
+		}
+		prompt := fmt.Sprintf("Roll back %d migration(s) from version %d? Down migrations may drop data.", steps, status.Version)
+		if !yes && !confirm(in, out, prompt) {
+			return errors.New("aborted")
+		}
+		if err := m.MigrateDown(migrations, steps); err != nil {
+			return err
+		}
+		return printMigrationStatus(m, migrations, out)
+
+	case "force":
+		if len(rest) != 1 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.Atoi(rest[0])
+		if err != nil || version < -1 {
+			return fmt.Errorf("invalid version %q: must be an integer (-1 for none)", rest[0])
+		}
+		prompt := fmt.Sprintf("Mark the database as version %d and clear the dirty flag without running migrations?", version)
+		if !yes && !confirm(in, out, prompt) {
+			return errors.New("aborted")
+		}
+		if err := m.MigrateForce(migrations, version); err != nil {
+			return err
+		}
+		return printMigrationStatus(m, migrations, out)
+
+	case "status", "version":
+		if len(rest) != 0 {
+			return errors.New(migrateUsage)
+		}
+		return printMigrationStatus(m, migrations, out)
+	}
+	return fmt.Errorf("unknown migrate command %q\n%s", cmd, migrateUsage)
+}
+
+// parseMigrateArgs separates positional arguments from flags, which may
+// appear anywhere (`down 2 --yes` and `--yes down 2` are equivalent).
+func parseMigrateArgs(args []string) ([]string, bool, error) {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	yes := fs.Bool("yes", false, "skip confirmation prompts")
+
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, false, fmt.Errorf("%v\n%s", err, migrateUsage)
+		}
+		if fs.NArg() == 0 {
+			return positional, *yes, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// confirm asks a yes/no question and reports whether the answer was yes.
+func confirm(in io.Reader, out io.Writer, prompt string) bool {
+	fmt.Fprintf(out, "%s [y/N] ", prompt)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func printMigrationStatus(m migrator, migrations fs.FS, out io.Writer) error {
+	status, err := m.MigrateStatus(migrations)
+	if err != nil {
+		return err
+	}
+	line := fmt.Sprintf("version %d (latest %d, %d pending)", status.Version, status.Latest, status.Pending)
+	if status.Dirty {
+		line += " DIRTY: fix the failed migration, then run `navplane migrate force <version>`"
+	}
+	fmt.Fprintln(out, line)
+	return nil
+}
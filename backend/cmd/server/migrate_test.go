@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"navplane/internal/database"
+)
+
+// fakeMigrator records the calls migrateCommand makes.
+type fakeMigrator struct {
+	status database.MigrationStatus
+	err    error
+	calls  []string
+}
+
+func (f *fakeMigrator) MigrateUp(fs.FS) error {
+	f.calls = append(f.calls, "up")
+	return f.err
+}
+
+func (f *fakeMigrator) MigrateDown(_ fs.FS, steps int) error {
+	f.calls = append(f.calls, fmt.Sprint("down ", steps))
+	return f.err
+}
+
+func (f *fakeMigrator) MigrateForce(_ fs.FS, version int) error {
+	f.calls = append(f.calls, fmt.Sprint("force ", version))
+	return f.err
+}
+
+func (f *fakeMigrator) MigrateStatus(fs.FS) (database.MigrationStatus, error) {
+	return f.status, nil
+}
+
+func TestMigrateCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		stdin     string
+		err       error
+		wantCalls []string
+		wantErr   string
+		wantOut   string
+	}{
+		{name: "up", args: []string{"up"}, wantCalls: []string{"up"}, wantOut: "version 6 (latest 6, 0 pending)"},
+		{name: "status", args: []string{"status"}, wantOut: "version 6 (latest 6, 0 pending)"},
+		{name: "version alias", args: []string{"version"}, wantOut: "version 6"},
+		{name: "down confirmed", args: []string{"down"}, stdin: "y\n", wantCalls: []string{"down 1"}, wantOut: "Roll back 1 migration(s) from version 6?"},
+		{name: "down declined", args: []string{"down", "2"}, stdin: "n\n", wantErr: "aborted"},
+		{name: "down with no answer", args: []string{"down"}, wantErr: "aborted"},
+		{name: "down with yes flag after", args: []string{"down", "2", "--yes"}, wantCalls: []string{"down 2"}},
+		{name: "down with yes flag before", args: []string{"-yes", "down", "3"}, wantCalls: []string{"down 3"}},
+		{name: "force confirmed", args: []string{"force", "5"}, stdin: "yes\n", wantCalls: []string{"force 5"}},
+		{name: "force declined", args: []string{"force", "5"}, stdin: "\n", wantErr: "aborted"},
+		{name: "migration error", args: []string{"up"}, err: errors.New("failed to run migrations: boom"), wantCalls: []string{"up"}, wantErr: "boom"},
+		{name: "no command", wantErr: "usage:"},
+		{name: "unknown command", args: []string{"sideways"}, wantErr: `unknown migrate command "sideways"`},
+		{name: "invalid step count", args: []string{"down", "0"}, wantErr: "invalid step count"},
+		{name: "force without version", args: []string{"force"}, wantErr: "usage:"},
+		{name: "invalid force version", args: []string{"force", "v5", "--yes"}, wantErr: "invalid version"},
+		{name: "unknown flag", args: []string{"up", "--force"}, wantErr: "usage:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &fakeMigrator{status: database.MigrationStatus{Version: 6, Latest: 6}, err: tt.err}
+			var out bytes.Buffer
+
+			err := migrateCommand(m, fstest.MapFS{}, tt.args, strings.NewReader(tt.stdin), &out)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if strings.Join(m.calls, ",") != strings.Join(tt.wantCalls, ",") {
+				t.Errorf("expected calls %v, got %v", tt.wantCalls, m.calls)
+			}
+			if !strings.Contains(out.String(), tt.wantOut) {
+				t.Errorf("expected output containing %q, got %q", tt.wantOut, out.String())
+			}
+		})
+	}
+}
+
+func TestPrintMigrationStatus_Dirty(t *testing.T) {
+	m := &fakeMigrator{status: database.MigrationStatus{Version: 4, Dirty: true, Latest: 6, Pending: 2}}
+	var out bytes.Buffer
+
+	if err := printMigrationStatus(m, fstest.MapFS{}, &out); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := out.String(); !strings.Contains(got, "version 4 (latest 6, 2 pending) DIRTY") {
+		t.Errorf("expected dirty status line, got %q", got)
+	}
+}
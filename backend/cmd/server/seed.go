@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/google/uuid"
+
+	"navplane/internal/config"
+	"navplane/internal/database"
+	"navplane/internal/org"
+)
+
+const seedUsage = "usage: navplane seed [--org-name name] [--provider-base-url url]"
+
+// orgSeeder is the subset of *org.Manager the seed subcommand uses.
+type orgSeeder interface {
+	Create(ctx context.Context, name string) (*org.CreateOrgResult, error)
+	Export(ctx context.Context, fn func(*org.OrgSummary) error) error
+	Update(ctx context.Context, id uuid.UUID, input org.UpdateInput) error
+	RotateAPIKey(ctx context.Context, id uuid.UUID) (*org.APIKey, error)
+}
+
+// seedOptions are the seed subcommand's flags.
+type seedOptions struct {
+	orgName         string
+	providerBaseURL string
+}
+
+// runSeed implements `navplane seed` and returns the process exit code. It
+// refuses to run when ENV=production.
+func runSeed(args []string) int {
+	opts, err := parseSeedArgs(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load configuration: %v\n", err)
+		return 1
+	}
+	if cfg.Environment == "production" {
+		fmt.Fprintln(os.Stderr, "refusing to seed: ENV is production")
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	db, err := database.Connect(ctx, cfg.Database)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	if cfg.Database.AutoMigrate {
+		migrationFS, _ := migrationSource()
+		if err := db.MigrateUp(migrationFS); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to run migrations: %v\n", err)
+			return 1
+		}
+	}
+
+	// No notifier: seeding a dev database shouldn't fire webhooks
+	orgManager := org.NewManager(org.NewDatastore(db.DB))
+	orgManager.SetAPIKeyPepper(cfg.APIKeyPepper) // Seeded keys must match what the server checks
+	if err := seedCommand(ctx, orgManager, opts, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func parseSeedArgs(args []string) (seedOptions, error) {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var opts seedOptions
+	fs.StringVar(&opts.orgName, "org-name", "Demo Org", "name of the demo organization")
+	fs.StringVar(&opts.providerBaseURL, "provider-base-url", "http://localhost:4010/v1", "mock provider endpoint to point the server at")
+	if err := fs.Parse(args); err != nil {
+		return seedOptions{}, fmt.Errorf("%v\n%s", err, seedUsage)
+	}
+	if fs.NArg() != 0 || strings.TrimSpace(opts.orgName) == "" {
+		return seedOptions{}, errors.New(seedUsage)
+	}
+	return opts, nil
+}
+
+// seedLabels marks organizations created by the seed subcommand.
+var seedLabels = map[string]string{"seeded-by": "navplane-seed"}
+
+// seedCommand creates the demo org, or re-enables and relabels it if it
+// already exists, and prints a fresh API key. Re-running never creates a
+// second org; it rotates the key so one can be printed again.
+func seedCommand(ctx context.Context, orgs orgSeeder, opts seedOptions, out io.Writer) error {
+	existing, err := findOrgByName(ctx, orgs, opts.orgName)
+	if err != nil {
+		return err
+	}
+
+	var id uuid.UUID
+	var apiKey string
+	action := "created"
+	if existing == nil {
+		result, err := orgs.Create(ctx, opts.orgName)
+		if err != nil {
+			return fmt.Errorf("failed to create demo org: %w", err)
+		}
+		id, apiKey = result.Org.ID, result.APIKey.Plaintext
+	} else {
+		key, err := orgs.RotateAPIKey(ctx, existing.ID)
+		if err != nil {
+			return fmt.Errorf("failed to rotate demo org API key: %w", err)
+		}
+		id, apiKey, action = existing.ID, key.Plaintext, "updated"
+	}
+
+	enabled := true
+	if err := orgs.Update(ctx, id, org.UpdateInput{Enabled: &enabled, Metadata: seedLabels}); err != nil {
+		return fmt.Errorf("failed to update demo org: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "org\t%s (%s) %s\n", opts.orgName, id, action)
+	fmt.Fprintf(tw, "api key\t%s\n", apiKey)
+	fmt.Fprintf(tw, "provider\tstart the server with PROVIDER_BASE_URL=%s to proxy to the mock\n", opts.providerBaseURL)
+	return tw.Flush()
+}
+
+// errFound stops an Export once the wanted org has been seen.
+var errFound = errors.New("found")
+
+// findOrgByName returns the org whose name matches case-insensitively, as
+// the unique index does, or nil.
+func findOrgByName(ctx context.Context, orgs orgSeeder, name string) (*org.Org, error) {
+	var found *org.Org
+	err := orgs.Export(ctx, func(s *org.OrgSummary) error {
+		if strings.EqualFold(s.Name, name) {
+			found = &s.Org
+			return errFound
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errFound) {
+		return nil, err
+	}
+	return found, nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"navplane/internal/org"
+)
+
+// fakeOrgSeeder is an in-memory orgSeeder.
+type fakeOrgSeeder struct {
+	orgs []*org.Org
+}
+
+func (f *fakeOrgSeeder) Create(_ context.Context, name string) (*org.CreateOrgResult, error) {
+	o := &org.Org{ID: uuid.New(), Name: name}
+	f.orgs = append(f.orgs, o)
+	return &org.CreateOrgResult{Org: o, APIKey: org.APIKey{Plaintext: "np_created"}}, nil
+}
+
+func (f *fakeOrgSeeder) Export(_ context.Context, fn func(*org.OrgSummary) error) error {
+	for _, o := range f.orgs {
+		if err := fn(&org.OrgSummary{Org: *o}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeOrgSeeder) Update(_ context.Context, id uuid.UUID, input org.UpdateInput) error {
+	for _, o := range f.orgs {
+		if o.ID == id {
+			o.Enabled = *input.Enabled
+			o.Metadata = input.Metadata
+			return nil
+		}
+	}
+	return org.ErrNotFound
+}
+
+func (f *fakeOrgSeeder) RotateAPIKey(_ context.Context, id uuid.UUID) (*org.APIKey, error) {
+	return &org.APIKey{Plaintext: "np_rotated"}, nil
+}
+
+func TestSeedCommand_Idempotent(t *testing.T) {
+	seeder := &fakeOrgSeeder{}
+	opts := seedOptions{orgName: "Demo Org", providerBaseURL: "http://mock:4010/v1"}
+
+	var out bytes.Buffer
+	if err := seedCommand(context.Background(), seeder, opts, &out); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	got := strings.Join(strings.Fields(out.String()), " ")
+	for _, want := range []string{"created", "api key np_created", "PROVIDER_BASE_URL=http://mock:4010/v1"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in first run output:\n%s", want, out.String())
+		}
+	}
+
+	// A second run, even with different casing, updates the same org
+	seeder.orgs[0].Enabled = false
+	out.Reset()
+	opts.orgName = "demo org"
+	if err := seedCommand(context.Background(), seeder, opts, &out); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(seeder.orgs) != 1 {
+		t.Fatalf("expected 1 org after re-running, got %d", len(seeder.orgs))
+	}
+	if got := out.String(); !strings.Contains(got, "updated") || !strings.Contains(got, "np_rotated") {
+		t.Errorf("expected updated org with rotated key, got:\n%s", got)
+	}
+	if o := seeder.orgs[0]; !o.Enabled || !maps.Equal(o.Metadata, seedLabels) {
+		t.Errorf("expected seeded org to be enabled and labelled, got %+v", o)
+	}
+}
+
+func TestSeedCommand_ExportError(t *testing.T) {
+	seeder := &failingExportSeeder{}
+	err := seedCommand(context.Background(), seeder, seedOptions{orgName: "Demo Org"}, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected export error, got %v", err)
+	}
+}
+
+type failingExportSeeder struct{ fakeOrgSeeder }
+
+func (f *failingExportSeeder) Export(context.Context, func(*org.OrgSummary) error) error {
+	return errors.New("boom")
+}
+
+func TestParseSeedArgs(t *testing.T) {
+	opts, err := parseSeedArgs([]string{"--org-name", "Acme", "--provider-base-url", "http://mock/v1"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if opts.orgName != "Acme" || opts.providerBaseURL != "http://mock/v1" {
+		t.Errorf("unexpected options %+v", opts)
+	}
+
+	for _, args := range [][]string{{"extra"}, {"--org-name", " "}, {"--unknown"}} {
+		if _, err := parseSeedArgs(args); err == nil || !strings.Contains(err.Error(), "usage:") {
+			t.Errorf("expected usage error for %v, got %v", args, err)
+		}
+	}
+}
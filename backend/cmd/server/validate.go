@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"text/tabwriter"
+	"time"
+
+	"navplane/internal/config"
+	"navplane/internal/database"
+	"navplane/internal/servertls"
+)
+
+// validateTimeout bounds each network check so a bad host fails fast
+// instead of hanging the deploy.
+const validateTimeout = 5 * time.Second
+
+// errSkipped marks a check that doesn't apply to this configuration.
+var errSkipped = errors.New("skipped")
+
+// configCheck is one row of the --validate-config report.
+type configCheck struct {
+	name string
+	run  func(ctx context.Context) (detail string, err error)
+}
+
+// validateConfig loads the configuration, runs the deeper checks against
+// it and writes a pass/fail table to w. It reports whether every check
+// passed; nothing is started or migrated.
+func validateConfig(w io.Writer) bool {
+	cfg, err := config.Load()
+	if err != nil {
+		return runChecks(context.Background(), w, []configCheck{{
+			name: "config",
+			run:  func(context.Context) (string, error) { return "", err },
+		}})
+	}
+
+	return runChecks(context.Background(), w, []configCheck{
+		{name: "config", run: func(context.Context) (string, error) { return "env " + cfg.Environment, nil }},
+		{name: "database", run: func(ctx context.Context) (string, error) { return checkDatabase(ctx, cfg.Database) }},
+		{name: "read replica", run: func(ctx context.Context) (string, error) { return checkReadReplica(ctx, cfg.Database) }},
+		{name: "migrations", run: func(context.Context) (string, error) { return checkMigrations(migrationSource()) }},
+		{name: "tls", run: func(context.Context) (string, error) { return checkTLS(cfg.TLS) }},
+	})
+}
+
+// runChecks runs checks in order, each with its own timeout, and writes a
+// row per check. Skipped checks don't count as failures.
+func runChecks(ctx context.Context, w io.Writer, checks []configCheck) bool {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tSTATUS\tDETAIL")
+
+	ok := true
+	for _, c := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, validateTimeout)
+		detail, err := c.run(checkCtx)
+		cancel()
+
+		status := "PASS"
+		switch {
+		case errors.Is(err, errSkipped):
+			status = "SKIP"
+		case err != nil:
+			status = "FAIL"
+			detail = err.Error()
+			ok = false
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", c.name, status, detail)
+	}
+
+	_ = tw.Flush()
+	return ok
+}
+
+// checkDatabase opens a pool and pings it within ctx.
+func checkDatabase(ctx context.Context, cfg config.DatabaseConfig) (string, error) {
+	db, err := database.New(cfg)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	if err := db.PingContext(ctx); err != nil {
+		return "", fmt.Errorf("failed to ping database: %w", err)
+	}
+	return "reachable", nil
+}
+
+// checkReadReplica pings the read replica when one is configured. The
+// server starts without it, but an unreachable replica at validation time
+// is almost always a typo in DATABASE_READ_URL.
+func checkReadReplica(ctx context.Context, cfg config.DatabaseConfig) (string, error) {
+	if cfg.ReadURL == "" {
+		return "DATABASE_READ_URL not set", errSkipped
+	}
+	cfg.URL = cfg.ReadURL
+	return checkDatabase(ctx, cfg)
+}
+
+// checkMigrations verifies the migration source is readable and holds at
+// least one up migration.
+func checkMigrations(migrations fs.FS, source string) (string, error) {
+	info, err := fs.Stat(migrations, ".")
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", source, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", source)
+	}
+	ups, err := fs.Glob(migrations, "*.up.sql")
+	if err != nil {
+		return "", err
+	}
+	if len(ups) == 0 {
+		return "", fmt.Errorf("%s contains no *.up.sql migrations", source)
+	}
+	return fmt.Sprintf("%s (%d migrations)", source, len(ups)), nil
+}
+
+// checkTLS loads the certificate pair and client CA the server would use.
+func checkTLS(cfg config.TLSConfig) (string, error) {
+	if !cfg.Enabled() {
+		return "TLS disabled", errSkipped
+	}
+	reloader, err := servertls.NewCertReloader(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return "", err
+	}
+	if _, err := servertls.NewConfig(cfg, reloader); err != nil {
+		return "", err
+	}
+	if cfg.ClientCAFile != "" {
+		return "certificate and client CA loaded", nil
+	}
+	return "certificate loaded", nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"navplane/internal/config"
+	"navplane/migrations"
+)
+
+func TestRunChecks(t *testing.T) {
+	pass := func(context.Context) (string, error) { return "fine", nil }
+	fail := func(context.Context) (string, error) { return "", errors.New("boom") }
+	skip := func(context.Context) (string, error) { return "not configured", errSkipped }
+
+	tests := []struct {
+		name     string
+		checks   []configCheck
+		wantOK   bool
+		wantRows []string
+	}{
+		{
+			name:     "all pass",
+			checks:   []configCheck{{name: "a", run: pass}, {name: "b", run: skip}},
+			wantOK:   true,
+			wantRows: []string{"a PASS fine", "b SKIP not configured"},
+		},
+		{
+			name:     "one failure",
+			checks:   []configCheck{{name: "a", run: fail}, {name: "b", run: pass}},
+			wantOK:   false,
+			wantRows: []string{"a FAIL boom", "b PASS fine"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if ok := runChecks(context.Background(), &out, tt.checks); ok != tt.wantOK {
+				t.Errorf("expected ok=%v, got %v", tt.wantOK, ok)
+			}
+			// Compare with tabwriter padding collapsed to single spaces
+			got := strings.Join(strings.Fields(out.String()), " ")
+			for _, row := range tt.wantRows {
+				if !strings.Contains(got, row) {
+					t.Errorf("expected row %q in output:\n%s", row, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestRunChecks_TimesOut(t *testing.T) {
+	slow := func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	var out bytes.Buffer
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if runChecks(ctx, &out, []configCheck{{name: "database", run: slow}}) {
+		t.Fatal("expected a cancelled check to fail")
+	}
+	if !strings.Contains(out.String(), "context canceled") {
+		t.Errorf("expected cancellation in output, got:\n%s", out.String())
+	}
+}
+
+func TestCheckMigrations(t *testing.T) {
+	withMigration := t.TempDir()
+	if err := os.WriteFile(filepath.Join(withMigration, "000001_init.up.sql"), []byte("SELECT 1;"), 0o600); err != nil {
+		t.Fatalf("failed to write migration: %v", err)
+	}
+	notDir := filepath.Join(withMigration, "000001_init.up.sql")
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{name: "valid", path: withMigration},
+		{name: "embedded"},
+		{name: "missing", path: filepath.Join(withMigration, "nope"), wantErr: "no such file"},
+		{name: "not a directory", path: notDir, wantErr: "not a directory"},
+		{name: "empty", path: t.TempDir(), wantErr: "no *.up.sql migrations"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrationFS, source := fs.FS(migrations.FS), "embedded"
+			if tt.path != "" {
+				migrationFS, source = os.DirFS(tt.path), tt.path
+			}
+			_, err := checkMigrations(migrationFS, source)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCheckReadReplica_Unset(t *testing.T) {
+	if _, err := checkReadReplica(context.Background(), config.DatabaseConfig{}); !errors.Is(err, errSkipped) {
+		t.Errorf("expected read replica check to be skipped, got %v", err)
+	}
+}
+
+func TestCheckTLS_Disabled(t *testing.T) {
+	if _, err := checkTLS(config.TLSConfig{}); !errors.Is(err, errSkipped) {
+		t.Errorf("expected TLS check to be skipped, got %v", err)
+	}
+}
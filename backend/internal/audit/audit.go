@@ -0,0 +1,38 @@
+// Package audit records admin actions that expose or act on secrets.
+//
+// Events go through log/slog's default logger like the rest of the process,
+// but as their own record type: the message is always "audit" and every
+// record carries log_type=audit, the action and the admin who performed
+// it, so a log pipeline can route them to their own retention.
+package audit
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Actions.
+const (
+	ActionKeyLookup = "admin.key_lookup"
+)
+
+// Event is one audited admin action.
+type Event struct {
+	Action   string      // One of the Action constants
+	Actor    string      // The admin identity auth resolved
+	ClientIP string      // Client address as resolved through trusted proxies
+	Details  []slog.Attr // Action-specific fields; never secrets
+}
+
+// Record writes e to the audit log.
+func Record(ctx context.Context, e Event) {
+	attrs := make([]slog.Attr, 0, 4+len(e.Details))
+	attrs = append(attrs,
+		slog.String("log_type", "audit"),
+		slog.String("action", e.Action),
+		slog.String("actor", e.Actor),
+		slog.String("client_ip", e.ClientIP),
+	)
+	attrs = append(attrs, e.Details...)
+	slog.LogAttrs(ctx, slog.LevelInfo, "audit", attrs...)
+}
@@ -0,0 +1,44 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"navplane/internal/logging"
+)
+
+func TestRecord(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logging.New(&buf, slog.LevelInfo, logging.FormatJSON))
+	defer slog.SetDefault(previous)
+
+	Record(context.Background(), Event{
+		Action:   ActionKeyLookup,
+		Actor:    "cert:CN=oncall",
+		ClientIP: "10.0.0.1",
+		Details:  []slog.Attr{slog.String("by", "key_prefix"), slog.Int("matches", 2)},
+	})
+
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("expected one JSON record, got %q: %v", buf.String(), err)
+	}
+	want := map[string]any{
+		"msg":       "audit",
+		"log_type":  "audit",
+		"action":    ActionKeyLookup,
+		"actor":     "cert:CN=oncall",
+		"client_ip": "10.0.0.1",
+		"by":        "key_prefix",
+		"matches":   float64(2),
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("expected %s=%v, got %v", k, v, got[k])
+		}
+	}
+}
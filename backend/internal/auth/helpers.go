@@ -2,11 +2,11 @@
 package auth
 
 import (
-	"encoding/json"
 	"errors"
-	"log"
 	"net/http"
 	"strings"
+
+	"navplane/internal/httpjson"
 )
 
 // Sentinel errors for token extraction failures.
@@ -42,30 +42,16 @@ func ExtractBearerToken(r *http.Request) (string, error) {
 }
 
 // APIError represents an OpenAI-compatible error response.
-type APIError struct {
-	Error ErrorDetail `json:"error"`
-}
+type APIError = httpjson.ErrorResponse
 
 // ErrorDetail contains the error message and type.
-type ErrorDetail struct {
-	Message string `json:"message"`
-	Type    string `json:"type"`
-}
+type ErrorDetail = httpjson.ErrorDetail
 
 // WriteJSONError writes an OpenAI-compatible JSON error response.
 // Always sets Content-Type: application/json.
 // Response format: {"error": {"message": "<message>", "type": "<errorType>"}}
 func WriteJSONError(w http.ResponseWriter, status int, message, errorType string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	if err := json.NewEncoder(w).Encode(APIError{
-		Error: ErrorDetail{
-			Message: message,
-			Type:    errorType,
-		},
-	}); err != nil {
-		log.Printf("failed to write JSON error response: %v", err)
-	}
+	httpjson.WriteError(w, status, message, errorType)
 }
 
 // WriteUnauthorized writes a 401 Unauthorized JSON response.
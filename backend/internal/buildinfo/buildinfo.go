@@ -0,0 +1,89 @@
+// Package buildinfo reports which build of NavPlane is running.
+//
+// Release builds set the values with -ldflags, e.g.
+//
+//	-X navplane/internal/buildinfo.version=v1.2.0
+//	-X navplane/internal/buildinfo.commit=$(git rev-parse HEAD)
+//	-X navplane/internal/buildinfo.date=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+//
+// Anything left unset falls back to the VCS stamp Go embeds in binaries
+// built from a git checkout (debug.ReadBuildInfo), so `go build` and
+// `go run` still report a commit.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Set via -ldflags -X; see the package comment.
+var (
+	version string
+	commit  string
+	date    string
+)
+
+// startTime approximates process start; package init runs before main.
+var startTime = time.Now().UTC()
+
+// Info describes the running build.
+type Info struct {
+	Version   string    `json:"version"`
+	Commit    string    `json:"commit"`
+	BuildDate string    `json:"build_date,omitempty"` // From ldflags, else the commit time
+	Modified  bool      `json:"modified,omitempty"`   // Built from a dirty working tree
+	GoVersion string    `json:"go_version"`
+	StartTime time.Time `json:"start_time"`
+}
+
+// Get returns the running build's information.
+var Get = sync.OnceValue(func() Info {
+	bi, _ := debug.ReadBuildInfo()
+	return resolve(version, commit, date, bi)
+})
+
+// Version returns the running build's version, e.g. for response headers.
+func Version() string {
+	return Get().Version
+}
+
+// resolve merges ldflags values with the embedded build info; ldflags win.
+func resolve(version, commit, date string, bi *debug.BuildInfo) Info {
+	info := Info{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: date,
+		GoVersion: runtime.Version(),
+		StartTime: startTime,
+	}
+
+	if bi != nil {
+		if info.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = s.Value
+				}
+			case "vcs.modified":
+				info.Modified = s.Value == "true" && commit == ""
+			}
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	return info
+}
@@ -0,0 +1,56 @@
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	vcs := &debug.BuildInfo{
+		Main: debug.Module{Path: "navplane", Version: "(devel)"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "abc123"},
+			{Key: "vcs.time", Value: "2026-01-02T03:04:05Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+
+	tests := []struct {
+		name                  string
+		version, commit, date string
+		bi                    *debug.BuildInfo
+		want                  Info
+	}{
+		{
+			name: "no information",
+			want: Info{Version: "dev", Commit: "unknown"},
+		},
+		{
+			name: "falls back to VCS stamp",
+			bi:   vcs,
+			want: Info{Version: "dev", Commit: "abc123", BuildDate: "2026-01-02T03:04:05Z", Modified: true},
+		},
+		{
+			name:    "ldflags win",
+			version: "v1.2.0", commit: "def456", date: "2026-02-01T00:00:00Z",
+			bi:   vcs,
+			want: Info{Version: "v1.2.0", Commit: "def456", BuildDate: "2026-02-01T00:00:00Z"},
+		},
+		{
+			name: "module version from go install",
+			bi:   &debug.BuildInfo{Main: debug.Module{Version: "v1.3.0"}},
+			want: Info{Version: "v1.3.0", Commit: "unknown"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.want.GoVersion = runtime.Version()
+			tt.want.StartTime = startTime
+			if got := resolve(tt.version, tt.commit, tt.date, tt.bi); got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
@@ -2,46 +2,161 @@ package config
 
 import (
 	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
 	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"time"
+
+	"navplane/internal/logging"
 )
 
-// ProviderConfig holds the configuration for the upstream AI provider.
-// This is temporary MVP configuration using environment variables.
-// Future: will be replaced by BYOK vault or per-org provider keys.
+// ProviderConfig holds the optional platform provider: an upstream base URL
+// and a platform-owned API key used for orgs that have no BYOK key of their
+// own. Precedence is org BYOK key, then this platform key; with neither, the
+// proxy refuses the request with 403.
 type ProviderConfig struct {
 	BaseURL string
 	APIKey  string
 }
 
+// Configured reports whether a platform provider is set.
+func (p ProviderConfig) Configured() bool {
+	return p.BaseURL != "" && p.APIKey != ""
+}
+
+// defaultAnthropicBaseURL is used when ANTHROPIC_API_KEY is set without
+// ANTHROPIC_BASE_URL.
+const defaultAnthropicBaseURL = "https://api.anthropic.com"
+
+// LogConfig holds structured logging configuration.
+// Zero thresholds disable the corresponding warning.
+type LogConfig struct {
+	Level  slog.Level
+	Format string // "json" or "text"
+
+	SlowRequestThreshold    time.Duration // Total duration before a non-streaming request logs as slow
+	SlowStreamTTFBThreshold time.Duration // Time to first byte before a streaming request logs as slow
+	ErrorRateThreshold      float64       // Per-route 5xx fraction over the rolling window that triggers a summary
+}
+
+// CORSConfig controls cross-origin access to the dashboard-facing APIs
+// (/admin/* and /api/v1/*). CORS is disabled when AllowedOrigins is empty.
+type CORSConfig struct {
+	AllowedOrigins []string // Exact origins or wildcard subdomains, e.g. "https://*.navplane.io"
+	AllowedHeaders []string // Request headers permitted in preflights
+	MaxAge         int      // Preflight cache lifetime in seconds
+}
+
+// RequestLogConfig controls the persisted request log.
+type RequestLogConfig struct {
+	RetentionDays int // Entries older than this are deleted; 0 keeps them forever
+	BufferSize    int // Entries buffered for async writes before new ones are dropped
+}
+
+// RequestLimitsConfig bounds what the proxy accepts in a request body; see
+// openai.Limits.
+type RequestLimitsConfig struct {
+	MaxJSONDepth   int // Deepest nesting of objects and arrays
+	MaxExtraFields int // Unknown top-level fields accepted per request
+	MaxExtraBytes  int // Serialized size of one unknown field
+}
+
 // DatabaseConfig holds PostgreSQL connection configuration.
 type DatabaseConfig struct {
 	URL             string
+	ReadURL         string // Optional read replica for per-request lookups; empty reads from URL
 	MaxOpenConns    int
 	MaxIdleConns    int
-	ConnMaxLifetime int // seconds
+	ConnMaxLifetime int  // seconds
+	ConnMaxIdleTime int  // seconds; 0 keeps idle connections until ConnMaxLifetime
+	AutoMigrate     bool // Run pending migrations at startup; disable to migrate with the CLI
+	ConnectTimeout  int  // seconds to keep retrying the initial connection; 0 tries once
+}
+
+// ServerConfig holds HTTP server settings.
+type ServerConfig struct {
+	ListenAddr         string        // host:port passed to http.Server, e.g. ":8080" or "[::]:8080"
+	AdminListenAddr    string        // Separate listener for /admin, /metrics and /version; empty serves them on ListenAddr
+	ShutdownTimeout    time.Duration // Grace period for in-flight requests on shutdown
+	StreamDrainTimeout time.Duration // Part of ShutdownTimeout streams get before being cut off with an error event
+	ReadHeaderTimeout  time.Duration
+	IdleTimeout        time.Duration // Keep-alive idle timeout
+
+	// TrustedProxies are the load balancers whose X-Forwarded-For entries
+	// are believed when deriving the client IP. Empty trusts no one.
+	TrustedProxies []netip.Prefix
+}
+
+// TLSConfig enables native TLS termination. TLS is on when CertFile and
+// KeyFile are set; ClientCAFile additionally requires admin requests to
+// present a client certificate signed by that CA.
+type TLSConfig struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+}
+
+// Enabled reports whether the server should terminate TLS itself.
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
 }
 
 type Config struct {
-	Port        string
+	Port        string // Port from Server.ListenAddr
+	Server      ServerConfig
+	TLS         TLSConfig
 	Environment string
 	Provider    ProviderConfig
+	// Anthropic is the platform key for the native /v1/messages route
+	Anthropic   ProviderConfig
 	Database    DatabaseConfig
+	AdminAPIKey string // Bearer token for /admin/* endpoints
+	// APIKeyPepper keys the HMAC of org API key hashes; empty keeps bare SHA-256
+	APIKeyPepper string
+	Log          LogConfig
+	CORS         CORSConfig
+	RequestLog   RequestLogConfig
+
+	RequestLimits RequestLimitsConfig
+
+	// DebugEndpoints mounts pprof and expvar under /debug/ on the admin routes
+	DebugEndpoints bool
 }
 
-// Load reads configuration from environment variables.
-// It fails fast with clear errors for missing required values.
+// Load reads configuration from environment variables and, when CONFIG_FILE
+// is set, a YAML or JSON file. Environment variables win field by field;
+// validation runs on the merged values. It fails fast with clear errors for
+// missing required values.
 func Load() (*Config, error) {
+	src, err := newSource(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		return nil, err
+	}
+
 	var missing []string
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080" // sensible default
+	server, port, err := loadServerConfig(src)
+	if err != nil {
+		return nil, err
 	}
 
-	env := os.Getenv("ENV")
+	tlsConfig := TLSConfig{
+		CertFile:     src.get("TLS_CERT_FILE"),
+		KeyFile:      src.get("TLS_KEY_FILE"),
+		ClientCAFile: src.get("TLS_CLIENT_CA_FILE"),
+	}
+	if (tlsConfig.CertFile == "") != (tlsConfig.KeyFile == "") {
+		return nil, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if tlsConfig.ClientCAFile != "" && !tlsConfig.Enabled() {
+		return nil, fmt.Errorf("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+
+	env := src.get("ENV")
 	if env == "" {
 		env = "development" // sensible default
 	}
@@ -51,61 +166,439 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("invalid ENV value %q: must be development, staging, or production", env)
 	}
 
-	// Load provider configuration (required for MVP)
-	providerBaseURL := os.Getenv("PROVIDER_BASE_URL")
-	if providerBaseURL == "" {
-		missing = append(missing, "PROVIDER_BASE_URL")
+	// Profiling endpoints default to on only for local development
+	debugEndpoints, err := src.getBool("DEBUG_ENDPOINTS", env == "development")
+	if err != nil {
+		return nil, err
 	}
 
-	providerAPIKey := os.Getenv("PROVIDER_API_KEY")
-	if providerAPIKey == "" {
-		missing = append(missing, "PROVIDER_API_KEY")
+	// Load the optional platform provider (fallback for orgs without a BYOK key)
+	providerBaseURL := src.get("PROVIDER_BASE_URL")
+	providerAPIKey, err := src.getSecret("PROVIDER_API_KEY")
+	if err != nil {
+		return nil, err
+	}
+
+	// Platform Anthropic key for /v1/messages; the base URL is optional
+	anthropicBaseURL := src.get("ANTHROPIC_BASE_URL")
+	anthropicAPIKey, err := src.getSecret("ANTHROPIC_API_KEY")
+	if err != nil {
+		return nil, err
 	}
 
 	// Load database configuration (required)
-	databaseURL := os.Getenv("DATABASE_URL")
+	databaseURL, err := src.getSecret("DATABASE_URL")
+	if err != nil {
+		return nil, err
+	}
 	if databaseURL == "" {
 		missing = append(missing, "DATABASE_URL")
 	}
+	databaseReadURL, err := src.getSecret("DATABASE_READ_URL")
+	if err != nil {
+		return nil, err
+	}
+
+	// Admin API key is required in production; elsewhere the admin API
+	// stays locked until it is configured.
+	adminAPIKey, err := src.getSecret("ADMIN_API_KEY")
+	if err != nil {
+		return nil, err
+	}
+	if adminAPIKey == "" && env == "production" {
+		missing = append(missing, "ADMIN_API_KEY")
+	}
+
+	// Pepper for org API key hashes. Optional so existing deployments keep
+	// working; once set it must never change or every HMAC hash is lost.
+	apiKeyPepper, err := src.getSecret("API_KEY_PEPPER")
+	if err != nil {
+		return nil, err
+	}
 
 	if len(missing) > 0 {
 		return nil, fmt.Errorf("missing required environment variables: %v", missing)
 	}
 
-	// Validate provider base URL format
-	if err := validateBaseURL(providerBaseURL); err != nil {
-		return nil, fmt.Errorf("invalid PROVIDER_BASE_URL: %w", err)
+	// The platform provider is validated only when set, and needs both halves
+	if (providerBaseURL == "") != (providerAPIKey == "") {
+		return nil, fmt.Errorf("PROVIDER_BASE_URL and PROVIDER_API_KEY must be set together")
+	}
+	if providerBaseURL != "" {
+		if err := validateBaseURL(providerBaseURL); err != nil {
+			return nil, fmt.Errorf("invalid PROVIDER_BASE_URL: %w", err)
+		}
+		if err := validateAPIKey(providerAPIKey); err != nil {
+			return nil, fmt.Errorf("invalid PROVIDER_API_KEY: %w", err)
+		}
 	}
 
-	// Validate provider API key format
-	if err := validateAPIKey(providerAPIKey); err != nil {
-		return nil, fmt.Errorf("invalid PROVIDER_API_KEY: %w", err)
+	if anthropicBaseURL != "" && anthropicAPIKey == "" {
+		return nil, fmt.Errorf("ANTHROPIC_BASE_URL requires ANTHROPIC_API_KEY")
+	}
+	if anthropicAPIKey != "" {
+		if anthropicBaseURL == "" {
+			anthropicBaseURL = defaultAnthropicBaseURL
+		}
+		if err := validateBaseURL(anthropicBaseURL); err != nil {
+			return nil, fmt.Errorf("invalid ANTHROPIC_BASE_URL: %w", err)
+		}
+		if err := validateAPIKey(anthropicAPIKey); err != nil {
+			return nil, fmt.Errorf("invalid ANTHROPIC_API_KEY: %w", err)
+		}
 	}
 
 	// Validate database URL format
 	if err := validateDatabaseURL(databaseURL); err != nil {
 		return nil, fmt.Errorf("invalid DATABASE_URL: %w", err)
 	}
+	if databaseReadURL != "" {
+		if err := validateDatabaseURL(databaseReadURL); err != nil {
+			return nil, fmt.Errorf("invalid DATABASE_READ_URL: %w", err)
+		}
+	}
+
+	// Validate admin API key format
+	if adminAPIKey != "" {
+		if err := validateAdminAPIKey(adminAPIKey); err != nil {
+			return nil, fmt.Errorf("invalid ADMIN_API_KEY: %w", err)
+		}
+	}
+	if apiKeyPepper != "" {
+		if err := validatePepper(apiKeyPepper); err != nil {
+			return nil, fmt.Errorf("invalid API_KEY_PEPPER: %w", err)
+		}
+	}
+
+	// Load logging settings
+	logLevel, err := logging.ParseLevel(src.get("LOG_LEVEL"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOG_LEVEL: %w", err)
+	}
+	logFormat := strings.ToLower(src.get("LOG_FORMAT"))
+	if logFormat == "" {
+		logFormat = logging.FormatJSON
+	}
+	if logFormat != logging.FormatJSON && logFormat != logging.FormatText {
+		return nil, fmt.Errorf("invalid LOG_FORMAT value %q: must be json or text", logFormat)
+	}
+
+	slowRequest, err := src.getDuration("LOG_SLOW_REQUEST_THRESHOLD", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	slowStreamTTFB, err := src.getDuration("LOG_SLOW_STREAM_TTFB_THRESHOLD", 3*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	errorRate := 0.05
+	if val := src.get("LOG_ERROR_RATE_THRESHOLD"); val != "" {
+		errorRate, err = strconv.ParseFloat(val, 64)
+		if err != nil || errorRate < 0 || errorRate > 1 {
+			return nil, fmt.Errorf("invalid LOG_ERROR_RATE_THRESHOLD value %q: must be a fraction between 0 and 1", val)
+		}
+	}
+
+	// Load CORS settings
+	corsOrigins := src.getList("CORS_ALLOWED_ORIGINS", "")
+	for _, origin := range corsOrigins {
+		if err := validateCORSOrigin(origin); err != nil {
+			return nil, fmt.Errorf("invalid CORS_ALLOWED_ORIGINS entry %q: %w", origin, err)
+		}
+	}
+	corsConfig := CORSConfig{
+		AllowedOrigins: corsOrigins,
+		AllowedHeaders: src.getList("CORS_ALLOWED_HEADERS", "Authorization,Content-Type,X-Request-ID"),
+	}
+	// 10 minutes default
+	if corsConfig.MaxAge, err = src.getInt("CORS_MAX_AGE", 600); err != nil {
+		return nil, err
+	}
+
+	// Load request log settings
+	var requestLogConfig RequestLogConfig
+	if requestLogConfig.RetentionDays, err = src.getInt("REQUEST_LOG_RETENTION_DAYS", 30); err != nil {
+		return nil, err
+	}
+	if requestLogConfig.BufferSize, err = src.getInt("REQUEST_LOG_BUFFER_SIZE", 1000); err != nil {
+		return nil, err
+	}
+	if requestLogConfig.RetentionDays < 0 {
+		return nil, fmt.Errorf("invalid REQUEST_LOG_RETENTION_DAYS value %d: must be 0 or more", requestLogConfig.RetentionDays)
+	}
+	if requestLogConfig.BufferSize < 1 {
+		return nil, fmt.Errorf("invalid REQUEST_LOG_BUFFER_SIZE value %d: must be at least 1", requestLogConfig.BufferSize)
+	}
+
+	// Load request parsing limits
+	var requestLimits RequestLimitsConfig
+	for _, limit := range []struct {
+		key        string
+		defaultVal int
+		dst        *int
+	}{
+		{"REQUEST_MAX_JSON_DEPTH", 128, &requestLimits.MaxJSONDepth},
+		{"REQUEST_MAX_EXTRA_FIELDS", 128, &requestLimits.MaxExtraFields},
+		{"REQUEST_MAX_EXTRA_BYTES", 1 << 20, &requestLimits.MaxExtraBytes}, // 1 MiB default
+	} {
+		if *limit.dst, err = src.getInt(limit.key, limit.defaultVal); err != nil {
+			return nil, err
+		}
+		if *limit.dst < 1 {
+			return nil, fmt.Errorf("invalid %s value %d: must be at least 1", limit.key, *limit.dst)
+		}
+	}
+
+	autoMigrate, err := src.getBool("AUTO_MIGRATE", true)
+	if err != nil {
+		return nil, err
+	}
 
 	// Load optional database pool settings
 	dbConfig := DatabaseConfig{
-		URL:             databaseURL,
-		MaxOpenConns:    getEnvInt("DB_MAX_OPEN_CONNS", 25),
-		MaxIdleConns:    getEnvInt("DB_MAX_IDLE_CONNS", 5),
-		ConnMaxLifetime: getEnvInt("DB_CONN_MAX_LIFETIME", 300), // 5 minutes default
+		URL:         databaseURL,
+		ReadURL:     databaseReadURL,
+		AutoMigrate: autoMigrate,
+	}
+	for _, setting := range []struct {
+		key        string
+		defaultVal int
+		dst        *int
+	}{
+		{"DB_MAX_OPEN_CONNS", 25, &dbConfig.MaxOpenConns},
+		{"DB_MAX_IDLE_CONNS", 5, &dbConfig.MaxIdleConns},
+		{"DB_CONN_MAX_LIFETIME", 300, &dbConfig.ConnMaxLifetime}, // 5 minutes default
+		{"DB_CONN_MAX_IDLE_TIME", 0, &dbConfig.ConnMaxIdleTime},
+		{"DB_CONNECT_TIMEOUT", 30, &dbConfig.ConnectTimeout},
+	} {
+		if *setting.dst, err = src.getInt(setting.key, setting.defaultVal); err != nil {
+			return nil, err
+		}
+	}
+	if dbConfig.ConnectTimeout < 0 {
+		return nil, fmt.Errorf("invalid DB_CONNECT_TIMEOUT value %d: must be 0 or more", dbConfig.ConnectTimeout)
 	}
 
 	return &Config{
 		Port:        port,
+		Server:      server,
+		TLS:         tlsConfig,
 		Environment: env,
 		Provider: ProviderConfig{
 			BaseURL: providerBaseURL,
 			APIKey:  providerAPIKey,
 		},
-		Database: dbConfig,
+		Anthropic: ProviderConfig{
+			BaseURL: anthropicBaseURL,
+			APIKey:  anthropicAPIKey,
+		},
+		Database:     dbConfig,
+		AdminAPIKey:  adminAPIKey,
+		APIKeyPepper: apiKeyPepper,
+		Log: LogConfig{
+			Level:  logLevel,
+			Format: logFormat,
+
+			SlowRequestThreshold:    slowRequest,
+			SlowStreamTTFBThreshold: slowStreamTTFB,
+			ErrorRateThreshold:      errorRate,
+		},
+		CORS:       corsConfig,
+		RequestLog: requestLogConfig,
+
+		RequestLimits: requestLimits,
+
+		DebugEndpoints: debugEndpoints,
 	}, nil
 }
 
+// loadServerConfig reads the listen addresses and server timeouts.
+// LISTEN_ADDR takes a full host:port; PORT alone is shorthand for ":PORT".
+// ADMIN_LISTEN_ADDR and ADMIN_PORT work the same way for the optional
+// admin listener. It also returns the listen port.
+func loadServerConfig(src source) (ServerConfig, string, error) {
+	addr, addrPort, err := loadListenAddr(src, "PORT", "LISTEN_ADDR", "8080")
+	if err != nil {
+		return ServerConfig{}, "", err
+	}
+	adminAddr, adminPort, err := loadListenAddr(src, "ADMIN_PORT", "ADMIN_LISTEN_ADDR", "")
+	if err != nil {
+		return ServerConfig{}, "", err
+	}
+	if adminAddr != "" && adminPort == addrPort {
+		return ServerConfig{}, "", fmt.Errorf("admin listener %q must use a different port than %q", adminAddr, addr)
+	}
+
+	cfg := ServerConfig{ListenAddr: addr, AdminListenAddr: adminAddr}
+	if cfg.ShutdownTimeout, err = src.getSeconds("SHUTDOWN_TIMEOUT", 30*time.Second); err != nil {
+		return ServerConfig{}, "", err
+	}
+	if cfg.ReadHeaderTimeout, err = src.getSeconds("READ_HEADER_TIMEOUT", 10*time.Second); err != nil {
+		return ServerConfig{}, "", err
+	}
+	if cfg.IdleTimeout, err = src.getSeconds("IDLE_TIMEOUT", 120*time.Second); err != nil {
+		return ServerConfig{}, "", err
+	}
+	if cfg.StreamDrainTimeout, err = src.getSeconds("STREAM_DRAIN_TIMEOUT", cfg.ShutdownTimeout*2/3); err != nil {
+		return ServerConfig{}, "", err
+	}
+	// Streams that hit the drain deadline still need time to send their error event
+	if cfg.StreamDrainTimeout >= cfg.ShutdownTimeout {
+		return ServerConfig{}, "", fmt.Errorf("STREAM_DRAIN_TIMEOUT (%s) must be shorter than SHUTDOWN_TIMEOUT (%s)", cfg.StreamDrainTimeout, cfg.ShutdownTimeout)
+	}
+	for _, entry := range src.getList("TRUSTED_PROXIES", "") {
+		prefix, err := parseTrustedProxy(entry)
+		if err != nil {
+			return ServerConfig{}, "", fmt.Errorf("invalid TRUSTED_PROXIES entry %q: %w", entry, err)
+		}
+		cfg.TrustedProxies = append(cfg.TrustedProxies, prefix)
+	}
+	return cfg, addrPort, nil
+}
+
+// loadListenAddr resolves addrKey (host:port) and its portKey shorthand.
+// Setting both is allowed only when they agree. With neither set it uses
+// defaultPort, or returns "" when that is empty too.
+func loadListenAddr(src source, portKey, addrKey, defaultPort string) (string, string, error) {
+	port := src.get(portKey)
+	addr := src.get(addrKey)
+
+	if addr == "" {
+		if port == "" {
+			port = defaultPort
+		}
+		if port == "" {
+			return "", "", nil
+		}
+		if _, err := validateListenAddr(":" + port); err != nil {
+			return "", "", fmt.Errorf("invalid %s %q: %w", portKey, port, err)
+		}
+		addr = ":" + port
+	}
+	addrPort, err := validateListenAddr(addr)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid %s %q: %w", addrKey, addr, err)
+	}
+	if port != "" && port != addrPort {
+		return "", "", fmt.Errorf("%s %q conflicts with %s %q: set only %s", portKey, port, addrKey, addr, addrKey)
+	}
+	return addr, addrPort, nil
+}
+
+// parseTrustedProxy accepts a CIDR ("10.0.0.0/8") or a single address,
+// which is treated as a /32 or /128.
+func parseTrustedProxy(entry string) (netip.Prefix, error) {
+	if strings.Contains(entry, "/") {
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("must be a CIDR or IP address")
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("must be a CIDR or IP address")
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// validateListenAddr checks addr is host:port with a numeric port in range
+// and returns the port. An empty host listens on all interfaces.
+func validateListenAddr(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("must be host:port (use brackets for IPv6, e.g. [::1]:8080)")
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return "", fmt.Errorf("port must be a number between 1 and 65535")
+	}
+	if strings.ContainsAny(host, " /") {
+		return "", fmt.Errorf("invalid host %q", host)
+	}
+	return port, nil
+}
+
+// String masks the provider API key so the config can be printed with %v or %+v.
+func (p ProviderConfig) String() string {
+	return fmt.Sprintf("{BaseURL:%s APIKey:%s}", p.BaseURL, redactSecret(p.APIKey))
+}
+
+// LogValue keeps the API key out of structured logs.
+func (p ProviderConfig) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("base_url", p.BaseURL),
+		slog.String("api_key", redactSecret(p.APIKey)),
+	)
+}
+
+// String masks the password in the database URL.
+func (d DatabaseConfig) String() string {
+	return fmt.Sprintf("{URL:%s ReadURL:%s MaxOpenConns:%d MaxIdleConns:%d ConnMaxLifetime:%d ConnMaxIdleTime:%d AutoMigrate:%t ConnectTimeout:%d}",
+		redactURL(d.URL), redactURL(d.ReadURL), d.MaxOpenConns, d.MaxIdleConns, d.ConnMaxLifetime, d.ConnMaxIdleTime, d.AutoMigrate, d.ConnectTimeout)
+}
+
+// LogValue keeps the database password out of structured logs.
+func (d DatabaseConfig) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("url", redactURL(d.URL)),
+		slog.String("read_url", redactURL(d.ReadURL)),
+		slog.Int("max_open_conns", d.MaxOpenConns),
+		slog.Int("max_idle_conns", d.MaxIdleConns),
+		slog.Int("conn_max_lifetime", d.ConnMaxLifetime),
+		slog.Int("conn_max_idle_time", d.ConnMaxIdleTime),
+		slog.Bool("auto_migrate", d.AutoMigrate),
+		slog.Int("connect_timeout", d.ConnectTimeout),
+	)
+}
+
+// String masks every secret so the config can be printed with %v or %+v.
+func (c Config) String() string {
+	return fmt.Sprintf("{Port:%s Server:%+v TLS:%+v Environment:%s Provider:%s Anthropic:%s Database:%s AdminAPIKey:%s APIKeyPepper:%s Log:%+v CORS:%+v}",
+		c.Port, c.Server, c.TLS, c.Environment, c.Provider, c.Anthropic, c.Database, redactSecret(c.AdminAPIKey), redactSecret(c.APIKeyPepper), c.Log, c.CORS)
+}
+
+// LogValue keeps secrets out of structured logs.
+func (c Config) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("port", c.Port),
+		slog.String("listen_addr", c.Server.ListenAddr),
+		slog.String("admin_listen_addr", c.Server.AdminListenAddr),
+		slog.String("environment", c.Environment),
+		slog.Bool("debug_endpoints", c.DebugEndpoints),
+		slog.Any("provider", c.Provider),
+		slog.Any("anthropic", c.Anthropic),
+		slog.Any("database", c.Database),
+		slog.String("admin_api_key", redactSecret(c.AdminAPIKey)),
+		slog.String("api_key_pepper", redactSecret(c.APIKeyPepper)),
+	)
+}
+
+// redactSecret masks a non-empty secret; empty values stay empty so a
+// missing key is still visible.
+func redactSecret(s string) string {
+	if s == "" {
+		return ""
+	}
+	return logging.Redacted
+}
+
+// redactURL masks the password in a connection URL. Unparseable URLs are
+// masked entirely since they may embed credentials.
+func redactURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return logging.Redacted
+	}
+	if _, ok := parsed.User.Password(); ok {
+		parsed.User = url.UserPassword(parsed.User.Username(), logging.Redacted)
+	}
+	return parsed.String()
+}
+
 // validateBaseURL ensures the provider base URL is a valid HTTP/HTTPS URL.
 func validateBaseURL(baseURL string) error {
 	parsed, err := url.Parse(baseURL)
@@ -151,6 +644,52 @@ func validateAPIKey(apiKey string) error {
 	return nil
 }
 
+// validateAdminAPIKey rejects admin keys that are too short to resist guessing.
+func validateAdminAPIKey(apiKey string) error {
+	if strings.TrimSpace(apiKey) != apiKey {
+		return fmt.Errorf("admin API key contains leading or trailing whitespace")
+	}
+	if len(apiKey) < 32 {
+		return fmt.Errorf("admin API key too short, must be at least 32 characters")
+	}
+	return nil
+}
+
+// validatePepper rejects API key peppers too short to resist brute force
+// if the database and pepper are ever guessed together.
+func validatePepper(pepper string) error {
+	if strings.TrimSpace(pepper) != pepper {
+		return fmt.Errorf("pepper contains leading or trailing whitespace")
+	}
+	if len(pepper) < 32 {
+		return fmt.Errorf("pepper too short, must be at least 32 characters")
+	}
+	return nil
+}
+
+// validateCORSOrigin accepts scheme://host[:port] origins, optionally with a
+// leading "*." wildcard label. A bare "*" is rejected: the admin API must
+// never be open to every origin.
+func validateCORSOrigin(origin string) error {
+	if origin == "*" {
+		return fmt.Errorf("wildcard origin not allowed, list origins explicitly")
+	}
+	parsed, err := url.Parse(strings.Replace(origin, "://*.", "://wildcard.", 1))
+	if err != nil {
+		return fmt.Errorf("malformed origin: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("origin must use http or https scheme, got %q", parsed.Scheme)
+	}
+	if parsed.Host == "" || strings.Contains(parsed.Host, "*") {
+		return fmt.Errorf("origin must include a host; wildcards are only allowed as the first label")
+	}
+	if parsed.Path != "" || parsed.RawQuery != "" || parsed.User != nil {
+		return fmt.Errorf("origin must not include a path, query, or credentials")
+	}
+	return nil
+}
+
 // validateDatabaseURL ensures the database URL is a valid PostgreSQL connection string.
 func validateDatabaseURL(dbURL string) error {
 	parsed, err := url.Parse(dbURL)
@@ -169,15 +708,75 @@ func validateDatabaseURL(dbURL string) error {
 	return nil
 }
 
-// getEnvInt reads an environment variable as an integer with a default fallback.
-func getEnvInt(key string, defaultVal int) int {
-	val := os.Getenv(key)
+// getList reads a comma-separated value, trimming whitespace and dropping
+// empty entries.
+func (s source) getList(key, defaultVal string) []string {
+	val := s.get(key)
+	if val == "" {
+		val = defaultVal
+	}
+	var list []string
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getDuration reads a time.Duration (e.g. "500ms", "10s") with a default
+// fallback. Malformed values are an error: a silently ignored threshold is
+// hard to notice.
+func (s source) getDuration(key string, defaultVal time.Duration) (time.Duration, error) {
+	val := s.get(key)
+	if val == "" {
+		return defaultVal, nil
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s value %q: must be a non-negative duration like 500ms or 10s", key, val)
+	}
+	return d, nil
+}
+
+// getSeconds reads a whole number of seconds as a time.Duration. Malformed
+// or non-positive values are an error.
+func (s source) getSeconds(key string, defaultVal time.Duration) (time.Duration, error) {
+	val := s.get(key)
+	if val == "" {
+		return defaultVal, nil
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid %s value %q: must be a positive number of seconds", key, val)
+	}
+	return time.Duration(n) * time.Second, nil
+}
+
+// getBool reads a boolean ("true", "false", "1", "0", ...) with a default
+// fallback. Malformed values are an error.
+func (s source) getBool(key string, defaultVal bool) (bool, error) {
+	val := s.get(key)
+	if val == "" {
+		return defaultVal, nil
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s value %q: must be true or false", key, val)
+	}
+	return b, nil
+}
+
+// getInt reads an integer with a default fallback. Malformed values are an
+// error.
+func (s source) getInt(key string, defaultVal int) (int, error) {
+	val := s.get(key)
 	if val == "" {
-		return defaultVal
+		return defaultVal, nil
 	}
 	intVal, err := strconv.Atoi(val)
 	if err != nil {
-		return defaultVal
+		return 0, fmt.Errorf("invalid %s value %q: must be a whole number", key, val)
 	}
-	return intVal
+	return intVal, nil
 }
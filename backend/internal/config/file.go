@@ -0,0 +1,250 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fieldKind is the value type a config file field accepts.
+type fieldKind int
+
+const (
+	kindString fieldKind = iota
+	kindInt
+	kindFloat
+	kindList
+	kindBool
+)
+
+// fileField maps a config file key to the environment variable it stands in for.
+type fileField struct {
+	env  string
+	kind fieldKind
+}
+
+// fileFields is the config file schema. Keys are dotted paths into the
+// file's nested objects; every field has an environment variable that
+// overrides it.
+var fileFields = map[string]fileField{
+	"port":                            {"PORT", kindString},
+	"server.listen_addr":              {"LISTEN_ADDR", kindString},
+	"server.stream_drain_timeout":     {"STREAM_DRAIN_TIMEOUT", kindInt},
+	"server.admin_port":               {"ADMIN_PORT", kindString},
+	"server.admin_listen_addr":        {"ADMIN_LISTEN_ADDR", kindString},
+	"server.shutdown_timeout":         {"SHUTDOWN_TIMEOUT", kindInt},
+	"server.read_header_timeout":      {"READ_HEADER_TIMEOUT", kindInt},
+	"server.idle_timeout":             {"IDLE_TIMEOUT", kindInt},
+	"server.trusted_proxies":          {"TRUSTED_PROXIES", kindList},
+	"tls.cert_file":                   {"TLS_CERT_FILE", kindString},
+	"tls.key_file":                    {"TLS_KEY_FILE", kindString},
+	"tls.client_ca_file":              {"TLS_CLIENT_CA_FILE", kindString},
+	"env":                             {"ENV", kindString},
+	"debug_endpoints":                 {"DEBUG_ENDPOINTS", kindBool},
+	"admin_api_key":                   {"ADMIN_API_KEY", kindString},
+	"api_key_pepper":                  {"API_KEY_PEPPER", kindString},
+	"provider.base_url":               {"PROVIDER_BASE_URL", kindString},
+	"provider.api_key":                {"PROVIDER_API_KEY", kindString},
+	"anthropic.base_url":              {"ANTHROPIC_BASE_URL", kindString},
+	"anthropic.api_key":               {"ANTHROPIC_API_KEY", kindString},
+	"database.url":                    {"DATABASE_URL", kindString},
+	"database.read_url":               {"DATABASE_READ_URL", kindString},
+	"database.max_open_conns":         {"DB_MAX_OPEN_CONNS", kindInt},
+	"database.max_idle_conns":         {"DB_MAX_IDLE_CONNS", kindInt},
+	"database.conn_max_lifetime":      {"DB_CONN_MAX_LIFETIME", kindInt},
+	"database.conn_max_idle_time":     {"DB_CONN_MAX_IDLE_TIME", kindInt},
+	"database.auto_migrate":           {"AUTO_MIGRATE", kindBool},
+	"database.connect_timeout":        {"DB_CONNECT_TIMEOUT", kindInt},
+	"log.level":                       {"LOG_LEVEL", kindString},
+	"log.format":                      {"LOG_FORMAT", kindString},
+	"log.slow_request_threshold":      {"LOG_SLOW_REQUEST_THRESHOLD", kindString},
+	"log.slow_stream_ttfb_threshold":  {"LOG_SLOW_STREAM_TTFB_THRESHOLD", kindString},
+	"log.error_rate_threshold":        {"LOG_ERROR_RATE_THRESHOLD", kindFloat},
+	"cors.allowed_origins":            {"CORS_ALLOWED_ORIGINS", kindList},
+	"cors.allowed_headers":            {"CORS_ALLOWED_HEADERS", kindList},
+	"cors.max_age":                    {"CORS_MAX_AGE", kindInt},
+	"request_log.retention_days":      {"REQUEST_LOG_RETENTION_DAYS", kindInt},
+	"request_log.buffer_size":         {"REQUEST_LOG_BUFFER_SIZE", kindInt},
+	"request_limits.max_json_depth":   {"REQUEST_MAX_JSON_DEPTH", kindInt},
+	"request_limits.max_extra_fields": {"REQUEST_MAX_EXTRA_FIELDS", kindInt},
+	"request_limits.max_extra_bytes":  {"REQUEST_MAX_EXTRA_BYTES", kindInt},
+}
+
+// source resolves config values: a non-empty environment variable wins,
+// then the config file, then the caller's default.
+type source struct {
+	file map[string]string // Env var name -> value from the config file
+}
+
+// newSource reads the config file at path, if any.
+func newSource(path string) (source, error) {
+	if path == "" {
+		return source{}, nil
+	}
+	values, err := readConfigFile(path)
+	if err != nil {
+		return source{}, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return source{file: values}, nil
+}
+
+func (s source) get(key string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return s.file[key]
+}
+
+// getSecret is get for secret-bearing values, which may instead be read
+// from the file named by <key>_FILE (Docker and Kubernetes secret mounts)
+// so they don't show up in the process environment. Setting both the
+// variable and its _FILE form is an error rather than a silent choice.
+// A _FILE variable takes precedence over the config file.
+func (s source) getSecret(key string) (string, error) {
+	path := os.Getenv(key + "_FILE")
+	if path == "" {
+		return s.get(key), nil
+	}
+	if os.Getenv(key) != "" {
+		return "", fmt.Errorf("%s and %s_FILE are both set: use only one", key, key)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s_FILE: %w", key, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// readConfigFile parses a YAML (.yaml, .yml) or JSON (.json) config file
+// into environment-variable-keyed strings, so file values go through the
+// same parsing and validation as the environment.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&doc)
+	default:
+		return nil, fmt.Errorf("unsupported extension %q: use .yaml, .yml or .json", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse error: %w", err)
+	}
+
+	values := make(map[string]string)
+	if err := flattenConfig("", doc, values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// flattenConfig walks doc, checking each leaf against fileFields.
+func flattenConfig(prefix string, doc map[string]any, values map[string]string) error {
+	keys := make([]string, 0, len(doc))
+	for k := range doc {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys) // Deterministic error for files with several problems
+
+	for _, k := range keys {
+		path := k
+		if prefix != "" {
+			path = prefix + "." + k
+		}
+		if nested, ok := doc[k].(map[string]any); ok {
+			if err := flattenConfig(path, nested, values); err != nil {
+				return err
+			}
+			continue
+		}
+
+		field, ok := fileFields[path]
+		if !ok {
+			return fmt.Errorf("unknown field %q", path)
+		}
+		if doc[k] == nil {
+			continue
+		}
+		val, err := fieldValue(field.kind, doc[k])
+		if err != nil {
+			return fmt.Errorf("field %q: %w", path, err)
+		}
+		values[field.env] = val
+	}
+	return nil
+}
+
+// fieldValue renders a decoded YAML/JSON value as the string its
+// environment variable would hold.
+func fieldValue(kind fieldKind, v any) (string, error) {
+	switch kind {
+	case kindString:
+		switch v := v.(type) {
+		case string:
+			return v, nil
+		case int, json.Number:
+			return fmt.Sprint(v), nil // e.g. port: 8080
+		}
+		return "", fmt.Errorf("expected a string, got %T", v)
+	case kindInt:
+		switch v := v.(type) {
+		case int:
+			return strconv.Itoa(v), nil
+		case json.Number:
+			if _, err := v.Int64(); err == nil {
+				return v.String(), nil
+			}
+		case float64:
+			if v == math.Trunc(v) {
+				return strconv.FormatFloat(v, 'f', -1, 64), nil
+			}
+		}
+		return "", fmt.Errorf("expected an integer, got %v", v)
+	case kindFloat:
+		switch v := v.(type) {
+		case int:
+			return strconv.Itoa(v), nil
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case json.Number:
+			return v.String(), nil
+		}
+		return "", fmt.Errorf("expected a number, got %v", v)
+	case kindList:
+		items, ok := v.([]any)
+		if !ok {
+			return "", fmt.Errorf("expected a list of strings, got %T", v)
+		}
+		parts := make([]string, len(items))
+		for i, item := range items {
+			str, ok := item.(string)
+			if !ok || strings.Contains(str, ",") {
+				return "", fmt.Errorf("item %d: expected a string without commas", i)
+			}
+			parts[i] = str
+		}
+		return strings.Join(parts, ","), nil
+	case kindBool:
+		if b, ok := v.(bool); ok {
+			return strconv.FormatBool(b), nil
+		}
+		return "", fmt.Errorf("expected true or false, got %v", v)
+	}
+	return "", fmt.Errorf("unsupported field kind %d", kind)
+}
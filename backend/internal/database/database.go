@@ -3,7 +3,10 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
+	"math/rand/v2"
 	"time"
 
 	_ "github.com/lib/pq"
@@ -12,8 +15,11 @@ import (
 )
 
 // DB wraps sql.DB to provide application-specific database operations.
+// The embedded pool is the primary; reader is set when a read replica is
+// configured.
 type DB struct {
 	*sql.DB
+	reader *Reader
 }
 
 // New creates a new database connection pool with the provided configuration.
@@ -23,34 +29,133 @@ func New(cfg config.DatabaseConfig) (*DB, error) {
 		return nil, fmt.Errorf("failed to open database connection: %w", err)
 	}
 
+	configurePool(db, cfg)
+	return &DB{DB: db}, nil
+}
+
+// configurePool applies the pool limits from cfg. Zero durations mean no
+// limit, matching database/sql.
+func configurePool(db *sql.DB, cfg config.DatabaseConfig) {
 	db.SetMaxOpenConns(cfg.MaxOpenConns)
 	db.SetMaxIdleConns(cfg.MaxIdleConns)
 	db.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime) * time.Second)
-
-	return &DB{DB: db}, nil
+	db.SetConnMaxIdleTime(time.Duration(cfg.ConnMaxIdleTime) * time.Second)
 }
 
+// pingTimeout bounds a single connection attempt.
+const pingTimeout = 10 * time.Second
+
+// connectBackoff is the delay before the first retry in Connect, doubled
+// per attempt up to connectMaxBackoff. Variables so tests can shorten them.
+var (
+	connectBackoff    = 250 * time.Millisecond
+	connectMaxBackoff = 5 * time.Second
+)
+
 // Connect establishes the database connection and verifies connectivity.
-func Connect(cfg config.DatabaseConfig) (*DB, error) {
+// When Postgres isn't reachable yet (it is often starting alongside us) it
+// retries with jittered exponential backoff for up to cfg.ConnectTimeout
+// seconds, then fails with the last ping error. Cancelling ctx, e.g. on
+// SIGTERM, stops the wait early.
+func Connect(ctx context.Context, cfg config.DatabaseConfig) (*DB, error) {
 	db, err := New(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	if err := db.PingContext(ctx); err != nil {
+	budget := time.Duration(cfg.ConnectTimeout) * time.Second
+	if err := pingWithRetry(ctx, db.PingContext, budget); err != nil {
 		_ = db.Close()
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+		return nil, err
+	}
+
+	if cfg.ReadURL != "" {
+		if err := db.openReplica(ctx, cfg); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
 	}
 
 	return db, nil
 }
 
-// Close closes the database connection pool.
+// openReplica opens the read replica pool. Unlike the primary, an
+// unreachable replica doesn't fail startup: reads go to the primary until
+// the health check sees it come up.
+func (db *DB) openReplica(ctx context.Context, cfg config.DatabaseConfig) error {
+	replica, err := sql.Open("postgres", cfg.ReadURL)
+	if err != nil {
+		return fmt.Errorf("failed to open read replica connection: %w", err)
+	}
+	configurePool(replica, cfg)
+
+	pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+	err = replica.PingContext(pingCtx)
+	cancel()
+	if err != nil {
+		slog.Warn("read replica is unreachable, routing reads to the primary", "error", err)
+	}
+
+	db.reader = newReader(db.DB, replica, err == nil)
+	db.reader.start()
+	return nil
+}
+
+// Reader returns the handle for read-only lookups that tolerate replica
+// lag: a replica-routing Reader when DATABASE_READ_URL is set, otherwise
+// the primary pool.
+func (db *DB) Reader() DBTX {
+	if db.reader == nil {
+		return db.DB
+	}
+	return db.reader
+}
+
+// Replica returns the read replica pool, or nil when none is configured.
+func (db *DB) Replica() *sql.DB {
+	if db.reader == nil {
+		return nil
+	}
+	return db.reader.replica
+}
+
+// pingWithRetry calls ping until it succeeds, budget is spent or ctx is
+// done. A zero budget makes a single attempt.
+func pingWithRetry(ctx context.Context, ping func(context.Context) error, budget time.Duration) error {
+	deadline := time.Now().Add(budget)
+	backoff := connectBackoff
+
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+		err := ping(pingCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		// Full jitter in [backoff/2, backoff) so replicas don't retry in lockstep
+		delay := backoff/2 + rand.N(backoff/2+1)
+		if time.Now().Add(delay).After(deadline) {
+			return fmt.Errorf("failed to ping database after %d attempt(s): %w", attempt, err)
+		}
+		slog.Warn("database not reachable, retrying", "attempt", attempt, "retry_in_ms", delay.Milliseconds(), "error", err)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return fmt.Errorf("gave up waiting for database after %d attempt(s): %w", attempt, err)
+		}
+		backoff = min(backoff*2, connectMaxBackoff)
+	}
+}
+
+// Close closes the database connection pools.
 func (db *DB) Close() error {
-	return db.DB.Close()
+	var replicaErr error
+	if db.reader != nil {
+		replicaErr = db.reader.Close()
+	}
+	return errors.Join(db.DB.Close(), replicaErr)
 }
 
 // Health checks if the database is reachable.
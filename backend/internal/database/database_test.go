@@ -1,7 +1,14 @@
 package database
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
 
 	"navplane/internal/config"
 )
@@ -55,8 +62,111 @@ func TestConnect_FailsForUnreachableDB(t *testing.T) {
 		ConnMaxLifetime: 60,
 	}
 
-	_, err := Connect(cfg)
+	_, err := Connect(context.Background(), cfg)
 	if err == nil {
 		t.Error("Connect should fail for unreachable database")
 	}
 }
+
+func TestConfigurePool_LimitsIdleConnections(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	configurePool(db, config.DatabaseConfig{MaxOpenConns: 4, MaxIdleConns: 1, ConnMaxLifetime: 300, ConnMaxIdleTime: 60})
+
+	// Check out three connections and return them; only one may stay idle
+	ctx := context.Background()
+	var conns []*sql.Conn
+	for range 3 {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatalf("failed to get connection: %v", err)
+		}
+		conns = append(conns, conn)
+	}
+	if got := db.Stats().InUse; got != 3 {
+		t.Errorf("expected 3 connections in use, got %d", got)
+	}
+	for _, conn := range conns {
+		_ = conn.Close()
+	}
+
+	stats := db.Stats()
+	if stats.MaxOpenConnections != 4 {
+		t.Errorf("expected MaxOpenConnections to be 4, got %d", stats.MaxOpenConnections)
+	}
+	if stats.Idle != 1 {
+		t.Errorf("expected 1 idle connection, got %d", stats.Idle)
+	}
+	if stats.MaxIdleClosed != 2 {
+		t.Errorf("expected 2 connections closed by the idle limit, got %d", stats.MaxIdleClosed)
+	}
+}
+
+func TestPingWithRetry(t *testing.T) {
+	defer func(base, limit time.Duration) { connectBackoff, connectMaxBackoff = base, limit }(connectBackoff, connectMaxBackoff)
+	connectBackoff, connectMaxBackoff = 2*time.Millisecond, 8*time.Millisecond
+
+	refused := errors.New("connection refused")
+
+	tests := []struct {
+		name         string
+		failures     int // Pings that fail before one succeeds; -1 never succeeds
+		budget       time.Duration
+		wantErr      string
+		wantAttempts int
+	}{
+		{name: "first attempt succeeds", budget: time.Second, wantAttempts: 1},
+		{name: "succeeds after retries", failures: 3, budget: time.Second, wantAttempts: 4},
+		{name: "zero budget tries once", failures: -1, wantErr: "after 1 attempt(s): connection refused", wantAttempts: 1},
+		{name: "budget exhausted", failures: -1, budget: 30 * time.Millisecond, wantErr: "connection refused"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			ping := func(context.Context) error {
+				attempts++
+				if tt.failures < 0 || attempts <= tt.failures {
+					return refused
+				}
+				return nil
+			}
+
+			err := pingWithRetry(context.Background(), ping, tt.budget)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !errors.Is(err, refused) {
+					t.Fatalf("expected error containing %q wrapping the ping error, got %v", tt.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if tt.wantAttempts != 0 && attempts != tt.wantAttempts {
+				t.Errorf("expected %d attempts, got %d", tt.wantAttempts, attempts)
+			}
+			if tt.wantAttempts == 0 && attempts < 2 {
+				t.Errorf("expected retries before the budget ran out, got %d attempts", attempts)
+			}
+		})
+	}
+}
+
+func TestPingWithRetry_ContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ping := func(context.Context) error {
+		cancel() // Shutdown signal arrives during the first attempt
+		return errors.New("connection refused")
+	}
+
+	start := time.Now()
+	err := pingWithRetry(ctx, ping, time.Hour)
+	if err == nil || !strings.Contains(err.Error(), "gave up waiting for database") {
+		t.Fatalf("expected cancellation error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected cancellation to stop the wait promptly, took %v", elapsed)
+	}
+}
@@ -3,16 +3,23 @@ package database
 import (
 	"errors"
 	"fmt"
-	"path/filepath"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 )
 
+// Migration methods read *.up.sql and *.down.sql files from the root of an
+// fs.FS: the embedded migrations.FS in production, or os.DirFS of
+// MIGRATIONS_PATH during development.
+
 // MigrateUp runs all pending migrations.
-func (db *DB) MigrateUp(migrationsPath string) error {
-	m, err := db.newMigrate(migrationsPath)
+func (db *DB) MigrateUp(migrations fs.FS) error {
+	m, err := db.newMigrate(migrations)
 	if err != nil {
 		return err
 	}
@@ -25,24 +32,98 @@ func (db *DB) MigrateUp(migrationsPath string) error {
 	return nil
 }
 
-// MigrateDown rolls back the last migration.
-func (db *DB) MigrateDown(migrationsPath string) error {
-	m, err := db.newMigrate(migrationsPath)
+// MigrateDown rolls back the last steps migrations.
+func (db *DB) MigrateDown(migrations fs.FS, steps int) error {
+	if steps < 1 {
+		return fmt.Errorf("steps must be at least 1, got %d", steps)
+	}
+
+	m, err := db.newMigrate(migrations)
 	if err != nil {
 		return err
 	}
 	defer closeMigrate(m)
 
-	if err := m.Steps(-1); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+	if err := m.Steps(-steps); err != nil && !errors.Is(err, migrate.ErrNoChange) {
 		return fmt.Errorf("failed to rollback migration: %w", err)
 	}
 
 	return nil
 }
 
+// MigrateForce sets the recorded version and clears the dirty flag without
+// running any migration. Use it after fixing a failed migration by hand.
+func (db *DB) MigrateForce(migrations fs.FS, version int) error {
+	m, err := db.newMigrate(migrations)
+	if err != nil {
+		return err
+	}
+	defer closeMigrate(m)
+
+	if err := m.Force(version); err != nil {
+		return fmt.Errorf("failed to force migration version: %w", err)
+	}
+
+	return nil
+}
+
+// MigrationStatus describes the database's position relative to the
+// available migrations.
+type MigrationStatus struct {
+	Version uint // Applied version; 0 when none have run
+	Dirty   bool // A migration failed part-way and needs MigrateForce
+	Latest  uint // Highest available version
+	Pending int  // Available migrations newer than Version
+}
+
+// MigrateStatus reports the applied version and how many migrations are
+// pending.
+func (db *DB) MigrateStatus(migrations fs.FS) (MigrationStatus, error) {
+	versions, err := availableMigrations(migrations)
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+	version, dirty, err := db.MigrateVersion(migrations)
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+	return newMigrationStatus(version, dirty, versions), nil
+}
+
+func newMigrationStatus(version uint, dirty bool, available []uint) MigrationStatus {
+	status := MigrationStatus{Version: version, Dirty: dirty}
+	for _, v := range available {
+		status.Latest = max(status.Latest, v)
+		if v > version {
+			status.Pending++
+		}
+	}
+	return status
+}
+
+// availableMigrations returns the versions of the up migrations in
+// migrations, named like 000001_init_schema.up.sql.
+func availableMigrations(migrations fs.FS) ([]uint, error) {
+	files, err := fs.Glob(migrations, "*.up.sql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	versions := make([]uint, 0, len(files))
+	for _, f := range files {
+		prefix, _, _ := strings.Cut(path.Base(f), "_")
+		v, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s has no numeric version prefix", path.Base(f))
+		}
+		versions = append(versions, uint(v))
+	}
+	return versions, nil
+}
+
 // MigrateVersion returns the current migration version.
-func (db *DB) MigrateVersion(migrationsPath string) (uint, bool, error) {
-	m, err := db.newMigrate(migrationsPath)
+func (db *DB) MigrateVersion(migrations fs.FS) (uint, bool, error) {
+	m, err := db.newMigrate(migrations)
 	if err != nil {
 		return 0, false, err
 	}
@@ -57,8 +138,8 @@ func (db *DB) MigrateVersion(migrationsPath string) (uint, bool, error) {
 }
 
 // MigrateReset rolls back all migrations (use with caution).
-func (db *DB) MigrateReset(migrationsPath string) error {
-	m, err := db.newMigrate(migrationsPath)
+func (db *DB) MigrateReset(migrations fs.FS) error {
+	m, err := db.newMigrate(migrations)
 	if err != nil {
 		return err
 	}
@@ -75,12 +156,10 @@ func closeMigrate(m *migrate.Migrate) {
 	_, _ = m.Close()
 }
 
-func (db *DB) newMigrate(migrationsPath string) (*migrate.Migrate, error) {
-	// Normalize to absolute path - file:// URLs require absolute paths
-	// otherwise relative paths are incorrectly parsed as host components
-	absPath, err := filepath.Abs(migrationsPath)
+func (db *DB) newMigrate(migrations fs.FS) (*migrate.Migrate, error) {
+	source, err := iofs.New(migrations, ".")
 	if err != nil {
-		return nil, fmt.Errorf("failed to resolve migrations path: %w", err)
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
 	}
 
 	driver, err := postgres.WithInstance(db.DB, &postgres.Config{})
@@ -88,8 +167,7 @@ func (db *DB) newMigrate(migrationsPath string) (*migrate.Migrate, error) {
 		return nil, fmt.Errorf("failed to create migration driver: %w", err)
 	}
 
-	sourceURL := "file://" + absPath
-	m, err := migrate.NewWithDatabaseInstance(sourceURL, "postgres", driver)
+	m, err := migrate.NewWithInstance("iofs", source, "postgres", driver)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create migrate instance: %w", err)
 	}
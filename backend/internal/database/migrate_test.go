@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"navplane/internal/config"
+	"navplane/internal/requestlog"
+	"navplane/migrations"
+)
+
+func TestAvailableMigrations(t *testing.T) {
+	// The embedded migrations must always parse
+	versions, err := availableMigrations(migrations.FS)
+	if err != nil {
+		t.Fatalf("failed to read embedded migrations: %v", err)
+	}
+	if len(versions) == 0 || !slices.Contains(versions, 1) {
+		t.Errorf("expected embedded migrations to include version 1, got %v", versions)
+	}
+
+	fsys := fstest.MapFS{
+		"000001_init.up.sql":   {},
+		"000001_init.down.sql": {},
+		"000003_more.up.sql":   {},
+		"README.md":            {},
+	}
+	versions, err = availableMigrations(fsys)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !slices.Equal(versions, []uint{1, 3}) {
+		t.Errorf("expected versions [1 3], got %v", versions)
+	}
+
+	fsys["latest.up.sql"] = &fstest.MapFile{}
+	if _, err := availableMigrations(fsys); err == nil || !strings.Contains(err.Error(), "no numeric version prefix") {
+		t.Errorf("expected version prefix error, got %v", err)
+	}
+}
+
+func TestNewMigrationStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		version uint
+		dirty   bool
+		want    MigrationStatus
+	}{
+		{name: "fresh database", version: 0, want: MigrationStatus{Latest: 5, Pending: 3}},
+		{name: "partially migrated", version: 2, want: MigrationStatus{Version: 2, Latest: 5, Pending: 1}},
+		{name: "up to date", version: 5, want: MigrationStatus{Version: 5, Latest: 5}},
+		{name: "dirty", version: 4, dirty: true, want: MigrationStatus{Version: 4, Dirty: true, Latest: 5, Pending: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newMigrationStatus(tt.version, tt.dirty, []uint{1, 2, 5})
+			if got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+// testMigrationDB connects to TEST_DATABASE_URL in a fresh schema that is
+// dropped when the test ends, skipping the test when the variable is unset.
+func testMigrationDB(t *testing.T) *DB {
+	t.Helper()
+	rawURL := os.Getenv("TEST_DATABASE_URL")
+	if rawURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	admin, err := New(config.DatabaseConfig{URL: rawURL, MaxOpenConns: 1})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	schema := fmt.Sprintf("migrate_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("failed to create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Errorf("failed to drop schema %s: %v", schema, err)
+		}
+		_ = admin.Close()
+	})
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("invalid TEST_DATABASE_URL: %v", err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+
+	db, err := New(config.DatabaseConfig{URL: u.String(), MaxOpenConns: 1})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+// TestMigrations_ApplyInOrder runs every embedded migration from 000001 to
+// the latest against a real database, then rolls them all back and applies
+// them again.
+func TestMigrations_ApplyInOrder(t *testing.T) {
+	db := testMigrationDB(t)
+
+	versions, err := availableMigrations(migrations.FS)
+	if err != nil {
+		t.Fatalf("failed to read embedded migrations: %v", err)
+	}
+	latest := slices.Max(versions)
+
+	for pass := range 2 {
+		if err := db.MigrateUp(migrations.FS); err != nil {
+			t.Fatalf("pass %d: migrate up: %v", pass, err)
+		}
+		status, err := db.MigrateStatus(migrations.FS)
+		if err != nil {
+			t.Fatalf("pass %d: migrate status: %v", pass, err)
+		}
+		if status.Version != latest || status.Dirty || status.Pending != 0 {
+			t.Fatalf("pass %d: expected clean version %d, got %+v", pass, latest, status)
+		}
+
+		// The schema must match what the request log datastore writes
+		entry := &requestlog.Entry{Route: "/v1/chat/completions", Status: 200, ClientIP: "203.0.113.7", CreatedAt: time.Now()}
+		if err := requestlog.NewDatastore(db.DB).Insert(context.Background(), entry); err != nil {
+			t.Fatalf("pass %d: insert request log: %v", pass, err)
+		}
+
+		if pass == 0 {
+			if err := db.MigrateReset(migrations.FS); err != nil {
+				t.Fatalf("migrate reset: %v", err)
+			}
+		}
+	}
+}
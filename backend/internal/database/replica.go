@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"navplane/internal/metrics"
+)
+
+// replicaCheckInterval is how often a Reader pings its replica. Variables
+// so tests can shorten them.
+var (
+	replicaCheckInterval = 2 * time.Second
+	replicaCheckTimeout  = time.Second
+)
+
+// Reader routes read queries to a replica while it is healthy and to the
+// primary otherwise. Exec always goes to the primary, so a Reader can be
+// passed wherever a DBTX is expected without risking a write on the replica.
+//
+// Replicas lag the primary; only use a Reader for lookups that tolerate
+// briefly stale rows.
+type Reader struct {
+	primary *sql.DB
+	replica *sql.DB
+	healthy atomic.Bool
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newReader returns a Reader that starts on the replica when healthy is
+// true. Call start to run the health check.
+func newReader(primary, replica *sql.DB, healthy bool) *Reader {
+	r := &Reader{primary: primary, replica: replica, stop: make(chan struct{})}
+	r.healthy.Store(healthy)
+	return r
+}
+
+// start pings the replica every replicaCheckInterval until Close.
+func (r *Reader) start() {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(replicaCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.check()
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// check pings the replica once and updates its health.
+func (r *Reader) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), replicaCheckTimeout)
+	defer cancel()
+	if err := r.replica.PingContext(ctx); err != nil {
+		r.markDown(err)
+		return
+	}
+	if r.healthy.CompareAndSwap(false, true) {
+		slog.Info("read replica is reachable again, routing reads to it")
+	}
+}
+
+// markDown switches reads to the primary until the next successful check.
+func (r *Reader) markDown(err error) {
+	if r.healthy.CompareAndSwap(true, false) {
+		metrics.DBReplicaFailovers.Inc()
+		slog.Warn("read replica is unreachable, routing reads to the primary", "error", err)
+	}
+}
+
+// pick returns the pool to read from and records the choice.
+func (r *Reader) pick() *sql.DB {
+	if r.healthy.Load() {
+		metrics.DBReads.WithLabelValues("replica").Inc()
+		return r.replica
+	}
+	metrics.DBReads.WithLabelValues("primary").Inc()
+	return r.primary
+}
+
+// ExecContext runs query on the primary.
+func (r *Reader) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return r.primary.ExecContext(ctx, query, args...)
+}
+
+// QueryContext runs query on the replica, falling back to the primary when
+// the replica is down or the query fails with a transient error.
+func (r *Reader) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	db := r.pick()
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil && db == r.replica && IsTransient(err) {
+		r.markDown(err)
+		metrics.DBReads.WithLabelValues("primary").Inc()
+		return r.primary.QueryContext(ctx, query, args...)
+	}
+	return rows, err
+}
+
+// QueryRowContext runs query on the replica while it is healthy. A *sql.Row
+// defers its error to Scan, so failover here relies on the health check.
+func (r *Reader) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return r.pick().QueryRowContext(ctx, query, args...)
+}
+
+// Close stops the health check and closes the replica pool. The primary is
+// owned by the DB and closed there.
+func (r *Reader) Close() error {
+	close(r.stop)
+	r.wg.Wait()
+	return r.replica.Close()
+}
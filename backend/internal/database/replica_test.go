@@ -0,0 +1,151 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"navplane/internal/metrics"
+)
+
+func TestReader_Routing(t *testing.T) {
+	primary, primaryMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer primary.Close()
+	replica, replicaMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+
+	r := newReader(primary, replica, true)
+	defer r.Close()
+	ctx := context.Background()
+	replicaReads := testutil.ToFloat64(metrics.DBReads.WithLabelValues("replica"))
+	primaryReads := testutil.ToFloat64(metrics.DBReads.WithLabelValues("primary"))
+
+	// Reads go to the healthy replica, writes always to the primary
+	replicaMock.ExpectQuery(`SELECT 1`).WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(1))
+	primaryMock.ExpectExec(`UPDATE t`).WillReturnResult(sqlmock.NewResult(0, 1))
+	var n int
+	if err := r.QueryRowContext(ctx, "SELECT 1").Scan(&n); err != nil {
+		t.Fatalf("expected replica read to succeed, got %v", err)
+	}
+	if _, err := r.ExecContext(ctx, "UPDATE t SET x = 1"); err != nil {
+		t.Fatalf("expected primary write to succeed, got %v", err)
+	}
+
+	// Once marked down, reads go to the primary
+	r.markDown(errors.New("connection refused"))
+	primaryMock.ExpectQuery(`SELECT 2`).WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(2))
+	if err := r.QueryRowContext(ctx, "SELECT 2").Scan(&n); err != nil {
+		t.Fatalf("expected primary read to succeed, got %v", err)
+	}
+
+	if err := replicaMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled replica expectations: %v", err)
+	}
+	if err := primaryMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled primary expectations: %v", err)
+	}
+	if got := testutil.ToFloat64(metrics.DBReads.WithLabelValues("replica")) - replicaReads; got != 1 {
+		t.Errorf("expected 1 replica read, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.DBReads.WithLabelValues("primary")) - primaryReads; got != 1 {
+		t.Errorf("expected 1 primary read, got %v", got)
+	}
+}
+
+func TestReader_QueryFailsOverOnTransientError(t *testing.T) {
+	primary, primaryMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer primary.Close()
+	replica, replicaMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+
+	r := newReader(primary, replica, true)
+	defer r.Close()
+	failovers := testutil.ToFloat64(metrics.DBReplicaFailovers)
+
+	replicaMock.ExpectQuery(`SELECT id`).WillReturnError(&pq.Error{Code: "08006"})
+	primaryMock.ExpectQuery(`SELECT id`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	rows, err := r.QueryContext(context.Background(), "SELECT id FROM t")
+	if err != nil {
+		t.Fatalf("expected failover to the primary, got %v", err)
+	}
+	_ = rows.Close()
+
+	if r.healthy.Load() {
+		t.Error("expected replica to be marked down")
+	}
+	if got := testutil.ToFloat64(metrics.DBReplicaFailovers) - failovers; got != 1 {
+		t.Errorf("expected 1 failover, got %v", got)
+	}
+	if err := primaryMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled primary expectations: %v", err)
+	}
+}
+
+func TestReader_HealthCheck(t *testing.T) {
+	primary, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer primary.Close()
+	replica, replicaMock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+
+	r := newReader(primary, replica, true)
+	defer r.Close()
+	failovers := testutil.ToFloat64(metrics.DBReplicaFailovers)
+
+	replicaMock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	replicaMock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	replicaMock.ExpectPing()
+
+	r.check()
+	if r.healthy.Load() {
+		t.Fatal("expected replica to be down after a failed ping")
+	}
+	r.check()
+	r.check()
+	if !r.healthy.Load() {
+		t.Fatal("expected replica to recover after a successful ping")
+	}
+
+	// Repeated failures while already down count as one failover
+	if got := testutil.ToFloat64(metrics.DBReplicaFailovers) - failovers; got != 1 {
+		t.Errorf("expected 1 failover, got %v", got)
+	}
+	if err := replicaMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled replica expectations: %v", err)
+	}
+}
+
+func TestDB_ReaderWithoutReplica(t *testing.T) {
+	primary, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	db := &DB{DB: primary}
+	defer db.Close()
+
+	if db.Reader() != DBTX(primary) {
+		t.Error("expected Reader to be the primary pool without a replica")
+	}
+	if db.Replica() != nil {
+		t.Error("expected no replica pool")
+	}
+}
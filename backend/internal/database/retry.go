@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log/slog"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+
+	"navplane/internal/metrics"
+)
+
+// readRetries is how many times RetryRead re-runs a query after a
+// transient failure; readBackoff is the delay before the first retry,
+// doubled each time. Variables so tests can shorten them.
+var (
+	readRetries = 2
+	readBackoff = 50 * time.Millisecond
+)
+
+// RetryRead runs a read-only query, retrying it when it fails with a
+// transient error (see IsTransient) so a brief failover doesn't surface as
+// a burst of 500s. query names the call site for the
+// navplane_db_query_retries_total metric.
+//
+// Only use it for reads outside a transaction: retrying a write can apply
+// it twice, and a failed transaction can't be resumed.
+func RetryRead[T any](ctx context.Context, query string, fn func() (T, error)) (T, error) {
+	backoff := readBackoff
+	for attempt := 0; ; attempt++ {
+		v, err := fn()
+		if err == nil || attempt == readRetries || !IsTransient(err) || ctx.Err() != nil {
+			return v, err
+		}
+
+		metrics.DBQueryRetries.WithLabelValues(query).Inc()
+		slog.Warn("transient database error, retrying query", "query", query, "attempt", attempt+1, "error", err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return v, err
+		}
+		backoff *= 2
+	}
+}
+
+// IsTransient reports whether err is worth retrying: a dropped or
+// refused connection, or a serialization failure or deadlock. Constraint
+// violations, missing rows and context cancellation are never transient.
+func IsTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		case "08": // connection_exception
+			return true
+		case "40": // serialization_failure, deadlock_detected
+			return pqErr.Code == "40001" || pqErr.Code == "40P01"
+		case "57": // admin_shutdown, crash_shutdown, cannot_connect_now (failover)
+			return pqErr.Code == "57P01" || pqErr.Code == "57P02" || pqErr.Code == "57P03"
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"navplane/internal/metrics"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "bad connection", err: driver.ErrBadConn, want: true},
+		{name: "wrapped bad connection", err: fmt.Errorf("query: %w", driver.ErrBadConn), want: true},
+		{name: "connection reset", err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}, want: true},
+		{name: "connection refused", err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, want: true},
+		{name: "serialization failure", err: &pq.Error{Code: "40001"}, want: true},
+		{name: "deadlock", err: &pq.Error{Code: "40P01"}, want: true},
+		{name: "connection failure", err: &pq.Error{Code: "08006"}, want: true},
+		{name: "admin shutdown", err: &pq.Error{Code: "57P01"}, want: true},
+		{name: "cannot connect now", err: &pq.Error{Code: "57P03"}, want: true},
+		{name: "unique violation", err: &pq.Error{Code: "23505"}},
+		{name: "foreign key violation", err: &pq.Error{Code: "23503"}},
+		{name: "query canceled", err: &pq.Error{Code: "57014"}},
+		{name: "no rows", err: sql.ErrNoRows},
+		{name: "context canceled", err: context.Canceled},
+		{name: "deadline exceeded", err: fmt.Errorf("dial: %w", context.DeadlineExceeded)},
+		{name: "other", err: errors.New("syntax error")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTransient(tt.err); got != tt.want {
+				t.Errorf("IsTransient(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryRead(t *testing.T) {
+	defer func(d time.Duration) { readBackoff = d }(readBackoff)
+	readBackoff = time.Millisecond
+
+	failover := &pq.Error{Code: "57P01"}
+	tests := []struct {
+		name      string
+		errs      []error // Returned by successive calls; nil thereafter
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "no error", wantCalls: 1},
+		{name: "recovers after a transient error", errs: []error{failover}, wantCalls: 2},
+		{name: "gives up after two retries", errs: []error{failover, failover, failover, failover}, wantCalls: 3, wantErr: failover},
+		{name: "non-transient error is not retried", errs: []error{sql.ErrNoRows}, wantCalls: 1, wantErr: sql.ErrNoRows},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := "test." + tt.name
+			calls := 0
+			got, err := RetryRead(context.Background(), query, func() (int, error) {
+				calls++
+				if calls <= len(tt.errs) {
+					return 0, tt.errs[calls-1]
+				}
+				return 42, nil
+			})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr == nil && got != 42 {
+				t.Errorf("expected result 42, got %d", got)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("expected %d calls, got %d", tt.wantCalls, calls)
+			}
+			if retries := testutil.ToFloat64(metrics.DBQueryRetries.WithLabelValues(query)); int(retries) != tt.wantCalls-1 {
+				t.Errorf("expected %d retries recorded, got %v", tt.wantCalls-1, retries)
+			}
+		})
+	}
+}
+
+func TestRetryRead_StopsWhenContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	_, err := RetryRead(ctx, "test.cancelled", func() (int, error) {
+		calls++
+		cancel() // The request is abandoned while the query fails
+		return 0, driver.ErrBadConn
+	})
+
+	if !errors.Is(err, driver.ErrBadConn) {
+		t.Errorf("expected the query error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected no retry after cancellation, got %d calls", calls)
+	}
+}
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestWithTx(t *testing.T) {
+	errInsert := errors.New("insert failed")
+
+	tests := []struct {
+		name    string
+		setup   func(mock sqlmock.Sqlmock)
+		fn      func(tx *sql.Tx) error
+		wantErr error
+	}{
+		{
+			name: "commits on success",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(`INSERT INTO a`).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(`INSERT INTO b`).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			fn: func(tx *sql.Tx) error {
+				if _, err := tx.Exec(`INSERT INTO a VALUES (1)`); err != nil {
+					return err
+				}
+				_, err := tx.Exec(`INSERT INTO b VALUES (1)`)
+				return err
+			},
+		},
+		{
+			name: "rolls back when a later step fails",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(`INSERT INTO a`).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(`INSERT INTO b`).WillReturnError(errInsert)
+				mock.ExpectRollback()
+			},
+			fn: func(tx *sql.Tx) error {
+				if _, err := tx.Exec(`INSERT INTO a VALUES (1)`); err != nil {
+					return err
+				}
+				_, err := tx.Exec(`INSERT INTO b VALUES (1)`)
+				return err
+			},
+			wantErr: errInsert,
+		},
+		{
+			name: "begin failure",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin().WillReturnError(sql.ErrConnDone)
+			},
+			fn:      func(*sql.Tx) error { t.Fatal("fn must not run without a transaction"); return nil },
+			wantErr: sql.ErrConnDone,
+		},
+		{
+			name: "commit failure",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit().WillReturnError(sql.ErrTxDone)
+			},
+			fn:      func(*sql.Tx) error { return nil },
+			wantErr: sql.ErrTxDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to create mock: %v", err)
+			}
+			defer db.Close()
+			tt.setup(mock)
+
+			err = WithTx(context.Background(), db, tt.fn)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestWithTx_RollsBackOnPanic(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	defer func() {
+		if p := recover(); p != "boom" {
+			t.Errorf("expected panic to be re-raised, got %v", p)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unfulfilled expectations: %v", err)
+		}
+	}()
+	_ = WithTx(context.Background(), db, func(*sql.Tx) error { panic("boom") })
+}
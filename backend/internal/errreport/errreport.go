@@ -0,0 +1,78 @@
+// Package errreport forwards unexpected server errors (5xx and panics) to an
+// external error tracker.
+//
+// Callers use the package-level Report, which goes to a no-op Reporter
+// until Setup installs Sentry (SENTRY_DSN set) or a test installs a fake via
+// SetDefault. Only identifying tags are attached; request and response
+// bodies, headers and keys are never reported.
+package errreport
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Tags identify where an error happened. Empty fields are omitted.
+type Tags struct {
+	RequestID string
+	OrgID     string
+	Route     string
+	Provider  string
+}
+
+// Map returns the non-empty tags keyed by their reported names.
+func (t Tags) Map() map[string]string {
+	m := make(map[string]string, 4)
+	set := func(k, v string) {
+		if v != "" {
+			m[k] = v
+		}
+	}
+	set("request_id", t.RequestID)
+	set("org_id", t.OrgID)
+	set("route", t.Route)
+	set("provider", t.Provider)
+	return m
+}
+
+// Reporter sends errors to an error tracker. Implementations must be safe
+// for concurrent use and must not block the caller on network I/O.
+type Reporter interface {
+	Report(err error, tags Tags)
+	// Flush waits up to timeout for queued reports to be sent.
+	Flush(timeout time.Duration)
+}
+
+// Nop is a Reporter that discards everything.
+type Nop struct{}
+
+func (Nop) Report(error, Tags)  {}
+func (Nop) Flush(time.Duration) {}
+
+// holder lets atomic.Value store Reporters of different concrete types.
+type holder struct{ r Reporter }
+
+var current atomic.Value
+
+func init() {
+	current.Store(holder{Nop{}})
+}
+
+// Default returns the installed Reporter.
+func Default() Reporter {
+	return current.Load().(holder).r
+}
+
+// SetDefault installs r as the Reporter used by Report. A nil r restores
+// the no-op default.
+func SetDefault(r Reporter) {
+	if r == nil {
+		r = Nop{}
+	}
+	current.Store(holder{r})
+}
+
+// Report sends err to the installed Reporter.
+func Report(err error, tags Tags) {
+	Default().Report(err, tags)
+}
@@ -0,0 +1,82 @@
+package errreport
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/getsentry/sentry-go"
+)
+
+func TestTags_Map(t *testing.T) {
+	got := Tags{RequestID: "req-1", Route: "GET /admin/orgs"}.Map()
+	if len(got) != 2 || got["request_id"] != "req-1" || got["route"] != "GET /admin/orgs" {
+		t.Errorf("expected only non-empty tags, got %v", got)
+	}
+}
+
+func TestSetDefault(t *testing.T) {
+	t.Cleanup(func() { SetDefault(nil) })
+
+	if _, ok := Default().(Nop); !ok {
+		t.Fatalf("expected no-op default, got %T", Default())
+	}
+	Report(errors.New("discarded"), Tags{}) // Must not panic
+
+	s := &Sentry{}
+	SetDefault(s)
+	if Default() != Reporter(s) {
+		t.Errorf("expected installed reporter, got %T", Default())
+	}
+	SetDefault(nil)
+	if _, ok := Default().(Nop); !ok {
+		t.Errorf("expected nil to restore the no-op default, got %T", Default())
+	}
+}
+
+func TestSetup(t *testing.T) {
+	t.Cleanup(func() { SetDefault(nil) })
+
+	t.Run("disabled without DSN", func(t *testing.T) {
+		t.Setenv("SENTRY_DSN", "")
+		flush, err := Setup("test")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		flush(0)
+		if _, ok := Default().(Nop); !ok {
+			t.Errorf("expected no-op default, got %T", Default())
+		}
+	})
+
+	t.Run("invalid DSN", func(t *testing.T) {
+		t.Setenv("SENTRY_DSN", "not a dsn")
+		if _, err := Setup("test"); err == nil {
+			t.Error("expected error for invalid DSN")
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		t.Setenv("SENTRY_DSN", "https://public@sentry.example.com/1")
+		if _, err := Setup("test"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, ok := Default().(*Sentry); !ok {
+			t.Errorf("expected Sentry reporter, got %T", Default())
+		}
+	})
+}
+
+func TestStripRequest(t *testing.T) {
+	event := &sentry.Event{
+		Request: &sentry.Request{Data: `{"messages": []}`, Headers: map[string]string{"Authorization": "Bearer np_secret"}},
+		User:    sentry.User{IPAddress: "10.0.0.1"},
+	}
+
+	got := stripRequest(event, nil)
+	if got.Request != nil {
+		t.Errorf("expected request data to be dropped, got %+v", got.Request)
+	}
+	if !got.User.IsEmpty() {
+		t.Errorf("expected user data to be dropped, got %+v", got.User)
+	}
+}
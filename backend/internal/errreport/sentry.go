@@ -0,0 +1,67 @@
+package errreport
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// Sentry reports errors through a Sentry client. Events are sent from the
+// SDK's background transport, so Report doesn't block on the network.
+type Sentry struct {
+	client *sentry.Client
+}
+
+// NewSentry creates a Sentry reporter for dsn.
+func NewSentry(dsn, environment string) (*Sentry, error) {
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:              dsn,
+		Environment:      environment,
+		AttachStacktrace: true,
+		SendDefaultPII:   false,
+		BeforeSend:       stripRequest,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Sentry{client: client}, nil
+}
+
+// Report captures err with tags.
+func (s *Sentry) Report(err error, tags Tags) {
+	scope := sentry.NewScope()
+	scope.SetTags(tags.Map())
+	s.client.CaptureException(err, nil, scope)
+}
+
+// Flush waits for buffered events to be delivered.
+func (s *Sentry) Flush(timeout time.Duration) {
+	s.client.Flush(timeout)
+}
+
+// stripRequest drops any request data an integration may have attached;
+// we only ever report tags.
+func stripRequest(event *sentry.Event, _ *sentry.EventHint) *sentry.Event {
+	event.Request = nil
+	event.User = sentry.User{}
+	return event
+}
+
+// Setup installs a Sentry reporter as the default when SENTRY_DSN is set.
+// The returned flush func must be called on exit. When SENTRY_DSN is unset,
+// Setup is a no-op and reports are discarded.
+func Setup(environment string) (func(time.Duration), error) {
+	dsn := os.Getenv("SENTRY_DSN")
+	if dsn == "" {
+		return func(time.Duration) {}, nil
+	}
+
+	reporter, err := NewSentry(dsn, environment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Sentry client: %w", err)
+	}
+	SetDefault(reporter)
+	return reporter.Flush, nil
+}
@@ -1,12 +1,19 @@
 package handler
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"errors"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"navplane/internal/audit"
+	"navplane/internal/httpjson"
+	"navplane/internal/middleware"
 	"navplane/internal/org"
 
 	"github.com/google/uuid"
@@ -25,11 +32,28 @@ func NewAdminOrgsHandler(manager *org.Manager) *AdminOrgsHandler {
 // orgResponse is the JSON response for an organization.
 // API key hash is never exposed.
 type orgResponse struct {
-	ID        string `json:"id"`
-	Name      string `json:"name"`
-	Enabled   bool   `json:"enabled"`
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Enabled   bool              `json:"enabled"`
+	Metadata  map[string]string `json:"metadata"`
+	CreatedAt string            `json:"created_at"`
+	UpdatedAt string            `json:"updated_at"`
+}
+
+// orgSummaryResponse is an organization with aggregate counts.
+// Returned by the list and detail endpoints.
+type orgSummaryResponse struct {
+	orgResponse
+	ProviderKeyCount       int `json:"provider_key_count"`
+	ActiveProviderKeyCount int `json:"active_provider_key_count"`
+}
+
+func toOrgSummaryResponse(s *org.OrgSummary) orgSummaryResponse {
+	return orgSummaryResponse{
+		orgResponse:            toOrgResponse(&s.Org),
+		ProviderKeyCount:       s.ProviderKeyCount,
+		ActiveProviderKeyCount: s.ActiveProviderKeyCount,
+	}
 }
 
 // createOrgResponse includes the API key (only on creation).
@@ -43,6 +67,7 @@ func toOrgResponse(o *org.Org) orgResponse {
 		ID:        o.ID.String(),
 		Name:      o.Name,
 		Enabled:   o.Enabled,
+		Metadata:  o.Metadata,
 		CreatedAt: o.CreatedAt.Format("2006-01-02T15:04:05Z"),
 		UpdatedAt: o.UpdatedAt.Format("2006-01-02T15:04:05Z"),
 	}
@@ -53,19 +78,18 @@ func (h *AdminOrgsHandler) List(w http.ResponseWriter, r *http.Request) {
 	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
 	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
 
-	orgs, err := h.manager.List(r.Context(), limit, offset)
+	orgs, err := h.manager.ListSummaries(r.Context(), limit, offset)
 	if err != nil {
-		log.Printf("failed to list organizations: %v", err)
-		writeAdminError(w, http.StatusInternalServerError, "failed to list organizations")
+		writeServerError(w, r, err, "failed to list organizations", "failed to list organizations")
 		return
 	}
 
-	response := make([]orgResponse, len(orgs))
+	response := make([]orgSummaryResponse, len(orgs))
 	for i, o := range orgs {
-		response[i] = toOrgResponse(o)
+		response[i] = toOrgSummaryResponse(o)
 	}
 
-	writeJSON(w, http.StatusOK, map[string]any{
+	httpjson.WriteJSON(w, http.StatusOK, map[string]any{
 		"organizations": response,
 		"count":         len(response),
 	})
@@ -75,22 +99,108 @@ func (h *AdminOrgsHandler) List(w http.ResponseWriter, r *http.Request) {
 func (h *AdminOrgsHandler) Get(w http.ResponseWriter, r *http.Request) {
 	id, err := parseOrgID(r)
 	if err != nil {
-		writeAdminError(w, http.StatusBadRequest, "invalid organization ID")
+		httpjson.WriteStatusError(w, http.StatusBadRequest, "invalid organization ID")
 		return
 	}
 
-	o, err := h.manager.GetByID(r.Context(), id)
+	s, err := h.manager.GetSummary(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, org.ErrNotFound) {
-			writeAdminError(w, http.StatusNotFound, "organization not found")
+			httpjson.WriteStatusError(w, http.StatusNotFound, "organization not found")
 			return
 		}
-		log.Printf("failed to get organization: %v", err)
-		writeAdminError(w, http.StatusInternalServerError, "failed to get organization")
+		writeServerError(w, r, err, "failed to get organization", "failed to get organization")
 		return
 	}
 
-	writeJSON(w, http.StatusOK, toOrgResponse(o))
+	httpjson.WriteJSON(w, http.StatusOK, toOrgSummaryResponse(s))
+}
+
+// exportCSVHeader is the header row for CSV exports.
+var exportCSVHeader = []string{"id", "name", "enabled", "provider_key_count", "active_provider_key_count", "created_at", "updated_at"}
+
+// Export handles GET /admin/orgs/export?format=json|csv
+// Streams every organization without pagination. JSON output is newline-delimited
+// (one object per line) so consumers can process it incrementally.
+func (h *AdminOrgsHandler) Export(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		httpjson.WriteStatusError(w, http.StatusBadRequest, "format must be json or csv")
+		return
+	}
+
+	var (
+		started bool
+		csvw    *csv.Writer
+		enc     *json.Encoder
+	)
+
+	// Headers are deferred until the first row so that a failure before any
+	// output can still be reported as a regular JSON error.
+	begin := func() error {
+		started = true
+		filename := "organizations-" + time.Now().UTC().Format("20060102") + "." + format
+		if format == "csv" {
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		} else {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		w.WriteHeader(http.StatusOK)
+
+		if format == "csv" {
+			csvw = csv.NewWriter(w)
+			return csvw.Write(exportCSVHeader)
+		}
+		enc = json.NewEncoder(w)
+		return nil
+	}
+
+	err := h.manager.Export(r.Context(), func(s *org.OrgSummary) error {
+		if !started {
+			if err := begin(); err != nil {
+				return err
+			}
+		}
+
+		resp := toOrgSummaryResponse(s)
+		if format == "csv" {
+			return csvw.Write([]string{
+				resp.ID,
+				resp.Name,
+				strconv.FormatBool(resp.Enabled),
+				strconv.Itoa(resp.ProviderKeyCount),
+				strconv.Itoa(resp.ActiveProviderKeyCount),
+				resp.CreatedAt,
+				resp.UpdatedAt,
+			})
+		}
+		return enc.Encode(resp)
+	})
+
+	if err == nil && !started {
+		// No organizations: still emit headers (and the CSV header row).
+		err = begin()
+	}
+	if err != nil {
+		if !started {
+			writeServerError(w, r, err, "failed to export organizations", "failed to export organizations")
+			return
+		}
+		// Response is already partially written; nothing to do but log.
+		slog.Error("organization export aborted", "error", err)
+		return
+	}
+
+	if csvw != nil {
+		csvw.Flush()
+		if err := csvw.Error(); err != nil {
+			slog.Error("failed to flush organization export", "error", err)
+		}
+	}
 }
 
 // createOrgRequest is the JSON request for creating an organization.
@@ -101,23 +211,26 @@ type createOrgRequest struct {
 // Create handles POST /admin/orgs
 func (h *AdminOrgsHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var req createOrgRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeAdminError(w, http.StatusBadRequest, "invalid JSON")
+	if err := httpjson.Decode(w, r, &req, httpjson.DecodeOptions{}); err != nil {
+		httpjson.WriteDecodeError(w, err)
 		return
 	}
 
 	result, err := h.manager.Create(r.Context(), req.Name)
 	if err != nil {
 		if errors.Is(err, org.ErrInvalidName) {
-			writeAdminError(w, http.StatusBadRequest, "name is required")
+			httpjson.WriteStatusError(w, http.StatusBadRequest, "name is required")
 			return
 		}
-		log.Printf("failed to create organization: %v", err)
-		writeAdminError(w, http.StatusInternalServerError, "failed to create organization")
+		if errors.Is(err, org.ErrNameTaken) {
+			httpjson.WriteStatusError(w, http.StatusConflict, "an organization with this name already exists")
+			return
+		}
+		writeServerError(w, r, err, "failed to create organization", "failed to create organization")
 		return
 	}
 
-	writeJSON(w, http.StatusCreated, createOrgResponse{
+	httpjson.WriteJSON(w, http.StatusCreated, createOrgResponse{
 		orgResponse: toOrgResponse(result.Org),
 		APIKey:      result.APIKey.Plaintext,
 	})
@@ -129,58 +242,101 @@ type updateOrgRequest struct {
 }
 
 // Update handles PUT /admin/orgs/{id}
+// PUT replaces the name and is kept for backward compatibility; it shares the
+// PATCH code path with only the name set.
 func (h *AdminOrgsHandler) Update(w http.ResponseWriter, r *http.Request) {
 	id, err := parseOrgID(r)
 	if err != nil {
-		writeAdminError(w, http.StatusBadRequest, "invalid organization ID")
+		httpjson.WriteStatusError(w, http.StatusBadRequest, "invalid organization ID")
 		return
 	}
 
 	var req updateOrgRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeAdminError(w, http.StatusBadRequest, "invalid JSON")
+	if err := httpjson.Decode(w, r, &req, httpjson.DecodeOptions{}); err != nil {
+		httpjson.WriteDecodeError(w, err)
+		return
+	}
+
+	h.applyUpdate(w, r, id, org.UpdateInput{Name: &req.Name})
+}
+
+// patchOrgRequest is the JSON request for partially updating an organization.
+// Pointer fields distinguish absent (or null) from zero values; absent fields
+// are left untouched.
+type patchOrgRequest struct {
+	Name     *string           `json:"name"`
+	Enabled  *bool             `json:"enabled"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// Patch handles PATCH /admin/orgs/{id}
+func (h *AdminOrgsHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	id, err := parseOrgID(r)
+	if err != nil {
+		httpjson.WriteStatusError(w, http.StatusBadRequest, "invalid organization ID")
+		return
+	}
+
+	// Unknown fields are rejected so a misspelled field isn't silently ignored
+	var req patchOrgRequest
+	if err := httpjson.Decode(w, r, &req, httpjson.DecodeOptions{DisallowUnknownFields: true}); err != nil {
+		httpjson.WriteDecodeError(w, err)
 		return
 	}
 
-	if err := h.manager.Update(r.Context(), id, req.Name); err != nil {
+	h.applyUpdate(w, r, id, org.UpdateInput{
+		Name:     req.Name,
+		Enabled:  req.Enabled,
+		Metadata: req.Metadata,
+	})
+}
+
+// applyUpdate runs an update through the manager and writes the resulting org.
+func (h *AdminOrgsHandler) applyUpdate(w http.ResponseWriter, r *http.Request, id uuid.UUID, input org.UpdateInput) {
+	if err := h.manager.Update(r.Context(), id, input); err != nil {
 		if errors.Is(err, org.ErrNotFound) {
-			writeAdminError(w, http.StatusNotFound, "organization not found")
+			httpjson.WriteStatusError(w, http.StatusNotFound, "organization not found")
 			return
 		}
 		if errors.Is(err, org.ErrInvalidName) {
-			writeAdminError(w, http.StatusBadRequest, "name is required")
+			httpjson.WriteStatusError(w, http.StatusBadRequest, "name is required")
 			return
 		}
-		log.Printf("failed to update organization: %v", err)
-		writeAdminError(w, http.StatusInternalServerError, "failed to update organization")
+		if errors.Is(err, org.ErrNameTaken) {
+			httpjson.WriteStatusError(w, http.StatusConflict, "an organization with this name already exists")
+			return
+		}
+		if errors.Is(err, org.ErrInvalidMetadata) {
+			httpjson.WriteStatusError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeServerError(w, r, err, "failed to update organization", "failed to update organization")
 		return
 	}
 
 	o, err := h.manager.GetByID(r.Context(), id)
 	if err != nil {
-		log.Printf("failed to get updated organization: %v", err)
-		writeAdminError(w, http.StatusInternalServerError, "failed to get organization")
+		writeServerError(w, r, err, "failed to get updated organization", "failed to get organization")
 		return
 	}
 
-	writeJSON(w, http.StatusOK, toOrgResponse(o))
+	httpjson.WriteJSON(w, http.StatusOK, toOrgResponse(o))
 }
 
 // Delete handles DELETE /admin/orgs/{id}
 func (h *AdminOrgsHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id, err := parseOrgID(r)
 	if err != nil {
-		writeAdminError(w, http.StatusBadRequest, "invalid organization ID")
+		httpjson.WriteStatusError(w, http.StatusBadRequest, "invalid organization ID")
 		return
 	}
 
 	if err := h.manager.Delete(r.Context(), id); err != nil {
 		if errors.Is(err, org.ErrNotFound) {
-			writeAdminError(w, http.StatusNotFound, "organization not found")
+			httpjson.WriteStatusError(w, http.StatusNotFound, "organization not found")
 			return
 		}
-		log.Printf("failed to delete organization: %v", err)
-		writeAdminError(w, http.StatusInternalServerError, "failed to delete organization")
+		writeServerError(w, r, err, "failed to delete organization", "failed to delete organization")
 		return
 	}
 
@@ -196,13 +352,13 @@ type setEnabledRequest struct {
 func (h *AdminOrgsHandler) SetEnabled(w http.ResponseWriter, r *http.Request) {
 	id, err := parseOrgID(r)
 	if err != nil {
-		writeAdminError(w, http.StatusBadRequest, "invalid organization ID")
+		httpjson.WriteStatusError(w, http.StatusBadRequest, "invalid organization ID")
 		return
 	}
 
 	var req setEnabledRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeAdminError(w, http.StatusBadRequest, "invalid JSON")
+	if err := httpjson.Decode(w, r, &req, httpjson.DecodeOptions{}); err != nil {
+		httpjson.WriteDecodeError(w, err)
 		return
 	}
 
@@ -214,45 +370,200 @@ func (h *AdminOrgsHandler) SetEnabled(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		if errors.Is(err, org.ErrNotFound) {
-			writeAdminError(w, http.StatusNotFound, "organization not found")
+			httpjson.WriteStatusError(w, http.StatusNotFound, "organization not found")
 			return
 		}
-		log.Printf("failed to set organization enabled state: %v", err)
-		writeAdminError(w, http.StatusInternalServerError, "failed to update organization")
+		writeServerError(w, r, err, "failed to set organization enabled state", "failed to update organization")
 		return
 	}
 
 	o, err := h.manager.GetByID(r.Context(), id)
 	if err != nil {
-		log.Printf("failed to get updated organization: %v", err)
-		writeAdminError(w, http.StatusInternalServerError, "failed to get organization")
+		writeServerError(w, r, err, "failed to get updated organization", "failed to get organization")
+		return
+	}
+
+	httpjson.WriteJSON(w, http.StatusOK, toOrgResponse(o))
+}
+
+// keyLookupResponse is an API key found by GET /admin/orgs/lookup.
+type keyLookupResponse struct {
+	Organization orgResponse `json:"organization"`
+	Key          keyResponse `json:"key"`
+}
+
+// keyResponse describes a matched key. The hash is never exposed.
+type keyResponse struct {
+	Prefix     string  `json:"prefix"`
+	Current    bool    `json:"current"` // False for the key replaced by the last rotation
+	Status     string  `json:"status"`  // active, expired or revoked
+	LastUsedAt *string `json:"last_used_at"`
+	ExpiresAt  *string `json:"expires_at,omitempty"`
+}
+
+func toKeyLookupResponse(m *org.KeyMatch, now time.Time) keyLookupResponse {
+	formatTime := func(t *time.Time) *string {
+		if t == nil {
+			return nil
+		}
+		s := t.UTC().Format(time.RFC3339)
+		return &s
+	}
+	return keyLookupResponse{
+		Organization: toOrgResponse(m.Org),
+		Key: keyResponse{
+			Prefix:     m.Prefix,
+			Current:    !m.Previous,
+			Status:     m.Status(now),
+			LastUsedAt: formatTime(m.LastUsedAt),
+			ExpiresAt:  formatTime(m.ExpiresAt),
+		},
+	}
+}
+
+// Lookup handles GET /admin/orgs/lookup?key_prefix=np_abc12345 and
+// ?key_hash=<sha256 hex>, finding which organization a key belongs to
+// without its plaintext. key_hash is refused once API_KEY_PEPPER is set;
+// LookupKey takes the full key instead. Every lookup is an audit event.
+func (h *AdminOrgsHandler) Lookup(w http.ResponseWriter, r *http.Request) {
+	prefix, hash := r.URL.Query().Get("key_prefix"), r.URL.Query().Get("key_hash")
+	if (prefix == "") == (hash == "") {
+		httpjson.WriteStatusError(w, http.StatusBadRequest, "exactly one of key_prefix or key_hash is required")
+		return
+	}
+
+	if prefix != "" {
+		query := prefix
+		if len(query) > org.KeyPrefixLength {
+			query = query[:org.KeyPrefixLength] // Never log more than a prefix of a pasted key
+		}
+		matches, err := h.manager.LookupByKeyPrefix(r.Context(), prefix)
+		h.writeLookup(w, r, "key_prefix", query, matches, err)
+		return
+	}
+	query := hash
+	if len(query) > 8 {
+		query = query[:8]
+	}
+	matches, err := h.manager.LookupByKeyHash(r.Context(), hash)
+	h.writeLookup(w, r, "key_hash", query, matches, err)
+}
+
+// lookupKeyRequest is the JSON body for POST /admin/orgs/lookup.
+type lookupKeyRequest struct {
+	Key string `json:"key"`
+}
+
+// LookupKey handles POST /admin/orgs/lookup with {"key": "np_..."}: the
+// full key is hashed server-side, so it matches keys stored under the
+// pepper too. It travels in the body so it stays out of URLs and access
+// logs, and only its prefix is logged.
+func (h *AdminOrgsHandler) LookupKey(w http.ResponseWriter, r *http.Request) {
+	var req lookupKeyRequest
+	if err := httpjson.Decode(w, r, &req, httpjson.DecodeOptions{DisallowUnknownFields: true}); err != nil {
+		httpjson.WriteDecodeError(w, err)
+		return
+	}
+	if req.Key == "" {
+		httpjson.WriteStatusError(w, http.StatusBadRequest, "key is required")
+		return
+	}
+
+	query := strings.TrimSpace(req.Key)
+	if len(query) > org.KeyPrefixLength {
+		query = query[:org.KeyPrefixLength]
+	}
+	matches, err := h.manager.LookupByKey(r.Context(), req.Key)
+	h.writeLookup(w, r, "key", query, matches, err)
+}
+
+// writeLookup records a key lookup as an audit event and writes its
+// result. query is the part of the search term that is safe to log.
+func (h *AdminOrgsHandler) writeLookup(w http.ResponseWriter, r *http.Request, by, query string, matches []*org.KeyMatch, err error) {
+	actor := "unknown" // Only when called outside AdminAuth, as in tests
+	if admin := middleware.GetAdmin(r.Context()); admin != nil {
+		actor = admin.String()
+	}
+	audit.Record(r.Context(), audit.Event{
+		Action:   audit.ActionKeyLookup,
+		Actor:    actor,
+		ClientIP: middleware.ClientIP(r),
+		Details: []slog.Attr{
+			slog.String("by", by),
+			slog.String("query", query),
+			slog.Int("matches", len(matches)),
+			slog.Bool("failed", err != nil),
+		},
+	})
+	if err != nil {
+		if errors.Is(err, org.ErrInvalidKeyPrefix) || errors.Is(err, org.ErrInvalidKeyHash) ||
+			errors.Is(err, org.ErrKeyHashPeppered) || errors.Is(err, org.ErrInvalidKey) {
+			httpjson.WriteStatusError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeServerError(w, r, err, "failed to look up API key", "failed to look up API key")
 		return
 	}
 
-	writeJSON(w, http.StatusOK, toOrgResponse(o))
+	now := time.Now()
+	response := make([]keyLookupResponse, len(matches))
+	for i, m := range matches {
+		response[i] = toKeyLookupResponse(m, now)
+	}
+
+	httpjson.WriteJSON(w, http.StatusOK, map[string]any{
+		"matches": response,
+		"count":   len(response),
+	})
+}
+
+// rotateKeyRequest is the optional JSON body for rotating an API key.
+type rotateKeyRequest struct {
+	// GracePeriod keeps the old key working this long, as a Go duration
+	// such as "24h". Omitted or zero invalidates it immediately.
+	GracePeriod string `json:"grace_period"`
 }
 
 // RotateAPIKey handles POST /admin/orgs/{id}/rotate-key
 func (h *AdminOrgsHandler) RotateAPIKey(w http.ResponseWriter, r *http.Request) {
 	id, err := parseOrgID(r)
 	if err != nil {
-		writeAdminError(w, http.StatusBadRequest, "invalid organization ID")
+		httpjson.WriteStatusError(w, http.StatusBadRequest, "invalid organization ID")
+		return
+	}
+
+	// The body is optional; existing clients send none
+	var req rotateKeyRequest
+	err = httpjson.Decode(w, r, &req, httpjson.DecodeOptions{DisallowUnknownFields: true})
+	if err != nil && !errors.Is(err, httpjson.ErrEmptyBody) {
+		httpjson.WriteDecodeError(w, err)
 		return
 	}
+	var grace time.Duration
+	if req.GracePeriod != "" {
+		if grace, err = time.ParseDuration(req.GracePeriod); err != nil {
+			httpjson.WriteStatusError(w, http.StatusBadRequest, `grace_period must be a duration such as "24h"`)
+			return
+		}
+	}
 
-	newKey, err := h.manager.RotateAPIKey(r.Context(), id)
+	result, err := h.manager.RotateAPIKeyWithGrace(r.Context(), id, grace)
 	if err != nil {
+		if errors.Is(err, org.ErrInvalidGracePeriod) {
+			httpjson.WriteStatusError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		if errors.Is(err, org.ErrNotFound) {
-			writeAdminError(w, http.StatusNotFound, "organization not found")
+			httpjson.WriteStatusError(w, http.StatusNotFound, "organization not found")
 			return
 		}
-		log.Printf("failed to rotate API key: %v", err)
-		writeAdminError(w, http.StatusInternalServerError, "failed to rotate API key")
+		writeServerError(w, r, err, "failed to rotate API key", "failed to rotate API key")
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]string{
-		"api_key": newKey.Plaintext,
+	httpjson.WriteJSON(w, http.StatusOK, map[string]string{
+		"api_key":                 result.APIKey.Plaintext,
+		"previous_key_expires_at": result.PreviousKeyExpiresAt.UTC().Format(time.RFC3339),
 	})
 }
 
@@ -264,21 +575,3 @@ func parseOrgID(r *http.Request) (uuid.UUID, error) {
 	}
 	return uuid.Parse(idStr)
 }
-
-// writeAdminError writes a JSON error response.
-func writeAdminError(w http.ResponseWriter, status int, message string) {
-	writeJSON(w, status, map[string]any{
-		"error": map[string]string{
-			"message": message,
-		},
-	})
-}
-
-// writeJSON writes a JSON response with the given status code.
-func writeJSON(w http.ResponseWriter, status int, data any) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	if err := json.NewEncoder(w).Encode(data); err != nil {
-		log.Printf("failed to encode JSON response: %v", err)
-	}
-}
@@ -3,18 +3,32 @@ package handler
 import (
 	"bytes"
 	"context"
+	"database/sql/driver"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"navplane/internal/logging"
+	"navplane/internal/middleware"
 	"navplane/internal/org"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+// orgRowColumns is the column set returned by organization SELECT queries.
+var orgRowColumns = []string{"id", "name", "api_key_hash", "enabled", "created_at", "updated_at", "metadata", "key_hash_version"}
+
+// summaryRowColumns is the column set returned by organization aggregate queries.
+var summaryRowColumns = append(append([]string{}, orgRowColumns...), "provider_key_count", "active_provider_key_count")
+
 func setupAdminTest(t *testing.T) (*AdminOrgsHandler, sqlmock.Sqlmock, func()) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -33,11 +47,12 @@ func TestAdminOrgsHandler_List(t *testing.T) {
 	id1, id2 := uuid.New(), uuid.New()
 	now := time.Now()
 
-	rows := sqlmock.NewRows([]string{"id", "name", "api_key_hash", "enabled", "created_at", "updated_at"}).
-		AddRow(id1, "Org 1", "hash1", true, now, now).
-		AddRow(id2, "Org 2", "hash2", false, now, now)
+	rows := sqlmock.NewRows(summaryRowColumns).
+		AddRow(id1, "Org 1", "hash1", true, now, now, []byte(`{}`), 1, 3, 2).
+		AddRow(id2, "Org 2", "hash2", false, now, now, []byte(`{}`), 1, 0, 0)
 
-	mock.ExpectQuery(`SELECT .+ FROM organizations`).
+	// One aggregate query for the whole page, no per-org lookups
+	mock.ExpectQuery(`SELECT .+ COUNT\(pk.id\) .+ FROM organizations o LEFT JOIN provider_keys pk ON pk.org_id = o.id GROUP BY o.id`).
 		WithArgs(20, 0).
 		WillReturnRows(rows)
 
@@ -57,7 +72,15 @@ func TestAdminOrgsHandler_List(t *testing.T) {
 
 	orgs := response["organizations"].([]any)
 	if len(orgs) != 2 {
-		t.Errorf("expected 2 orgs, got %d", len(orgs))
+		t.Fatalf("expected 2 orgs, got %d", len(orgs))
+	}
+	first := orgs[0].(map[string]any)
+	if first["provider_key_count"] != float64(3) || first["active_provider_key_count"] != float64(2) {
+		t.Errorf("unexpected counts: %v", first)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
 	}
 }
 
@@ -68,10 +91,10 @@ func TestAdminOrgsHandler_Get(t *testing.T) {
 	id := uuid.New()
 	now := time.Now()
 
-	rows := sqlmock.NewRows([]string{"id", "name", "api_key_hash", "enabled", "created_at", "updated_at"}).
-		AddRow(id, "Test Org", "hash123", true, now, now)
+	rows := sqlmock.NewRows(summaryRowColumns).
+		AddRow(id, "Test Org", "hash123", true, now, now, []byte(`{}`), 1, 2, 1)
 
-	mock.ExpectQuery(`SELECT .+ FROM organizations WHERE id = \$1`).
+	mock.ExpectQuery(`SELECT .+ FROM organizations o LEFT JOIN provider_keys pk .+ WHERE o.id = \$1 GROUP BY o.id`).
 		WithArgs(id).
 		WillReturnRows(rows)
 
@@ -85,7 +108,7 @@ func TestAdminOrgsHandler_Get(t *testing.T) {
 		t.Errorf("expected status 200, got %d", rec.Code)
 	}
 
-	var response orgResponse
+	var response orgSummaryResponse
 	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
 		t.Fatalf("failed to decode response: %v", err)
 	}
@@ -93,6 +116,9 @@ func TestAdminOrgsHandler_Get(t *testing.T) {
 	if response.Name != "Test Org" {
 		t.Errorf("expected name 'Test Org', got %q", response.Name)
 	}
+	if response.ProviderKeyCount != 2 || response.ActiveProviderKeyCount != 1 {
+		t.Errorf("expected counts 2/1, got %d/%d", response.ProviderKeyCount, response.ActiveProviderKeyCount)
+	}
 }
 
 func TestAdminOrgsHandler_Get_NotFound(t *testing.T) {
@@ -101,9 +127,9 @@ func TestAdminOrgsHandler_Get_NotFound(t *testing.T) {
 
 	id := uuid.New()
 
-	mock.ExpectQuery(`SELECT .+ FROM organizations WHERE id = \$1`).
+	mock.ExpectQuery(`SELECT .+ FROM organizations o .+ WHERE o.id = \$1`).
 		WithArgs(id).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "api_key_hash", "enabled", "created_at", "updated_at"}))
+		WillReturnRows(sqlmock.NewRows(summaryRowColumns))
 
 	req := httptest.NewRequest(http.MethodGet, "/admin/orgs/"+id.String(), nil)
 	req.SetPathValue("id", id.String())
@@ -123,7 +149,7 @@ func TestAdminOrgsHandler_Create(t *testing.T) {
 	now := time.Now()
 
 	mock.ExpectQuery(`INSERT INTO organizations`).
-		WithArgs(sqlmock.AnyArg(), "New Org", sqlmock.AnyArg(), true, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), "New Org", sqlmock.AnyArg(), true, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at"}).AddRow(now, now))
 
 	body := bytes.NewBufferString(`{"name": "New Org"}`)
@@ -177,8 +203,8 @@ func TestAdminOrgsHandler_SetEnabled_Disable(t *testing.T) {
 
 	mock.ExpectQuery(`SELECT .+ FROM organizations WHERE id = \$1`).
 		WithArgs(id).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "api_key_hash", "enabled", "created_at", "updated_at"}).
-			AddRow(id, "Test Org", "hash123", false, now, now))
+		WillReturnRows(sqlmock.NewRows(orgRowColumns).
+			AddRow(id, "Test Org", "hash123", false, now, now, []byte(`{}`), 1))
 
 	body := bytes.NewBufferString(`{"enabled": false}`)
 	req := httptest.NewRequest(http.MethodPut, "/admin/orgs/"+id.String()+"/enabled", body)
@@ -214,8 +240,8 @@ func TestAdminOrgsHandler_SetEnabled_Enable(t *testing.T) {
 
 	mock.ExpectQuery(`SELECT .+ FROM organizations WHERE id = \$1`).
 		WithArgs(id).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "api_key_hash", "enabled", "created_at", "updated_at"}).
-			AddRow(id, "Test Org", "hash123", true, now, now))
+		WillReturnRows(sqlmock.NewRows(orgRowColumns).
+			AddRow(id, "Test Org", "hash123", true, now, now, []byte(`{}`), 1))
 
 	body := bytes.NewBufferString(`{"enabled": true}`)
 	req := httptest.NewRequest(http.MethodPut, "/admin/orgs/"+id.String()+"/enabled", body)
@@ -287,16 +313,19 @@ func TestAdminOrgsHandler_RotateAPIKey(t *testing.T) {
 	id := uuid.New()
 	now := time.Now()
 
-	// GetByID call
-	mock.ExpectQuery(`SELECT .+ FROM organizations WHERE id = \$1`).
+	// Locked read and update in one transaction
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT .+ FROM organizations WHERE id = \$1 FOR UPDATE`).
 		WithArgs(id).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "api_key_hash", "enabled", "created_at", "updated_at"}).
-			AddRow(id, "Test Org", "hash123", true, now, now))
-
-	// Update call
-	mock.ExpectExec(`UPDATE organizations`).
-		WithArgs(id, "Test Org", sqlmock.AnyArg(), true).
+		WillReturnRows(sqlmock.NewRows(orgRowColumns).
+			AddRow(id, "Test Org", "hash123", true, now, now, []byte(`{}`), 1))
+	mock.ExpectExec(`UPDATE organizations SET previous_key_hash`).
+		WithArgs(id, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE organizations SET name`).
+		WithArgs(id, "Test Org", sqlmock.AnyArg(), true, sqlmock.AnyArg(), org.KeyHashSHA256).
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
 
 	req := httptest.NewRequest(http.MethodPost, "/admin/orgs/"+id.String()+"/rotate-key", nil)
 	req.SetPathValue("id", id.String())
@@ -316,6 +345,265 @@ func TestAdminOrgsHandler_RotateAPIKey(t *testing.T) {
 	if response["api_key"] == "" {
 		t.Error("expected API key to be returned")
 	}
+	expiresAt, err := time.Parse(time.RFC3339, response["previous_key_expires_at"])
+	if err != nil || expiresAt.After(time.Now().Add(time.Second)) {
+		t.Errorf("expected the old key to expire immediately, got %q", response["previous_key_expires_at"])
+	}
+}
+
+func TestAdminOrgsHandler_RotateAPIKey_GracePeriod(t *testing.T) {
+	id := uuid.New()
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantGrace  time.Duration
+	}{
+		{name: "grace period", body: `{"grace_period": "24h"}`, wantStatus: http.StatusOK, wantGrace: 24 * time.Hour},
+		{name: "zero grace period", body: `{"grace_period": "0s"}`, wantStatus: http.StatusOK},
+		{name: "not a duration", body: `{"grace_period": "tomorrow"}`, wantStatus: http.StatusBadRequest},
+		{name: "negative", body: `{"grace_period": "-1h"}`, wantStatus: http.StatusBadRequest},
+		{name: "too long", body: `{"grace_period": "721h"}`, wantStatus: http.StatusBadRequest},
+		{name: "unknown field", body: `{"grace": "24h"}`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, mock, cleanup := setupAdminTest(t)
+			defer cleanup()
+
+			if tt.wantStatus == http.StatusOK {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT .+ FROM organizations WHERE id = \$1 FOR UPDATE`).
+					WithArgs(id).
+					WillReturnRows(sqlmock.NewRows(orgRowColumns).
+						AddRow(id, "Test Org", "hash123", true, now, now, []byte(`{}`), 1))
+				mock.ExpectExec(`UPDATE organizations SET previous_key_hash`).
+					WithArgs(id, sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`UPDATE organizations SET name`).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/orgs/"+id.String()+"/rotate-key", strings.NewReader(tt.body))
+			req.SetPathValue("id", id.String())
+			rec := httptest.NewRecorder()
+
+			handler.RotateAPIKey(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK {
+				var response map[string]string
+				if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				expiresAt, err := time.Parse(time.RFC3339, response["previous_key_expires_at"])
+				if err != nil {
+					t.Fatalf("expected previous_key_expires_at, got %q", response["previous_key_expires_at"])
+				}
+				if d := time.Until(expiresAt) - tt.wantGrace; d < -time.Minute || d > time.Minute {
+					t.Errorf("expected the old key to expire in %v, got %s", tt.wantGrace, expiresAt)
+				}
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestAdminOrgsHandler_Lookup(t *testing.T) {
+	handler, mock, cleanup := setupAdminTest(t)
+	defer cleanup()
+
+	id := uuid.New()
+	now := time.Now()
+	expired := now.Add(-time.Hour)
+
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logging.New(&buf, slog.LevelInfo, logging.FormatJSON))
+	defer slog.SetDefault(previous)
+
+	columns := append(append([]string{}, orgRowColumns...), "previous", "prefix", "last_used_at", "expires_at")
+	mock.ExpectQuery(`UNION ALL`).
+		WithArgs(`np\_0123abcd%`, 20).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(id, "Test Org", "hash1", true, now, now, []byte(`{}`), 1, false, "np_0123abcd", now, nil).
+			AddRow(id, "Test Org", "hash2", true, now, now, []byte(`{}`), 1, true, "np_0123abcd", nil, expired))
+
+	// A pasted full key is cut to its prefix before it reaches the log
+	req := httptest.NewRequest(http.MethodGet, "/admin/orgs/lookup?key_prefix=np_0123abcd-ef01-4000-8000-000000000000", nil)
+	req.Header.Set("Authorization", "Bearer "+testAdminAPIKey)
+	rec := httptest.NewRecorder()
+	middleware.AdminAuth(testAdminAPIKey)(http.HandlerFunc(handler.Lookup)).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var response struct {
+		Matches []keyLookupResponse `json:"matches"`
+		Count   int                 `json:"count"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.Count != 2 || len(response.Matches) != 2 {
+		t.Fatalf("expected 2 matches, got %+v", response)
+	}
+	current, old := response.Matches[0].Key, response.Matches[1].Key
+	if !current.Current || current.Status != org.KeyStatusActive || current.LastUsedAt == nil {
+		t.Errorf("unexpected current key: %+v", current)
+	}
+	if old.Current || old.Status != org.KeyStatusExpired || old.LastUsedAt != nil || old.ExpiresAt == nil {
+		t.Errorf("unexpected previous key: %+v", old)
+	}
+	if response.Matches[0].Organization.ID != id.String() {
+		t.Errorf("expected organization %s, got %s", id, response.Matches[0].Organization.ID)
+	}
+	if strings.Contains(rec.Body.String(), "hash1") {
+		t.Error("expected the key hash to be omitted from the response")
+	}
+
+	logged := buf.String()
+	for _, want := range []string{`"msg":"audit"`, `"action":"admin.key_lookup"`, `"actor":"key:`, `"query":"np_0123abcd"`, `"matches":2`} {
+		if !strings.Contains(logged, want) {
+			t.Errorf("expected an audit event with %s, got:\n%s", want, logged)
+		}
+	}
+	if strings.Contains(logged, testAdminAPIKey) {
+		t.Errorf("expected the audit event to hold only a fingerprint of the admin key, got:\n%s", logged)
+	}
+	if strings.Contains(logged, "ef01") {
+		t.Errorf("expected the log to omit the rest of the key, got:\n%s", logged)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdminOrgsHandler_Lookup_BadRequest(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "neither", query: ""},
+		{name: "both", query: "?key_prefix=np_0123abcd&key_hash=" + strings.Repeat("a", 64)},
+		{name: "short prefix", query: "?key_prefix=np_01"},
+		{name: "invalid hash", query: "?key_hash=abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, mock, cleanup := setupAdminTest(t)
+			defer cleanup()
+
+			rec := httptest.NewRecorder()
+			handler.Lookup(rec, httptest.NewRequest(http.MethodGet, "/admin/orgs/lookup"+tt.query, nil))
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d: %s", rec.Code, rec.Body.String())
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unexpected queries: %v", err)
+			}
+		})
+	}
+}
+
+func TestAdminOrgsHandler_LookupKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+	manager := org.NewManager(org.NewDatastore(db))
+	manager.SetAPIKeyPepper("test-pepper")
+	handler := NewAdminOrgsHandler(manager)
+
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logging.New(&buf, slog.LevelInfo, logging.FormatJSON))
+	defer slog.SetDefault(previous)
+
+	const key = "np_0123abcd-ef01-4000-8000-000000000000"
+	id, now := uuid.New(), time.Now()
+	hmacHash := org.HashAPIKeyHMAC(key, []byte("test-pepper"))
+	columns := append(append([]string{}, orgRowColumns...), "previous", "prefix", "last_used_at", "expires_at")
+	mock.ExpectQuery(`UNION ALL`).
+		WithArgs(hmacHash, 20).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(id, "Test Org", hmacHash, true, now, now, []byte(`{}`), 2, false, "np_0123abcd", now, nil))
+	mock.ExpectQuery(`UNION ALL`).
+		WithArgs(org.HashAPIKey(key), 20).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	rec := httptest.NewRecorder()
+	handler.LookupKey(rec, httptest.NewRequest(http.MethodPost, "/admin/orgs/lookup", strings.NewReader(`{"key": "`+key+`"}`)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var response struct {
+		Matches []keyLookupResponse `json:"matches"`
+		Count   int                 `json:"count"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.Count != 1 || response.Matches[0].Organization.ID != id.String() {
+		t.Fatalf("expected the peppered key to match, got %+v", response)
+	}
+	if logged := buf.String(); strings.Contains(logged, "ef01") || !strings.Contains(logged, `"query":"np_0123abcd"`) {
+		t.Errorf("expected the log to hold only the key prefix, got:\n%s", logged)
+	}
+
+	// A client-side hash can't match once a pepper is set
+	rec = httptest.NewRecorder()
+	handler.Lookup(rec, httptest.NewRequest(http.MethodGet, "/admin/orgs/lookup?key_hash="+org.HashAPIKey(key), nil))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "full key") {
+		t.Errorf("expected a 400 pointing at the full-key lookup, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdminOrgsHandler_LookupKey_BadRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "empty body", body: ""},
+		{name: "missing key", body: `{}`},
+		{name: "not a NavPlane key", body: `{"key": "sk-0123456789"}`},
+		{name: "unknown field", body: `{"key": "np_0123abcd", "key_hash": "x"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, mock, cleanup := setupAdminTest(t)
+			defer cleanup()
+
+			rec := httptest.NewRecorder()
+			handler.LookupKey(rec, httptest.NewRequest(http.MethodPost, "/admin/orgs/lookup", strings.NewReader(tt.body)))
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d: %s", rec.Code, rec.Body.String())
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unexpected queries: %v", err)
+			}
+		})
+	}
 }
 
 func TestParseOrgID_Invalid(t *testing.T) {
@@ -337,3 +625,306 @@ func TestParseOrgID_Missing(t *testing.T) {
 		t.Error("expected error for missing ID")
 	}
 }
+
+func exportRows() *sqlmock.Rows {
+	return sqlmock.NewRows(summaryRowColumns)
+}
+
+func TestAdminOrgsHandler_Export_CSV(t *testing.T) {
+	handler, mock, cleanup := setupAdminTest(t)
+	defer cleanup()
+
+	id1, id2 := uuid.New(), uuid.New()
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT .+ FROM organizations o LEFT JOIN provider_keys`).
+		WillReturnRows(exportRows().
+			AddRow(id1, `Acme, "Inc"`, "hash1", true, now, now, []byte(`{}`), 1, 3, 3).
+			AddRow(id2, "Beta", "hash2", false, now, now, []byte(`{}`), 1, 0, 0))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/orgs/export?format=csv", nil)
+	rec := httptest.NewRecorder()
+
+	handler.Export(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("expected text/csv content type, got %q", ct)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, "attachment; filename=") || !strings.HasSuffix(cd, `.csv"`) {
+		t.Errorf("unexpected Content-Disposition: %q", cd)
+	}
+
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse CSV: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected header + 2 rows, got %d", len(records))
+	}
+	if strings.Join(records[0], ",") != "id,name,enabled,provider_key_count,active_provider_key_count,created_at,updated_at" {
+		t.Errorf("unexpected header row: %v", records[0])
+	}
+	if records[1][0] != id1.String() || records[1][1] != `Acme, "Inc"` || records[1][3] != "3" {
+		t.Errorf("unexpected first row: %v", records[1])
+	}
+	if records[2][2] != "false" {
+		t.Errorf("expected enabled=false for second row, got %q", records[2][2])
+	}
+}
+
+func TestAdminOrgsHandler_Export_JSON(t *testing.T) {
+	handler, mock, cleanup := setupAdminTest(t)
+	defer cleanup()
+
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT .+ FROM organizations o LEFT JOIN provider_keys`).
+		WillReturnRows(exportRows().
+			AddRow(uuid.New(), "Org 1", "hash1", true, now, now, []byte(`{}`), 1, 1, 1).
+			AddRow(uuid.New(), "Org 2", "hash2", true, now, now, []byte(`{}`), 1, 2, 2))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/orgs/export", nil)
+	rec := httptest.NewRecorder()
+
+	handler.Export(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("expected application/x-ndjson, got %q", ct)
+	}
+
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+	var first orgSummaryResponse
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("failed to decode line: %v", err)
+	}
+	if first.Name != "Org 1" || first.ProviderKeyCount != 1 {
+		t.Errorf("unexpected first line: %+v", first)
+	}
+	if strings.Contains(rec.Body.String(), "hash1") {
+		t.Error("export must not include API key hashes")
+	}
+}
+
+func TestAdminOrgsHandler_Export_Empty(t *testing.T) {
+	handler, mock, cleanup := setupAdminTest(t)
+	defer cleanup()
+
+	mock.ExpectQuery(`SELECT .+ FROM organizations o`).WillReturnRows(exportRows())
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/orgs/export?format=csv", nil)
+	rec := httptest.NewRecorder()
+
+	handler.Export(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if strings.TrimSpace(rec.Body.String()) != "id,name,enabled,provider_key_count,active_provider_key_count,created_at,updated_at" {
+		t.Errorf("expected only header row, got %q", rec.Body.String())
+	}
+}
+
+func TestAdminOrgsHandler_Export_InvalidFormat(t *testing.T) {
+	handler, _, cleanup := setupAdminTest(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/orgs/export?format=xml", nil)
+	rec := httptest.NewRecorder()
+
+	handler.Export(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rec.Code)
+	}
+}
+
+func TestAdminOrgsHandler_Export_DatabaseError(t *testing.T) {
+	handler, mock, cleanup := setupAdminTest(t)
+	defer cleanup()
+
+	mock.ExpectQuery(`SELECT .+ FROM organizations o`).WillReturnError(errors.New("connection refused"))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/orgs/export?format=json", nil)
+	rec := httptest.NewRecorder()
+
+	handler.Export(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected JSON error, got content type %q", ct)
+	}
+}
+
+func TestAdminOrgsHandler_Patch(t *testing.T) {
+	id := uuid.New()
+	now := time.Now()
+
+	tests := []struct {
+		name         string
+		body         string
+		expectUpdate []any // nil means no UPDATE is expected
+		wantStatus   int
+	}{
+		{
+			name:         "name only",
+			body:         `{"name": "Renamed"}`,
+			expectUpdate: []any{id, "Renamed", "hash123", true, []byte(`{"tier":"gold"}`), 1},
+			wantStatus:   http.StatusOK,
+		},
+		{
+			name:         "enabled only leaves name untouched",
+			body:         `{"enabled": false}`,
+			expectUpdate: []any{id, "Test Org", "hash123", false, []byte(`{"tier":"gold"}`), 1},
+			wantStatus:   http.StatusOK,
+		},
+		{
+			name:         "metadata replaced",
+			body:         `{"metadata": {"tier": "silver"}}`,
+			expectUpdate: []any{id, "Test Org", "hash123", true, []byte(`{"tier":"silver"}`), 1},
+			wantStatus:   http.StatusOK,
+		},
+		{
+			name:         "explicit null is treated as absent",
+			body:         `{"name": null, "enabled": null}`,
+			expectUpdate: nil,
+			wantStatus:   http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, mock, cleanup := setupAdminTest(t)
+			defer cleanup()
+
+			current := func() *sqlmock.Rows {
+				return sqlmock.NewRows(orgRowColumns).
+					AddRow(id, "Test Org", "hash123", true, now, now, []byte(`{"tier":"gold"}`), 1)
+			}
+
+			if tt.expectUpdate != nil {
+				args := make([]driver.Value, len(tt.expectUpdate))
+				for i, a := range tt.expectUpdate {
+					args[i] = a
+				}
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT .+ FROM organizations WHERE id = \$1 FOR UPDATE`).
+					WithArgs(id).
+					WillReturnRows(current())
+				mock.ExpectExec(`UPDATE organizations`).
+					WithArgs(args...).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectQuery(`SELECT .+ FROM organizations WHERE id = \$1`).
+					WithArgs(id).
+					WillReturnRows(current())
+			}
+			mock.ExpectQuery(`SELECT .+ FROM organizations WHERE id = \$1`).
+				WithArgs(id).
+				WillReturnRows(current())
+
+			req := httptest.NewRequest(http.MethodPatch, "/admin/orgs/"+id.String(), bytes.NewBufferString(tt.body))
+			req.SetPathValue("id", id.String())
+			rec := httptest.NewRecorder()
+
+			handler.Patch(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestAdminOrgsHandler_Patch_EmptyName(t *testing.T) {
+	handler, _, cleanup := setupAdminTest(t)
+	defer cleanup()
+
+	id := uuid.New()
+	req := httptest.NewRequest(http.MethodPatch, "/admin/orgs/"+id.String(), bytes.NewBufferString(`{"name": "  "}`))
+	req.SetPathValue("id", id.String())
+	rec := httptest.NewRecorder()
+
+	handler.Patch(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rec.Code)
+	}
+}
+
+func TestAdminOrgsHandler_Update_MissingNameRejected(t *testing.T) {
+	handler, _, cleanup := setupAdminTest(t)
+	defer cleanup()
+
+	id := uuid.New()
+	req := httptest.NewRequest(http.MethodPut, "/admin/orgs/"+id.String(), bytes.NewBufferString(`{}`))
+	req.SetPathValue("id", id.String())
+	rec := httptest.NewRecorder()
+
+	handler.Update(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rec.Code)
+	}
+}
+
+func TestAdminOrgsHandler_Create_NameTaken(t *testing.T) {
+	handler, mock, cleanup := setupAdminTest(t)
+	defer cleanup()
+
+	mock.ExpectQuery(`INSERT INTO organizations`).
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "idx_organizations_name_lower"})
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/orgs", bytes.NewBufferString(`{"name": "Acme"}`))
+	rec := httptest.NewRecorder()
+
+	handler.Create(rec, req)
+
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "already exists") {
+		t.Errorf("expected conflict message, got %s", rec.Body.String())
+	}
+}
+
+func TestAdminOrgsHandler_Update_NameTaken(t *testing.T) {
+	handler, mock, cleanup := setupAdminTest(t)
+	defer cleanup()
+
+	id := uuid.New()
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT .+ FROM organizations WHERE id = \$1 FOR UPDATE`).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(orgRowColumns).
+			AddRow(id, "Beta", "hash123", true, now, now, []byte(`{}`), 1))
+	mock.ExpectExec(`UPDATE organizations`).
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "idx_organizations_name_lower"})
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest(http.MethodPut, "/admin/orgs/"+id.String(), bytes.NewBufferString(`{"name": "acme"}`))
+	req.SetPathValue("id", id.String())
+	rec := httptest.NewRecorder()
+
+	handler.Update(rec, req)
+
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"navplane/internal/httpjson"
+	"navplane/internal/requestlog"
+
+	"github.com/google/uuid"
+)
+
+// AdminRequestLogsHandler handles admin search over persisted request logs.
+type AdminRequestLogsHandler struct {
+	manager *requestlog.Manager
+}
+
+// NewAdminRequestLogsHandler creates a new admin request logs handler.
+func NewAdminRequestLogsHandler(manager *requestlog.Manager) *AdminRequestLogsHandler {
+	return &AdminRequestLogsHandler{manager: manager}
+}
+
+// requestLogResponse is the JSON response for a request log entry.
+type requestLogResponse struct {
+	ID               int64  `json:"id"`
+	OrgID            string `json:"org_id,omitempty"`
+	RequestID        string `json:"request_id"`
+	Route            string `json:"route"`
+	Model            string `json:"model"`
+	Provider         string `json:"provider"`
+	Status           int    `json:"status"`
+	LatencyMS        int64  `json:"latency_ms"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	ErrorType        string `json:"error_type,omitempty"`
+	ClientIP         string `json:"client_ip,omitempty"`
+	CreatedAt        string `json:"created_at"`
+}
+
+func toRequestLogResponse(e *requestlog.Entry) requestLogResponse {
+	resp := requestLogResponse{
+		ID:               e.ID,
+		RequestID:        e.RequestID,
+		Route:            e.Route,
+		Model:            e.Model,
+		Provider:         e.Provider,
+		Status:           e.Status,
+		LatencyMS:        e.LatencyMS,
+		PromptTokens:     e.PromptTokens,
+		CompletionTokens: e.CompletionTokens,
+		ErrorType:        e.ErrorType,
+		ClientIP:         e.ClientIP,
+		CreatedAt:        e.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+	if e.OrgID != nil {
+		resp.OrgID = e.OrgID.String()
+	}
+	return resp
+}
+
+// List handles GET /admin/request-logs
+// Filters: org_id, status (2xx/4xx/5xx), model, from and to (RFC 3339),
+// limit, and cursor (next_cursor from the previous page).
+func (h *AdminRequestLogsHandler) List(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseRequestLogFilter(r)
+	if err != nil {
+		httpjson.WriteStatusError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := h.manager.List(r.Context(), filter)
+	if err != nil {
+		if errors.Is(err, requestlog.ErrInvalidFilter) || errors.Is(err, requestlog.ErrInvalidCursor) {
+			httpjson.WriteStatusError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeServerError(w, r, err, "failed to list request logs", "failed to list request logs")
+		return
+	}
+
+	response := make([]requestLogResponse, len(page.Entries))
+	for i, e := range page.Entries {
+		response[i] = toRequestLogResponse(e)
+	}
+
+	httpjson.WriteJSON(w, http.StatusOK, map[string]any{
+		"request_logs": response,
+		"count":        len(response),
+		"next_cursor":  page.NextCursor,
+	})
+}
+
+func parseRequestLogFilter(r *http.Request) (requestlog.Filter, error) {
+	q := r.URL.Query()
+	f := requestlog.Filter{
+		Model:  q.Get("model"),
+		Cursor: q.Get("cursor"),
+	}
+
+	if v := q.Get("org_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return f, errors.New("invalid org_id")
+		}
+		f.OrgID = &id
+	}
+	if v := q.Get("status"); v != "" {
+		class, ok := strings.CutSuffix(strings.ToLower(v), "xx")
+		n, err := strconv.Atoi(class)
+		if !ok || err != nil || len(class) != 1 {
+			return f, errors.New("status must be a status class like 2xx, 4xx or 5xx")
+		}
+		f.StatusClass = n
+	}
+	if v := q.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return f, errors.New("from must be an RFC 3339 timestamp")
+		}
+		f.From = t
+	}
+	if v := q.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return f, errors.New("to must be an RFC 3339 timestamp")
+		}
+		f.Until = t
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return f, errors.New("limit must be a positive integer")
+		}
+		f.Limit = n
+	}
+	return f, nil
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"navplane/internal/requestlog"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+// requestLogRowColumns is the column set returned by request log SELECT queries.
+var requestLogRowColumns = []string{"id", "org_id", "request_id", "route", "model", "provider", "status", "latency_ms",
+	"prompt_tokens", "completion_tokens", "error_type", "client_ip", "created_at"}
+
+func setupAdminRequestLogsTest(t *testing.T) (*AdminRequestLogsHandler, sqlmock.Sqlmock, func()) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	manager := requestlog.NewManager(requestlog.NewDatastore(db))
+	return NewAdminRequestLogsHandler(manager), mock, func() { db.Close() }
+}
+
+func TestAdminRequestLogsHandler_List(t *testing.T) {
+	h, mock, cleanup := setupAdminRequestLogsTest(t)
+	defer cleanup()
+
+	orgID := uuid.New()
+	from := time.Date(2026, 3, 1, 14, 0, 0, 0, time.UTC)
+	until := from.Add(time.Hour)
+	rows := sqlmock.NewRows(requestLogRowColumns)
+	for i := range 3 {
+		rows.AddRow(int64(3-i), orgID, "req", "POST /v1/chat/completions", "gpt-4o", "api.openai.com",
+			502, 40, 0, 0, requestlog.ErrorTypeUpstream, "203.0.113.7", from.Add(time.Duration(i)*time.Minute))
+	}
+	mock.ExpectQuery(`WHERE org_id = \$1 AND status / 100 = \$2 AND model = \$3 AND created_at >= \$4 AND created_at < \$5`).
+		WithArgs(orgID, 5, "gpt-4o", from, until, 3).
+		WillReturnRows(rows)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/request-logs?org_id="+orgID.String()+
+		"&status=5xx&model=gpt-4o&from=2026-03-01T14:00:00Z&to=2026-03-01T15:00:00Z&limit=2", nil)
+	rec := httptest.NewRecorder()
+
+	h.List(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		RequestLogs []requestLogResponse `json:"request_logs"`
+		Count       int                  `json:"count"`
+		NextCursor  string               `json:"next_cursor"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Count != 2 || len(resp.RequestLogs) != 2 {
+		t.Errorf("expected 2 request logs, got %d", resp.Count)
+	}
+	if resp.NextCursor == "" {
+		t.Error("expected next_cursor when more results exist")
+	}
+	if resp.RequestLogs[0].OrgID != orgID.String() || resp.RequestLogs[0].ErrorType != requestlog.ErrorTypeUpstream {
+		t.Errorf("unexpected first entry: %+v", resp.RequestLogs[0])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdminRequestLogsHandler_List_InvalidParams(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"invalid org_id", "org_id=nope"},
+		{"invalid status", "status=500"},
+		{"status class out of range", "status=9xx"},
+		{"invalid from", "from=yesterday"},
+		{"invalid to", "to=2026-03-01"},
+		{"from after to", "from=2026-03-02T00:00:00Z&to=2026-03-01T00:00:00Z"},
+		{"invalid limit", "limit=0"},
+		{"invalid cursor", "cursor=!!!"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _, cleanup := setupAdminRequestLogsTest(t)
+			defer cleanup()
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/request-logs?"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			h.List(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d: %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
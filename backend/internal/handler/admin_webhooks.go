@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"navplane/internal/httpjson"
+	"navplane/internal/webhook"
+
+	"github.com/google/uuid"
+)
+
+// AdminWebhooksHandler handles admin operations for webhooks.
+type AdminWebhooksHandler struct {
+	manager *webhook.Manager
+}
+
+// NewAdminWebhooksHandler creates a new admin webhooks handler.
+func NewAdminWebhooksHandler(manager *webhook.Manager) *AdminWebhooksHandler {
+	return &AdminWebhooksHandler{manager: manager}
+}
+
+// webhookResponse is the JSON response for a webhook.
+// The signing secret is never exposed after creation.
+type webhookResponse struct {
+	ID        string   `json:"id"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	Enabled   bool     `json:"enabled"`
+	CreatedAt string   `json:"created_at"`
+	UpdatedAt string   `json:"updated_at"`
+}
+
+// createWebhookResponse includes the signing secret (only on creation).
+type createWebhookResponse struct {
+	webhookResponse
+	Secret string `json:"secret"`
+}
+
+func toWebhookResponse(w *webhook.Webhook) webhookResponse {
+	return webhookResponse{
+		ID:        w.ID.String(),
+		URL:       w.URL,
+		Events:    w.Events,
+		Enabled:   w.Enabled,
+		CreatedAt: w.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		UpdatedAt: w.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+}
+
+// createWebhookRequest is the JSON request for registering a webhook.
+type createWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+// Create handles POST /admin/webhooks
+func (h *AdminWebhooksHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req createWebhookRequest
+	if err := httpjson.Decode(w, r, &req, httpjson.DecodeOptions{}); err != nil {
+		httpjson.WriteDecodeError(w, err)
+		return
+	}
+
+	wh, err := h.manager.Create(r.Context(), req.URL, req.Events)
+	if err != nil {
+		if errors.Is(err, webhook.ErrInvalidURL) || errors.Is(err, webhook.ErrInvalidEvent) || errors.Is(err, webhook.ErrNoEvents) {
+			httpjson.WriteStatusError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeServerError(w, r, err, "failed to create webhook", "failed to create webhook")
+		return
+	}
+
+	httpjson.WriteJSON(w, http.StatusCreated, createWebhookResponse{
+		webhookResponse: toWebhookResponse(wh),
+		Secret:          wh.Secret,
+	})
+}
+
+// List handles GET /admin/webhooks
+func (h *AdminWebhooksHandler) List(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.manager.List(r.Context())
+	if err != nil {
+		writeServerError(w, r, err, "failed to list webhooks", "failed to list webhooks")
+		return
+	}
+
+	response := make([]webhookResponse, len(webhooks))
+	for i, wh := range webhooks {
+		response[i] = toWebhookResponse(wh)
+	}
+
+	httpjson.WriteJSON(w, http.StatusOK, map[string]any{
+		"webhooks": response,
+		"count":    len(response),
+	})
+}
+
+// Delete handles DELETE /admin/webhooks/{id}
+func (h *AdminWebhooksHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		httpjson.WriteStatusError(w, http.StatusBadRequest, "invalid webhook ID")
+		return
+	}
+
+	if err := h.manager.Delete(r.Context(), id); err != nil {
+		if errors.Is(err, webhook.ErrNotFound) {
+			httpjson.WriteStatusError(w, http.StatusNotFound, "webhook not found")
+			return
+		}
+		writeServerError(w, r, err, "failed to delete webhook", "failed to delete webhook")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
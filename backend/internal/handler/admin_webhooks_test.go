@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"navplane/internal/webhook"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+// webhookRowColumns is the column set returned by webhook SELECT queries.
+var webhookRowColumns = []string{"id", "url", "secret", "events", "enabled", "created_at", "updated_at"}
+
+func setupAdminWebhooksTest(t *testing.T) (*AdminWebhooksHandler, sqlmock.Sqlmock, func()) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	manager := webhook.NewManager(webhook.NewDatastore(db))
+	return NewAdminWebhooksHandler(manager), mock, func() { db.Close() }
+}
+
+func TestAdminWebhooksHandler_Create(t *testing.T) {
+	h, mock, cleanup := setupAdminWebhooksTest(t)
+	defer cleanup()
+
+	now := time.Now()
+	mock.ExpectQuery(`INSERT INTO webhooks`).
+		WillReturnRows(sqlmock.NewRows(webhookRowColumns).
+			AddRow(uuid.New(), "https://example.com/hook", "whsec_abc", "{org.created}", true, now, now))
+
+	body := `{"url": "https://example.com/hook", "events": ["org.created"]}`
+	req := httptest.NewRequest(http.MethodPost, "/admin/webhooks", bytes.NewBufferString(body))
+	rec := httptest.NewRecorder()
+
+	h.Create(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp createWebhookResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Secret != "whsec_abc" {
+		t.Errorf("expected secret in create response, got %q", resp.Secret)
+	}
+}
+
+func TestAdminWebhooksHandler_Create_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"invalid JSON", `{`},
+		{"invalid URL", `{"url": "not a url", "events": ["org.created"]}`},
+		{"unknown event", `{"url": "https://example.com", "events": ["org.renamed"]}`},
+		{"no events", `{"url": "https://example.com", "events": []}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _, cleanup := setupAdminWebhooksTest(t)
+			defer cleanup()
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/webhooks", bytes.NewBufferString(tt.body))
+			rec := httptest.NewRecorder()
+
+			h.Create(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d", rec.Code)
+			}
+		})
+	}
+}
+
+func TestAdminWebhooksHandler_List_OmitsSecret(t *testing.T) {
+	h, mock, cleanup := setupAdminWebhooksTest(t)
+	defer cleanup()
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT .+ FROM webhooks`).
+		WillReturnRows(sqlmock.NewRows(webhookRowColumns).
+			AddRow(uuid.New(), "https://example.com/hook", "whsec_abc", "{org.created}", true, now, now))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/webhooks", nil)
+	rec := httptest.NewRecorder()
+
+	h.List(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if bytes.Contains(rec.Body.Bytes(), []byte("whsec_abc")) {
+		t.Error("list response must not include webhook secrets")
+	}
+
+	var resp struct {
+		Webhooks []webhookResponse `json:"webhooks"`
+		Count    int               `json:"count"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Count != 1 {
+		t.Errorf("expected count 1, got %d", resp.Count)
+	}
+}
+
+func TestAdminWebhooksHandler_Delete_NotFound(t *testing.T) {
+	h, mock, cleanup := setupAdminWebhooksTest(t)
+	defer cleanup()
+
+	id := uuid.New()
+	mock.ExpectExec(`DELETE FROM webhooks`).
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	req := httptest.NewRequest(http.MethodDelete, "/admin/webhooks/"+id.String(), nil)
+	req.SetPathValue("id", id.String())
+	rec := httptest.NewRecorder()
+
+	h.Delete(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+}
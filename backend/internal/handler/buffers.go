@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+const (
+	// copyBufferSize matches io.Copy's own buffer, so pooling changes
+	// allocations, not how responses are chunked.
+	copyBufferSize = 32 * 1024
+
+	// maxResponseBodySize guards against an upstream sending an absurd
+	// non-streaming body; completions are far smaller.
+	maxResponseBodySize = 64 * 1024 * 1024 // 64 MB
+)
+
+// errResponseTooLarge reports an upstream body over maxResponseBodySize.
+var errResponseTooLarge = errors.New("upstream response body too large")
+
+// bufferPool holds copy buffers shared by the streaming loop and the
+// non-streaming copy, so steady traffic stops allocating them per request.
+var bufferPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, copyBufferSize)
+		return &b
+	},
+}
+
+func getBuffer() *[]byte {
+	return bufferPool.Get().(*[]byte)
+}
+
+func putBuffer(b *[]byte) {
+	bufferPool.Put(b)
+}
+
+// copyBody copies src to dst through a pooled buffer, stopping with
+// errResponseTooLarge once more than limit bytes have been read. Partial
+// writes surface as io.ErrShortWrite, as with io.Copy.
+func copyBody(dst io.Writer, src io.Reader, limit int64) (int64, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	// Hide any WriterTo/ReaderFrom so the pooled buffer is always used
+	limited := &io.LimitedReader{R: src, N: limit + 1}
+	n, err := io.CopyBuffer(struct{ io.Writer }{dst}, struct{ io.Reader }{limited}, *buf)
+	if err == nil && n > limit {
+		err = errResponseTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// shortWriter accepts at most limit bytes in total and then reports a
+// short write, like a client connection dropping mid-response.
+type shortWriter struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	n := min(len(p), w.limit-w.buf.Len())
+	w.buf.Write(p[:n])
+	return n, nil
+}
+
+type failingWriter struct{ err error }
+
+func (w failingWriter) Write([]byte) (int, error) { return 0, w.err }
+
+func TestCopyBody(t *testing.T) {
+	body := strings.Repeat("0123456789", 10_000) // Spans several buffers
+
+	t.Run("copies everything", func(t *testing.T) {
+		var dst bytes.Buffer
+		n, err := copyBody(&dst, strings.NewReader(body), int64(len(body)))
+		if err != nil || n != int64(len(body)) || dst.String() != body {
+			t.Errorf("expected full copy, got n=%d err=%v", n, err)
+		}
+	})
+
+	t.Run("over the limit", func(t *testing.T) {
+		_, err := copyBody(io.Discard, strings.NewReader(body), int64(len(body)-1))
+		if !errors.Is(err, errResponseTooLarge) {
+			t.Errorf("expected errResponseTooLarge, got %v", err)
+		}
+	})
+
+	t.Run("short write", func(t *testing.T) {
+		dst := &shortWriter{limit: 100}
+		n, err := copyBody(dst, strings.NewReader(body), int64(len(body)))
+		if !errors.Is(err, io.ErrShortWrite) {
+			t.Errorf("expected io.ErrShortWrite, got %v", err)
+		}
+		if n != 100 || dst.buf.String() != body[:100] {
+			t.Errorf("expected 100 bytes copied, got %d", n)
+		}
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		writeErr := errors.New("broken pipe")
+		if _, err := copyBody(failingWriter{writeErr}, strings.NewReader(body), int64(len(body))); !errors.Is(err, writeErr) {
+			t.Errorf("expected write error, got %v", err)
+		}
+	})
+}
+
+func TestChatCompletions_NonStreamingResponseTooLarge(t *testing.T) {
+	client := mockHTTPClient(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(io.LimitReader(zeroReader{}, maxResponseBodySize+1)),
+		}, nil
+	})
+	handler := NewChatCompletionsHandlerWithClient(testConfig(), client)
+
+	body := `{"model": "gpt-4", "messages": [{"role": "user", "content": "Hello"}]}`
+	rec := httptest.NewRecorder()
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Fatalf("expected the handler to abort, got %v", v)
+		}
+		if rec.Code != http.StatusOK {
+			t.Errorf("expected the upstream status to be sent first, got %d", rec.Code)
+		}
+	}()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func BenchmarkCopyBody(b *testing.B) {
+	body := bytes.Repeat([]byte(`{"choices":[{"message":{"content":"lorem ipsum"}}]}`), 2_000)
+
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := copyBody(io.Discard, io.TeeReader(bytes.NewReader(body), io.Discard), maxResponseBodySize); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	// The previous path: io.Copy allocates a fresh 32 KB buffer per call
+	b.Run("io.Copy", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := io.Copy(struct{ io.Writer }{io.Discard}, io.TeeReader(bytes.NewReader(body), io.Discard)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkChatCompletions_Streaming(b *testing.B) {
+	var events strings.Builder
+	for range 200 {
+		events.WriteString("data: {\"choices\":[{\"delta\":{\"content\":\"token\"}}]}\n\n")
+	}
+	events.WriteString("data: [DONE]\n\n")
+	stream := events.String()
+
+	client := mockHTTPClient(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+			Body:       io.NopCloser(strings.NewReader(stream)),
+		}, nil
+	})
+	handler := NewChatCompletionsHandlerWithClient(testConfig(), client)
+	body := []byte(`{"model": "gpt-4", "stream": true, "messages": [{"role": "user", "content": "Hello"}]}`)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body)))
+		if rec.Code != http.StatusOK {
+			b.Fatalf("expected status 200, got %d", rec.Code)
+		}
+	}
+}
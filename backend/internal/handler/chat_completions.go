@@ -1,16 +1,16 @@
 package handler
 
 import (
-	"bytes"
-	"context"
 	"encoding/json"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"strings"
 	"time"
 
 	"navplane/internal/config"
+	"navplane/internal/httpjson"
+	"navplane/internal/openai"
+	"navplane/internal/requestlog"
 )
 
 const (
@@ -20,201 +20,108 @@ const (
 
 func closeBody(body io.Closer) {
 	if err := body.Close(); err != nil {
-		log.Printf("failed to close body: %v", err)
+		slog.Error("failed to close body", "error", err)
 	}
 }
 
 // chatCompletionsHandler handles POST /v1/chat/completions as a passthrough proxy.
 //
 // Design goals:
-//  1. Full transparency: Upstream responses (including errors) returned as-is
+//  1. Full transparency: Upstream responses returned as-is; only errors from
+//     providers with a native error schema are translated (provider_errors.go)
 //  2. No request validation: Upstream provider validates the request
-//  3. Minimal parsing: Only check stream flag for routing
+//  3. Minimal parsing: One pass reads the model and stream flag; the body
+//     is forwarded byte for byte
 //  4. SSE streaming: Stream responses with continuous flushing when stream=true
 //
-// NavPlane errors only for: 405, 400 (read fail), 413, 502, 504
+// NavPlane errors only for: 405, 400 (read fail, nesting limit), 403 (no provider key), 413, 502, 504
 type chatCompletionsHandler struct {
-	upstreamURL string
-	apiKey      string
-	client      *http.Client
+	upstreamProxy
 }
 
-func newHandler(cfg *config.Config, client *http.Client) *chatCompletionsHandler {
-	if client == nil {
-		client = &http.Client{
-			Timeout: 0, // Per-request timeout via context
-			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				return http.ErrUseLastResponse
-			},
-		}
-	}
-	// Normalize base URL: strip trailing slash and /v1 suffix to avoid duplication
-	baseURL := strings.TrimSuffix(cfg.Provider.BaseURL, "/")
-	baseURL = strings.TrimSuffix(baseURL, "/v1")
+// chatCompletionsAPI is OpenAI's Chat Completions API, which OpenAI-compatible
+// providers also serve.
+var chatCompletionsAPI = upstreamAPI{
+	path:                 "/v1/chat/completions",
+	spanName:             "upstream.chat_completions",
+	requestIDHeader:      "X-Request-Id",
+	rateLimitPrefix:      "x-ratelimit-",
+	translateErrors:      true,
+	setHeaders:           setUpstreamHeaders,
+	writeError:           writeOpenAIServerError,
+	refuseDuringShutdown: refuseDuringShutdown,
+	writeShutdownEvent:   writeShutdownEvent,
+	parseUsage:           parseCompletionUsage,
+}
 
-	return &chatCompletionsHandler{
-		upstreamURL: baseURL + "/v1/chat/completions",
-		apiKey:      cfg.Provider.APIKey,
-		client:      client,
-	}
+func newHandler(cfg *config.Config, client *http.Client) *chatCompletionsHandler {
+	return &chatCompletionsHandler{newUpstreamProxy(cfg.Provider, client, chatCompletionsAPI)}
 }
 
 func (h *chatCompletionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Defense-in-depth: mux routes by method, but check here for direct handler use
 	if r.Method != http.MethodPost {
-		writeProxyError(w, http.StatusMethodNotAllowed, "method not allowed", "invalid_request_error")
+		httpjson.WriteError(w, http.StatusMethodNotAllowed, "method not allowed", httpjson.TypeInvalidRequest)
 		return
 	}
 
 	defer closeBody(r.Body)
 
-	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBodySize+1))
-	if err != nil {
-		writeProxyError(w, http.StatusBadRequest, "failed to read request body", "invalid_request_error")
+	// An org's BYOK key would take precedence here; until orgs can store one,
+	// every request uses the platform key, and without it there is nothing
+	// to authenticate upstream with.
+	if h.apiKey == "" {
+		httpjson.WriteError(w, http.StatusForbidden, "no provider key is configured for this organization", httpjson.TypePermission)
 		return
 	}
-	if len(body) > maxRequestBodySize {
-		writeProxyError(w, http.StatusRequestEntityTooLarge, "request body too large", "invalid_request_error")
-		return
-	}
-
-	if isStreamingRequest(body) {
-		h.handleStreaming(w, r, body)
-	} else {
-		h.handleNonStreaming(w, r, body)
-	}
-}
-
-func (h *chatCompletionsHandler) handleNonStreaming(w http.ResponseWriter, r *http.Request, body []byte) {
-	start := time.Now()
-	reqID := r.Header.Get("X-Request-ID")
 
-	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
-	defer cancel()
-
-	upstreamReq, err := http.NewRequestWithContext(ctx, http.MethodPost, h.upstreamURL, bytes.NewReader(body))
-	if err != nil {
-		writeProxyError(w, http.StatusInternalServerError, "failed to create upstream request", "server_error")
-		return
-	}
-
-	setUpstreamHeaders(upstreamReq, r, h.apiKey)
-
-	upstreamResp, err := h.client.Do(upstreamReq)
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBodySize+1))
 	if err != nil {
-		if r.Context().Err() == context.Canceled {
-			return
-		}
-		if ctx.Err() == context.DeadlineExceeded {
-			writeProxyError(w, http.StatusGatewayTimeout, "upstream request timed out", "server_error")
-			logRequest(r.URL.Path, http.StatusGatewayTimeout, time.Since(start), reqID)
-			return
-		}
-		writeProxyError(w, http.StatusBadGateway, "failed to reach upstream provider", "server_error")
-		logRequest(r.URL.Path, http.StatusBadGateway, time.Since(start), reqID)
+		httpjson.WriteError(w, http.StatusBadRequest, "failed to read request body", httpjson.TypeInvalidRequest)
 		return
 	}
-	defer closeBody(upstreamResp.Body)
-
-	copyResponseHeaders(w, upstreamResp)
-	w.WriteHeader(upstreamResp.StatusCode)
-	if _, err := io.Copy(w, upstreamResp.Body); err != nil {
-		log.Printf("failed to copy upstream response: %v", err)
-	}
-
-	logRequest(r.URL.Path, upstreamResp.StatusCode, time.Since(start), reqID)
-}
-
-func (h *chatCompletionsHandler) handleStreaming(w http.ResponseWriter, r *http.Request, body []byte) {
-	start := time.Now()
-	reqID := r.Header.Get("X-Request-ID")
-
-	// No timeout for streaming - runs until upstream closes or client disconnects
-	ctx, cancel := context.WithCancel(r.Context())
-	defer cancel()
-
-	upstreamReq, err := http.NewRequestWithContext(ctx, http.MethodPost, h.upstreamURL, bytes.NewReader(body))
-	if err != nil {
-		writeProxyError(w, http.StatusInternalServerError, "failed to create upstream request", "server_error")
+	if len(body) > maxRequestBodySize {
+		httpjson.WriteError(w, http.StatusRequestEntityTooLarge, "request body too large", httpjson.TypeInvalidRequest)
 		return
 	}
 
-	setUpstreamHeaders(upstreamReq, r, h.apiKey)
-	upstreamReq.Header.Set("Accept", "text/event-stream")
-
-	upstreamResp, err := h.client.Do(upstreamReq)
+	parsed, err := parseChatRequest(body)
 	if err != nil {
-		if ctx.Err() != nil {
-			return // Client disconnected
-		}
-		writeProxyError(w, http.StatusBadGateway, "failed to reach upstream provider", "server_error")
-		logRequest(r.URL.Path, http.StatusBadGateway, time.Since(start), reqID)
-		return
-	}
-	defer closeBody(upstreamResp.Body)
-
-	// Non-200: pass through as regular response (not SSE)
-	if upstreamResp.StatusCode != http.StatusOK {
-		upstreamBody, err := io.ReadAll(upstreamResp.Body)
-		if err != nil {
-			writeProxyError(w, http.StatusBadGateway, "failed to read upstream error response", "server_error")
-			logRequest(r.URL.Path, http.StatusBadGateway, time.Since(start), reqID)
-			return
-		}
-		copyResponseHeaders(w, upstreamResp)
-		w.WriteHeader(upstreamResp.StatusCode)
-		if _, err := w.Write(upstreamBody); err != nil {
-			log.Printf("failed to write upstream error response: %v", err)
-		}
-		logRequest(r.URL.Path, upstreamResp.StatusCode, time.Since(start), reqID)
+		httpjson.WriteError(w, http.StatusBadRequest, err.Error(), httpjson.TypeInvalidRequest)
 		return
 	}
-
-	// Check if we can flush
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		writeProxyError(w, http.StatusInternalServerError, "streaming not supported", "server_error")
-		return
+	if e := requestlog.FromContext(r.Context()); e != nil {
+		e.Model = parsed.Model
+		e.Provider = h.provider
 	}
 
-	// Copy rate limit headers from upstream before setting SSE headers
-	copyRateLimitHeaders(w, upstreamResp)
-
-	// Set SSE headers (these override Content-Type from upstream)
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("X-Accel-Buffering", "no")
-	flusher.Flush()
-
-	// Stream response body
-	// Note: Context cancellation closes the HTTP connection, causing Read to return an error.
-	// No explicit select needed - the transport layer handles cancellation.
-	buf := make([]byte, 4096)
-	for {
-		n, err := upstreamResp.Body.Read(buf)
-		if n > 0 {
-			if _, writeErr := w.Write(buf[:n]); writeErr != nil {
-				return // Client disconnected
-			}
-			flusher.Flush()
-		}
-		if err != nil {
-			break // EOF or error (including context cancellation)
-		}
-	}
+	h.forward(w, r, body, parsed.Model, parsed.Stream)
+}
 
-	logRequest(r.URL.Path, http.StatusOK, time.Since(start), reqID)
+// chatRequest is the part of a request body the proxy reads itself.
+type chatRequest struct {
+	// Model is only used as a metric label once the upstream has accepted
+	// the request, which keeps arbitrary client values out of the label set
+	Model  string `json:"model"`
+	Stream bool   `json:"stream"`
 }
 
-func isStreamingRequest(body []byte) bool {
-	var partial struct {
-		Stream *bool `json:"stream"`
-	}
-	if err := json.Unmarshal(body, &partial); err != nil {
-		return false
-	}
-	return partial.Stream != nil && *partial.Stream
+// parseChatRequest enforces openai.Limits on body, then decodes the model
+// and stream flag in a single pass, skipping everything else without
+// allocating. Only a limit violation is an error: a field of the wrong
+// type is left zero while the others still decode, and anything
+// unparseable is treated as a non-streaming request for the upstream to
+// reject.
+func parseChatRequest(body []byte) (chatRequest, error) {
+	if err := openai.CheckRequest(body); err != nil {
+		return chatRequest{}, err
+	}
+	var req chatRequest
+	_ = json.Unmarshal(body, &req)
+	if req.Model == "" {
+		req.Model = "unknown"
+	}
+	return req, nil
 }
 
 func setUpstreamHeaders(upstream *http.Request, original *http.Request, apiKey string) {
@@ -234,56 +141,10 @@ func setUpstreamHeaders(upstream *http.Request, original *http.Request, apiKey s
 	}
 }
 
-func copyResponseHeaders(w http.ResponseWriter, resp *http.Response) {
-	headers := []string{
-		"Content-Type",
-		"Content-Length",
-		"Content-Encoding",
-		"X-Request-Id",
-	}
-	for _, h := range headers {
-		if v := resp.Header.Get(h); v != "" {
-			w.Header().Set(h, v)
-		}
-	}
-	copyRateLimitHeaders(w, resp)
-}
-
-func copyRateLimitHeaders(w http.ResponseWriter, resp *http.Response) {
-	rateLimitHeaders := []string{
-		"X-RateLimit-Limit-Requests",
-		"X-RateLimit-Limit-Tokens",
-		"X-RateLimit-Remaining-Requests",
-		"X-RateLimit-Remaining-Tokens",
-		"X-RateLimit-Reset-Requests",
-		"X-RateLimit-Reset-Tokens",
-	}
-	for _, h := range rateLimitHeaders {
-		if v := resp.Header.Get(h); v != "" {
-			w.Header().Set(h, v)
-		}
-	}
-}
-
-func writeProxyError(w http.ResponseWriter, statusCode int, message, errorType string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	if err := json.NewEncoder(w).Encode(map[string]any{
-		"error": map[string]any{
-			"message": message,
-			"type":    errorType,
-		},
-	}); err != nil {
-		log.Printf("failed to write proxy error response: %v", err)
-	}
-}
-
-func logRequest(path string, status int, duration time.Duration, reqID string) {
-	if reqID != "" {
-		log.Printf("route=%s status=%d duration=%s request_id=%s", path, status, duration, reqID)
-	} else {
-		log.Printf("route=%s status=%d duration=%s", path, status, duration)
-	}
+// writeOpenAIServerError writes one of NavPlane's own server-side errors on the
+// chat completions route.
+func writeOpenAIServerError(w http.ResponseWriter, status int, message string) {
+	httpjson.WriteError(w, status, message, httpjson.TypeServer)
 }
 
 // NewChatCompletionsHandler creates a handler for production use.
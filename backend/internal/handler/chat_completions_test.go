@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -12,6 +13,7 @@ import (
 	"time"
 
 	"navplane/internal/config"
+	"navplane/internal/openai"
 )
 
 // roundTripperFunc allows using a function as an http.RoundTripper for testing.
@@ -59,6 +61,26 @@ func TestChatCompletions_MethodNotAllowed_GET(t *testing.T) {
 
 // --- Non-Streaming Passthrough Tests ---
 
+func TestChatCompletions_NoProviderKey(t *testing.T) {
+	cfg := testConfig()
+	cfg.Provider = config.ProviderConfig{} // No platform fallback and no org BYOK key
+	client := mockHTTPClient(func(req *http.Request) (*http.Response, error) {
+		t.Fatal("upstream should not be called without a provider key")
+		return nil, nil
+	})
+
+	handler := NewChatCompletionsHandlerWithClient(cfg, client)
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"gpt-4"}`))
+	rec := httptest.NewRecorder()
+
+	handler(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", rec.Code)
+	}
+	assertJSONError(t, rec.Body.Bytes(), "no provider key is configured for this organization", "permission_error")
+}
+
 func TestChatCompletions_PassthroughSuccess(t *testing.T) {
 	cfg := testConfig()
 
@@ -202,18 +224,37 @@ func TestChatCompletions_IsStreamingRequest(t *testing.T) {
 		{"stream false", `{"stream": false}`, false},
 		{"stream missing", `{"model": "gpt-4"}`, false},
 		{"invalid json", `{invalid}`, false},
+		{"stream with mistyped model", `{"model": 5, "stream": true}`, true},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := isStreamingRequest([]byte(tt.body))
-			if result != tt.expected {
-				t.Errorf("isStreamingRequest(%q) = %v, want %v", tt.body, result, tt.expected)
+			parsed, err := parseChatRequest([]byte(tt.body))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result := parsed.Stream; result != tt.expected {
+				t.Errorf("parseChatRequest(%q).Stream = %v, want %v", tt.body, parsed.Stream, tt.expected)
 			}
 		})
 	}
 }
 
+func BenchmarkParseChatRequest(b *testing.B) {
+	var messages []string
+	for i := range 500 {
+		messages = append(messages, fmt.Sprintf(`{"role":"user","content":"message %d %s"}`, i, strings.Repeat("lorem ipsum ", 40)))
+	}
+	body := []byte(`{"model":"gpt-4o","messages":[` + strings.Join(messages, ",") + `],"stream":true}`)
+	b.SetBytes(int64(len(body)))
+	b.ReportAllocs()
+	for b.Loop() {
+		if parsed, err := parseChatRequest(body); err != nil || !parsed.Stream {
+			b.Fatalf("expected a streaming request, got %+v, %v", parsed, err)
+		}
+	}
+}
+
 func TestChatCompletions_StreamingResponse(t *testing.T) {
 	cfg := testConfig()
 
@@ -248,6 +289,67 @@ func TestChatCompletions_StreamingResponse(t *testing.T) {
 	}
 }
 
+func TestChatCompletions_RateLimitHeadersPassthrough(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		contentType  string
+		stream       bool
+		upstreamBody string
+	}{
+		{"success", http.StatusOK, "application/json", false, `{"id":"chatcmpl-123"}`},
+		{"streaming", http.StatusOK, "text/event-stream", true, "data: [DONE]\n\n"},
+		{"rate limited", http.StatusTooManyRequests, "application/json", false, `{"error":{"message":"Rate limit reached","type":"requests"}}`},
+		{"streaming rate limited", http.StatusTooManyRequests, "application/json", true, `{"error":{"message":"Rate limit reached","type":"requests"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+
+			client := mockHTTPClient(func(req *http.Request) (*http.Response, error) {
+				h := http.Header{}
+				h.Set("Content-Type", tt.contentType)
+				h.Set("X-RateLimit-Remaining-Requests", "59")
+				h.Set("X-RateLimit-Reset-Tokens", "6m0s")
+				h.Set("X-Ratelimit-Limit-Requests-Day", "14400")
+				h.Set("Retry-After", "20")
+				h.Set("X-Upstream-Internal", "secret")
+				return &http.Response{
+					StatusCode: tt.status,
+					Header:     h,
+					Body:       io.NopCloser(strings.NewReader(tt.upstreamBody)),
+				}, nil
+			})
+
+			handler := NewChatCompletionsHandlerWithClient(cfg, client)
+
+			body := fmt.Sprintf(`{"model": "gpt-4", "stream": %t}`, tt.stream)
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(body))
+			rec := httptest.NewRecorder()
+
+			handler(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, rec.Code)
+			}
+			for h, want := range map[string]string{
+				"X-RateLimit-Remaining-Requests": "59",
+				"X-RateLimit-Reset-Tokens":       "6m0s",
+				"X-RateLimit-Limit-Requests-Day": "14400",
+				"Retry-After":                    "20",
+			} {
+				if got := rec.Header().Get(h); got != want {
+					t.Errorf("expected %s %q, got %q", h, want, got)
+				}
+			}
+			if rec.Header().Get("X-Upstream-Internal") != "" {
+				t.Error("unrelated upstream headers should not be passed through")
+			}
+		})
+	}
+}
+
 func TestChatCompletions_StreamingUpstreamError(t *testing.T) {
 	cfg := testConfig()
 
@@ -306,6 +408,62 @@ func TestChatCompletions_RequestBodyTooLarge(t *testing.T) {
 	assertJSONError(t, rec.Body.Bytes(), "request body too large", "invalid_request_error")
 }
 
+func TestChatCompletions_RequestTooDeeplyNested(t *testing.T) {
+	cfg := testConfig()
+	client := mockHTTPClient(func(req *http.Request) (*http.Response, error) {
+		t.Fatal("upstream should not be called for a deeply nested request")
+		return nil, nil
+	})
+
+	handler := NewChatCompletionsHandlerWithClient(cfg, client)
+
+	body := `{"model": "gpt-4", "tools": ` + strings.Repeat("[", 5000) + strings.Repeat("]", 5000) + `}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	handler(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rec.Code)
+	}
+	assertJSONError(t, rec.Body.Bytes(), "request exceeds parsing limits: nesting depth exceeds 128", "invalid_request_error")
+}
+
+func TestChatCompletions_RequestExtraFieldLimits(t *testing.T) {
+	previous := openai.CurrentLimits()
+	openai.SetLimits(openai.Limits{MaxExtraFields: 2, MaxExtraBytes: 64})
+	t.Cleanup(func() { openai.SetLimits(previous) })
+
+	tests := []struct {
+		name    string
+		body    string
+		wantMsg string
+	}{
+		{"too many unknown fields", `{"model": "gpt-4", "seed": 1, "user": "u", "n": 2}`, "request exceeds parsing limits: more than 2 unknown fields"},
+		{"oversized unknown field", `{"model": "gpt-4", "metadata": "` + strings.Repeat("x", 100) + `"}`, `request exceeds parsing limits: field "metadata" is 102 bytes, over the 64 byte limit`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := mockHTTPClient(func(req *http.Request) (*http.Response, error) {
+				t.Fatal("upstream should not be called for a request over the limits")
+				return nil, nil
+			})
+			handler := NewChatCompletionsHandlerWithClient(testConfig(), client)
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+
+			handler(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d", rec.Code)
+			}
+			assertJSONError(t, rec.Body.Bytes(), tt.wantMsg, "invalid_request_error")
+		})
+	}
+}
+
 func TestChatCompletions_UpstreamUnreachable(t *testing.T) {
 	cfg := testConfig()
 
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"expvar"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+
+	"navplane/internal/middleware"
+)
+
+// registerDebugRoutes mounts the runtime profiling endpoints under /debug/:
+// pprof (/debug/pprof/ lists goroutine, heap, allocs and the other named
+// profiles; /debug/pprof/profile takes a CPU profile) and expvar
+// (/debug/vars). Profiles expose memory contents and command lines, so
+// they sit behind protect, the admin API's auth, and are registered only
+// when DEBUG_ENDPOINTS allows it.
+func registerDebugRoutes(mux *http.ServeMux, protect func(http.Handler) http.Handler) {
+	debugMux := http.NewServeMux()
+	debugMux.HandleFunc("/debug/pprof/", pprof.Index)
+	debugMux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	debugMux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	debugMux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	debugMux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	debugMux.Handle("GET /debug/vars", expvar.Handler())
+	mux.Handle("/debug/", logDebugAccess(protect(debugMux)))
+}
+
+// logDebugAccess records every debug request, including those auth
+// rejects.
+func logDebugAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slog.Info("debug endpoint accessed",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"query", r.URL.RawQuery,
+			"client_ip", middleware.ClientIP(r),
+		)
+		next.ServeHTTP(w, r)
+	})
+}
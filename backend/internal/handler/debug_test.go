@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"navplane/internal/config"
+	"navplane/internal/logging"
+	"navplane/internal/org"
+	"navplane/internal/webhook"
+)
+
+func TestRegisterAdminRoutes_DebugEndpoints(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+	newMuxes := func(debug bool) (proxy, admin *http.ServeMux) {
+		deps := &Deps{
+			Config:         &config.Config{AdminAPIKey: testAdminAPIKey, DebugEndpoints: debug},
+			OrgManager:     org.NewManager(org.NewDatastore(db)),
+			WebhookManager: webhook.NewManager(webhook.NewDatastore(db)),
+		}
+		proxy, admin = http.NewServeMux(), http.NewServeMux()
+		RegisterProxyRoutes(proxy, deps)
+		RegisterAdminRoutes(admin, deps)
+		return proxy, admin
+	}
+
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logging.New(&buf, slog.LevelInfo, logging.FormatJSON))
+	defer slog.SetDefault(previous)
+
+	paths := []string{
+		"/debug/pprof/",
+		"/debug/pprof/goroutine?debug=1",
+		"/debug/pprof/heap",
+		"/debug/pprof/profile?seconds=1",
+		"/debug/vars",
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		_, admin := newMuxes(false)
+		for _, path := range paths {
+			rec := httptest.NewRecorder()
+			admin.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			if rec.Code != http.StatusNotFound {
+				t.Errorf("%s: expected status 404, got %d", path, rec.Code)
+			}
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		buf.Reset()
+		proxy, admin := newMuxes(true)
+		for _, path := range paths {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("Authorization", "Bearer "+testAdminAPIKey)
+			admin.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK || rec.Body.Len() == 0 {
+				t.Errorf("%s: expected a 200 response with a body, got %d", path, rec.Code)
+			}
+
+			// Admin auth is required
+			rec = httptest.NewRecorder()
+			admin.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("%s: expected status 401 without the admin key, got %d", path, rec.Code)
+			}
+
+			// Never on the public listener
+			rec = httptest.NewRecorder()
+			proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			if rec.Code != http.StatusNotFound {
+				t.Errorf("%s: expected status 404 on the proxy routes, got %d", path, rec.Code)
+			}
+		}
+		if got := strings.Count(buf.String(), `"msg":"debug endpoint accessed"`); got != 2*len(paths) {
+			t.Errorf("expected %d debug access log entries, got %d:\n%s", 2*len(paths), got, buf.String())
+		}
+		if !strings.Contains(buf.String(), `"path":"/debug/pprof/heap"`) {
+			t.Errorf("expected access log to record the path, got:\n%s", buf.String())
+		}
+	})
+}
@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"navplane/internal/errreport"
+	"navplane/internal/httpjson"
+	"navplane/internal/middleware"
+)
+
+// writeServerError logs and reports err, then writes a 500 with clientMsg.
+// Use it for failures that are our fault; err itself never reaches the client.
+func writeServerError(w http.ResponseWriter, r *http.Request, err error, logMsg, clientMsg string) {
+	reportServerError(w, r, err, logMsg, "")
+	httpjson.WriteStatusError(w, http.StatusInternalServerError, clientMsg)
+}
+
+// reportServerError logs err and sends it to the error reporter tagged with
+// the request ID, org, route and (when non-empty) provider.
+func reportServerError(w http.ResponseWriter, r *http.Request, err error, msg, provider string) {
+	slog.Error(msg, "error", err)
+	tags := middleware.ErrorTags(w, r)
+	tags.Provider = provider
+	errreport.Report(fmt.Errorf("%s: %w", msg, err), tags)
+}
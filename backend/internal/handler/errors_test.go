@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"navplane/internal/errreport"
+	"navplane/internal/httpjson"
+
+	"github.com/google/uuid"
+)
+
+// TestErrorResponses_SharedSchema asserts that every error produced by the
+// router — proxy, auth middleware, and admin handlers — uses one schema.
+func TestErrorResponses_SharedSchema(t *testing.T) {
+	adminAuth := "Bearer " + testAdminAPIKey
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		authHeader string
+		wantStatus int
+	}{
+		{"proxy method not allowed", http.MethodGet, "/v1/chat/completions", "", "", http.StatusMethodNotAllowed},
+		{"proxy missing API key", http.MethodPost, "/v1/chat/completions", `{}`, "", http.StatusUnauthorized},
+		{"admin missing credentials", http.MethodGet, "/admin/orgs", "", "", http.StatusUnauthorized},
+		{"admin invalid org ID", http.MethodGet, "/admin/orgs/not-a-uuid", "", adminAuth, http.StatusBadRequest},
+		{"admin invalid JSON", http.MethodPost, "/admin/orgs", `{`, adminAuth, http.StatusBadRequest},
+		{"admin empty body", http.MethodPost, "/admin/orgs", ``, adminAuth, http.StatusBadRequest},
+		{"admin unknown patch field", http.MethodPatch, "/admin/orgs/" + uuid.NewString(), `{"enable": true}`, adminAuth, http.StatusBadRequest},
+		{"admin invalid export format", http.MethodGet, "/admin/orgs/export?format=xml", "", adminAuth, http.StatusBadRequest},
+		{"webhook invalid ID", http.MethodDelete, "/admin/webhooks/nope", "", adminAuth, http.StatusBadRequest},
+		{"webhook invalid URL", http.MethodPost, "/admin/webhooks", `{"url":"nope","events":["org.created"]}`, adminAuth, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux, _, cleanup := setupRoutesTest(t)
+			defer cleanup()
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+			rec := httptest.NewRecorder()
+
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			assertErrorSchema(t, rec)
+		})
+	}
+}
+
+// assertErrorSchema fails unless the response body is exactly one
+// httpjson.ErrorResponse with a message and type.
+func assertErrorSchema(t *testing.T, rec *httptest.ResponseRecorder) {
+	t.Helper()
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected Content-Type application/json, got %q", ct)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(rec.Body.Bytes()))
+	dec.DisallowUnknownFields()
+	var resp httpjson.ErrorResponse
+	if err := dec.Decode(&resp); err != nil {
+		t.Fatalf("error body does not match schema: %v (%s)", err, rec.Body.String())
+	}
+	if resp.Error.Message == "" {
+		t.Error("expected error.message to be set")
+	}
+	if resp.Error.Type == "" {
+		t.Error("expected error.type to be set")
+	}
+}
+
+// fakeReporter records error reports in memory.
+type fakeReporter struct {
+	mu      sync.Mutex
+	reports []fakeReport
+}
+
+type fakeReport struct {
+	err  error
+	tags errreport.Tags
+}
+
+func (f *fakeReporter) Report(err error, tags errreport.Tags) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reports = append(f.reports, fakeReport{err: err, tags: tags})
+}
+
+func (f *fakeReporter) Flush(time.Duration) {}
+
+func TestServerErrors_Reported(t *testing.T) {
+	reporter := &fakeReporter{}
+	errreport.SetDefault(reporter)
+	t.Cleanup(func() { errreport.SetDefault(nil) })
+
+	mux, mock, cleanup := setupRoutesTest(t)
+	defer cleanup()
+
+	mock.ExpectQuery(`INSERT INTO organizations`).
+		WillReturnError(errors.New("connection reset by peer"))
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/orgs", strings.NewReader(`{"name": "body-marker-org"}`))
+	req.Header.Set("Authorization", "Bearer "+testAdminAPIKey)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	rec.Header().Set(httpjson.RequestIDHeader, "req-500")
+
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(reporter.reports) != 1 {
+		t.Fatalf("expected 1 error report, got %d", len(reporter.reports))
+	}
+	report := reporter.reports[0]
+	want := errreport.Tags{RequestID: "req-500", Route: "POST /admin/orgs"}
+	if report.tags != want {
+		t.Errorf("expected tags %+v, got %+v", want, report.tags)
+	}
+	if !strings.Contains(report.err.Error(), "connection reset by peer") {
+		t.Errorf("expected underlying error in report, got %v", report.err)
+	}
+	if reported := fmt.Sprintf("%v %+v", report.err, report.tags); strings.Contains(reported, "body-marker-org") ||
+		strings.Contains(reported, testAdminAPIKey) {
+		t.Errorf("report leaked request body or key: %s", reported)
+	}
+}
+
+func TestClientErrors_NotReported(t *testing.T) {
+	reporter := &fakeReporter{}
+	errreport.SetDefault(reporter)
+	t.Cleanup(func() { errreport.SetDefault(nil) })
+
+	mux, _, cleanup := setupRoutesTest(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/orgs", strings.NewReader(`{`))
+	req.Header.Set("Authorization", "Bearer "+testAdminAPIKey)
+	rec := httptest.NewRecorder()
+
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+	if len(reporter.reports) != 0 {
+		t.Errorf("expected 4xx responses not to be reported, got %d", len(reporter.reports))
+	}
+}
@@ -1,14 +1,81 @@
 package handler
 
 import (
-	"encoding/json"
-	"log"
+	"context"
+	"log/slog"
 	"net/http"
+	"sync"
+	"time"
+
+	"navplane/internal/httpjson"
 )
 
+// readinessTimeout bounds each dependency check so a hung dependency
+// can't stall the probe past the orchestrator's own timeout.
+const readinessTimeout = 2 * time.Second
+
+// ReadinessCheck is a named dependency probe run by /health/ready.
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// CheckResult is the outcome of one readiness check.
+type CheckResult struct {
+	Status string `json:"status"` // "ok" or "fail"
+	Error  string `json:"error,omitempty"`
+}
+
+// ReadinessResponse is the body returned by /health/ready and /health.
+type ReadinessResponse struct {
+	Status string                 `json:"status"` // "healthy" or "unhealthy"
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// HealthCheck reports that the process is up. Served at /health/live; it
+// never touches dependencies so a slow database can't get the pod killed.
 func HealthCheck(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]string{"status": "healthy"}); err != nil {
-		log.Printf("failed to write health response: %v", err)
+	httpjson.WriteJSON(w, http.StatusOK, map[string]string{"status": "healthy"})
+}
+
+// ReadinessHandler runs every check concurrently and returns 503 with a
+// per-check breakdown if any fail. Failure details are logged rather than
+// returned, since the endpoint is unauthenticated.
+func ReadinessHandler(checks []ReadinessCheck) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+
+		resp := ReadinessResponse{
+			Status: "healthy",
+			Checks: make(map[string]CheckResult, len(checks)),
+		}
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for _, c := range checks {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				result := CheckResult{Status: "ok"}
+				if err := c.Check(ctx); err != nil {
+					slog.Warn("readiness check failed", "check", c.Name, "error", err)
+					result = CheckResult{Status: "fail", Error: "unavailable"}
+				}
+				mu.Lock()
+				resp.Checks[c.Name] = result
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		status := http.StatusOK
+		for _, result := range resp.Checks {
+			if result.Status != "ok" {
+				resp.Status = "unhealthy"
+				status = http.StatusServiceUnavailable
+				break
+			}
+		}
+		httpjson.WriteJSON(w, status, resp)
 	}
 }
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthCheck_Live(t *testing.T) {
+	rec := httptest.NewRecorder()
+	HealthCheck(rec, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rec.Code)
+	}
+}
+
+func TestReadinessHandler(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("dial tcp 10.0.0.5:5432: connection refused") }
+	hung := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	tests := []struct {
+		name       string
+		checks     []ReadinessCheck
+		wantStatus int
+		wantBody   string
+		wantChecks map[string]string
+	}{
+		{
+			name:       "no checks",
+			wantStatus: http.StatusOK,
+			wantBody:   "healthy",
+			wantChecks: map[string]string{},
+		},
+		{
+			name:       "all pass",
+			checks:     []ReadinessCheck{{Name: "database", Check: ok}, {Name: "cache", Check: ok}},
+			wantStatus: http.StatusOK,
+			wantBody:   "healthy",
+			wantChecks: map[string]string{"database": "ok", "cache": "ok"},
+		},
+		{
+			name:       "one fails",
+			checks:     []ReadinessCheck{{Name: "database", Check: failing}, {Name: "cache", Check: ok}},
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   "unhealthy",
+			wantChecks: map[string]string{"database": "fail", "cache": "ok"},
+		},
+		{
+			name:       "check times out",
+			checks:     []ReadinessCheck{{Name: "database", Check: hung}},
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   "unhealthy",
+			wantChecks: map[string]string{"database": "fail"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			req := httptest.NewRequest(http.MethodGet, "/health/ready", nil).WithContext(ctx)
+			rec := httptest.NewRecorder()
+
+			ReadinessHandler(tt.checks)(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			var resp ReadinessResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid JSON body %q: %v", rec.Body.String(), err)
+			}
+			if resp.Status != tt.wantBody {
+				t.Errorf("expected status %q, got %q", tt.wantBody, resp.Status)
+			}
+			if len(resp.Checks) != len(tt.wantChecks) {
+				t.Fatalf("expected %d checks, got %v", len(tt.wantChecks), resp.Checks)
+			}
+			for name, want := range tt.wantChecks {
+				if got := resp.Checks[name].Status; got != want {
+					t.Errorf("expected check %s = %q, got %q", name, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestReadinessHandler_HidesErrorDetail(t *testing.T) {
+	checks := []ReadinessCheck{{Name: "database", Check: func(ctx context.Context) error {
+		return errors.New("dial tcp 10.0.0.5:5432: connection refused")
+	}}}
+	rec := httptest.NewRecorder()
+
+	ReadinessHandler(checks)(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+
+	var resp ReadinessResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON body: %v", err)
+	}
+	if got := resp.Checks["database"].Error; got != "unavailable" {
+		t.Errorf("expected generic error, got %q", got)
+	}
+}
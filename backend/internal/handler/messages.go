@@ -0,0 +1,222 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"navplane/internal/config"
+	"navplane/internal/httpjson"
+	"navplane/internal/openai"
+	"navplane/internal/requestlog"
+)
+
+// defaultAnthropicVersion is sent when the client doesn't pick an API
+// version; the Anthropic SDKs always do.
+const defaultAnthropicVersion = "2023-06-01"
+
+// Anthropic error types used for NavPlane's own errors on /v1/messages.
+const (
+	anthropicInvalidRequest = "invalid_request_error"
+	anthropicPermission     = "permission_error"
+	anthropicTooLarge       = "request_too_large"
+	anthropicAPIError       = "api_error"
+	anthropicOverloaded     = "overloaded_error"
+)
+
+// messagesHandler handles POST /v1/messages, Anthropic's native Messages
+// API, so the Anthropic SDKs can point at NavPlane directly.
+//
+// Forwarding is shared with the chat completions proxy (upstreamProxy): the
+// body is forwarded byte for byte, upstream responses (including errors)
+// are returned as-is and SSE is streamed straight through. Unlike it,
+// model, messages and max_tokens are checked first, and NavPlane's own
+// errors use Anthropic's error shape, which its SDKs parse.
+//
+// NavPlane errors only for: 405, 400 (read fail, nesting limit, invalid request), 403 (no Anthropic key), 413, 502, 504
+type messagesHandler struct {
+	upstreamProxy
+}
+
+// messagesAPI is Anthropic's Messages API.
+var messagesAPI = upstreamAPI{
+	path:                 "/v1/messages",
+	spanName:             "upstream.messages",
+	requestIDHeader:      "Request-Id",
+	rateLimitPrefix:      "anthropic-ratelimit-",
+	setHeaders:           setAnthropicHeaders,
+	writeError:           writeAnthropicServerError,
+	refuseDuringShutdown: refuseAnthropicDuringShutdown,
+	writeShutdownEvent:   writeAnthropicShutdownEvent,
+	parseUsage:           parseAnthropicUsage,
+	mergeEvent:           mergeAnthropicEvent, // Input tokens arrive in message_start, the output count in message_delta
+}
+
+func newMessagesHandler(cfg *config.Config, client *http.Client) *messagesHandler {
+	return &messagesHandler{newUpstreamProxy(cfg.Anthropic, client, messagesAPI)}
+}
+
+func (h *messagesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAnthropicError(w, http.StatusMethodNotAllowed, "method not allowed", anthropicInvalidRequest)
+		return
+	}
+
+	defer closeBody(r.Body)
+
+	// As on the chat route, an org's own Anthropic key would take
+	// precedence here once orgs can store one
+	if h.apiKey == "" {
+		writeAnthropicError(w, http.StatusForbidden, "no active Anthropic key is configured for this organization", anthropicPermission)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBodySize+1))
+	if err != nil {
+		writeAnthropicError(w, http.StatusBadRequest, "failed to read request body", anthropicInvalidRequest)
+		return
+	}
+	if len(body) > maxRequestBodySize {
+		writeAnthropicError(w, http.StatusRequestEntityTooLarge, "request body too large", anthropicTooLarge)
+		return
+	}
+	if err := openai.CheckDepth(body); err != nil {
+		writeAnthropicError(w, http.StatusBadRequest, err.Error(), anthropicInvalidRequest)
+		return
+	}
+
+	parsed, err := parseMessagesRequest(body)
+	if err != nil {
+		writeAnthropicError(w, http.StatusBadRequest, err.Error(), anthropicInvalidRequest)
+		return
+	}
+	if e := requestlog.FromContext(r.Context()); e != nil {
+		e.Model = parsed.Model
+		e.Provider = h.provider
+	}
+
+	h.forward(w, r, body, parsed.Model, parsed.Stream)
+}
+
+// messagesRequest is the part of a Messages API request the proxy checks.
+// Everything else, including the content of each message, is left for the
+// upstream to validate.
+type messagesRequest struct {
+	Model     string            `json:"model"`
+	Messages  []json.RawMessage `json:"messages"`
+	MaxTokens int               `json:"max_tokens"`
+	Stream    bool              `json:"stream"`
+}
+
+// parseMessagesRequest decodes and lightly validates a Messages API request,
+// returning errors phrased the way Anthropic phrases its own.
+func parseMessagesRequest(body []byte) (messagesRequest, error) {
+	var req messagesRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return req, fmt.Errorf("%s: Input should be a valid %s", typeErr.Field, jsonTypeName(typeErr.Type.Kind().String()))
+		}
+		return req, errors.New("request body must be a JSON object")
+	}
+	switch {
+	case req.Model == "":
+		return req, errors.New("model: Field required")
+	case len(req.Messages) == 0:
+		return req, errors.New("messages: at least one message is required")
+	case req.MaxTokens < 1:
+		return req, errors.New("max_tokens: Field required and must be at least 1")
+	}
+	return req, nil
+}
+
+// jsonTypeName names a Go kind the way a JSON client knows it.
+func jsonTypeName(kind string) string {
+	switch kind {
+	case "string":
+		return "string"
+	case "bool":
+		return "boolean"
+	case "slice":
+		return "list"
+	case "int", "int64":
+		return "integer"
+	}
+	return kind
+}
+
+func setAnthropicHeaders(upstream *http.Request, original *http.Request, apiKey string) {
+	upstream.Header.Set("Content-Type", "application/json")
+	upstream.Header.Set("User-Agent", "NavPlane/1.0")
+	// SECURITY: Always use provider key, never forward client auth
+	upstream.Header.Set("x-api-key", apiKey)
+
+	version := original.Header.Get("anthropic-version")
+	if version == "" {
+		version = defaultAnthropicVersion
+	}
+	upstream.Header.Set("anthropic-version", version)
+
+	// Beta features are opted into per request
+	for _, v := range original.Header.Values("anthropic-beta") {
+		upstream.Header.Add("anthropic-beta", v)
+	}
+	if v := original.Header.Get("Accept"); v != "" {
+		upstream.Header.Set("Accept", v)
+	}
+	if v := original.Header.Get("X-Request-ID"); v != "" {
+		upstream.Header.Set("X-Request-ID", v)
+	}
+}
+
+// anthropicError is Anthropic's error body.
+type anthropicError struct {
+	Type  string               `json:"type"` // Always "error"
+	Error anthropicErrorDetail `json:"error"`
+}
+
+type anthropicErrorDetail struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// writeAnthropicError writes a NavPlane error in Anthropic's error shape.
+func writeAnthropicError(w http.ResponseWriter, status int, message, errorType string) {
+	httpjson.WriteJSON(w, status, anthropicError{
+		Type:  "error",
+		Error: anthropicErrorDetail{Type: errorType, Message: message},
+	})
+}
+
+// writeAnthropicServerError writes one of NavPlane's own server-side errors
+// on /v1/messages.
+func writeAnthropicServerError(w http.ResponseWriter, status int, message string) {
+	writeAnthropicError(w, status, message, anthropicAPIError)
+}
+
+// refuseAnthropicDuringShutdown is refuseDuringShutdown for /v1/messages.
+func refuseAnthropicDuringShutdown(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(shutdownRetryAfter))
+	writeAnthropicError(w, http.StatusServiceUnavailable, "server is shutting down, retry the request", anthropicOverloaded)
+}
+
+// writeAnthropicShutdownEvent ends an interrupted stream with an Anthropic
+// error event, the counterpart of writeShutdownEvent.
+func writeAnthropicShutdownEvent(w io.Writer, atBoundary bool) error {
+	data, err := json.Marshal(anthropicError{
+		Type:  "error",
+		Error: anthropicErrorDetail{Type: anthropicOverloaded, Message: "server is shutting down, the stream was interrupted"},
+	})
+	if err != nil {
+		return err
+	}
+	event := "event: error\ndata: " + string(data) + "\n\n"
+	if !atBoundary {
+		event = "\n\n" + event
+	}
+	_, err = io.WriteString(w, event)
+	return err
+}
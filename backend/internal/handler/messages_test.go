@@ -0,0 +1,317 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"navplane/internal/config"
+	"navplane/internal/metrics"
+	"navplane/internal/middleware"
+	"navplane/internal/org"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func anthropicTestConfig() *config.Config {
+	return &config.Config{
+		Anthropic: config.ProviderConfig{
+			BaseURL: "https://api.anthropic.com",
+			APIKey:  "sk-ant-REDACTED",
+		},
+	}
+}
+
+const testMessagesBody = `{"model": "claude-sonnet-4-5", "max_tokens": 1024, "messages": [{"role": "user", "content": "Hello"}]}`
+
+func decodeAnthropicError(t *testing.T, body []byte) anthropicError {
+	t.Helper()
+	var resp anthropicError
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("failed to parse error response: %v", err)
+	}
+	if resp.Type != "error" {
+		t.Errorf("expected Anthropic error shape, got %s", body)
+	}
+	return resp
+}
+
+func TestMessages_NoAnthropicKey(t *testing.T) {
+	client := mockHTTPClient(func(req *http.Request) (*http.Response, error) {
+		t.Fatal("upstream should not be called without an Anthropic key")
+		return nil, nil
+	})
+	h := newMessagesHandler(&config.Config{}, client)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(testMessagesBody)))
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status 403, got %d", rec.Code)
+	}
+	if resp := decodeAnthropicError(t, rec.Body.Bytes()); resp.Error.Type != "permission_error" || !strings.Contains(resp.Error.Message, "Anthropic key") {
+		t.Errorf("unexpected error: %+v", resp.Error)
+	}
+}
+
+func TestMessages_InvalidRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantMsg string
+	}{
+		{name: "not an object", body: `[1, 2]`, wantMsg: "JSON object"},
+		{name: "malformed", body: `{"model": `, wantMsg: "JSON object"},
+		{name: "missing model", body: `{"max_tokens": 1024, "messages": [{"role": "user", "content": "Hi"}]}`, wantMsg: "model: Field required"},
+		{name: "model wrong type", body: `{"model": 4, "max_tokens": 1024, "messages": []}`, wantMsg: "model: Input should be a valid string"},
+		{name: "no messages", body: `{"model": "claude-sonnet-4-5", "max_tokens": 1024, "messages": []}`, wantMsg: "messages:"},
+		{name: "messages not a list", body: `{"model": "claude-sonnet-4-5", "max_tokens": 1024, "messages": "Hi"}`, wantMsg: "messages: Input should be a valid list"},
+		{name: "missing max_tokens", body: `{"model": "claude-sonnet-4-5", "messages": [{"role": "user", "content": "Hi"}]}`, wantMsg: "max_tokens:"},
+		{name: "fractional max_tokens", body: `{"model": "claude-sonnet-4-5", "max_tokens": 1.5, "messages": [{"role": "user", "content": "Hi"}]}`, wantMsg: "max_tokens: Input should be a valid integer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := mockHTTPClient(func(req *http.Request) (*http.Response, error) {
+				t.Fatal("upstream should not be called for an invalid request")
+				return nil, nil
+			})
+			h := newMessagesHandler(anthropicTestConfig(), client)
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(tt.body)))
+
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("expected status 400, got %d", rec.Code)
+			}
+			resp := decodeAnthropicError(t, rec.Body.Bytes())
+			if resp.Error.Type != "invalid_request_error" || !strings.Contains(resp.Error.Message, tt.wantMsg) {
+				t.Errorf("expected invalid_request_error containing %q, got %+v", tt.wantMsg, resp.Error)
+			}
+		})
+	}
+}
+
+func TestMessages_Passthrough(t *testing.T) {
+	upstreamBody := `{"id":"msg_01","type":"message","role":"assistant","model":"claude-sonnet-4-5-20250929",` +
+		`"content":[{"type":"text","text":"Hi!"}],"stop_reason":"end_turn",` +
+		`"usage":{"input_tokens":12,"cache_creation_input_tokens":100,"cache_read_input_tokens":50,"output_tokens":5}}`
+
+	var upstreamReq *http.Request
+	var forwarded []byte
+	client := mockHTTPClient(func(req *http.Request) (*http.Response, error) {
+		upstreamReq = req
+		forwarded, _ = io.ReadAll(req.Body)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header: http.Header{
+				"Content-Type":                           []string{"application/json"},
+				"Request-Id":                             []string{"req_01"},
+				"Anthropic-Ratelimit-Requests-Remaining": []string{"49"},
+				"Anthropic-Ratelimit-Tokens-Reset":       []string{"2026-10-14T12:00:00Z"},
+			},
+			Body: io.NopCloser(strings.NewReader(upstreamBody)),
+		}, nil
+	})
+	h := newMessagesHandler(anthropicTestConfig(), client)
+
+	testOrg := &org.Org{ID: uuid.New(), Name: "Test Org"}
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(testMessagesBody))
+	req.Header.Set("x-api-key", "np_client-key")
+	req.Header.Add("anthropic-beta", "prompt-caching-2024-07-31")
+	req = req.WithContext(context.WithValue(req.Context(), middleware.OrgContextKey, testOrg))
+	rec := httptest.NewRecorder()
+
+	prompt := metrics.TokensProxied.WithLabelValues(testOrg.ID.String(), "claude-sonnet-4-5-20250929", "prompt")
+	completion := metrics.TokensProxied.WithLabelValues(testOrg.ID.String(), "claude-sonnet-4-5-20250929", "completion")
+
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec.Body.String() != upstreamBody {
+		t.Errorf("expected body passed through unchanged, got %q", rec.Body.String())
+	}
+	if got := upstreamReq.URL.String(); got != "https://api.anthropic.com/v1/messages" {
+		t.Errorf("unexpected upstream URL %q", got)
+	}
+	if string(forwarded) != testMessagesBody {
+		t.Errorf("expected request body forwarded byte for byte, got %q", forwarded)
+	}
+	if got := upstreamReq.Header.Get("x-api-key"); got != "sk-ant-REDACTED" {
+		t.Errorf("expected the platform Anthropic key upstream, got %q", got)
+	}
+	if got := upstreamReq.Header.Get("Authorization"); got != "" {
+		t.Errorf("expected no Authorization header upstream, got %q", got)
+	}
+	if got := upstreamReq.Header.Get("anthropic-version"); got != defaultAnthropicVersion {
+		t.Errorf("expected default anthropic-version, got %q", got)
+	}
+	if got := upstreamReq.Header.Get("anthropic-beta"); got != "prompt-caching-2024-07-31" {
+		t.Errorf("expected anthropic-beta forwarded, got %q", got)
+	}
+	if rec.Header().Get("Request-Id") != "req_01" ||
+		rec.Header().Get("Anthropic-Ratelimit-Requests-Remaining") != "49" ||
+		rec.Header().Get("Anthropic-Ratelimit-Tokens-Reset") == "" {
+		t.Errorf("expected upstream headers passed through, got %v", rec.Header())
+	}
+
+	// Cached input counts towards prompt tokens
+	if got := testutil.ToFloat64(prompt); got != 162 {
+		t.Errorf("expected 162 prompt tokens, got %v", got)
+	}
+	if got := testutil.ToFloat64(completion); got != 5 {
+		t.Errorf("expected 5 completion tokens, got %v", got)
+	}
+}
+
+func TestMessages_ClientVersionForwarded(t *testing.T) {
+	var version string
+	client := mockHTTPClient(func(req *http.Request) (*http.Response, error) {
+		version = req.Header.Get("anthropic-version")
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+	})
+	h := newMessagesHandler(anthropicTestConfig(), client)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(testMessagesBody))
+	req.Header.Set("anthropic-version", "2024-01-01")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if version != "2024-01-01" {
+		t.Errorf("expected client anthropic-version forwarded, got %q", version)
+	}
+}
+
+func TestMessages_UpstreamErrorPassthrough(t *testing.T) {
+	upstreamBody := `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`
+	client := mockHTTPClient(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 529,
+			Header:     http.Header{"Content-Type": []string{"application/json"}, "Retry-After": []string{"10"}},
+			Body:       io.NopCloser(strings.NewReader(upstreamBody)),
+		}, nil
+	})
+	h := newMessagesHandler(anthropicTestConfig(), client)
+
+	for _, body := range []string{testMessagesBody, strings.Replace(testMessagesBody, "{", `{"stream": true, `, 1)} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body)))
+
+		if rec.Code != 529 {
+			t.Errorf("expected status 529, got %d", rec.Code)
+		}
+		if rec.Body.String() != upstreamBody {
+			t.Errorf("expected upstream error passed through, got %q", rec.Body.String())
+		}
+		if rec.Header().Get("Retry-After") != "10" {
+			t.Errorf("expected Retry-After passed through, got %q", rec.Header().Get("Retry-After"))
+		}
+	}
+}
+
+// anthropicStream is a Messages API stream as sent by the API.
+const anthropicStream = "event: message_start\n" +
+	`data: {"type":"message_start","message":{"id":"msg_01","type":"message","role":"assistant","model":"claude-sonnet-4-5-20250929","content":[],"stop_reason":null,"usage":{"input_tokens":25,"cache_read_input_tokens":10,"output_tokens":1}}}` + "\n\n" +
+	"event: content_block_start\n" +
+	`data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}` + "\n\n" +
+	"event: ping\n" +
+	`data: {"type": "ping"}` + "\n\n" +
+	"event: content_block_delta\n" +
+	`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}` + "\n\n" +
+	"event: content_block_stop\n" +
+	`data: {"type":"content_block_stop","index":0}` + "\n\n" +
+	"event: message_delta\n" +
+	`data: {"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":15}}` + "\n\n" +
+	"event: message_stop\n" +
+	`data: {"type":"message_stop"}` + "\n\n"
+
+func TestMessages_Streaming(t *testing.T) {
+	var accept string
+	client := mockHTTPClient(func(req *http.Request) (*http.Response, error) {
+		accept = req.Header.Get("Accept")
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header: http.Header{
+				"Content-Type": []string{"text/event-stream"},
+				// Not one of the documented per-limit headers: matched by prefix
+				"Anthropic-Ratelimit-Unified-Status": []string{"allowed"},
+			},
+			Body: io.NopCloser(strings.NewReader(anthropicStream)),
+		}, nil
+	})
+	h := newMessagesHandler(anthropicTestConfig(), client)
+
+	testOrg := &org.Org{ID: uuid.New(), Name: "Test Org"}
+	body := strings.Replace(testMessagesBody, "{", `{"stream": true, `, 1)
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewBufferString(body))
+	req = req.WithContext(context.WithValue(req.Context(), middleware.OrgContextKey, testOrg))
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if accept != "text/event-stream" {
+		t.Errorf("expected SSE Accept header upstream, got %q", accept)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected Content-Type 'text/event-stream', got %q", ct)
+	}
+	if got := rec.Header().Get("Anthropic-Ratelimit-Unified-Status"); got != "allowed" {
+		t.Errorf("expected anthropic-ratelimit-* headers passed through, got %q", got)
+	}
+	if rec.Body.String() != anthropicStream {
+		t.Errorf("streaming response was modified!\nexpected: %q\ngot: %q", anthropicStream, rec.Body.String())
+	}
+
+	model := "claude-sonnet-4-5-20250929"
+	if got := testutil.ToFloat64(metrics.TokensProxied.WithLabelValues(testOrg.ID.String(), model, "prompt")); got != 35 {
+		t.Errorf("expected 35 prompt tokens, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.TokensProxied.WithLabelValues(testOrg.ID.String(), model, "completion")); got != 15 {
+		t.Errorf("expected 15 completion tokens, got %v", got)
+	}
+}
+
+func TestMergeAnthropicEvent(t *testing.T) {
+	var u completionUsage
+	events := []struct {
+		data  string
+		found bool
+	}{
+		{`{"type":"message_start","message":{"model":"claude-haiku-4-5","usage":{"input_tokens":40,"output_tokens":1}}}`, true},
+		{`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"usage"}}`, false},
+		{`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":9}}`, true},
+		// Later API versions repeat cumulative input counts in message_delta
+		{`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"input_tokens":45,"output_tokens":20}}`, true},
+		{`not json`, false},
+	}
+	for _, e := range events {
+		if got := mergeAnthropicEvent([]byte(e.data), &u); got != e.found {
+			t.Errorf("%s: expected found %t, got %t", e.data, e.found, got)
+		}
+	}
+
+	if u.Model != "claude-haiku-4-5" || u.Usage.PromptTokens != 45 || u.Usage.CompletionTokens != 20 || u.Usage.TotalTokens != 65 {
+		t.Errorf("unexpected usage: %+v %+v", u, u.Usage)
+	}
+}
+
+func TestWriteAnthropicShutdownEvent(t *testing.T) {
+	var b strings.Builder
+	if err := writeAnthropicShutdownEvent(&b, true); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	want := "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"server is shutting down, the stream was interrupted\"}}\n\n"
+	if b.String() != want {
+		t.Errorf("expected %q, got %q", want, b.String())
+	}
+}
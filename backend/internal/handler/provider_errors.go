@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"navplane/internal/httpjson"
+)
+
+// maxProviderErrorSize bounds how much of a non-2xx upstream body is read
+// for translation. Provider errors are a few hundred bytes; anything larger
+// is relayed as-is.
+const maxProviderErrorSize = 64 * 1024
+
+// errorTranslator turns a provider's native error body into OpenAI's error
+// schema, reporting false when it doesn't recognize the body.
+type errorTranslator func(status int, body []byte) (httpjson.ErrorDetail, bool)
+
+// errorTranslators maps an upstream host (the provider metrics label) to
+// the translator for its native errors. Hosts that already answer in
+// OpenAI's schema, including OpenAI itself, have none.
+var errorTranslators = map[string]errorTranslator{
+	"api.anthropic.com":                 translateAnthropicError,
+	"generativelanguage.googleapis.com": translateGeminiError,
+}
+
+// translateProviderError rewrites a non-2xx upstream body from provider
+// into an httpjson.ErrorResponse, with the original payload under
+// error.provider_error. It reports false, leaving the body to be relayed
+// unchanged, when the provider has no translator or the body isn't an
+// error it recognizes.
+func translateProviderError(provider string, status int, body []byte, requestID string) ([]byte, bool) {
+	translate, ok := errorTranslators[provider]
+	if !ok || len(body) > maxProviderErrorSize {
+		return nil, false
+	}
+	detail, ok := translate(status, body)
+	if !ok || detail.Message == "" {
+		return nil, false
+	}
+	detail.Type = httpjson.TypeForStatus(status)
+	detail.RequestID = requestID
+	detail.ProviderError = json.RawMessage(bytes.TrimSpace(body))
+
+	translated, err := json.Marshal(httpjson.ErrorResponse{Error: detail})
+	if err != nil {
+		return nil, false
+	}
+	return translated, true
+}
+
+// anthropicErrorBody is Anthropic's native error:
+//
+//	{"type": "error", "error": {"type": "rate_limit_error", "message": "..."}}
+type anthropicErrorBody struct {
+	Type  string `json:"type"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// translateAnthropicError keeps Anthropic's error type (overloaded_error,
+// rate_limit_error, ...) as the code.
+func translateAnthropicError(_ int, body []byte) (httpjson.ErrorDetail, bool) {
+	var e anthropicErrorBody
+	if err := json.Unmarshal(body, &e); err != nil || e.Type != "error" || e.Error == nil {
+		return httpjson.ErrorDetail{}, false
+	}
+	return httpjson.ErrorDetail{Message: e.Error.Message, Code: e.Error.Type}, true
+}
+
+// geminiErrorBody is a Google API error. The native API returns the
+// object; Gemini's OpenAI-compatible endpoint wraps it in a one-element
+// array.
+type geminiErrorBody struct {
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"` // gRPC status, e.g. RESOURCE_EXHAUSTED
+		Details []struct {
+			Type   string `json:"@type"`
+			Reason string `json:"reason"` // Set on google.rpc.ErrorInfo, e.g. API_KEY_INVALID
+		} `json:"details"`
+	} `json:"error"`
+}
+
+// translateGeminiError uses the ErrorInfo reason as the code when there is
+// one, else the gRPC status, lowercased to match OpenAI's codes.
+func translateGeminiError(_ int, body []byte) (httpjson.ErrorDetail, bool) {
+	var e geminiErrorBody
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		var list []geminiErrorBody
+		if err := json.Unmarshal(trimmed, &list); err != nil || len(list) == 0 {
+			return httpjson.ErrorDetail{}, false
+		}
+		e = list[0]
+	} else if err := json.Unmarshal(trimmed, &e); err != nil {
+		return httpjson.ErrorDetail{}, false
+	}
+	if e.Error == nil {
+		return httpjson.ErrorDetail{}, false
+	}
+
+	code := e.Error.Status
+	for _, d := range e.Error.Details {
+		if strings.HasSuffix(d.Type, "google.rpc.ErrorInfo") && d.Reason != "" {
+			code = d.Reason
+			break
+		}
+	}
+	return httpjson.ErrorDetail{Message: e.Error.Message, Code: strings.ToLower(code)}, true
+}
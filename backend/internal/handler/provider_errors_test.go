@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"navplane/internal/httpjson"
+)
+
+// Captured provider error bodies.
+const (
+	anthropicOverloadedBody = `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`
+	anthropicAuthBody       = `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`
+	anthropicRateLimitBody  = `{"type":"error","error":{"type":"rate_limit_error","message":"Number of request tokens has exceeded your per-minute rate limit (https://docs.anthropic.com/en/api/rate-limits); see the response headers for current usage. Please reduce the prompt length or the maximum tokens requested, or try again later. You may also contact sales at https://www.anthropic.com/contact-sales to discuss your options for a rate limit increase."}}`
+
+	geminiInvalidKeyBody = `{
+  "error": {
+    "code": 400,
+    "message": "API key not valid. Please pass a valid API key.",
+    "status": "INVALID_ARGUMENT",
+    "details": [
+      {
+        "@type": "type.googleapis.com/google.rpc.ErrorInfo",
+        "reason": "API_KEY_INVALID",
+        "domain": "googleapis.com",
+        "metadata": {
+          "service": "generativelanguage.googleapis.com"
+        }
+      }
+    ]
+  }
+}`
+	geminiQuotaBody = `[{
+  "error": {
+    "code": 429,
+    "message": "Resource has been exhausted (e.g. check quota).",
+    "status": "RESOURCE_EXHAUSTED"
+  }
+}]`
+)
+
+func TestTranslateProviderError(t *testing.T) {
+	tests := []struct {
+		name        string
+		provider    string
+		status      int
+		body        string
+		wantOK      bool
+		wantType    string
+		wantCode    string
+		wantMessage string
+	}{
+		{"anthropic overloaded", "api.anthropic.com", 529, anthropicOverloadedBody, true, httpjson.TypeServer, "overloaded_error", "Overloaded"},
+		{"anthropic auth", "api.anthropic.com", http.StatusUnauthorized, anthropicAuthBody, true, httpjson.TypeAuthentication, "authentication_error", "invalid x-api-key"},
+		{"anthropic rate limit", "api.anthropic.com", http.StatusTooManyRequests, anthropicRateLimitBody, true, httpjson.TypeRateLimit, "rate_limit_error", ""},
+		{"gemini invalid key", "generativelanguage.googleapis.com", http.StatusBadRequest, geminiInvalidKeyBody, true, httpjson.TypeInvalidRequest, "api_key_invalid", "API key not valid. Please pass a valid API key."},
+		{"gemini wrapped quota", "generativelanguage.googleapis.com", http.StatusTooManyRequests, geminiQuotaBody, true, httpjson.TypeRateLimit, "resource_exhausted", "Resource has been exhausted (e.g. check quota)."},
+		{"openai untouched", "api.openai.com", http.StatusTooManyRequests, `{"error":{"message":"Rate limit reached","type":"requests","code":"rate_limit_exceeded"}}`, false, "", "", ""},
+		{"anthropic non-json", "api.anthropic.com", http.StatusBadGateway, `<html>502 Bad Gateway</html>`, false, "", "", ""},
+		{"anthropic other shape", "api.anthropic.com", http.StatusBadRequest, `{"error":{"message":"x"}}`, false, "", "", ""},
+		{"gemini empty list", "generativelanguage.googleapis.com", http.StatusInternalServerError, `[]`, false, "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := translateProviderError(tt.provider, tt.status, []byte(tt.body), "req-123")
+			if ok != tt.wantOK {
+				t.Fatalf("expected ok=%v, got %v (%s)", tt.wantOK, ok, got)
+			}
+			if !ok {
+				return
+			}
+
+			var resp httpjson.ErrorResponse
+			if err := json.Unmarshal(got, &resp); err != nil {
+				t.Fatalf("translated body is not JSON: %v", err)
+			}
+			if resp.Error.Type != tt.wantType {
+				t.Errorf("expected type %q, got %q", tt.wantType, resp.Error.Type)
+			}
+			if resp.Error.Code != tt.wantCode {
+				t.Errorf("expected code %q, got %q", tt.wantCode, resp.Error.Code)
+			}
+			if tt.wantMessage != "" && resp.Error.Message != tt.wantMessage {
+				t.Errorf("expected message %q, got %q", tt.wantMessage, resp.Error.Message)
+			}
+			if resp.Error.RequestID != "req-123" {
+				t.Errorf("expected request_id req-123, got %q", resp.Error.RequestID)
+			}
+
+			var original, preserved any
+			_ = json.Unmarshal([]byte(tt.body), &original)
+			if err := json.Unmarshal(resp.Error.ProviderError, &preserved); err != nil {
+				t.Fatalf("provider_error is not JSON: %v", err)
+			}
+			a, _ := json.Marshal(original)
+			b, _ := json.Marshal(preserved)
+			if !bytes.Equal(a, b) {
+				t.Errorf("provider_error does not match original payload:\n%s\n%s", a, b)
+			}
+		})
+	}
+}
+
+func TestTranslateProviderError_TooLarge(t *testing.T) {
+	body := `{"type":"error","error":{"type":"api_error","message":"` + string(bytes.Repeat([]byte("x"), maxProviderErrorSize)) + `"}}`
+	if _, ok := translateProviderError("api.anthropic.com", http.StatusInternalServerError, []byte(body), ""); ok {
+		t.Error("expected oversized body to be relayed as-is")
+	}
+}
+
+func TestChatCompletions_TranslatesProviderError(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		name := "non-streaming"
+		if stream {
+			name = "streaming"
+		}
+		t.Run(name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.Provider.BaseURL = "https://api.anthropic.com/v1"
+
+			client := mockHTTPClient(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 529,
+					Header: http.Header{
+						"Content-Type":   []string{"application/json"},
+						"Content-Length": []string{"75"},
+					},
+					Body: io.NopCloser(bytes.NewBufferString(anthropicOverloadedBody)),
+				}, nil
+			})
+
+			handler := NewChatCompletionsHandlerWithClient(cfg, client)
+
+			body, _ := json.Marshal(map[string]any{"model": "claude-sonnet-4-5", "stream": stream})
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body))
+			rec := httptest.NewRecorder()
+
+			handler(rec, req)
+
+			if rec.Code != 529 {
+				t.Fatalf("expected upstream status 529, got %d", rec.Code)
+			}
+			if cl := rec.Header().Get("Content-Length"); cl == "75" {
+				t.Error("stale upstream Content-Length should be dropped")
+			}
+			var resp httpjson.ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("response is not JSON: %v: %s", err, rec.Body.String())
+			}
+			if resp.Error.Code != "overloaded_error" || resp.Error.Type != httpjson.TypeServer {
+				t.Errorf("unexpected translated error: %+v", resp.Error)
+			}
+			if len(resp.Error.ProviderError) == 0 {
+				t.Error("expected provider_error to carry the original payload")
+			}
+		})
+	}
+}
+
+func TestChatCompletions_UnrecognizedProviderErrorRelayed(t *testing.T) {
+	cfg := testConfig()
+	cfg.Provider.BaseURL = "https://api.anthropic.com/v1"
+
+	upstreamBody := `upstream connect error or disconnect/reset before headers`
+	client := mockHTTPClient(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusServiceUnavailable,
+			Header:     http.Header{"Content-Type": []string{"text/plain"}},
+			Body:       io.NopCloser(bytes.NewBufferString(upstreamBody)),
+		}, nil
+	})
+
+	handler := NewChatCompletionsHandlerWithClient(cfg, client)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(`{"model":"claude-sonnet-4-5"}`))
+	rec := httptest.NewRecorder()
+
+	handler(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503, got %d", rec.Code)
+	}
+	if rec.Body.String() != upstreamBody {
+		t.Errorf("expected body relayed as-is, got %q", rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/plain" {
+		t.Errorf("expected upstream Content-Type, got %q", ct)
+	}
+}
+
+func TestChatCompletions_OversizedStreamingErrorRelayed(t *testing.T) {
+	cfg := testConfig()
+	cfg.Provider.BaseURL = "https://api.anthropic.com/v1"
+
+	// Over maxProviderErrorSize: only its head is buffered, and it is
+	// relayed untranslated rather than truncated
+	upstreamBody := `{"type":"error","error":{"type":"api_error","message":"` + strings.Repeat("x", 2*maxProviderErrorSize) + `"}}`
+	client := mockHTTPClient(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusInternalServerError,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(upstreamBody)),
+		}, nil
+	})
+
+	handler := NewChatCompletionsHandlerWithClient(cfg, client)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"claude-sonnet-4-5","stream":true}`))
+	rec := httptest.NewRecorder()
+
+	handler(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500, got %d", rec.Code)
+	}
+	if rec.Body.String() != upstreamBody {
+		t.Errorf("expected the %d byte body relayed intact, got %d bytes", len(upstreamBody), rec.Body.Len())
+	}
+}
@@ -0,0 +1,414 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"navplane/internal/config"
+	"navplane/internal/httpjson"
+	"navplane/internal/metrics"
+	"navplane/internal/requestlog"
+	"navplane/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// upstreamAPI is what differs between the provider APIs NavPlane proxies.
+// Everything else about forwarding a request is shared (upstreamProxy).
+type upstreamAPI struct {
+	path            string // Appended to the provider base URL
+	spanName        string // Client span around each upstream call
+	requestIDHeader string // The provider's request ID response header
+	rateLimitPrefix string // Prefix of the provider's rate limit headers
+	translateErrors bool   // Rewrite provider-native errors into OpenAI's shape (provider_errors.go)
+
+	setHeaders           func(upstream, original *http.Request, apiKey string)
+	writeError           func(w http.ResponseWriter, status int, message string) // NavPlane's own server-side errors
+	refuseDuringShutdown func(w http.ResponseWriter)
+	writeShutdownEvent   func(w io.Writer, atBoundary bool) error
+	parseUsage           func(body []byte) (completionUsage, bool)
+	mergeEvent           func(data []byte, u *completionUsage) bool // Nil for usage in a final chunk
+}
+
+// upstreamProxy forwards a request body the handler has already read and
+// checked to one provider API, and relays the response: non-streaming
+// bodies are copied, SSE is streamed straight through, and upstream errors
+// are passed back as-is.
+type upstreamProxy struct {
+	upstreamURL string
+	apiKey      string
+	provider    string // Metrics label for the upstream (its host)
+	client      *http.Client
+	streams     *StreamTracker // Nil disables shutdown draining
+	api         upstreamAPI
+}
+
+func newUpstreamProxy(provider config.ProviderConfig, client *http.Client, api upstreamAPI) upstreamProxy {
+	baseURL, label := upstreamBase(provider.BaseURL)
+	return upstreamProxy{
+		upstreamURL: baseURL + api.path,
+		apiKey:      provider.APIKey,
+		provider:    label,
+		client:      upstreamClient(client),
+		api:         api,
+	}
+}
+
+// upstreamClient returns client, or the default client for upstream calls
+// when it is nil.
+func upstreamClient(client *http.Client) *http.Client {
+	if client != nil {
+		return client
+	}
+	return &http.Client{
+		Timeout: 0, // Per-request timeout via context
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// upstreamBase normalizes a provider base URL, stripping a trailing slash
+// and /v1 suffix to avoid duplication, and returns it with the metrics
+// label for the provider (its host).
+func upstreamBase(rawURL string) (baseURL, provider string) {
+	baseURL = strings.TrimSuffix(rawURL, "/")
+	baseURL = strings.TrimSuffix(baseURL, "/v1")
+
+	provider = baseURL
+	if parsed, err := url.Parse(baseURL); err == nil && parsed.Host != "" {
+		provider = parsed.Host
+	}
+	return baseURL, provider
+}
+
+// forward sends body upstream and relays the response, streaming it when
+// the client asked for a stream. model labels the stream metrics.
+func (p *upstreamProxy) forward(w http.ResponseWriter, r *http.Request, body []byte, model string, stream bool) {
+	if stream {
+		p.handleStreaming(w, r, body, model)
+	} else {
+		p.handleNonStreaming(w, r, body)
+	}
+}
+
+func (p *upstreamProxy) handleNonStreaming(w http.ResponseWriter, r *http.Request, body []byte) {
+	spanCtx, span := startUpstreamSpan(r.Context(), p.api.spanName, p.provider, false)
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(spanCtx, requestTimeout)
+	defer cancel()
+
+	upstreamReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.upstreamURL, bytes.NewReader(body))
+	if err != nil {
+		reportServerError(w, r, err, "failed to create upstream request", p.provider)
+		p.api.writeError(w, http.StatusInternalServerError, "failed to create upstream request")
+		return
+	}
+
+	p.api.setHeaders(upstreamReq, r, p.apiKey)
+
+	upstreamResp, err := doUpstream(p.client, p.provider, upstreamReq)
+	if err != nil {
+		if r.Context().Err() == context.Canceled {
+			return
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			p.api.writeError(w, http.StatusGatewayTimeout, "upstream request timed out")
+			return
+		}
+		p.api.writeError(w, http.StatusBadGateway, "failed to reach upstream provider")
+		return
+	}
+	defer closeBody(upstreamResp.Body)
+
+	if upstreamResp.StatusCode >= 400 {
+		markUpstreamError(r.Context())
+	}
+
+	var respBody io.Reader = upstreamResp.Body
+	translated := false
+	if p.api.translateErrors && !isSuccess(upstreamResp.StatusCode) {
+		if _, ok := errorTranslators[p.provider]; ok {
+			head, err := io.ReadAll(io.LimitReader(upstreamResp.Body, maxProviderErrorSize+1))
+			if err != nil {
+				p.api.writeError(w, http.StatusBadGateway, "failed to read upstream error response")
+				return
+			}
+			if body, ok := translateProviderError(p.provider, upstreamResp.StatusCode, head, w.Header().Get(httpjson.RequestIDHeader)); ok {
+				respBody, translated = bytes.NewReader(body), true
+			} else {
+				respBody = io.MultiReader(bytes.NewReader(head), upstreamResp.Body)
+			}
+		}
+	}
+	p.copyResponseHeaders(w, upstreamResp)
+	if translated {
+		setTranslatedErrorHeaders(w)
+	}
+	w.WriteHeader(upstreamResp.StatusCode)
+
+	captured := &cappedBuffer{limit: maxUsageCaptureSize}
+	if _, err := copyBody(w, io.TeeReader(respBody, captured), maxResponseBodySize); err != nil {
+		if errors.Is(err, errResponseTooLarge) {
+			slog.Error("aborting upstream response", "error", err, "limit_bytes", maxResponseBodySize)
+			// The status is already sent; closing the connection keeps the
+			// client from taking the truncated body as complete
+			panic(http.ErrAbortHandler)
+		}
+		slog.Error("failed to copy upstream response", "error", err)
+		return
+	}
+	if upstreamResp.StatusCode == http.StatusOK {
+		if u, ok := p.api.parseUsage(captured.Bytes()); ok {
+			recordUsage(spanCtx, u)
+		}
+	}
+}
+
+func (p *upstreamProxy) handleStreaming(w http.ResponseWriter, r *http.Request, body []byte, model string) {
+	// New streams would outlive the drain, so refuse them once shutdown begins
+	if !p.streams.begin() {
+		p.api.refuseDuringShutdown(w)
+		return
+	}
+	defer p.streams.end()
+
+	// The span covers the whole stream: it ends when this function returns
+	spanCtx, span := startUpstreamSpan(r.Context(), p.api.spanName, p.provider, true)
+	defer span.End()
+
+	// No timeout for streaming - runs until upstream closes, the client
+	// disconnects or the shutdown drain deadline passes
+	ctx, cancel := context.WithCancel(spanCtx)
+	defer cancel()
+	stopped := p.streams.stopped()
+	defer context.AfterFunc(stopped, cancel)()
+
+	upstreamReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.upstreamURL, bytes.NewReader(body))
+	if err != nil {
+		reportServerError(w, r, err, "failed to create upstream request", p.provider)
+		p.api.writeError(w, http.StatusInternalServerError, "failed to create upstream request")
+		return
+	}
+
+	p.api.setHeaders(upstreamReq, r, p.apiKey)
+	upstreamReq.Header.Set("Accept", "text/event-stream")
+
+	start := time.Now()
+	upstreamResp, err := doUpstream(p.client, p.provider, upstreamReq)
+	if err != nil {
+		if r.Context().Err() != nil {
+			return // Client disconnected
+		}
+		if stopped.Err() != nil {
+			p.api.refuseDuringShutdown(w)
+			return
+		}
+		p.api.writeError(w, http.StatusBadGateway, "failed to reach upstream provider")
+		return
+	}
+	defer closeBody(upstreamResp.Body)
+
+	// Non-200: pass through as regular response (not SSE). Only a bounded
+	// head is buffered, enough to translate; a longer body is relayed as-is.
+	if upstreamResp.StatusCode != http.StatusOK {
+		head, err := io.ReadAll(io.LimitReader(upstreamResp.Body, maxProviderErrorSize+1))
+		if err != nil {
+			p.api.writeError(w, http.StatusBadGateway, "failed to read upstream error response")
+			return
+		}
+		markUpstreamError(r.Context())
+		p.copyResponseHeaders(w, upstreamResp)
+		respBody := io.MultiReader(bytes.NewReader(head), upstreamResp.Body)
+		if p.api.translateErrors && !isSuccess(upstreamResp.StatusCode) {
+			if body, ok := translateProviderError(p.provider, upstreamResp.StatusCode, head, w.Header().Get(httpjson.RequestIDHeader)); ok {
+				respBody = bytes.NewReader(body)
+				setTranslatedErrorHeaders(w)
+			}
+		}
+		w.WriteHeader(upstreamResp.StatusCode)
+		if _, err := copyBody(w, respBody, maxResponseBodySize); err != nil {
+			if errors.Is(err, errResponseTooLarge) {
+				slog.Error("aborting upstream error response", "error", err, "limit_bytes", maxResponseBodySize)
+				panic(http.ErrAbortHandler)
+			}
+			slog.Error("failed to write upstream error response", "error", err)
+		}
+		return
+	}
+
+	// Check if we can flush
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		reportServerError(w, r, errors.New("response writer does not implement http.Flusher"), "streaming not supported", p.provider)
+		p.api.writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	// Copy rate limit headers from upstream before setting SSE headers
+	copyRateLimitHeaders(w, upstreamResp, p.api.rateLimitPrefix)
+
+	// Set SSE headers (these override Content-Type from upstream)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	flusher.Flush()
+
+	metrics.ActiveStreams.Inc()
+	defer metrics.ActiveStreams.Dec()
+
+	scanner := &sseUsageScanner{merge: p.api.mergeEvent}
+	stats := streamStats{provider: p.provider, model: model, start: start}
+	defer func() {
+		if scanner.found {
+			recordUsage(spanCtx, scanner.usage)
+		}
+		stats.events = scanner.events
+		stats.record(r.Context())
+	}()
+
+	// Stream response body
+	// Note: Context cancellation closes the HTTP connection, causing Read to return an error.
+	// No explicit select needed - the transport layer handles cancellation.
+	pooled := getBuffer()
+	defer putBuffer(pooled)
+	buf := *pooled
+	atBoundary := true // Whether the bytes sent so far end an SSE event
+	for {
+		n, err := upstreamResp.Body.Read(buf)
+		if n > 0 {
+			if stats.firstByte.IsZero() {
+				stats.firstByte = time.Now()
+			}
+			_, _ = scanner.Write(buf[:n])
+			written, writeErr := w.Write(buf[:n])
+			stats.bytes += int64(written)
+			if writeErr != nil {
+				stats.outcome = streamClientDisconnect
+				return
+			}
+			atBoundary = bytes.HasSuffix(buf[:n], []byte("\n\n"))
+			flusher.Flush()
+		}
+		if err != nil {
+			// EOF or error (including context cancellation)
+			switch {
+			case err == io.EOF:
+				stats.outcome = streamCompleted
+			case r.Context().Err() != nil:
+				stats.outcome = streamClientDisconnect
+			case stopped.Err() != nil:
+				// Drain deadline passed: tell the client instead of cutting mid-token
+				stats.outcome = streamShutdown
+				if err := p.api.writeShutdownEvent(w, atBoundary); err == nil {
+					flusher.Flush()
+				}
+			default:
+				stats.outcome = streamUpstreamError
+			}
+			return
+		}
+	}
+}
+
+// startUpstreamSpan starts a client span named name around an upstream call.
+func startUpstreamSpan(ctx context.Context, name, provider string, streaming bool) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("navplane.provider", provider),
+			attribute.Bool("navplane.streaming", streaming),
+		),
+	)
+}
+
+// doUpstream sends req with client, recording the status on the span in
+// its context and the time to response headers under provider.
+func doUpstream(client *http.Client, provider string, req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := client.Do(req)
+
+	span := trace.SpanFromContext(req.Context())
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+		if resp.StatusCode >= 500 {
+			span.SetStatus(codes.Error, "upstream error")
+		}
+	} else {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "upstream request failed")
+	}
+	metrics.UpstreamRequestDuration.WithLabelValues(provider, status).Observe(time.Since(start).Seconds())
+
+	return resp, err
+}
+
+// markUpstreamError tags the request log entry when an error response is
+// the provider's, passed through, rather than NavPlane's own.
+func markUpstreamError(ctx context.Context) {
+	if e := requestlog.FromContext(ctx); e != nil {
+		e.ErrorType = requestlog.ErrorTypeUpstream
+	}
+}
+
+// isSuccess reports whether status is 2xx.
+func isSuccess(status int) bool {
+	return status >= 200 && status < 300
+}
+
+// setTranslatedErrorHeaders fixes up headers copied from an upstream error
+// whose body was replaced by a translation.
+func setTranslatedErrorHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Del("Content-Length")
+	w.Header().Del("Content-Encoding")
+}
+
+func (p *upstreamProxy) copyResponseHeaders(w http.ResponseWriter, resp *http.Response) {
+	headers := []string{
+		"Content-Type",
+		"Content-Length",
+		"Content-Encoding",
+		p.api.requestIDHeader,
+	}
+	for _, h := range headers {
+		if v := resp.Header.Get(h); v != "" {
+			w.Header().Set(h, v)
+		}
+	}
+	copyRateLimitHeaders(w, resp, p.api.rateLimitPrefix)
+}
+
+// copyRateLimitHeaders passes through Retry-After and every header whose
+// name starts with prefix. Providers name them differently (OpenAI's
+// x-ratelimit-requests/-tokens, Anthropic's anthropic-ratelimit-input-tokens,
+// per-day variants elsewhere), so they are matched by prefix rather than
+// listed.
+func copyRateLimitHeaders(w http.ResponseWriter, resp *http.Response, prefix string) {
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		w.Header().Set("Retry-After", v)
+	}
+	for h, values := range resp.Header {
+		if len(h) <= len(prefix) || !strings.EqualFold(h[:len(prefix)], prefix) {
+			continue
+		}
+		w.Header().Del(h)
+		for _, v := range values {
+			w.Header().Add(h, v)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"navplane/internal/config"
+	"navplane/internal/logging"
+	"navplane/internal/middleware"
+	"navplane/internal/org"
+	"navplane/internal/webhook"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+// TestLogs_NeverContainSecrets drives the main request paths through the
+// production middleware chain against a fake provider, capturing every log
+// line (request logger and slog default), and asserts no secret appears.
+func TestLogs_NeverContainSecrets(t *testing.T) {
+	const (
+		providerKey   = "sk-proj-redaction0123456789abcdefghij"
+		dbPassword    = "db-pass-7f3a9c"
+		wrongAdminKey = "wrong-admin-0123456789abcdef012345"
+		webhookSecret = "whsec_redaction0123456789"
+	)
+	orgKey := "np_" + uuid.NewString()
+	unknownOrgKey := "np_" + uuid.NewString()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+providerKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if bytes.Contains(body, []byte(`"stream":true`)) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"model\":\"gpt-4\",\"usage\":{\"prompt_tokens\":1,\"completion_tokens\":1,\"total_tokens\":2}}\n\ndata: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"model":"gpt-4","usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`)
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{
+		Environment: "development",
+		Provider:    config.ProviderConfig{BaseURL: upstream.URL, APIKey: providerKey},
+		Database:    config.DatabaseConfig{URL: "postgres://navplane:" + dbPassword + "@db:5432/navplane"},
+		AdminAPIKey: testAdminAPIKey,
+		// Force the slow-request path so warn lines are covered too
+		Log: config.LogConfig{SlowRequestThreshold: time.Nanosecond},
+	}
+
+	var buf bytes.Buffer
+	logger := logging.New(&buf, slog.LevelDebug, logging.FormatJSON)
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	defer slog.SetDefault(previous)
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+	mock.MatchExpectationsInOrder(false)
+
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, &Deps{
+		Config:         cfg,
+		OrgManager:     org.NewManager(org.NewDatastore(db)),
+		WebhookManager: webhook.NewManager(webhook.NewDatastore(db)),
+	})
+	server := middleware.RequestLogger(logger, cfg.Log)(middleware.Metrics(middleware.Recover(logger)(mux)))
+
+	now := time.Now()
+	orgID := uuid.New()
+	// Key use writes are queued for a writer this test doesn't run
+	for range 2 {
+		mock.ExpectQuery(`SELECT .+ FROM organizations\s+WHERE api_key_hash = \$1`).
+			WithArgs(org.HashAPIKey(orgKey)).
+			WillReturnRows(sqlmock.NewRows(orgRowColumns).
+				AddRow(orgID, "Org", org.HashAPIKey(orgKey), true, now, now, []byte(`{}`), 1))
+	}
+	mock.ExpectQuery(`SELECT .+ FROM organizations\s+WHERE api_key_hash = \$1`).
+		WithArgs(org.HashAPIKey(unknownOrgKey)).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`SELECT .+ FROM organizations\s+WHERE previous_key_hash = \$1`).
+		WithArgs(org.HashAPIKey(unknownOrgKey), org.KeyHashSHA256).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`INSERT INTO organizations`).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at"}).AddRow(now, now))
+	mock.ExpectQuery(`INSERT INTO webhooks`).
+		WillReturnRows(sqlmock.NewRows(webhookRowColumns).
+			AddRow(uuid.New(), "https://hooks.example.com/services/T000/B000/tok123", webhookSecret, "{org.created}", true, now, now))
+
+	requests := []struct {
+		name       string
+		method     string
+		path       string
+		auth       string
+		body       string
+		wantStatus int
+	}{
+		{"chat completion", http.MethodPost, "/v1/chat/completions", "Bearer " + orgKey, `{"model":"gpt-4","messages":[]}`, http.StatusOK},
+		{"streaming chat completion", http.MethodPost, "/v1/chat/completions", "Bearer " + orgKey, `{"model":"gpt-4","messages":[],"stream":true}`, http.StatusOK},
+		{"unknown org key", http.MethodPost, "/v1/chat/completions", "Bearer " + unknownOrgKey, `{}`, http.StatusUnauthorized},
+		{"provider key sent as org key", http.MethodPost, "/v1/chat/completions", "Bearer " + providerKey, `{}`, http.StatusUnauthorized},
+		{"wrong admin key", http.MethodGet, "/admin/orgs", "Bearer " + wrongAdminKey, "", http.StatusUnauthorized},
+		{"create org", http.MethodPost, "/admin/orgs", "Bearer " + testAdminAPIKey, `{"name":"New Org"}`, http.StatusCreated},
+		{"create webhook", http.MethodPost, "/admin/webhooks", "Bearer " + testAdminAPIKey, `{"url":"https://hooks.example.com/services/T000/B000/tok123","events":["org.created"]}`, http.StatusCreated},
+	}
+
+	secrets := []string{providerKey, dbPassword, testAdminAPIKey, wrongAdminKey, orgKey, unknownOrgKey, webhookSecret, "tok123"}
+	for _, tt := range requests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Authorization", tt.auth)
+		rec := httptest.NewRecorder()
+
+		server.ServeHTTP(rec, req)
+
+		if rec.Code != tt.wantStatus {
+			t.Fatalf("%s: expected status %d, got %d: %s", tt.name, tt.wantStatus, rec.Code, rec.Body.String())
+		}
+		if tt.name == "create org" {
+			var resp createOrgResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode create response: %v", err)
+			}
+			secrets = append(secrets, resp.APIKey)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+
+	// Careless call sites: whole config and webhook structs
+	hook := &webhook.Webhook{ID: uuid.New(), URL: "https://hooks.example.com/services/T000/B000/tok123", Secret: webhookSecret}
+	slog.Info("config loaded", "config", cfg, "provider_api_key", providerKey)
+	slog.Debug(fmt.Sprintf("config %+v, webhook %+v", cfg, hook))
+	slog.Info("webhook", "webhook", hook, "secret", webhookSecret)
+
+	out := buf.String()
+	if !strings.Contains(out, `"msg":"request"`) {
+		t.Fatalf("expected request log lines, got: %s", out)
+	}
+	for _, secret := range secrets {
+		if strings.Contains(out, secret) {
+			t.Errorf("log output contains secret %q", secret)
+		}
+	}
+}
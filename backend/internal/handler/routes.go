@@ -4,47 +4,120 @@ import (
 	"net/http"
 
 	"navplane/internal/config"
+	"navplane/internal/httpjson"
+	"navplane/internal/metrics"
 	"navplane/internal/middleware"
 	"navplane/internal/org"
+	"navplane/internal/requestlog"
+	"navplane/internal/webhook"
 )
 
 // Deps contains dependencies for route handlers.
 type Deps struct {
-	Config     *config.Config
-	OrgManager *org.Manager
+	Config         *config.Config
+	OrgManager     *org.Manager
+	WebhookManager *webhook.Manager
+
+	// Request log search, and the async writer for the proxy path (nil disables persistence)
+	RequestLogManager *requestlog.Manager
+	RequestLogWriter  *requestlog.Writer
+
+	// ReadinessChecks are dependency probes for /health/ready
+	ReadinessChecks []ReadinessCheck
+
+	// Streams tracks in-flight streaming responses for the shutdown drain (nil disables it)
+	Streams *StreamTracker
 }
 
-// RegisterRoutes registers all HTTP routes with the provided mux.
+// RegisterRoutes registers all HTTP routes with the provided mux, for a
+// server that serves the proxy and admin API on one listener.
 func RegisterRoutes(mux *http.ServeMux, deps *Deps) {
-	// Health and status endpoints (no auth required)
-	mux.HandleFunc("GET /health", HealthCheck)
-	mux.HandleFunc("GET /api/v1/status", statusHandler(deps.Config))
+	RegisterProxyRoutes(mux, deps)
+	RegisterAdminRoutes(mux, deps)
+}
+
+// RegisterProxyRoutes registers the public routes: health checks, the
+// dashboard status API, the OpenAI-compatible proxy and the
+// Anthropic-native Messages API.
+func RegisterProxyRoutes(mux *http.ServeMux, deps *Deps) {
+	// CORS for the browser dashboard; never applied to the /v1/* proxy routes
+	cors := middleware.CORS(deps.Config.CORS)
+
+	// Health endpoints (no auth required).
+	// /health predates the live/ready split and stays an alias for readiness.
+	ready := ReadinessHandler(deps.ReadinessChecks)
+	mux.HandleFunc("GET /health/live", HealthCheck)
+	mux.HandleFunc("GET /health/ready", ready)
+	mux.HandleFunc("GET /health", ready)
+
+	// Dashboard status API (no auth required)
+	apiMux := http.NewServeMux()
+	apiMux.HandleFunc("GET /api/v1/status", statusHandler(deps.Config))
+	mux.Handle("/api/v1/", cors(apiMux))
 
 	// Auth middleware for protected routes
 	authMiddleware := middleware.Auth(deps.OrgManager)
 
 	// OpenAI-compatible API endpoints (auth required)
-	chatHandler := NewChatCompletionsHandler(deps.Config)
-	mux.Handle("POST /v1/chat/completions", authMiddleware(http.HandlerFunc(chatHandler)))
+	chat := newHandler(deps.Config, nil)
+	chat.streams = deps.Streams
+	chatHandler := chat.ServeHTTP
+	persist := middleware.PersistRequests(deps.RequestLogWriter)
+	mux.Handle("POST /v1/chat/completions", authMiddleware(persist(http.HandlerFunc(chatHandler))))
+
+	// Anthropic-native Messages API for clients using the Anthropic SDKs
+	messages := newMessagesHandler(deps.Config, nil)
+	messages.streams = deps.Streams
+	mux.Handle("POST /v1/messages", authMiddleware(persist(messages)))
 
 	// Return proper 405 for other methods on protected endpoints
 	mux.HandleFunc("/v1/chat/completions", methodNotAllowedHandler("POST"))
+	mux.HandleFunc("/v1/messages", methodNotAllowedHandler("POST"))
+}
+
+// RegisterAdminRoutes registers the internal routes: the admin API and the
+// optional debug endpoints, both behind admin auth, plus metrics and build
+// info, which need no auth but shouldn't face the internet when a separate
+// admin listener is configured.
+func RegisterAdminRoutes(mux *http.ServeMux, deps *Deps) {
+	// Admin auth, separate from org auth
+	protect := func(next http.Handler) http.Handler {
+		h := middleware.AdminAuth(deps.Config.AdminAPIKey)(next)
+		if deps.Config.TLS.ClientCAFile != "" {
+			h = middleware.RequireClientCert()(h) // mTLS for the admin API
+		}
+		return h
+	}
 
-	// Admin API endpoints
-	// TODO: Add admin authentication (separate from org auth)
-	registerAdminRoutes(mux, deps)
+	mux.Handle("GET /metrics", metrics.Handler())
+	mux.HandleFunc("GET /version", VersionHandler)
+	if deps.Config.DebugEndpoints {
+		registerDebugRoutes(mux, protect)
+	}
+
+	// Admin API endpoints. CORS sits outside auth so preflights, which
+	// carry no credentials, succeed.
+	cors := middleware.CORS(deps.Config.CORS)
+	adminMux := http.NewServeMux()
+	registerAdminAPI(adminMux, deps)
+	mux.Handle("/admin/", cors(withVersionHeader(protect(adminMux))))
 }
 
-// registerAdminRoutes registers admin API endpoints.
-// These endpoints are for dashboard/internal use only.
-func registerAdminRoutes(mux *http.ServeMux, deps *Deps) {
+// registerAdminAPI registers admin API endpoints.
+// These endpoints are for dashboard/internal use only; RegisterAdminRoutes
+// mounts them behind admin authentication.
+func registerAdminAPI(mux *http.ServeMux, deps *Deps) {
 	adminOrgs := NewAdminOrgsHandler(deps.OrgManager)
 
 	// Organization management
 	mux.HandleFunc("GET /admin/orgs", adminOrgs.List)
 	mux.HandleFunc("POST /admin/orgs", adminOrgs.Create)
+	mux.HandleFunc("GET /admin/orgs/export", adminOrgs.Export)
+	mux.HandleFunc("GET /admin/orgs/lookup", adminOrgs.Lookup)
+	mux.HandleFunc("POST /admin/orgs/lookup", adminOrgs.LookupKey)
 	mux.HandleFunc("GET /admin/orgs/{id}", adminOrgs.Get)
 	mux.HandleFunc("PUT /admin/orgs/{id}", adminOrgs.Update)
+	mux.HandleFunc("PATCH /admin/orgs/{id}", adminOrgs.Patch)
 	mux.HandleFunc("DELETE /admin/orgs/{id}", adminOrgs.Delete)
 
 	// Kill switch - enable/disable org
@@ -52,11 +125,21 @@ func registerAdminRoutes(mux *http.ServeMux, deps *Deps) {
 
 	// API key rotation
 	mux.HandleFunc("POST /admin/orgs/{id}/rotate-key", adminOrgs.RotateAPIKey)
+
+	// Webhook destinations
+	adminWebhooks := NewAdminWebhooksHandler(deps.WebhookManager)
+	mux.HandleFunc("GET /admin/webhooks", adminWebhooks.List)
+	mux.HandleFunc("POST /admin/webhooks", adminWebhooks.Create)
+	mux.HandleFunc("DELETE /admin/webhooks/{id}", adminWebhooks.Delete)
+
+	// Request log search
+	adminRequestLogs := NewAdminRequestLogsHandler(deps.RequestLogManager)
+	mux.HandleFunc("GET /admin/request-logs", adminRequestLogs.List)
 }
 
 func methodNotAllowedHandler(allowedMethods string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Allow", allowedMethods)
-		writeProxyError(w, http.StatusMethodNotAllowed, "method not allowed", "invalid_request_error")
+		httpjson.WriteError(w, http.StatusMethodNotAllowed, "method not allowed", httpjson.TypeInvalidRequest)
 	}
 }
@@ -0,0 +1,369 @@
+package handler
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"navplane/internal/buildinfo"
+	"navplane/internal/config"
+	"navplane/internal/org"
+	"navplane/internal/webhook"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+const testAdminAPIKey = "admin-0123456789abcdef0123456789ab"
+
+func setupRoutesTest(t *testing.T) (*http.ServeMux, sqlmock.Sqlmock, func()) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, &Deps{
+		Config: &config.Config{
+			AdminAPIKey: testAdminAPIKey,
+			CORS: config.CORSConfig{
+				AllowedOrigins: []string{"https://app.navplane.io"},
+				AllowedHeaders: []string{"Authorization", "Content-Type"},
+				MaxAge:         600,
+			},
+		},
+		OrgManager:     org.NewManager(org.NewDatastore(db)),
+		WebhookManager: webhook.NewManager(webhook.NewDatastore(db)),
+	})
+	return mux, mock, func() { db.Close() }
+}
+
+func TestRegisterRoutes_AdminAuth(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		authHeader string
+		wantStatus int
+	}{
+		{"no credentials", http.MethodGet, "/admin/orgs", "", http.StatusUnauthorized},
+		{"org API key rejected", http.MethodGet, "/admin/orgs", "Bearer np_" + uuid.NewString(), http.StatusUnauthorized},
+		{"wrong admin key", http.MethodDelete, "/admin/orgs/" + uuid.NewString(), "Bearer wrong-admin-key-0123456789abcdef", http.StatusUnauthorized},
+		{"unknown admin path still requires auth", http.MethodGet, "/admin/unknown", "", http.StatusUnauthorized},
+		{"webhooks protected", http.MethodGet, "/admin/webhooks", "", http.StatusUnauthorized},
+		{"request logs protected", http.MethodGet, "/admin/request-logs", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux, mock, cleanup := setupRoutesTest(t)
+			defer cleanup()
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+			rec := httptest.NewRecorder()
+
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("handler must not touch the database: %v", err)
+			}
+		})
+	}
+}
+
+func TestRegisterRoutes_AdminAuth_ValidKey(t *testing.T) {
+	mux, mock, cleanup := setupRoutesTest(t)
+	defer cleanup()
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT .+ FROM organizations o`).
+		WithArgs(20, 0).
+		WillReturnRows(sqlmock.NewRows(summaryRowColumns).
+			AddRow(uuid.New(), "Org", "hash", true, now, now, []byte(`{}`), 1, 0, 0))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/orgs", nil)
+	req.Header.Set("Authorization", "Bearer "+testAdminAPIKey)
+	rec := httptest.NewRecorder()
+
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestRegisterRoutes_AdminAuth_NotConfigured(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, &Deps{
+		Config:         &config.Config{},
+		OrgManager:     org.NewManager(org.NewDatastore(db)),
+		WebhookManager: webhook.NewManager(webhook.NewDatastore(db)),
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/orgs", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	rec := httptest.NewRecorder()
+
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %d", rec.Code)
+	}
+}
+
+func TestRegisterRoutes_MetricsNoAuth(t *testing.T) {
+	mux, _, cleanup := setupRoutesTest(t)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rec := httptest.NewRecorder()
+
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "go_goroutines") {
+		t.Error("expected Prometheus exposition output")
+	}
+}
+
+func TestRegisterRoutes_AdminClientCert(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, &Deps{
+		Config: &config.Config{
+			AdminAPIKey: testAdminAPIKey,
+			TLS:         config.TLSConfig{CertFile: "tls.crt", KeyFile: "tls.key", ClientCAFile: "ca.crt"},
+		},
+		OrgManager:     org.NewManager(org.NewDatastore(db)),
+		WebhookManager: webhook.NewManager(webhook.NewDatastore(db)),
+	})
+
+	tests := []struct {
+		name       string
+		tls        *tls.ConnectionState
+		wantStatus int
+	}{
+		{"admin key without client cert", &tls.ConnectionState{}, http.StatusUnauthorized},
+		{"admin key with verified client cert", &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantStatus == http.StatusOK {
+				mock.ExpectQuery(`FROM webhooks`).WillReturnRows(sqlmock.NewRows(webhookRowColumns))
+			}
+			req := httptest.NewRequest(http.MethodGet, "/admin/webhooks", nil)
+			req.Header.Set("Authorization", "Bearer "+testAdminAPIKey)
+			req.TLS = tt.tls
+			rec := httptest.NewRecorder()
+
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	// The proxy is not subject to the admin client cert requirement
+	req := httptest.NewRequest(http.MethodGet, "/health/live", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected non-admin routes to skip client cert checks, got %d", rec.Code)
+	}
+}
+
+func TestRegisterRoutes_CORS(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantCORS   bool
+	}{
+		{"admin preflight skips auth", http.MethodOptions, "/admin/orgs/" + uuid.NewString(), http.StatusNoContent, true},
+		{"status preflight", http.MethodOptions, "/api/v1/status", http.StatusNoContent, true},
+		{"admin request still requires auth", http.MethodGet, "/admin/orgs", http.StatusUnauthorized, true},
+		{"proxy route has no CORS", http.MethodOptions, "/v1/chat/completions", http.StatusMethodNotAllowed, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux, _, cleanup := setupRoutesTest(t)
+			defer cleanup()
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Origin", "https://app.navplane.io")
+			if tt.method == http.MethodOptions {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPut)
+			}
+			rec := httptest.NewRecorder()
+
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin") != ""; got != tt.wantCORS {
+				t.Errorf("expected CORS headers = %v, got %v", tt.wantCORS, got)
+			}
+		})
+	}
+}
+
+func TestRegisterRoutes_Health(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, &Deps{
+		Config:          &config.Config{},
+		OrgManager:      org.NewManager(org.NewDatastore(db)),
+		WebhookManager:  webhook.NewManager(webhook.NewDatastore(db)),
+		ReadinessChecks: []ReadinessCheck{{Name: "database", Check: db.PingContext}},
+	})
+
+	// Liveness never touches the database
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("live: expected status 200, got %d", rec.Code)
+	}
+
+	// Readiness and the legacy alias both ping the database
+	for _, path := range []string{"/health/ready", "/health"} {
+		mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s: expected status 503, got %d", path, rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), `"database":{"status":"fail"`) {
+			t.Errorf("%s: expected database failure in body, got %s", path, rec.Body.String())
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRegisterRoutes_Version(t *testing.T) {
+	mux, _, cleanup := setupRoutesTest(t)
+	defer cleanup()
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var info buildinfo.Info
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if info.Version != buildinfo.Version() || info.GoVersion == "" || info.StartTime.IsZero() {
+		t.Errorf("unexpected version response %+v", info)
+	}
+
+	// The dashboard status reports the same version
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/status", nil))
+	if !strings.Contains(rec.Body.String(), `"version":"`+info.Version+`"`) {
+		t.Errorf("expected status to report version %q, got %s", info.Version, rec.Body.String())
+	}
+}
+
+func TestRegisterRoutes_AdminVersionHeader(t *testing.T) {
+	mux, _, cleanup := setupRoutesTest(t)
+	defer cleanup()
+
+	// Set even when auth fails, so any admin response identifies the build
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/orgs", nil))
+	if got := rec.Header().Get("X-NavPlane-Version"); got != buildinfo.Version() {
+		t.Errorf("expected X-NavPlane-Version %q on admin response, got %q", buildinfo.Version(), got)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+	if got := rec.Header().Get("X-NavPlane-Version"); got != "" {
+		t.Errorf("expected no version header outside /admin, got %q", got)
+	}
+}
+
+func TestRegisterProxyAndAdminRoutes_Split(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+	deps := &Deps{
+		Config:         &config.Config{AdminAPIKey: testAdminAPIKey},
+		OrgManager:     org.NewManager(org.NewDatastore(db)),
+		WebhookManager: webhook.NewManager(webhook.NewDatastore(db)),
+	}
+	proxyMux := http.NewServeMux()
+	RegisterProxyRoutes(proxyMux, deps)
+	adminMux := http.NewServeMux()
+	RegisterAdminRoutes(adminMux, deps)
+
+	tests := []struct {
+		path      string
+		wantProxy int
+		wantAdmin int
+	}{
+		{"/health/live", http.StatusOK, http.StatusNotFound},
+		{"/api/v1/status", http.StatusOK, http.StatusNotFound},
+		{"/v1/chat/completions", http.StatusMethodNotAllowed, http.StatusNotFound},
+		{"/v1/messages", http.StatusMethodNotAllowed, http.StatusNotFound},
+		{"/admin/orgs", http.StatusNotFound, http.StatusUnauthorized},
+		{"/metrics", http.StatusNotFound, http.StatusOK},
+		{"/version", http.StatusNotFound, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			for _, s := range []struct {
+				name string
+				mux  *http.ServeMux
+				want int
+			}{{"proxy", proxyMux, tt.wantProxy}, {"admin", adminMux, tt.wantAdmin}} {
+				rec := httptest.NewRecorder()
+				s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+				if rec.Code != s.want {
+					t.Errorf("%s listener: expected status %d, got %d", s.name, s.want, rec.Code)
+				}
+			}
+		})
+	}
+}
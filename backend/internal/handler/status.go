@@ -1,11 +1,11 @@
 package handler
 
 import (
-	"encoding/json"
-	"log"
 	"net/http"
 
+	"navplane/internal/buildinfo"
 	"navplane/internal/config"
+	"navplane/internal/httpjson"
 )
 
 // statusHandler returns an HTTP handler that has access to the config.
@@ -17,13 +17,28 @@ import (
 // in the status response.
 func statusHandler(cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(map[string]any{
+		httpjson.WriteJSON(w, http.StatusOK, map[string]any{
 			"service": "navplane",
-			"version": "0.1.0",
+			"version": buildinfo.Version(),
 			"status":  "operational",
-		}); err != nil {
-			log.Printf("failed to write status response: %v", err)
-		}
+		})
 	}
 }
+
+// VersionHandler serves the running build's version, commit, build date,
+// Go version and start time at /version.
+func VersionHandler(w http.ResponseWriter, r *http.Request) {
+	httpjson.WriteJSON(w, http.StatusOK, buildinfo.Get())
+}
+
+// versionHeaderName identifies the build that served an admin response.
+const versionHeaderName = "X-NavPlane-Version"
+
+// withVersionHeader sets X-NavPlane-Version on every response from next.
+func withVersionHeader(next http.Handler) http.Handler {
+	version := buildinfo.Version()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(versionHeaderName, version)
+		next.ServeHTTP(w, r)
+	})
+}
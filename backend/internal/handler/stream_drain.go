@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"navplane/internal/httpjson"
+)
+
+// shutdownRetryAfter is the Retry-After, in seconds, sent to streaming
+// requests refused during shutdown; by then the load balancer should be
+// routing to another instance.
+const shutdownRetryAfter = 5
+
+// StreamTracker counts in-flight streaming responses so shutdown can let
+// them finish. Once Drain starts, new streams are refused with 503; streams
+// still open at the drain deadline get an SSE error event and are closed.
+// A nil *StreamTracker tracks nothing and never drains.
+type StreamTracker struct {
+	mu       sync.Mutex
+	active   sync.WaitGroup
+	count    int
+	draining bool
+
+	stopCtx context.Context // Cancelled when remaining streams must end
+	stop    context.CancelFunc
+}
+
+// NewStreamTracker creates a tracker that accepts streams until Drain.
+func NewStreamTracker() *StreamTracker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &StreamTracker{stopCtx: ctx, stop: cancel}
+}
+
+// begin registers a new stream, reporting false once draining has begun.
+// Each successful begin must be paired with end.
+func (t *StreamTracker) begin() bool {
+	if t == nil {
+		return true
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.draining {
+		return false
+	}
+	t.count++
+	t.active.Add(1) // Never races Wait: Drain sets draining under mu first
+	return true
+}
+
+// end unregisters a stream started with begin.
+func (t *StreamTracker) end() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.count--
+	t.mu.Unlock()
+	t.active.Done()
+}
+
+// stopped is cancelled when in-flight streams must be cut off.
+func (t *StreamTracker) stopped() context.Context {
+	if t == nil {
+		return context.Background()
+	}
+	return t.stopCtx
+}
+
+// Active returns the number of in-flight streams.
+func (t *StreamTracker) Active() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.count
+}
+
+// Drain refuses new streams and waits up to timeout for in-flight ones to
+// finish, then tells the rest to send an error event and close, waiting
+// for them until ctx is done. It returns how many streams were cut off.
+func (t *StreamTracker) Drain(ctx context.Context, timeout time.Duration) int {
+	t.mu.Lock()
+	t.draining = true
+	t.mu.Unlock()
+
+	idle := make(chan struct{})
+	go func() {
+		t.active.Wait()
+		close(idle)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-idle:
+		return 0
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	cut := t.Active()
+	t.stop()
+	select {
+	case <-idle:
+	case <-ctx.Done():
+	}
+	return cut
+}
+
+// refuseDuringShutdown writes the 503 returned to streaming requests that
+// arrive while the server drains.
+func refuseDuringShutdown(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(shutdownRetryAfter))
+	httpjson.WriteErrorCode(w, http.StatusServiceUnavailable, "server is shutting down, retry the request", httpjson.TypeServer, "server_shutdown")
+}
+
+// writeShutdownEvent ends an interrupted stream with an OpenAI-style error
+// event. atBoundary reports whether the bytes written so far ended an
+// event; if not, a blank line first terminates the partial one.
+func writeShutdownEvent(w io.Writer, atBoundary bool) error {
+	data, err := json.Marshal(httpjson.ErrorResponse{Error: httpjson.ErrorDetail{
+		Message: "server is shutting down, the stream was interrupted",
+		Type:    httpjson.TypeServer,
+		Code:    "server_shutdown",
+	}})
+	if err != nil {
+		return err
+	}
+	event := "data: " + string(data) + "\n\n"
+	if !atBoundary {
+		event = "\n\n" + event
+	}
+	_, err = io.WriteString(w, event)
+	return err
+}
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowStreamClient returns an upstream that sends one SSE event and then
+// stalls until the upstream request is cancelled.
+func slowStreamClient() *http.Client {
+	return mockHTTPClient(func(req *http.Request) (*http.Response, error) {
+		pr, pw := io.Pipe()
+		go func() {
+			_, _ = io.WriteString(pw, "data: {\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\n\n")
+			<-req.Context().Done()
+			_ = pw.CloseWithError(req.Context().Err())
+		}()
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+			Body:       pr,
+		}, nil
+	})
+}
+
+func postStream(t *testing.T, url string) *http.Response {
+	t.Helper()
+	body := `{"stream": true, "model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}]}`
+	resp, err := http.Post(url+"/v1/chat/completions", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	return resp
+}
+
+func TestStreamTracker_DrainInterruptsSlowStream(t *testing.T) {
+	streams := NewStreamTracker()
+	h := newHandler(testConfig(), slowStreamClient())
+	h.streams = streams
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	resp := postStream(t, srv.URL)
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	if line, err := reader.ReadString('\n'); err != nil || !strings.Contains(line, "Hel") {
+		t.Fatalf("expected first event, got %q (%v)", line, err)
+	}
+	if streams.Active() != 1 {
+		t.Fatalf("expected 1 active stream, got %d", streams.Active())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cut := make(chan int, 1)
+	go func() { cut <- streams.Drain(ctx, 100*time.Millisecond) }()
+	for deadline := time.Now().Add(time.Second); ; {
+		streams.mu.Lock()
+		draining := streams.draining
+		streams.mu.Unlock()
+		if draining {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("drain did not start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// New streams are refused while draining
+	refused := postStream(t, srv.URL)
+	refusedBody, _ := io.ReadAll(refused.Body)
+	refused.Body.Close()
+	if refused.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 during drain, got %d", refused.StatusCode)
+	}
+	if got := refused.Header.Get("Retry-After"); got != "5" {
+		t.Errorf("expected Retry-After 5, got %q", got)
+	}
+	if !strings.Contains(string(refusedBody), `"code":"server_shutdown"`) {
+		t.Errorf("expected server_shutdown error, got %s", refusedBody)
+	}
+
+	// The open stream ends with an error event and a clean close
+	rest, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("expected stream to end cleanly, got %v", err)
+	}
+	if !strings.HasSuffix(string(rest), "\"code\":\"server_shutdown\"}}\n\n") {
+		t.Errorf("expected trailing shutdown error event, got %q", rest)
+	}
+
+	select {
+	case n := <-cut:
+		if n != 1 {
+			t.Errorf("expected 1 interrupted stream, got %d", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Drain did not return after the stream closed")
+	}
+	if streams.Active() != 0 {
+		t.Errorf("expected no active streams, got %d", streams.Active())
+	}
+}
+
+func TestStreamTracker_DrainReturnsWhenIdle(t *testing.T) {
+	streams := NewStreamTracker()
+	if !streams.begin() {
+		t.Fatal("expected begin to succeed before draining")
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		streams.end()
+	}()
+
+	start := time.Now()
+	if cut := streams.Drain(context.Background(), 5*time.Second); cut != 0 {
+		t.Errorf("expected no interrupted streams, got %d", cut)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Drain waited for the deadline although the stream finished")
+	}
+	if streams.stopped().Err() != nil {
+		t.Error("expected streams not to be stopped after a clean drain")
+	}
+	if streams.begin() {
+		t.Error("expected begin to fail once draining")
+	}
+}
+
+func TestWriteShutdownEvent_TerminatesPartialEvent(t *testing.T) {
+	var b strings.Builder
+	if err := writeShutdownEvent(&b, false); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !strings.HasPrefix(b.String(), "\n\ndata: {\"error\":") {
+		t.Errorf("expected blank line before the event, got %q", b.String())
+	}
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"navplane/internal/metrics"
+	"navplane/internal/middleware"
+)
+
+// Stream outcomes for the streams_finished_total counter.
+const (
+	streamCompleted        = "completed"
+	streamUpstreamError    = "upstream_error"
+	streamClientDisconnect = "client_disconnect"
+	streamShutdown         = "shutdown"
+)
+
+// streamStats accumulates measurements for one streamed response.
+type streamStats struct {
+	provider  string
+	model     string
+	start     time.Time // When the upstream request was sent
+	firstByte time.Time // When the first body byte arrived from upstream
+	events    int
+	bytes     int64
+	outcome   string
+}
+
+// record exports the stats as metrics and adds TTFB and event count to the
+// request's log line.
+func (s *streamStats) record(ctx context.Context) {
+	outcome := s.outcome
+	if outcome == "" {
+		outcome = streamUpstreamError
+	}
+
+	metrics.StreamDuration.WithLabelValues(s.provider, s.model).Observe(time.Since(s.start).Seconds())
+	metrics.StreamEvents.WithLabelValues(s.provider, s.model).Add(float64(s.events))
+	metrics.StreamBytes.WithLabelValues(s.provider, s.model).Add(float64(s.bytes))
+	metrics.StreamsFinished.WithLabelValues(s.provider, s.model, outcome).Inc()
+
+	attrs := []slog.Attr{
+		slog.Int("sse_events", s.events),
+		slog.String("stream_outcome", outcome),
+	}
+	if !s.firstByte.IsZero() {
+		ttfb := s.firstByte.Sub(s.start)
+		metrics.StreamTTFB.WithLabelValues(s.provider, s.model).Observe(ttfb.Seconds())
+		attrs = append(attrs, slog.Int64("upstream_ttfb_ms", ttfb.Milliseconds()))
+	}
+	middleware.AddLogAttrs(ctx, attrs...)
+
+	// A stream cut off by the provider is their error even though it began with a 200
+	if outcome == streamUpstreamError {
+		markUpstreamError(ctx)
+	}
+}
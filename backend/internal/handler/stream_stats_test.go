@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"navplane/internal/config"
+	"navplane/internal/logging"
+	"navplane/internal/metrics"
+	"navplane/internal/middleware"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// disconnectedWriter simulates a client that went away: body writes fail.
+type disconnectedWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w disconnectedWriter) Write([]byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestChatCompletions_StreamStats(t *testing.T) {
+	const stream = "data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\n" +
+		"data: {\"choices\":[{\"delta\":{\"content\":\"there\"}}]}\n\n" +
+		"data: [DONE]\n\n"
+
+	tests := []struct {
+		name        string
+		model       string
+		body        func() io.Reader
+		disconnect  bool
+		wantOutcome string
+		wantEvents  int
+		wantBytes   float64
+	}{
+		{
+			name:        "completed",
+			model:       "stats-completed",
+			body:        func() io.Reader { return strings.NewReader(stream) },
+			wantOutcome: streamCompleted,
+			wantEvents:  3,
+			wantBytes:   float64(len(stream)),
+		},
+		{
+			name:  "upstream failure",
+			model: "stats-upstream-error",
+			body: func() io.Reader {
+				return io.MultiReader(strings.NewReader(stream[:50]), iotest.ErrReader(errors.New("connection reset")))
+			},
+			wantOutcome: streamUpstreamError,
+			wantEvents:  1,
+			wantBytes:   50,
+		},
+		{
+			name:        "client disconnect",
+			model:       "stats-client-disconnect",
+			body:        func() io.Reader { return strings.NewReader(stream) },
+			disconnect:  true,
+			wantOutcome: streamClientDisconnect,
+			wantEvents:  3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := mockHTTPClient(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+					Body:       io.NopCloser(tt.body()),
+				}, nil
+			})
+
+			var logs bytes.Buffer
+			logger := logging.New(&logs, slog.LevelInfo, logging.FormatJSON)
+			handler := middleware.RequestLogger(logger, config.LogConfig{})(NewChatCompletionsHandlerWithClient(testConfig(), client))
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions",
+				bytes.NewBufferString(`{"model":"`+tt.model+`","stream":true}`))
+			var w http.ResponseWriter = httptest.NewRecorder()
+			if tt.disconnect {
+				w = disconnectedWriter{httptest.NewRecorder()}
+			}
+
+			handler.ServeHTTP(w, req)
+
+			provider := "api.openai.com"
+			if got := testutil.ToFloat64(metrics.StreamsFinished.WithLabelValues(provider, tt.model, tt.wantOutcome)); got != 1 {
+				t.Errorf("expected 1 %s stream, got %v", tt.wantOutcome, got)
+			}
+			if got := testutil.ToFloat64(metrics.StreamEvents.WithLabelValues(provider, tt.model)); got != float64(tt.wantEvents) {
+				t.Errorf("expected %d events, got %v", tt.wantEvents, got)
+			}
+			if got := testutil.ToFloat64(metrics.StreamBytes.WithLabelValues(provider, tt.model)); got != tt.wantBytes {
+				t.Errorf("expected %v bytes forwarded, got %v", tt.wantBytes, got)
+			}
+			if n := testutil.CollectAndCount(metrics.StreamTTFB); n == 0 {
+				t.Error("expected TTFB to be observed")
+			}
+
+			var entry map[string]any
+			if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+				t.Fatalf("invalid log line %q: %v", logs.String(), err)
+			}
+			if entry["sse_events"] != float64(tt.wantEvents) {
+				t.Errorf("expected sse_events %d in log, got %v", tt.wantEvents, entry["sse_events"])
+			}
+			if entry["stream_outcome"] != tt.wantOutcome {
+				t.Errorf("expected stream_outcome %s in log, got %v", tt.wantOutcome, entry["stream_outcome"])
+			}
+			if _, ok := entry["upstream_ttfb_ms"]; !ok {
+				t.Error("expected upstream_ttfb_ms in log")
+			}
+		})
+	}
+}
+
+func TestRequestModel(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"model":"gpt-4o","stream":true}`, "gpt-4o"},
+		{`{"stream":true}`, "unknown"},
+		{`not json`, "unknown"},
+		{`{"model":["gpt-4o"],"stream":true}`, "unknown"},
+	}
+	for _, tt := range tests {
+		if got, _ := parseChatRequest([]byte(tt.body)); got.Model != tt.want {
+			t.Errorf("parseChatRequest(%s).Model = %q, want %q", tt.body, got.Model, tt.want)
+		}
+	}
+}
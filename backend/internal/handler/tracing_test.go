@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs an in-memory tracer provider for the test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		_ = provider.Shutdown(t.Context())
+	})
+	return recorder
+}
+
+func spanAttrs(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestChatCompletions_UpstreamSpan(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		contentType  string
+		upstreamBody string
+		streaming    bool
+	}{
+		{
+			name:         "non-streaming",
+			body:         `{"model":"gpt-4","messages":[]}`,
+			contentType:  "application/json",
+			upstreamBody: `{"model":"gpt-4-0613","usage":{"prompt_tokens":12,"completion_tokens":5,"total_tokens":17}}`,
+		},
+		{
+			name:         "streaming",
+			body:         `{"model":"gpt-4","messages":[],"stream":true}`,
+			contentType:  "text/event-stream",
+			upstreamBody: "data: {\"model\":\"gpt-4-0613\",\"usage\":{\"prompt_tokens\":12,\"completion_tokens\":5,\"total_tokens\":17}}\n\ndata: [DONE]\n\n",
+			streaming:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := recordSpans(t)
+			client := mockHTTPClient(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": []string{tt.contentType}},
+					Body:       io.NopCloser(strings.NewReader(tt.upstreamBody)),
+				}, nil
+			})
+
+			handler := NewChatCompletionsHandlerWithClient(testConfig(), client)
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(tt.body))
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", rec.Code)
+			}
+
+			ended := recorder.Ended()
+			if len(ended) != 1 {
+				t.Fatalf("expected 1 ended span, got %d", len(ended))
+			}
+			span := ended[0]
+			if span.Name() != "upstream.chat_completions" {
+				t.Errorf("expected span name upstream.chat_completions, got %q", span.Name())
+			}
+
+			attrs := spanAttrs(span)
+			if got := attrs["navplane.provider"].AsString(); got != "api.openai.com" {
+				t.Errorf("expected provider api.openai.com, got %q", got)
+			}
+			if got := attrs["navplane.streaming"].AsBool(); got != tt.streaming {
+				t.Errorf("expected streaming %v, got %v", tt.streaming, got)
+			}
+			if got := attrs["http.response.status_code"].AsInt64(); got != http.StatusOK {
+				t.Errorf("expected status attribute 200, got %d", got)
+			}
+			if got := attrs["gen_ai.usage.input_tokens"].AsInt64(); got != 12 {
+				t.Errorf("expected 12 input tokens, got %d", got)
+			}
+			if got := attrs["gen_ai.usage.output_tokens"].AsInt64(); got != 5 {
+				t.Errorf("expected 5 output tokens, got %d", got)
+			}
+		})
+	}
+}
+
+func TestChatCompletions_UpstreamSpanRecordsError(t *testing.T) {
+	recorder := recordSpans(t)
+	client := mockHTTPClient(func(req *http.Request) (*http.Response, error) {
+		return nil, io.ErrUnexpectedEOF
+	})
+
+	handler := NewChatCompletionsHandlerWithClient(testConfig(), client)
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(`{"model":"gpt-4"}`))
+	handler(httptest.NewRecorder(), req)
+
+	ended := recorder.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("expected 1 ended span, got %d", len(ended))
+	}
+	if got := ended[0].Status().Code.String(); got != "Error" {
+		t.Errorf("expected error status, got %s", got)
+	}
+}
@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"navplane/internal/metrics"
+	"navplane/internal/middleware"
+	"navplane/internal/openai"
+	"navplane/internal/requestlog"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// maxUsageCaptureSize bounds how much of a non-streaming response is
+	// buffered to extract usage. Larger responses are passed through uncounted.
+	maxUsageCaptureSize = 2 * 1024 * 1024 // 2 MB
+
+	// maxSSELineSize bounds a single buffered SSE line. Usage chunks are tiny;
+	// longer lines are content chunks and are skipped.
+	maxSSELineSize = 64 * 1024
+)
+
+// completionUsage is the subset of a completion (or final stream chunk) that
+// carries token usage.
+type completionUsage struct {
+	Model string        `json:"model"`
+	Usage *openai.Usage `json:"usage"`
+}
+
+// recordUsage adds reported token counts to the tokens-proxied counters, the
+// request log entry and the upstream span in ctx.
+func recordUsage(ctx context.Context, u completionUsage) {
+	if u.Usage == nil {
+		return
+	}
+
+	orgID := "unknown"
+	if o := middleware.GetOrg(ctx); o != nil {
+		orgID = o.ID.String()
+	}
+	model := u.Model
+	if model == "" {
+		model = "unknown"
+	}
+
+	metrics.TokensProxied.WithLabelValues(orgID, model, "prompt").Add(float64(u.Usage.PromptTokens))
+	metrics.TokensProxied.WithLabelValues(orgID, model, "completion").Add(float64(u.Usage.CompletionTokens))
+
+	if e := requestlog.FromContext(ctx); e != nil {
+		e.PromptTokens = u.Usage.PromptTokens
+		e.CompletionTokens = u.Usage.CompletionTokens
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("gen_ai.response.model", model),
+		attribute.Int("gen_ai.usage.input_tokens", u.Usage.PromptTokens),
+		attribute.Int("gen_ai.usage.output_tokens", u.Usage.CompletionTokens),
+	)
+}
+
+// parseCompletionUsage extracts usage from a non-streaming response body.
+func parseCompletionUsage(body []byte) (completionUsage, bool) {
+	var u completionUsage
+	if err := json.Unmarshal(body, &u); err != nil || u.Usage == nil {
+		return completionUsage{}, false
+	}
+	return u, true
+}
+
+// anthropicUsage is Anthropic's usage object. With prompt caching the
+// input is split across three counts; all of them are prompt tokens.
+type anthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+func (u *anthropicUsage) promptTokens() int {
+	return u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+}
+
+// anthropicMessage is the subset of a Messages API response that carries
+// token usage.
+type anthropicMessage struct {
+	Model string          `json:"model"`
+	Usage *anthropicUsage `json:"usage"`
+}
+
+// anthropicEvent is the subset of a Messages API stream event that carries
+// token usage: message_start nests the message with the input count, and
+// message_delta reports the cumulative output count at the top level.
+type anthropicEvent struct {
+	Type    string            `json:"type"`
+	Message *anthropicMessage `json:"message"`
+	Usage   *anthropicUsage   `json:"usage"`
+}
+
+// parseAnthropicUsage extracts usage from a non-streaming Messages API
+// response body.
+func parseAnthropicUsage(body []byte) (completionUsage, bool) {
+	var m anthropicMessage
+	if err := json.Unmarshal(body, &m); err != nil || m.Usage == nil {
+		return completionUsage{}, false
+	}
+	return completionUsage{Model: m.Model, Usage: &openai.Usage{
+		PromptTokens:     m.Usage.promptTokens(),
+		CompletionTokens: m.Usage.OutputTokens,
+		TotalTokens:      m.Usage.promptTokens() + m.Usage.OutputTokens,
+	}}, true
+}
+
+// mergeAnthropicEvent folds the usage in one Messages API stream event
+// into u, reporting whether the event carried any.
+func mergeAnthropicEvent(data []byte, u *completionUsage) bool {
+	var e anthropicEvent
+	if err := json.Unmarshal(data, &e); err != nil {
+		return false
+	}
+	var usage *anthropicUsage
+	switch e.Type {
+	case "message_start":
+		if e.Message == nil || e.Message.Usage == nil {
+			return false
+		}
+		u.Model = e.Message.Model
+		usage = e.Message.Usage
+	case "message_delta":
+		usage = e.Usage
+	}
+	if usage == nil {
+		return false
+	}
+
+	if u.Usage == nil {
+		u.Usage = &openai.Usage{}
+	}
+	// Counts are cumulative; a delta repeats input only when it has changed
+	if prompt := usage.promptTokens(); prompt > 0 {
+		u.Usage.PromptTokens = prompt
+	}
+	u.Usage.CompletionTokens = usage.OutputTokens
+	u.Usage.TotalTokens = u.Usage.PromptTokens + u.Usage.CompletionTokens
+	return true
+}
+
+// cappedBuffer collects up to limit bytes; once exceeded it discards everything.
+type cappedBuffer struct {
+	buf      bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.overflow {
+		return len(p), nil
+	}
+	if b.buf.Len()+len(p) > b.limit {
+		b.overflow = true
+		b.buf.Reset()
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// Bytes returns the captured body, or nil if the limit was exceeded.
+func (b *cappedBuffer) Bytes() []byte {
+	if b.overflow {
+		return nil
+	}
+	return b.buf.Bytes()
+}
+
+// sseUsageScanner watches SSE bytes as they are streamed to the client,
+// counts "data:" events and keeps the last usage block seen in one.
+type sseUsageScanner struct {
+	line     []byte
+	skipping bool // current line exceeded maxSSELineSize
+	usage    completionUsage
+	found    bool
+	events   int
+
+	// merge folds an event's usage into the running total for providers
+	// that spread it over several events; nil keeps the last OpenAI block
+	merge func(data []byte, u *completionUsage) bool
+}
+
+func (s *sseUsageScanner) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			s.appendLine(p)
+			break
+		}
+		s.appendLine(p[:i])
+		s.processLine()
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+func (s *sseUsageScanner) appendLine(p []byte) {
+	if s.skipping {
+		return
+	}
+	if len(s.line)+len(p) > maxSSELineSize {
+		s.skipping = true
+		s.line = s.line[:0]
+		return
+	}
+	s.line = append(s.line, p...)
+}
+
+func (s *sseUsageScanner) processLine() {
+	line := bytes.TrimSpace(s.line)
+	skipping := s.skipping
+	s.line = s.line[:0]
+	s.skipping = false
+	if skipping {
+		// Oversized lines are content chunks; still count them as events
+		s.events++
+		return
+	}
+
+	data, ok := bytes.CutPrefix(line, []byte("data:"))
+	if !ok {
+		return
+	}
+	s.events++
+	data = bytes.TrimSpace(data)
+	if !bytes.Contains(data, []byte(`"usage"`)) {
+		return
+	}
+	if s.merge != nil {
+		if s.merge(data, &s.usage) {
+			s.found = true
+		}
+		return
+	}
+	if u, ok := parseCompletionUsage(data); ok {
+		s.usage = u
+		s.found = true
+	}
+}
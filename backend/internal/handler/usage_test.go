@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"navplane/internal/metrics"
+	"navplane/internal/middleware"
+	"navplane/internal/org"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSSEUsageScanner_SplitAcrossWrites(t *testing.T) {
+	stream := "data: {\"model\":\"gpt-4o\",\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\n" +
+		"data: {\"model\":\"gpt-4o\",\"choices\":[],\"usage\":{\"prompt_tokens\":7,\"completion_tokens\":3,\"total_tokens\":10}}\n\n" +
+		"data: [DONE]\n\n"
+
+	// Feed in small chunks so lines straddle write boundaries
+	s := &sseUsageScanner{}
+	for i := 0; i < len(stream); i += 5 {
+		chunk := []byte(stream[i:min(i+5, len(stream))])
+		if n, _ := s.Write(chunk); n != len(chunk) {
+			t.Fatalf("expected Write to report %d bytes, got %d", len(chunk), n)
+		}
+	}
+
+	if !s.found {
+		t.Fatal("expected usage to be found")
+	}
+	if s.usage.Model != "gpt-4o" || s.usage.Usage.PromptTokens != 7 || s.usage.Usage.CompletionTokens != 3 {
+		t.Errorf("unexpected usage: %+v %+v", s.usage, s.usage.Usage)
+	}
+}
+
+func TestSSEUsageScanner_SkipsOversizedLines(t *testing.T) {
+	s := &sseUsageScanner{}
+	_, _ = s.Write([]byte("data: " + strings.Repeat("x", maxSSELineSize+10) + `"usage"` + "\n"))
+	_, _ = s.Write([]byte("data: {\"usage\":{\"prompt_tokens\":1,\"completion_tokens\":2}}\n"))
+
+	if !s.found || s.usage.Usage.CompletionTokens != 2 {
+		t.Errorf("expected usage from line after oversized line, got %+v", s.usage)
+	}
+}
+
+func TestCappedBuffer(t *testing.T) {
+	b := &cappedBuffer{limit: 4}
+	_, _ = b.Write([]byte("ab"))
+	if string(b.Bytes()) != "ab" {
+		t.Errorf("expected 'ab', got %q", b.Bytes())
+	}
+	_, _ = b.Write([]byte("cde"))
+	if b.Bytes() != nil {
+		t.Errorf("expected nil after overflow, got %q", b.Bytes())
+	}
+}
+
+func TestChatCompletions_RecordsTokenMetrics(t *testing.T) {
+	testOrg := &org.Org{ID: uuid.New(), Name: "Metrics Org"}
+	upstreamBody := `{"id":"chatcmpl-1","model":"gpt-4o-mini","choices":[],"usage":{"prompt_tokens":11,"completion_tokens":5,"total_tokens":16}}`
+
+	client := mockHTTPClient(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(upstreamBody)),
+		}, nil
+	})
+	handler := NewChatCompletionsHandlerWithClient(testConfig(), client)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(`{"model":"gpt-4o-mini"}`))
+	req = req.WithContext(context.WithValue(req.Context(), middleware.OrgContextKey, testOrg))
+	rec := httptest.NewRecorder()
+
+	prompt := metrics.TokensProxied.WithLabelValues(testOrg.ID.String(), "gpt-4o-mini", "prompt")
+	completion := metrics.TokensProxied.WithLabelValues(testOrg.ID.String(), "gpt-4o-mini", "completion")
+
+	handler(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if rec.Body.String() != upstreamBody {
+		t.Errorf("expected body passed through unchanged, got %q", rec.Body.String())
+	}
+	if got := testutil.ToFloat64(prompt); got != 11 {
+		t.Errorf("expected 11 prompt tokens, got %v", got)
+	}
+	if got := testutil.ToFloat64(completion); got != 5 {
+		t.Errorf("expected 5 completion tokens, got %v", got)
+	}
+	if testutil.CollectAndCount(metrics.UpstreamRequestDuration) == 0 {
+		t.Error("expected upstream duration to be observed")
+	}
+}
+
+func TestChatCompletions_StreamingMetrics(t *testing.T) {
+	testOrg := &org.Org{ID: uuid.New(), Name: "Streaming Org"}
+	stream := "data: {\"model\":\"gpt-4o\",\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\n" +
+		"data: {\"model\":\"gpt-4o\",\"choices\":[],\"usage\":{\"prompt_tokens\":4,\"completion_tokens\":2,\"total_tokens\":6}}\n\n" +
+		"data: [DONE]\n\n"
+
+	var activeDuringStream float64
+	client := mockHTTPClient(func(req *http.Request) (*http.Response, error) {
+		pr, pw := io.Pipe()
+		go func() {
+			_, _ = pw.Write([]byte(stream[:40]))
+			activeDuringStream = testutil.ToFloat64(metrics.ActiveStreams)
+			_, _ = pw.Write([]byte(stream[40:]))
+			_ = pw.Close()
+		}()
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+			Body:       pr,
+		}, nil
+	})
+	handler := NewChatCompletionsHandlerWithClient(testConfig(), client)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(`{"model":"gpt-4o","stream":true}`))
+	req = req.WithContext(context.WithValue(req.Context(), middleware.OrgContextKey, testOrg))
+	rec := httptest.NewRecorder()
+
+	before := testutil.ToFloat64(metrics.ActiveStreams)
+	handler(rec, req)
+
+	if activeDuringStream != before+1 {
+		t.Errorf("expected %v active streams during stream, got %v", before+1, activeDuringStream)
+	}
+	if got := testutil.ToFloat64(metrics.ActiveStreams); got != before {
+		t.Errorf("expected active streams back to %v, got %v", before, got)
+	}
+	if got := testutil.ToFloat64(metrics.TokensProxied.WithLabelValues(testOrg.ID.String(), "gpt-4o", "completion")); got != 2 {
+		t.Errorf("expected 2 completion tokens, got %v", got)
+	}
+}
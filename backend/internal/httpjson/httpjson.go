@@ -0,0 +1,169 @@
+// Package httpjson provides the JSON response and request helpers shared by
+// every NavPlane HTTP handler, so that all endpoints emit one error schema.
+package httpjson
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// RequestIDHeader is the header carrying the request ID. When set on the
+// response before an error is written, its value is echoed in the error body.
+const RequestIDHeader = "X-Request-ID"
+
+// OpenAI-compatible error types.
+const (
+	TypeInvalidRequest = "invalid_request_error"
+	TypeAuthentication = "authentication_error"
+	TypePermission     = "permission_error"
+	TypeNotFound       = "not_found_error"
+	TypeRateLimit      = "rate_limit_error"
+	TypeServer         = "server_error"
+)
+
+// DefaultMaxBodyBytes is the request body limit used when DecodeOptions.MaxBytes is zero.
+const DefaultMaxBodyBytes = 1 << 20 // 1 MB
+
+// ErrorResponse is the single error schema returned by all endpoints:
+//
+//	{"error": {"message": "...", "type": "...", "code": "...", "request_id": "..."}}
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail contains the error fields. Code, RequestID and ProviderError
+// are omitted when empty.
+type ErrorDetail struct {
+	Message   string `json:"message"`
+	Type      string `json:"type"`
+	Code      string `json:"code,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+
+	// ProviderError is an upstream provider's original error payload, kept
+	// when the proxy translates it into this schema
+	ProviderError json.RawMessage `json:"provider_error,omitempty"`
+}
+
+// WriteJSON writes data as a JSON response with the given status code.
+func WriteJSON(w http.ResponseWriter, status int, data any) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		slog.Error("failed to encode JSON response", "error", err)
+		status = http.StatusInternalServerError
+		body = []byte(`{"error":{"message":"failed to encode response","type":"server_error"}}`)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(body); err != nil {
+		slog.Error("failed to write JSON response", "error", err)
+	}
+}
+
+// WriteError writes an error response with the given message and type.
+func WriteError(w http.ResponseWriter, status int, message, errorType string) {
+	WriteErrorCode(w, status, message, errorType, "")
+}
+
+// WriteErrorCode writes an error response with a machine-readable code.
+func WriteErrorCode(w http.ResponseWriter, status int, message, errorType, code string) {
+	WriteJSON(w, status, ErrorResponse{
+		Error: ErrorDetail{
+			Message:   message,
+			Type:      errorType,
+			Code:      code,
+			RequestID: w.Header().Get(RequestIDHeader),
+		},
+	})
+}
+
+// WriteStatusError writes an error response whose type is derived from status.
+func WriteStatusError(w http.ResponseWriter, status int, message string) {
+	WriteError(w, status, message, TypeForStatus(status))
+}
+
+// TypeForStatus maps an HTTP status code to its error type.
+func TypeForStatus(status int) string {
+	switch {
+	case status == http.StatusUnauthorized:
+		return TypeAuthentication
+	case status == http.StatusForbidden:
+		return TypePermission
+	case status == http.StatusNotFound:
+		return TypeNotFound
+	case status == http.StatusTooManyRequests:
+		return TypeRateLimit
+	case status >= 500:
+		return TypeServer
+	default:
+		return TypeInvalidRequest
+	}
+}
+
+// Decoding errors returned by Decode.
+var (
+	ErrEmptyBody    = errors.New("request body is empty")
+	ErrBodyTooLarge = errors.New("request body too large")
+	ErrInvalidJSON  = errors.New("invalid JSON")
+)
+
+// DecodeOptions controls request body decoding.
+type DecodeOptions struct {
+	MaxBytes              int64 // Body size limit; DefaultMaxBodyBytes when zero
+	DisallowUnknownFields bool  // Reject fields not present in the destination struct
+}
+
+// Decode reads a single JSON value from the request body into v.
+// Returns ErrEmptyBody, ErrBodyTooLarge, or an error wrapping ErrInvalidJSON.
+func Decode(w http.ResponseWriter, r *http.Request, v any, opts DecodeOptions) error {
+	maxBytes := opts.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBodyBytes
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBytes))
+	if opts.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+
+	if err := dec.Decode(v); err != nil {
+		var maxErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxErr):
+			return ErrBodyTooLarge
+		case errors.Is(err, io.EOF):
+			return ErrEmptyBody
+		default:
+			return fmt.Errorf("%w: %v", ErrInvalidJSON, err)
+		}
+	}
+
+	// Reject trailing data such as a second JSON object
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return ErrBodyTooLarge
+		}
+		return fmt.Errorf("%w: body must contain a single JSON value", ErrInvalidJSON)
+	}
+
+	return nil
+}
+
+// WriteDecodeError writes the error response for a Decode failure:
+// 413 for oversized bodies, 400 otherwise.
+func WriteDecodeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrBodyTooLarge) {
+		WriteError(w, http.StatusRequestEntityTooLarge, ErrBodyTooLarge.Error(), TypeInvalidRequest)
+		return
+	}
+	if errors.Is(err, ErrEmptyBody) {
+		WriteError(w, http.StatusBadRequest, ErrEmptyBody.Error(), TypeInvalidRequest)
+		return
+	}
+	WriteError(w, http.StatusBadRequest, "invalid JSON", TypeInvalidRequest)
+}
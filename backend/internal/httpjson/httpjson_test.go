@@ -0,0 +1,151 @@
+package httpjson
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteJSON(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	WriteJSON(rec, http.StatusCreated, map[string]string{"id": "abc"})
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("expected status 201, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected Content-Type application/json, got %s", ct)
+	}
+	if rec.Body.String() != `{"id":"abc"}` {
+		t.Errorf("unexpected body %q", rec.Body.String())
+	}
+}
+
+func TestWriteJSON_EncodeFailure(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	WriteJSON(rec, http.StatusOK, map[string]any{"bad": make(chan int)})
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", rec.Code)
+	}
+}
+
+func TestWriteErrorCode(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rec.Header().Set(RequestIDHeader, "req_123")
+
+	WriteErrorCode(rec, http.StatusUnauthorized, "invalid API key", TypeAuthentication, "invalid_api_key")
+
+	want := `{"error":{"message":"invalid API key","type":"authentication_error","code":"invalid_api_key","request_id":"req_123"}}`
+	if rec.Body.String() != want {
+		t.Errorf("expected body %q, got %q", want, rec.Body.String())
+	}
+}
+
+func TestWriteError_OmitsEmptyOptionalFields(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	WriteError(rec, http.StatusBadRequest, "bad", TypeInvalidRequest)
+
+	want := `{"error":{"message":"bad","type":"invalid_request_error"}}`
+	if rec.Body.String() != want {
+		t.Errorf("expected body %q, got %q", want, rec.Body.String())
+	}
+}
+
+func TestTypeForStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		want   string
+	}{
+		{http.StatusBadRequest, TypeInvalidRequest},
+		{http.StatusConflict, TypeInvalidRequest},
+		{http.StatusUnauthorized, TypeAuthentication},
+		{http.StatusForbidden, TypePermission},
+		{http.StatusNotFound, TypeNotFound},
+		{http.StatusTooManyRequests, TypeRateLimit},
+		{http.StatusInternalServerError, TypeServer},
+		{http.StatusBadGateway, TypeServer},
+	}
+
+	for _, tt := range tests {
+		if got := TypeForStatus(tt.status); got != tt.want {
+			t.Errorf("TypeForStatus(%d): expected %q, got %q", tt.status, tt.want, got)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	type payload struct {
+		Name string `json:"name"`
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		opts    DecodeOptions
+		wantErr error
+	}{
+		{name: "valid", body: `{"name":"a"}`},
+		{name: "unknown field allowed", body: `{"name":"a","extra":1}`},
+		{name: "unknown field rejected", body: `{"name":"a","extra":1}`, opts: DecodeOptions{DisallowUnknownFields: true}, wantErr: ErrInvalidJSON},
+		{name: "empty body", body: ``, wantErr: ErrEmptyBody},
+		{name: "malformed", body: `{"name":`, wantErr: ErrInvalidJSON},
+		{name: "trailing data", body: `{"name":"a"}{"name":"b"}`, wantErr: ErrInvalidJSON},
+		{name: "too large", body: `{"name":"` + strings.Repeat("a", 64) + `"}`, opts: DecodeOptions{MaxBytes: 16}, wantErr: ErrBodyTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+
+			var p payload
+			err := Decode(rec, req, &p, tt.opts)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if p.Name != "a" {
+					t.Errorf("expected name 'a', got %q", p.Name)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestWriteDecodeError(t *testing.T) {
+	tests := []struct {
+		err        error
+		wantStatus int
+	}{
+		{ErrBodyTooLarge, http.StatusRequestEntityTooLarge},
+		{ErrEmptyBody, http.StatusBadRequest},
+		{ErrInvalidJSON, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		WriteDecodeError(rec, tt.err)
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%v: expected status %d, got %d", tt.err, tt.wantStatus, rec.Code)
+		}
+		var resp ErrorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid error body: %v", err)
+		}
+		if resp.Error.Type != TypeInvalidRequest {
+			t.Errorf("%v: expected type %q, got %q", tt.err, TypeInvalidRequest, resp.Error.Type)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Package logging configures the process-wide structured logger.
+//
+// All packages log through log/slog's default logger; main calls New and
+// slog.SetDefault once at startup.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Supported LOG_FORMAT values.
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Redacted replaces secret values: in log attributes whose key looks
+// sensitive, and in the String methods of types that hold secrets.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys that are never logged verbatim.
+// Keys are matched lowercased with "-" folded to "_", so header names like
+// X-Api-Key match too. This is a backstop; call sites must not log secrets
+// or request bodies.
+var sensitiveKeys = map[string]bool{
+	"authorization": true,
+	"api_key":       true,
+	"apikey":        true,
+	"x_api_key":     true,
+	"encrypted_key": true,
+	"secret":        true,
+	"password":      true,
+	"token":         true,
+	"cookie":        true,
+	"set_cookie":    true,
+	"database_url":  true,
+	"body":          true,
+}
+
+// sensitiveSuffixes catch prefixed variants such as provider_api_key or
+// webhook_secret.
+var sensitiveSuffixes = []string{"_api_key", "_secret", "_token", "_password"}
+
+// ParseLevel converts a LOG_LEVEL value (debug, info, warn, error) to a slog.Level.
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("unknown log level %q: must be debug, info, warn, or error", s)
+	}
+}
+
+// New creates a logger writing to w in the given format ("json" or "text").
+func New(w io.Writer, level slog.Level, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redact,
+	}
+	if format == FormatText {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if isSensitiveKey(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	return a
+}
+
+// isSensitiveKey reports whether values logged under key must be masked.
+func isSensitiveKey(key string) bool {
+	key = strings.ReplaceAll(strings.ToLower(key), "-", "_")
+	if sensitiveKeys[key] {
+		return true
+	}
+	for _, suffix := range sensitiveSuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    slog.Level
+		wantErr bool
+	}{
+		{"", slog.LevelInfo, false},
+		{"debug", slog.LevelDebug, false},
+		{"INFO", slog.LevelInfo, false},
+		{"warn", slog.LevelWarn, false},
+		{"warning", slog.LevelWarn, false},
+		{"error", slog.LevelError, false},
+		{"verbose", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseLevel(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNew_JSONFormat(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo, FormatJSON)
+
+	logger.Debug("hidden")
+	logger.Info("hello", "org_id", "abc")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 line (debug filtered), got %d: %q", len(lines), buf.String())
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("expected JSON output, got %q: %v", lines[0], err)
+	}
+	if entry["msg"] != "hello" || entry["org_id"] != "abc" {
+		t.Errorf("unexpected entry: %v", entry)
+	}
+}
+
+func TestNew_TextFormat(t *testing.T) {
+	var buf bytes.Buffer
+	New(&buf, slog.LevelInfo, FormatText).Info("hello")
+
+	if !strings.Contains(buf.String(), "msg=hello") {
+		t.Errorf("expected text output, got %q", buf.String())
+	}
+}
+
+func TestNew_RedactsSensitiveKeys(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo, FormatJSON)
+
+	logger.Info("oops",
+		"authorization", "Bearer np_secret",
+		"api_key", "sk-secret",
+		"Secret", "whsec_secret",
+		"body", `{"messages":[]}`,
+		"prompt_tokens", 12,
+	)
+
+	out := buf.String()
+	for _, leaked := range []string{"np_secret", "sk-secret", "whsec_secret", "messages"} {
+		if strings.Contains(out, leaked) {
+			t.Errorf("log output leaked %q: %s", leaked, out)
+		}
+	}
+	if !strings.Contains(out, `"prompt_tokens":12`) {
+		t.Errorf("non-sensitive attribute was redacted: %s", out)
+	}
+}
+
+func TestIsSensitiveKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"Authorization", true},
+		{"X-Api-Key", true},
+		{"encrypted_key", true},
+		{"provider_api_key", true},
+		{"webhook_secret", true},
+		{"refresh_token", true},
+		{"Set-Cookie", true},
+		{"prompt_tokens", false},
+		{"request_id", false},
+		{"org_id", false},
+		{"key_id", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := isSensitiveKey(tt.key); got != tt.want {
+				t.Errorf("isSensitiveKey(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+// Package metrics defines NavPlane's Prometheus metrics.
+//
+// Collectors are registered on a package-level Registry rather than the global
+// default so tests can inspect them and nothing else leaks onto /metrics.
+// This package imports only Prometheus, so handlers, middleware and managers
+// can all import it without cycles.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "navplane"
+
+// Registry holds every NavPlane collector plus Go runtime and process stats.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+var (
+	// HTTPRequestDuration observes request latency by mux route pattern.
+	HTTPRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "status"})
+
+	// UpstreamRequestDuration observes time to upstream response headers.
+	UpstreamRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "Upstream provider latency (time to response headers) by provider and status code.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"provider", "status"})
+
+	// TokensProxied counts tokens reported in upstream usage blocks.
+	TokensProxied = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tokens_proxied_total",
+		Help:      "Tokens reported by upstream providers, by org, model and type (prompt or completion).",
+	}, []string{"org_id", "model", "type"})
+
+	// ActiveStreams tracks in-flight SSE streaming responses.
+	ActiveStreams = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_streaming_connections",
+		Help:      "Streaming chat completion responses currently in flight.",
+	})
+
+	// StreamTTFB observes time from sending the upstream request to the first
+	// streamed body byte.
+	StreamTTFB = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "stream_ttfb_seconds",
+		Help:      "Time to first streamed byte from the upstream provider, by provider and model.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2, 3, 5, 10, 20, 30},
+	}, []string{"provider", "model"})
+
+	// StreamDuration observes total streaming response duration.
+	StreamDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "stream_duration_seconds",
+		Help:      "Total streaming response duration by provider and model.",
+		Buckets:   []float64{1, 2.5, 5, 10, 20, 30, 60, 120, 300, 600},
+	}, []string{"provider", "model"})
+
+	// StreamEvents counts SSE data events forwarded to clients.
+	StreamEvents = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "stream_events_total",
+		Help:      "SSE data events forwarded to clients by provider and model.",
+	}, []string{"provider", "model"})
+
+	// StreamBytes counts streamed bytes forwarded to clients.
+	StreamBytes = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "stream_bytes_total",
+		Help:      "Streamed bytes forwarded to clients by provider and model.",
+	}, []string{"provider", "model"})
+
+	// StreamsFinished counts streams by how they ended: completed,
+	// upstream_error, or client_disconnect.
+	StreamsFinished = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "streams_finished_total",
+		Help:      "Finished streaming responses by provider, model and outcome.",
+	}, []string{"provider", "model", "outcome"})
+
+	// RequestLogsDropped counts request log entries not persisted, either
+	// because the write buffer was full or the insert failed.
+	RequestLogsDropped = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "request_logs_dropped_total",
+		Help:      "Request log entries dropped under backpressure or insert failure.",
+	})
+
+	// AuthFailures counts rejected authentication attempts.
+	AuthFailures = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_failures_total",
+		Help:      "Rejected authentication attempts by scope (org or admin) and reason.",
+	}, []string{"scope", "reason"})
+
+	// DBQueryRetries counts read queries retried after a transient
+	// database error, by query name.
+	DBQueryRetries = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_query_retries_total",
+		Help:      "Read queries retried after a transient database error, by query.",
+	}, []string{"query"})
+
+	// DBReads counts queries made through the read replica router, by
+	// target (replica or primary).
+	DBReads = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_reads_total",
+		Help:      "Read queries routed by the replica router, by target (replica or primary).",
+	}, []string{"target"})
+
+	// DBReplicaFailovers counts switches from the read replica to the
+	// primary after the replica became unreachable.
+	DBReplicaFailovers = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_replica_failovers_total",
+		Help:      "Times reads failed over from the read replica to the primary.",
+	})
+
+	// PanicsRecovered counts handler panics caught by the recovery middleware.
+	PanicsRecovered = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "panics_recovered_total",
+		Help:      "Handler panics recovered by route.",
+	}, []string{"route"})
+)
+
+// RegisterDB exports connection pool statistics for db, labelled
+// db_name=name.
+func RegisterDB(db *sql.DB, name string) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
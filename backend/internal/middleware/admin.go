@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+
+	"navplane/internal/auth"
+	"navplane/internal/metrics"
+)
+
+// AdminContextKey is the context key for the AdminIdentity that passed
+// admin auth.
+const AdminContextKey contextKey = "admin"
+
+// AdminIdentity is who passed admin auth, for audit records. Every admin
+// shares ADMIN_API_KEY, so its fingerprint only says which key was in use;
+// the client certificate subject, when mTLS is on, names the caller.
+type AdminIdentity struct {
+	KeyFingerprint string // First 16 hex characters of the key's SHA-256
+	CertSubject    string // Verified client certificate subject; empty without mTLS
+}
+
+// String identifies the admin by certificate subject when there is one,
+// else by key fingerprint.
+func (a AdminIdentity) String() string {
+	if a.CertSubject != "" {
+		return "cert:" + a.CertSubject
+	}
+	return "key:" + a.KeyFingerprint
+}
+
+// GetAdmin returns the identity AdminAuth stored in ctx, or nil outside
+// admin routes.
+func GetAdmin(ctx context.Context) *AdminIdentity {
+	a, ok := ctx.Value(AdminContextKey).(*AdminIdentity)
+	if !ok {
+		return nil
+	}
+	return a
+}
+
+// AdminAuth creates middleware that protects admin endpoints with a static
+// ADMIN_API_KEY sent as "Authorization: Bearer <key>". This is separate from org API key auth: org keys
+// are never accepted here. Authenticated requests carry an AdminIdentity.
+//
+// An empty apiKey rejects every request so that a missing configuration fails
+// closed rather than exposing the admin API.
+func AdminAuth(apiKey string) func(http.Handler) http.Handler {
+	sum := sha256.Sum256([]byte(apiKey))
+	fingerprint := hex.EncodeToString(sum[:8])
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if apiKey == "" {
+				metrics.AuthFailures.WithLabelValues("admin", "not_configured").Inc()
+				slog.Warn("admin request rejected: ADMIN_API_KEY is not configured")
+				writeAuthError(w, http.StatusUnauthorized, "admin authentication is not configured")
+				return
+			}
+
+			// Bearer only: unlike org keys, the admin key is never taken
+			// from X-Api-Key, which proxies and clients log more freely
+			token, err := auth.ExtractBearerToken(r)
+			if err != nil {
+				metrics.AuthFailures.WithLabelValues("admin", "missing_token").Inc()
+				writeAuthError(w, http.StatusUnauthorized, "missing or invalid authorization header")
+				return
+			}
+
+			// Constant-time comparison to avoid leaking the key via timing
+			if subtle.ConstantTimeCompare([]byte(token), []byte(apiKey)) != 1 {
+				metrics.AuthFailures.WithLabelValues("admin", "invalid_key").Inc()
+				writeAuthError(w, http.StatusUnauthorized, "invalid admin API key")
+				return
+			}
+
+			admin := &AdminIdentity{KeyFingerprint: fingerprint}
+			if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+				admin.CertSubject = r.TLS.VerifiedChains[0][0].Subject.String()
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), AdminContextKey, admin)))
+		})
+	}
+}
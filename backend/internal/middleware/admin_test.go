@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminAuth(t *testing.T) {
+	const adminKey = "admin-key-0123456789abcdef0123456789"
+
+	tests := []struct {
+		name         string
+		apiKey       string
+		authHeader   string
+		apiKeyHeader string // X-Api-Key
+		wantStatus   int
+	}{
+		{
+			name:       "valid admin key",
+			apiKey:     adminKey,
+			authHeader: "Bearer " + adminKey,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "missing header",
+			apiKey:     adminKey,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "wrong scheme",
+			apiKey:     adminKey,
+			authHeader: "Basic " + adminKey,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "wrong key",
+			apiKey:     adminKey,
+			authHeader: "Bearer np_550e8400-e29b-41d4-a716-446655440000",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "key prefix only",
+			apiKey:     adminKey,
+			authHeader: "Bearer " + adminKey[:10],
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:         "x-api-key not accepted",
+			apiKey:       adminKey,
+			apiKeyHeader: adminKey,
+			wantStatus:   http.StatusUnauthorized,
+		},
+		{
+			name:         "x-api-key ignored next to bearer",
+			apiKey:       adminKey,
+			authHeader:   "Bearer " + adminKey,
+			apiKeyHeader: "wrong",
+			wantStatus:   http.StatusOK,
+		},
+		{
+			name:       "not configured rejects everything",
+			apiKey:     "",
+			authHeader: "Bearer anything",
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/orgs", nil)
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+			if tt.apiKeyHeader != "" {
+				req.Header.Set("X-Api-Key", tt.apiKeyHeader)
+			}
+			w := httptest.NewRecorder()
+
+			AdminAuth(tt.apiKey)(next).ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if called != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("next handler called = %v, want %v", called, tt.wantStatus == http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestAdminAuth_Identity(t *testing.T) {
+	const adminKey = "admin-key-0123456789abcdef0123456789"
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "oncall", Organization: []string{"NavPlane"}}}
+
+	for _, tt := range []struct {
+		name string
+		tls  *tls.ConnectionState
+		want string
+	}{
+		{name: "key only", want: "key:"},
+		{name: "client certificate", tls: &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}, want: "cert:CN=oncall,O=NavPlane"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *AdminIdentity
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = GetAdmin(r.Context())
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/orgs", nil)
+			req.Header.Set("Authorization", "Bearer "+adminKey)
+			req.TLS = tt.tls
+			AdminAuth(adminKey)(next).ServeHTTP(httptest.NewRecorder(), req)
+
+			if got == nil {
+				t.Fatal("expected an admin identity in the request context")
+			}
+			if len(got.KeyFingerprint) != 16 || strings.Contains(adminKey, got.KeyFingerprint) {
+				t.Errorf("expected a 16-character fingerprint of the key, got %q", got.KeyFingerprint)
+			}
+			if !strings.HasPrefix(got.String(), tt.want) {
+				t.Errorf("expected identity starting %q, got %q", tt.want, got.String())
+			}
+		})
+	}
+}
@@ -3,11 +3,20 @@ package middleware
 import (
 	"context"
 	"errors"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
 
+	"navplane/internal/errreport"
+	"navplane/internal/httpjson"
+	"navplane/internal/metrics"
 	"navplane/internal/org"
+	"navplane/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // contextKey is a custom type for context keys to avoid collisions.
@@ -19,33 +28,52 @@ const (
 )
 
 // Auth creates authentication middleware that validates NavPlane API keys.
-// Extracts Bearer token from Authorization header, authenticates via org manager,
-// and injects the org into the request context.
+// Extracts Bearer token from Authorization header (or x-api-key, as the
+// Anthropic SDKs send it), authenticates via org manager, and injects the
+// org into the request context.
 func Auth(manager *org.Manager) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			token, err := extractBearerToken(r)
 			if err != nil {
+				metrics.AuthFailures.WithLabelValues("org", "missing_token").Inc()
 				writeAuthError(w, http.StatusUnauthorized, "missing or invalid authorization header")
 				return
 			}
 
-			authenticatedOrg, err := manager.Authenticate(r.Context(), token)
+			ctx, span := tracing.Tracer().Start(r.Context(), "org.authenticate",
+				trace.WithAttributes(attribute.String("client.address", ClientIP(r))))
+			authenticatedOrg, err := manager.Authenticate(ctx, token)
 			if err != nil {
+				span.SetStatus(codes.Error, "authentication failed")
+				span.End()
 				if errors.Is(err, org.ErrNotFound) || errors.Is(err, org.ErrInvalidKey) {
+					metrics.AuthFailures.WithLabelValues("org", "invalid_key").Inc()
 					writeAuthError(w, http.StatusUnauthorized, "invalid API key")
 					return
 				}
+				if errors.Is(err, org.ErrKeyRotated) {
+					metrics.AuthFailures.WithLabelValues("org", "key_rotated").Inc()
+					httpjson.WriteErrorCode(w, http.StatusUnauthorized, "API key has been rotated, use the organization's new key",
+						httpjson.TypeAuthentication, "key_rotated")
+					return
+				}
 				if errors.Is(err, org.ErrOrgDisabled) {
+					metrics.AuthFailures.WithLabelValues("org", "org_disabled").Inc()
 					writeAuthError(w, http.StatusForbidden, "organization is disabled")
 					return
 				}
-				log.Printf("authentication error: %v", err)
+				slog.Error("authentication error", "error", err)
+				errreport.Report(fmt.Errorf("authenticate org: %w", err), ErrorTags(w, r))
 				writeAuthError(w, http.StatusInternalServerError, "authentication failed")
 				return
 			}
 
-			ctx := context.WithValue(r.Context(), OrgContextKey, authenticatedOrg)
+			span.SetAttributes(attribute.String("navplane.org_id", authenticatedOrg.ID.String()))
+			span.End()
+
+			setLogOrgID(r.Context(), authenticatedOrg.ID.String())
+			ctx = context.WithValue(r.Context(), OrgContextKey, authenticatedOrg)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -61,10 +89,14 @@ func GetOrg(ctx context.Context) *org.Org {
 	return o
 }
 
-// extractBearerToken extracts the token from "Authorization: Bearer <token>" header.
+// extractBearerToken extracts the token from "Authorization: Bearer <token>" header,
+// falling back to "x-api-key: <token>" when no Authorization header is sent.
 func extractBearerToken(r *http.Request) (string, error) {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
+		if key := r.Header.Get("X-Api-Key"); key != "" {
+			return key, nil
+		}
 		return "", errors.New("missing authorization header")
 	}
 
@@ -83,11 +115,5 @@ func extractBearerToken(r *http.Request) (string, error) {
 
 // writeAuthError writes an OpenAI-compatible authentication error response.
 func writeAuthError(w http.ResponseWriter, status int, message string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	// Using simple string concatenation to avoid import cycle with auth package
-	response := `{"error":{"message":"` + message + `","type":"authentication_error"}}`
-	if _, err := w.Write([]byte(response)); err != nil {
-		log.Printf("failed to write auth error response: %v", err)
-	}
+	httpjson.WriteError(w, status, message, httpjson.TypeAuthentication)
 }
@@ -2,19 +2,26 @@ package middleware
 
 import (
 	"context"
+	"database/sql"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
 
 	"navplane/internal/org"
 )
 
 func TestExtractBearerToken(t *testing.T) {
 	tests := []struct {
-		name        string
-		authHeader  string
-		wantToken   string
-		wantErr     bool
+		name       string
+		authHeader string
+		apiKey     string // x-api-key header
+		wantToken  string
+		wantErr    bool
 	}{
 		{
 			name:       "valid bearer token",
@@ -22,6 +29,17 @@ func TestExtractBearerToken(t *testing.T) {
 			wantToken:  "np_abc123",
 			wantErr:    false,
 		},
+		{
+			name:      "x-api-key header",
+			apiKey:    "np_abc123",
+			wantToken: "np_abc123",
+		},
+		{
+			name:       "authorization wins over x-api-key",
+			authHeader: "Bearer np_abc123",
+			apiKey:     "np_other",
+			wantToken:  "np_abc123",
+		},
 		{
 			name:       "missing header",
 			authHeader: "",
@@ -55,6 +73,9 @@ func TestExtractBearerToken(t *testing.T) {
 			if tt.authHeader != "" {
 				req.Header.Set("Authorization", tt.authHeader)
 			}
+			if tt.apiKey != "" {
+				req.Header.Set("x-api-key", tt.apiKey)
+			}
 
 			token, err := extractBearerToken(req)
 			if tt.wantErr {
@@ -149,3 +170,53 @@ func TestWriteAuthError(t *testing.T) {
 		})
 	}
 }
+
+func TestAuth_RotatedKey(t *testing.T) {
+	const apiKey = "np_old-key-12345"
+	columns := []string{"id", "name", "api_key_hash", "enabled", "created_at", "updated_at", "metadata", "key_hash_version", "previous_key_expires_at"}
+
+	tests := []struct {
+		name       string
+		expiresAt  time.Time
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "within grace period", expiresAt: time.Now().Add(time.Hour), wantStatus: http.StatusOK},
+		{name: "grace period over", expiresAt: time.Now().Add(-time.Hour), wantStatus: http.StatusUnauthorized, wantBody: `"code":"key_rotated"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to create mock: %v", err)
+			}
+			defer db.Close()
+
+			now := time.Now()
+			mock.ExpectQuery(`WHERE api_key_hash = \$1`).WillReturnError(sql.ErrNoRows)
+			mock.ExpectQuery(`WHERE previous_key_hash = \$1`).
+				WithArgs(org.HashAPIKey(apiKey), org.KeyHashSHA256).
+				WillReturnRows(sqlmock.NewRows(columns).
+					AddRow(uuid.New(), "Org", "new-hash", true, now, now, []byte(`{}`), org.KeyHashSHA256, tt.expiresAt))
+
+			handler := Auth(org.NewManager(org.NewDatastore(db)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			req.Header.Set("Authorization", "Bearer "+apiKey)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("expected body containing %s, got %s", tt.wantBody, rec.Body.String())
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+
+	"navplane/internal/metrics"
+)
+
+// RequireClientCert creates middleware that rejects requests without a
+// verified TLS client certificate. The TLS layer verifies certificates
+// against TLS_CLIENT_CA_FILE when presented; this makes one mandatory for
+// the routes it wraps (the admin API), on top of their normal auth.
+func RequireClientCert() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+				metrics.AuthFailures.WithLabelValues("admin", "missing_client_cert").Inc()
+				writeAuthError(w, http.StatusUnauthorized, "a verified client certificate is required")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
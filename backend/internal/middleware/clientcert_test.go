@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireClientCert(t *testing.T) {
+	tests := []struct {
+		name       string
+		state      *tls.ConnectionState
+		wantStatus int
+	}{
+		{name: "plain HTTP", wantStatus: http.StatusUnauthorized},
+		{name: "TLS without client cert", state: &tls.ConnectionState{}, wantStatus: http.StatusUnauthorized},
+		{
+			name:       "unverified client cert",
+			state:      &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{}}},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "verified client cert",
+			state:      &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := RequireClientCert()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/orgs", nil)
+			req.TLS = tt.state
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
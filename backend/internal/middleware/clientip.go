@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// clientIPKey is the context key for the resolved client IP.
+const clientIPKey contextKey = "client_ip"
+
+// RealIP creates middleware that resolves the client IP once per request so
+// ClientIP returns the same answer everywhere (request logs, auth, the
+// persisted request log).
+//
+// X-Forwarded-For is only believed as far as it was written by trusted
+// proxies: hops are walked from the right (the entry our nearest proxy
+// appended) and the first address outside trusted is the client. With no
+// trusted proxies, or when RemoteAddr itself isn't trusted, the header is
+// ignored entirely, so it can't be spoofed by connecting directly.
+func RealIP(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := resolveClientIP(r.RemoteAddr, r.Header.Values("X-Forwarded-For"), trusted)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey, ip)))
+		})
+	}
+}
+
+// ClientIP returns the client IP resolved by RealIP, or the RemoteAddr host
+// for requests that didn't pass through it.
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey).(string); ok {
+		return ip
+	}
+	return remoteHost(r.RemoteAddr)
+}
+
+func resolveClientIP(remoteAddr string, forwardedFor []string, trusted []netip.Prefix) string {
+	client := remoteHost(remoteAddr)
+	addr, err := netip.ParseAddr(client)
+	if err != nil || !isTrusted(addr, trusted) {
+		return client
+	}
+
+	// Multiple headers are equivalent to one comma-joined header, in order
+	var hops []string
+	for _, v := range forwardedFor {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// Garbage from beyond our proxies; the last good hop is all we know
+			return client
+		}
+		client = hop.Unmap().String()
+		if !isTrusted(hop, trusted) {
+			return client
+		}
+	}
+	return client
+}
+
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+	addr = addr.Unmap()
+	for _, p := range trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteHost strips the port from a RemoteAddr.
+func remoteHost(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
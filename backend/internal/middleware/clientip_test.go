@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	proxies := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("fd00::/8"),
+	}
+
+	tests := []struct {
+		name         string
+		trusted      []netip.Prefix
+		remoteAddr   string
+		forwardedFor []string
+		want         string
+	}{
+		{name: "no header", trusted: proxies, remoteAddr: "203.0.113.7:5000", want: "203.0.113.7"},
+		{
+			name:         "no trusted proxies ignores header",
+			remoteAddr:   "10.0.0.1:5000",
+			forwardedFor: []string{"198.51.100.9"},
+			want:         "10.0.0.1",
+		},
+		{
+			name:         "spoofed header from untrusted peer",
+			trusted:      proxies,
+			remoteAddr:   "203.0.113.7:5000",
+			forwardedFor: []string{"198.51.100.9"},
+			want:         "203.0.113.7",
+		},
+		{
+			name:         "single trusted proxy",
+			trusted:      proxies,
+			remoteAddr:   "10.0.0.1:5000",
+			forwardedFor: []string{"198.51.100.9"},
+			want:         "198.51.100.9",
+		},
+		{
+			name:         "multiple trusted hops",
+			trusted:      proxies,
+			remoteAddr:   "10.0.0.1:5000",
+			forwardedFor: []string{"198.51.100.9, 10.1.1.1, 10.2.2.2"},
+			want:         "198.51.100.9",
+		},
+		{
+			name:         "client-supplied prefix is not believed",
+			trusted:      proxies,
+			remoteAddr:   "10.0.0.1:5000",
+			forwardedFor: []string{"1.2.3.4, 198.51.100.9, 10.1.1.1"},
+			want:         "198.51.100.9",
+		},
+		{
+			name:         "multiple headers",
+			trusted:      proxies,
+			remoteAddr:   "10.0.0.1:5000",
+			forwardedFor: []string{"1.2.3.4, 198.51.100.9", "10.1.1.1"},
+			want:         "198.51.100.9",
+		},
+		{
+			name:         "garbage hop stops the walk",
+			trusted:      proxies,
+			remoteAddr:   "10.0.0.1:5000",
+			forwardedFor: []string{"not-an-ip, 10.1.1.1"},
+			want:         "10.1.1.1",
+		},
+		{
+			name:         "all hops trusted",
+			trusted:      proxies,
+			remoteAddr:   "10.0.0.1:5000",
+			forwardedFor: []string{"10.3.3.3, 10.1.1.1"},
+			want:         "10.3.3.3",
+		},
+		{
+			name:         "IPv6 proxy and client",
+			trusted:      proxies,
+			remoteAddr:   "[fd00::1]:5000",
+			forwardedFor: []string{"2001:db8::7"},
+			want:         "2001:db8::7",
+		},
+		{
+			name:         "IPv4-mapped addresses",
+			trusted:      proxies,
+			remoteAddr:   "[::ffff:10.0.0.1]:5000",
+			forwardedFor: []string{"::ffff:198.51.100.9"},
+			want:         "198.51.100.9",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := RealIP(tt.trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = ClientIP(r)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/chat/completions", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.forwardedFor {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("expected client IP %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestClientIP_WithoutMiddleware(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.7:5000"
+	req.Header.Set("X-Forwarded-For", "198.51.100.9")
+
+	if got := ClientIP(req); got != "203.0.113.7" {
+		t.Errorf("expected RemoteAddr host, got %q", got)
+	}
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"navplane/internal/config"
+	"navplane/internal/httpjson"
+)
+
+// corsAllowedMethods covers every method the admin and status APIs serve.
+const corsAllowedMethods = "GET, POST, PUT, PATCH, DELETE, OPTIONS"
+
+// CORS creates middleware that lets the browser dashboard call the API.
+//
+// Allowed origins get Access-Control-Allow-Origin echoed back. Preflights
+// (OPTIONS with Access-Control-Request-Method) are answered here with 204 and
+// never reach auth or the handler; preflights from other origins get 403.
+// Requests without an Origin header pass through untouched. When no origins
+// are configured the middleware is a no-op.
+//
+// Apply it to dashboard-facing routes only (/admin/*, /api/v1/*), outside
+// auth, so preflights (which never carry credentials) are not rejected.
+func CORS(cfg config.CORSConfig) func(http.Handler) http.Handler {
+	allowHeaders := strings.Join(cfg.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(cfg.MaxAge)
+
+	return func(next http.Handler) http.Handler {
+		if len(cfg.AllowedOrigins) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Add("Vary", "Origin")
+
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if !originAllowed(cfg.AllowedOrigins, origin) {
+				if preflight {
+					httpjson.WriteError(w, http.StatusForbidden, "origin not allowed", httpjson.TypePermission)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("Access-Control-Allow-Origin", origin)
+			if !preflight {
+				h.Set("Access-Control-Expose-Headers", httpjson.RequestIDHeader)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", corsAllowedMethods)
+			h.Set("Access-Control-Allow-Headers", allowHeaders)
+			h.Set("Access-Control-Max-Age", maxAge)
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// originAllowed reports whether origin matches one of the allowed entries.
+// An entry like "https://*.navplane.io" matches any subdomain of
+// navplane.io over https, but not navplane.io itself.
+func originAllowed(allowed []string, origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, entry := range allowed {
+		entry = strings.ToLower(entry)
+		scheme, suffix, wildcard := strings.Cut(entry, "://*.")
+		if !wildcard {
+			if origin == entry {
+				return true
+			}
+			continue
+		}
+
+		host, ok := strings.CutPrefix(origin, scheme+"://")
+		if !ok {
+			continue
+		}
+		sub, ok := strings.CutSuffix(host, "."+suffix)
+		if ok && sub != "" && !strings.ContainsAny(sub, "/:") {
+			return true
+		}
+	}
+	return false
+}
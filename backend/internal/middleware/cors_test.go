@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"navplane/internal/config"
+)
+
+func testCORSConfig() config.CORSConfig {
+	return config.CORSConfig{
+		AllowedOrigins: []string{"https://app.navplane.io", "https://*.staging.navplane.io"},
+		AllowedHeaders: []string{"Authorization", "Content-Type", "X-Request-ID"},
+		MaxAge:         600,
+	}
+}
+
+func TestCORS(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		origin        string
+		requestMethod string
+		wantStatus    int
+		wantOrigin    string
+		wantNext      bool
+	}{
+		{name: "allowed origin", method: http.MethodGet, origin: "https://app.navplane.io", wantStatus: http.StatusOK, wantOrigin: "https://app.navplane.io", wantNext: true},
+		{name: "wildcard subdomain", method: http.MethodGet, origin: "https://pr-42.staging.navplane.io", wantStatus: http.StatusOK, wantOrigin: "https://pr-42.staging.navplane.io", wantNext: true},
+		{name: "wildcard excludes apex", method: http.MethodGet, origin: "https://staging.navplane.io", wantStatus: http.StatusOK, wantNext: true},
+		{name: "wildcard checks scheme", method: http.MethodGet, origin: "http://pr-42.staging.navplane.io", wantStatus: http.StatusOK, wantNext: true},
+		{name: "disallowed origin", method: http.MethodGet, origin: "https://evil.example", wantStatus: http.StatusOK, wantNext: true},
+		{name: "lookalike suffix", method: http.MethodGet, origin: "https://app.navplane.io.evil.example", wantStatus: http.StatusOK, wantNext: true},
+		{name: "no origin", method: http.MethodGet, wantStatus: http.StatusOK, wantNext: true},
+		{name: "preflight allowed", method: http.MethodOptions, origin: "https://app.navplane.io", requestMethod: http.MethodPut, wantStatus: http.StatusNoContent, wantOrigin: "https://app.navplane.io"},
+		{name: "preflight disallowed", method: http.MethodOptions, origin: "https://evil.example", requestMethod: http.MethodPut, wantStatus: http.StatusForbidden},
+		{name: "plain OPTIONS passes through", method: http.MethodOptions, origin: "https://app.navplane.io", wantStatus: http.StatusOK, wantOrigin: "https://app.navplane.io", wantNext: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+			})
+
+			req := httptest.NewRequest(tt.method, "/admin/orgs/123", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.requestMethod != "" {
+				req.Header.Set("Access-Control-Request-Method", tt.requestMethod)
+			}
+			rec := httptest.NewRecorder()
+
+			CORS(testCORSConfig())(next).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("expected Access-Control-Allow-Origin %q, got %q", tt.wantOrigin, got)
+			}
+			if called != tt.wantNext {
+				t.Errorf("expected next called = %v, got %v", tt.wantNext, called)
+			}
+		})
+	}
+}
+
+func TestCORS_PreflightHeaders(t *testing.T) {
+	req := httptest.NewRequest(http.MethodOptions, "/admin/orgs/123", nil)
+	req.Header.Set("Origin", "https://app.navplane.io")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPut)
+	req.Header.Set("Access-Control-Request-Headers", "authorization, content-type, x-request-id")
+	rec := httptest.NewRecorder()
+
+	CORS(testCORSConfig())(http.NotFoundHandler()).ServeHTTP(rec, req)
+
+	want := map[string]string{
+		"Access-Control-Allow-Methods": corsAllowedMethods,
+		"Access-Control-Allow-Headers": "Authorization, Content-Type, X-Request-ID",
+		"Access-Control-Max-Age":       "600",
+	}
+	for header, value := range want {
+		if got := rec.Header().Get(header); got != value {
+			t.Errorf("expected %s %q, got %q", header, value, got)
+		}
+	}
+	if vary := rec.Header().Values("Vary"); len(vary) == 0 || vary[0] != "Origin" {
+		t.Errorf("expected Vary: Origin, got %v", vary)
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("expected empty preflight body, got %q", rec.Body.String())
+	}
+}
+
+func TestCORS_DisabledWithoutOrigins(t *testing.T) {
+	req := httptest.NewRequest(http.MethodOptions, "/admin/orgs", nil)
+	req.Header.Set("Origin", "https://app.navplane.io")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	rec := httptest.NewRecorder()
+
+	CORS(config.CORSConfig{})(http.NotFoundHandler()).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected request to reach next handler, got status %d", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("expected no CORS headers, got %q", got)
+	}
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// errorRateWindow is the rolling window the per-route error rate covers.
+	errorRateWindow = 5 * time.Minute
+
+	// errorRateBuckets splits the window; older buckets expire one at a time.
+	errorRateBuckets = 5
+
+	// errorRateMinRequests keeps a single failure on a quiet route from
+	// reading as a 100% error rate.
+	errorRateMinRequests = 20
+)
+
+// errorRateTracker keeps rolling per-route request and 5xx counts.
+// Routes are mux patterns, so the map stays bounded.
+type errorRateTracker struct {
+	threshold float64
+
+	mu     sync.Mutex
+	routes map[string]*routeErrorRate
+}
+
+type routeErrorRate struct {
+	buckets   [errorRateBuckets]errorRateBucket
+	lastAlert time.Time
+}
+
+type errorRateBucket struct {
+	start    time.Time
+	requests int
+	errors   int
+}
+
+// errorRateSummary is the window totals reported when a route trips the threshold.
+type errorRateSummary struct {
+	requests int
+	errors   int
+}
+
+func (s errorRateSummary) rate() float64 {
+	return float64(s.errors) / float64(s.requests)
+}
+
+// newErrorRateTracker creates a tracker. A zero threshold disables it.
+func newErrorRateTracker(threshold float64) *errorRateTracker {
+	return &errorRateTracker{
+		threshold: threshold,
+		routes:    make(map[string]*routeErrorRate),
+	}
+}
+
+// record counts one request and reports whether the route's error rate over
+// the window now exceeds the threshold. Each route alerts at most once per
+// window so a sustained outage produces a summary, not a flood.
+func (t *errorRateTracker) record(route string, isError bool, now time.Time) (errorRateSummary, bool) {
+	if t.threshold <= 0 {
+		return errorRateSummary{}, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r, ok := t.routes[route]
+	if !ok {
+		r = &routeErrorRate{}
+		t.routes[route] = r
+	}
+
+	width := errorRateWindow / errorRateBuckets
+	start := now.Truncate(width)
+	b := &r.buckets[int(start.UnixNano()/int64(width))%errorRateBuckets]
+	if !b.start.Equal(start) {
+		*b = errorRateBucket{start: start}
+	}
+	b.requests++
+	if isError {
+		b.errors++
+	}
+
+	var summary errorRateSummary
+	for _, b := range r.buckets {
+		if now.Sub(b.start) < errorRateWindow {
+			summary.requests += b.requests
+			summary.errors += b.errors
+		}
+	}
+
+	if summary.requests < errorRateMinRequests || summary.rate() <= t.threshold {
+		return summary, false
+	}
+	if !r.lastAlert.IsZero() && now.Sub(r.lastAlert) < errorRateWindow {
+		return summary, false
+	}
+	r.lastAlert = now
+	return summary, true
+}
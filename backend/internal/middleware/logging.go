@@ -0,0 +1,193 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"navplane/internal/config"
+	"navplane/internal/httpjson"
+)
+
+// requestLogKey is the context key for the per-request log entry.
+const requestLogKey contextKey = "request_log"
+
+// requestLog collects fields set by inner middleware (e.g. the authenticated
+// org) so the outer logging middleware can include them in its line.
+type requestLog struct {
+	orgID string
+	route string
+	extra []slog.Attr
+}
+
+// RequestLogger creates middleware that emits one structured log line per
+// request: method, path, route, status, duration, bytes, streaming,
+// request_id and org_id (when authenticated). Headers, query strings and
+// bodies are never logged.
+//
+// The request ID is taken from X-Request-ID or generated, and echoed on the
+// response so error bodies and upstream requests carry the same ID.
+//
+// Requests over the configured thresholds log at warn with slow=true:
+// streaming responses are judged on time to first byte (their total duration
+// is the length of the generation), everything else on total duration.
+// A per-route summary is logged when the 5xx rate over the rolling window
+// exceeds cfg.ErrorRateThreshold.
+func RequestLogger(logger *slog.Logger, cfg config.LogConfig) func(http.Handler) http.Handler {
+	return requestLogger(logger, cfg, time.Now)
+}
+
+// requestLogger is RequestLogger with an injectable clock for tests.
+func requestLogger(logger *slog.Logger, cfg config.LogConfig, now func() time.Time) func(http.Handler) http.Handler {
+	errorRates := newErrorRateTracker(cfg.ErrorRateThreshold)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := now()
+
+			reqID := r.Header.Get(httpjson.RequestIDHeader)
+			if reqID == "" || len(reqID) > 128 {
+				reqID = uuid.NewString()
+				r.Header.Set(httpjson.RequestIDHeader, reqID)
+			}
+			w.Header().Set(httpjson.RequestIDHeader, reqID)
+
+			entry := &requestLog{}
+			ctx := context.WithValue(r.Context(), requestLogKey, entry)
+			rw := &statusRecorder{ResponseWriter: w, now: now}
+
+			next.ServeHTTP(rw, r.WithContext(ctx))
+
+			duration := now().Sub(start)
+			status := rw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			route := entry.route
+			if route == "" {
+				route = unmatchedRoute
+			}
+
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("client_ip", ClientIP(r)),
+				slog.String("route", route),
+				slog.Int("status", status),
+				slog.Int64("duration_ms", duration.Milliseconds()),
+				slog.Int64("bytes", rw.bytes),
+				slog.Bool("streaming", rw.streaming),
+				slog.String("request_id", reqID),
+			}
+			if entry.orgID != "" {
+				attrs = append(attrs, slog.String("org_id", entry.orgID))
+			}
+			attrs = append(attrs, entry.extra...)
+
+			var slow bool
+			if rw.streaming {
+				if !rw.firstByte.IsZero() {
+					ttfb := rw.firstByte.Sub(start)
+					attrs = append(attrs, slog.Int64("ttfb_ms", ttfb.Milliseconds()))
+					slow = cfg.SlowStreamTTFBThreshold > 0 && ttfb > cfg.SlowStreamTTFBThreshold
+				}
+			} else {
+				slow = cfg.SlowRequestThreshold > 0 && duration > cfg.SlowRequestThreshold
+			}
+			if slow {
+				attrs = append(attrs, slog.Bool("slow", true))
+			}
+
+			level := slog.LevelInfo
+			if status >= 500 {
+				level = slog.LevelError
+			} else if slow {
+				level = slog.LevelWarn
+			}
+			logger.LogAttrs(r.Context(), level, "request", attrs...)
+
+			if summary, ok := errorRates.record(route, status >= 500, now()); ok {
+				logger.Warn("elevated error rate",
+					"route", route,
+					"requests", summary.requests,
+					"errors", summary.errors,
+					"error_rate", summary.rate(),
+					"window", errorRateWindow.String(),
+				)
+			}
+		})
+	}
+}
+
+// setLogOrgID records the authenticated org on the request's log entry.
+func setLogOrgID(ctx context.Context, orgID string) {
+	if entry, ok := ctx.Value(requestLogKey).(*requestLog); ok {
+		entry.orgID = orgID
+	}
+}
+
+// AddLogAttrs appends handler-specific fields (e.g. streaming stats) to the
+// request's log line. It is a no-op outside RequestLogger. Never pass
+// secrets or bodies.
+func AddLogAttrs(ctx context.Context, attrs ...slog.Attr) {
+	if entry, ok := ctx.Value(requestLogKey).(*requestLog); ok {
+		entry.extra = append(entry.extra, attrs...)
+	}
+}
+
+// setLogRoute records the matched mux pattern on the request's log entry.
+// The mux only sets r.Pattern on the request it receives, which the outer
+// logger never sees, so Metrics passes it back through the context.
+func setLogRoute(ctx context.Context, route string) {
+	if entry, ok := ctx.Value(requestLogKey).(*requestLog); ok {
+		entry.route = route
+	}
+}
+
+// statusRecorder captures the status code and response size while passing
+// writes, flushes and other interfaces through to the underlying writer.
+// When now is set it also records when the first body byte was written.
+type statusRecorder struct {
+	http.ResponseWriter
+	status    int
+	bytes     int64
+	streaming bool
+	now       func() time.Time
+	firstByte time.Time
+}
+
+func (rw *statusRecorder) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+		rw.streaming = strings.HasPrefix(rw.Header().Get("Content-Type"), "text/event-stream")
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *statusRecorder) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if rw.now != nil && rw.firstByte.IsZero() {
+		rw.firstByte = rw.now()
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher so SSE streaming keeps working.
+func (rw *statusRecorder) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rw *statusRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
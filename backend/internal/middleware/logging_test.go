@@ -0,0 +1,351 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"navplane/internal/config"
+	"navplane/internal/httpjson"
+	"navplane/internal/logging"
+)
+
+func decodeLogLine(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected exactly one log line, got %d: %q", len(lines), buf.String())
+	}
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("invalid JSON log line %q: %v", lines[0], err)
+	}
+	return entry
+}
+
+func TestRequestLogger_Fields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(&buf, slog.LevelInfo, logging.FormatJSON)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setLogOrgID(r.Context(), "org-123")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("hello"))
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/orgs?secret=1", nil)
+	req.Header.Set(httpjson.RequestIDHeader, "req-abc")
+	rec := httptest.NewRecorder()
+
+	RequestLogger(logger, config.LogConfig{})(next).ServeHTTP(rec, req)
+
+	entry := decodeLogLine(t, &buf)
+	want := map[string]any{
+		"method":     "POST",
+		"path":       "/admin/orgs",
+		"status":     float64(201),
+		"bytes":      float64(5),
+		"streaming":  false,
+		"request_id": "req-abc",
+		"org_id":     "org-123",
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("%s: expected %v, got %v", k, v, entry[k])
+		}
+	}
+	if _, ok := entry["duration_ms"]; !ok {
+		t.Error("expected duration_ms field")
+	}
+	if got := rec.Header().Get(httpjson.RequestIDHeader); got != "req-abc" {
+		t.Errorf("expected request ID echoed on response, got %q", got)
+	}
+}
+
+func TestRequestLogger_GeneratesRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(&buf, slog.LevelInfo, logging.FormatJSON)
+
+	var seen string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Header.Get(httpjson.RequestIDHeader)
+	})
+
+	rec := httptest.NewRecorder()
+	RequestLogger(logger, config.LogConfig{})(next).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	entry := decodeLogLine(t, &buf)
+	if seen == "" || entry["request_id"] != seen || rec.Header().Get(httpjson.RequestIDHeader) != seen {
+		t.Errorf("expected generated request ID to be shared, got handler=%q log=%v response=%q",
+			seen, entry["request_id"], rec.Header().Get(httpjson.RequestIDHeader))
+	}
+	if entry["status"] != float64(200) {
+		t.Errorf("expected implicit 200 status, got %v", entry["status"])
+	}
+	if _, ok := entry["org_id"]; ok {
+		t.Error("expected no org_id for unauthenticated request")
+	}
+}
+
+func TestRequestLogger_StreamingAndFlush(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(&buf, slog.LevelInfo, logging.FormatJSON)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("data: {}\n\n"))
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatal("expected wrapped writer to implement http.Flusher")
+		}
+		flusher.Flush()
+	})
+
+	rec := httptest.NewRecorder()
+	RequestLogger(logger, config.LogConfig{})(next).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil))
+
+	if !rec.Flushed {
+		t.Error("expected flush to reach the underlying writer")
+	}
+	if entry := decodeLogLine(t, &buf); entry["streaming"] != true {
+		t.Errorf("expected streaming=true, got %v", entry["streaming"])
+	}
+}
+
+// TestRequestLogger_ExcludesSecretsAndBodies guards the logging contract:
+// credentials and request/response bodies never reach the log pipeline.
+func TestRequestLogger_ExcludesSecretsAndBodies(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(&buf, slog.LevelInfo, logging.FormatJSON)
+
+	const (
+		apiKey       = "np_550e8400-e29b-41d4-a716-446655440000"
+		prompt       = "my-confidential-prompt"
+		responseText = "confidential-completion"
+	)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte(responseText))
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions?key="+apiKey,
+		strings.NewReader(`{"messages":[{"role":"user","content":"`+prompt+`"}]}`))
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	req.Header.Set("X-Api-Key", apiKey)
+	rec := httptest.NewRecorder()
+
+	RequestLogger(logger, config.LogConfig{})(next).ServeHTTP(rec, req)
+
+	out := buf.String()
+	for _, secret := range []string{apiKey, prompt, responseText, "Bearer"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("log output contains %q: %s", secret, out)
+		}
+	}
+	if entry := decodeLogLine(t, &buf); entry["level"] != "ERROR" {
+		t.Errorf("expected 5xx to log at ERROR, got %v", entry["level"])
+	}
+}
+
+// fakeClock is a manually advanced clock for threshold tests.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) Now() time.Time          { return c.t }
+func (c *fakeClock) Advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestRequestLogger_SlowRequests(t *testing.T) {
+	cfg := config.LogConfig{
+		SlowRequestThreshold:    time.Second,
+		SlowStreamTTFBThreshold: 500 * time.Millisecond,
+	}
+
+	tests := []struct {
+		name      string
+		streaming bool
+		ttfb      time.Duration
+		total     time.Duration
+		status    int
+		wantSlow  bool
+		wantLevel string
+	}{
+		{name: "fast", total: 200 * time.Millisecond, status: http.StatusOK, wantLevel: "INFO"},
+		{name: "slow", total: 2 * time.Second, status: http.StatusOK, wantSlow: true, wantLevel: "WARN"},
+		{name: "slow 5xx stays error", total: 2 * time.Second, status: http.StatusBadGateway, wantSlow: true, wantLevel: "ERROR"},
+		{name: "long stream with fast first byte", streaming: true, ttfb: 100 * time.Millisecond, total: time.Minute, status: http.StatusOK, wantLevel: "INFO"},
+		{name: "slow first byte", streaming: true, ttfb: 800 * time.Millisecond, total: time.Second, status: http.StatusOK, wantSlow: true, wantLevel: "WARN"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := logging.New(&buf, slog.LevelInfo, logging.FormatJSON)
+			clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.streaming {
+					w.Header().Set("Content-Type", "text/event-stream")
+				}
+				w.WriteHeader(tt.status)
+				clock.Advance(tt.ttfb)
+				_, _ = w.Write([]byte("data: {}\n\n"))
+				clock.Advance(tt.total - tt.ttfb)
+			})
+
+			rec := httptest.NewRecorder()
+			requestLogger(logger, cfg, clock.Now)(next).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil))
+
+			entry := decodeLogLine(t, &buf)
+			if entry["level"] != tt.wantLevel {
+				t.Errorf("expected level %s, got %v", tt.wantLevel, entry["level"])
+			}
+			if got := entry["slow"] == true; got != tt.wantSlow {
+				t.Errorf("expected slow=%v, got %v", tt.wantSlow, entry["slow"])
+			}
+			if got := entry["duration_ms"]; got != float64(tt.total.Milliseconds()) {
+				t.Errorf("expected duration_ms %d, got %v", tt.total.Milliseconds(), got)
+			}
+			if tt.streaming {
+				if got := entry["ttfb_ms"]; got != float64(tt.ttfb.Milliseconds()) {
+					t.Errorf("expected ttfb_ms %d, got %v", tt.ttfb.Milliseconds(), got)
+				}
+			} else if _, ok := entry["ttfb_ms"]; ok {
+				t.Error("expected no ttfb_ms for non-streaming request")
+			}
+		})
+	}
+}
+
+func TestRequestLogger_ErrorRateSummary(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(&buf, slog.LevelInfo, logging.FormatJSON)
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	status := http.StatusOK
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/orgs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	})
+	handler := requestLogger(logger, config.LogConfig{ErrorRateThreshold: 0.1}, clock.Now)(Metrics(mux))
+
+	serve := func(n, code int) {
+		status = code
+		for i := range n {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/admin/orgs/"+strconv.Itoa(i), nil))
+			clock.Advance(time.Second)
+		}
+	}
+
+	// 2/20 sits at the threshold; the third error tips it over
+	serve(18, http.StatusOK)
+	serve(3, http.StatusInternalServerError)
+
+	var summaries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid JSON log line %q: %v", line, err)
+		}
+		if entry["msg"] == "elevated error rate" {
+			summaries = append(summaries, entry)
+		}
+	}
+	if len(summaries) != 1 {
+		t.Fatalf("expected exactly one summary, got %d", len(summaries))
+	}
+	summary := summaries[0]
+	if summary["route"] != "GET /admin/orgs/{id}" || summary["requests"] != float64(21) || summary["errors"] != float64(3) {
+		t.Errorf("unexpected summary: %v", summary)
+	}
+}
+
+func TestErrorRateTracker(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("below minimum requests", func(t *testing.T) {
+		tracker := newErrorRateTracker(0.1)
+		for range errorRateMinRequests - 1 {
+			if _, ok := tracker.record("r", true, start); ok {
+				t.Fatal("expected no alert before minimum request count")
+			}
+		}
+	})
+
+	t.Run("alerts once per window", func(t *testing.T) {
+		tracker := newErrorRateTracker(0.1)
+		alerts := 0
+		now := start
+		for range 100 {
+			if _, ok := tracker.record("r", true, now); ok {
+				alerts++
+			}
+			now = now.Add(time.Second)
+		}
+		if alerts != 1 {
+			t.Errorf("expected 1 alert within the window, got %d", alerts)
+		}
+
+		now = start.Add(errorRateWindow + time.Minute)
+		var summary errorRateSummary
+		var ok bool
+		for range errorRateMinRequests {
+			summary, ok = tracker.record("r", true, now)
+			if ok {
+				break
+			}
+		}
+		if !ok {
+			t.Fatal("expected a new alert once the window has passed")
+		}
+		if summary.requests != errorRateMinRequests {
+			t.Errorf("expected only in-window requests counted, got %d", summary.requests)
+		}
+	})
+
+	t.Run("old buckets expire", func(t *testing.T) {
+		tracker := newErrorRateTracker(0.5)
+		for range 50 {
+			tracker.record("r", true, start)
+		}
+		// After the window passes, only fresh successes count
+		later := start.Add(errorRateWindow + time.Minute)
+		var summary errorRateSummary
+		for range errorRateMinRequests {
+			summary, _ = tracker.record("r", false, later)
+		}
+		if summary.errors != 0 || summary.requests != errorRateMinRequests {
+			t.Errorf("expected expired errors to drop out, got %+v", summary)
+		}
+	})
+
+	t.Run("routes tracked separately", func(t *testing.T) {
+		tracker := newErrorRateTracker(0.1)
+		for range errorRateMinRequests {
+			tracker.record("healthy", false, start)
+		}
+		summary, _ := tracker.record("failing", true, start)
+		if summary.requests != 1 || summary.errors != 1 {
+			t.Errorf("expected failing route isolated, got %+v", summary)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		tracker := newErrorRateTracker(0)
+		for range 100 {
+			if _, ok := tracker.record("r", true, start); ok {
+				t.Fatal("expected disabled tracker never to alert")
+			}
+		}
+	})
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"navplane/internal/metrics"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// unmatchedRoute labels requests that matched no mux pattern, keeping
+// arbitrary 404 paths out of metric label values.
+const unmatchedRoute = "unmatched"
+
+// Metrics creates middleware that records request latency by route.
+//
+// It must see the same *http.Request as the ServeMux: the mux records the
+// matched pattern on the request it receives, so wrap the mux directly or
+// through middleware that passes the request unchanged (like Recover).
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rw, r)
+
+		status := rw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := r.Pattern
+		if route == "" {
+			route = unmatchedRoute
+		} else {
+			// The server span is started before routing; name it after the route now it's known
+			trace.SpanFromContext(r.Context()).SetName(route)
+		}
+		setLogRoute(r.Context(), route)
+		metrics.HTTPRequestDuration.
+			WithLabelValues(route, strconv.Itoa(status)).
+			Observe(time.Since(start).Seconds())
+	})
+}
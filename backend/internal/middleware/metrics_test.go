@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"navplane/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestMetrics_LabelsByRoutePattern(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/orgs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler := Metrics(mux)
+
+	for _, path := range []string{"/admin/orgs/a", "/admin/orgs/b", "/nope/123"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// Both org IDs share one series keyed by the pattern, not the raw path
+	if n := sampleCount(t, "GET /admin/orgs/{id}", "204"); n != 2 {
+		t.Errorf("expected 2 observations for route pattern, got %d", n)
+	}
+	if n := sampleCount(t, unmatchedRoute, "404"); n != 1 {
+		t.Errorf("expected 1 unmatched observation, got %d", n)
+	}
+}
+
+func TestMetrics_NamesSpanAfterRoute(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/orgs/{id}", func(w http.ResponseWriter, r *http.Request) {})
+
+	ctx, span := tracer.Start(t.Context(), "http.server")
+	req := httptest.NewRequest(http.MethodGet, "/admin/orgs/a", nil).WithContext(ctx)
+	Metrics(mux).ServeHTTP(httptest.NewRecorder(), req)
+	span.End()
+
+	if got := recorder.Ended()[0].Name(); got != "GET /admin/orgs/{id}" {
+		t.Errorf("expected span named after route pattern, got %q", got)
+	}
+}
+
+func TestAuthFailureMetrics(t *testing.T) {
+	counter := metrics.AuthFailures.WithLabelValues("admin", "invalid_key")
+	before := testutil.ToFloat64(counter)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/orgs", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	AdminAuth("admin-0123456789abcdef0123456789ab")(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), req)
+
+	if got := testutil.ToFloat64(counter); got != before+1 {
+		t.Errorf("expected counter %v, got %v", before+1, got)
+	}
+}
+
+func sampleCount(t *testing.T, route, status string) uint64 {
+	t.Helper()
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, f := range families {
+		if f.GetName() != "navplane_http_request_duration_seconds" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["route"] == route && labels["status"] == status {
+				return m.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"navplane/internal/errreport"
+	"navplane/internal/httpjson"
+	"navplane/internal/metrics"
+)
+
+// Recover creates middleware that turns handler panics into 500 responses.
+//
+// The panic and stack are logged with the request ID, and the panic is sent
+// to the error reporter. If the handler had not yet written headers, the
+// client gets the standard server_error body; otherwise (e.g. mid-stream)
+// the response is simply ended, since nothing valid can be appended to it.
+// http.ErrAbortHandler is re-panicked so deliberate aborts keep their
+// net/http semantics.
+//
+// Recover passes the request through unchanged, so it can sit between
+// Metrics and the ServeMux without hiding the matched route.
+func Recover(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := &statusRecorder{ResponseWriter: w}
+
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+
+				route := r.Pattern
+				if route == "" {
+					route = unmatchedRoute
+				}
+				metrics.PanicsRecovered.WithLabelValues(route).Inc()
+				logger.Error("panic recovered",
+					"method", r.Method,
+					"path", r.URL.Path,
+					"route", route,
+					"request_id", w.Header().Get(httpjson.RequestIDHeader),
+					"headers_sent", rw.status != 0,
+					"panic", fmt.Sprint(v),
+					"stack", string(debug.Stack()),
+				)
+				tags := ErrorTags(w, r)
+				tags.Route = route
+				errreport.Report(fmt.Errorf("panic: %v", v), tags)
+
+				if rw.status == 0 {
+					httpjson.WriteError(rw, http.StatusInternalServerError, "internal server error", httpjson.TypeServer)
+				}
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
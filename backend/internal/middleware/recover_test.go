@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"navplane/internal/httpjson"
+	"navplane/internal/logging"
+	"navplane/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRecover(t *testing.T) {
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		wantStatus  int
+		wantBody    string
+		wantHeaders bool
+	}{
+		{
+			name: "before headers",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				panic("boom")
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "after headers",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write([]byte("data: {}\n\n"))
+				panic("boom")
+			},
+			wantStatus:  http.StatusOK,
+			wantBody:    "data: {}\n\n",
+			wantHeaders: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := logging.New(&buf, slog.LevelInfo, logging.FormatJSON)
+			reporter := installFakeReporter(t)
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("POST /v1/chat/completions", tt.handler)
+			counter := metrics.PanicsRecovered.WithLabelValues("POST /v1/chat/completions")
+			before := testutil.ToFloat64(counter)
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			rec := httptest.NewRecorder()
+			rec.Header().Set(httpjson.RequestIDHeader, "req-panic")
+
+			Recover(logger)(mux).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.wantHeaders {
+				if rec.Body.String() != tt.wantBody {
+					t.Errorf("expected body %q left intact, got %q", tt.wantBody, rec.Body.String())
+				}
+			} else {
+				var resp httpjson.ErrorResponse
+				if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("invalid JSON body %q: %v", rec.Body.String(), err)
+				}
+				if resp.Error.Type != httpjson.TypeServer {
+					t.Errorf("expected type %q, got %q", httpjson.TypeServer, resp.Error.Type)
+				}
+				if resp.Error.RequestID != "req-panic" {
+					t.Errorf("expected request_id req-panic, got %q", resp.Error.RequestID)
+				}
+			}
+
+			if got := testutil.ToFloat64(counter); got != before+1 {
+				t.Errorf("expected panic counter %v, got %v", before+1, got)
+			}
+
+			entry := decodeLogLine(t, &buf)
+			if entry["msg"] != "panic recovered" || entry["panic"] != "boom" {
+				t.Errorf("unexpected log entry: %v", entry)
+			}
+			if entry["request_id"] != "req-panic" {
+				t.Errorf("expected request_id in log, got %v", entry["request_id"])
+			}
+			if entry["headers_sent"] != tt.wantHeaders {
+				t.Errorf("expected headers_sent %v, got %v", tt.wantHeaders, entry["headers_sent"])
+			}
+			if stack, _ := entry["stack"].(string); !strings.Contains(stack, "recover_test.go") {
+				t.Errorf("expected stack trace to reference the panicking handler")
+			}
+
+			if len(reporter.reports) != 1 {
+				t.Fatalf("expected 1 error report, got %d", len(reporter.reports))
+			}
+			report := reporter.reports[0]
+			if !strings.Contains(report.err.Error(), "boom") {
+				t.Errorf("expected report to carry the panic value, got %v", report.err)
+			}
+			if report.tags.RequestID != "req-panic" || report.tags.Route != "POST /v1/chat/completions" {
+				t.Errorf("unexpected report tags: %+v", report.tags)
+			}
+		})
+	}
+}
+
+func TestRecover_RepanicsAbortHandler(t *testing.T) {
+	reporter := installFakeReporter(t)
+	logger := logging.New(&bytes.Buffer{}, slog.LevelInfo, logging.FormatJSON)
+	handler := Recover(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Errorf("expected http.ErrAbortHandler to propagate, got %v", v)
+		}
+		if len(reporter.reports) != 0 {
+			t.Errorf("expected deliberate aborts not to be reported, got %d reports", len(reporter.reports))
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+
+	"navplane/internal/errreport"
+	"navplane/internal/httpjson"
+)
+
+// ErrorTags builds error report tags for r: the request ID echoed on w, the
+// matched route and the authenticated org when known. Callers that know the
+// upstream provider set Provider themselves.
+func ErrorTags(w http.ResponseWriter, r *http.Request) errreport.Tags {
+	tags := errreport.Tags{
+		RequestID: w.Header().Get(httpjson.RequestIDHeader),
+		Route:     r.Pattern,
+	}
+	if o := GetOrg(r.Context()); o != nil {
+		tags.OrgID = o.ID.String()
+	} else if entry, ok := r.Context().Value(requestLogKey).(*requestLog); ok {
+		// Outside Auth (e.g. in Recover) the org is only on the log entry
+		tags.OrgID = entry.orgID
+	}
+	return tags
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"navplane/internal/errreport"
+	"navplane/internal/httpjson"
+	"navplane/internal/org"
+
+	"github.com/google/uuid"
+)
+
+// fakeReporter records reports in memory.
+type fakeReporter struct {
+	mu      sync.Mutex
+	reports []fakeReport
+}
+
+type fakeReport struct {
+	err  error
+	tags errreport.Tags
+}
+
+func (f *fakeReporter) Report(err error, tags errreport.Tags) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reports = append(f.reports, fakeReport{err: err, tags: tags})
+}
+
+func (f *fakeReporter) Flush(time.Duration) {}
+
+// installFakeReporter swaps in a fakeReporter for the duration of the test.
+func installFakeReporter(t *testing.T) *fakeReporter {
+	t.Helper()
+	f := &fakeReporter{}
+	errreport.SetDefault(f)
+	t.Cleanup(func() { errreport.SetDefault(nil) })
+	return f
+}
+
+func TestErrorTags(t *testing.T) {
+	orgID := uuid.New()
+
+	t.Run("authenticated org", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		req.Pattern = "POST /v1/chat/completions"
+		req = req.WithContext(context.WithValue(req.Context(), OrgContextKey, &org.Org{ID: orgID}))
+		rec := httptest.NewRecorder()
+		rec.Header().Set(httpjson.RequestIDHeader, "req-1")
+
+		got := ErrorTags(rec, req)
+		want := errreport.Tags{RequestID: "req-1", OrgID: orgID.String(), Route: "POST /v1/chat/completions"}
+		if got != want {
+			t.Errorf("expected %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("org from log entry outside auth", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		req = req.WithContext(context.WithValue(req.Context(), requestLogKey, &requestLog{orgID: orgID.String()}))
+
+		if got := ErrorTags(httptest.NewRecorder(), req); got.OrgID != orgID.String() {
+			t.Errorf("expected org ID %s, got %q", orgID, got.OrgID)
+		}
+	})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		got := ErrorTags(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		if got != (errreport.Tags{}) {
+			t.Errorf("expected empty tags, got %+v", got)
+		}
+	})
+}
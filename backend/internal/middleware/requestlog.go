@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"navplane/internal/httpjson"
+	"navplane/internal/requestlog"
+)
+
+// PersistRequests creates middleware that records each request's metadata
+// to the request log. It must run inside Auth so the org is known; handlers
+// fill in model, provider and token counts via requestlog.FromContext.
+//
+// Writes are asynchronous and never block the request. A nil writer
+// disables persistence.
+func PersistRequests(writer *requestlog.Writer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if writer == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			entry := &requestlog.Entry{
+				Route:     r.Pattern,
+				ClientIP:  ClientIP(r),
+				CreatedAt: start.UTC(),
+			}
+			if o := GetOrg(r.Context()); o != nil {
+				orgID := o.ID
+				entry.OrgID = &orgID
+			}
+			rw := &statusRecorder{ResponseWriter: w}
+
+			next.ServeHTTP(rw, r.WithContext(requestlog.WithEntry(r.Context(), entry)))
+
+			entry.Status = rw.status
+			if entry.Status == 0 {
+				entry.Status = http.StatusOK
+			}
+			if entry.Status >= 400 && entry.ErrorType == "" {
+				entry.ErrorType = httpjson.TypeForStatus(entry.Status)
+			}
+			entry.RequestID = w.Header().Get(httpjson.RequestIDHeader)
+			entry.LatencyMS = time.Since(start).Milliseconds()
+			writer.Record(entry)
+		})
+	}
+}
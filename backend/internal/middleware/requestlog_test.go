@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"navplane/internal/httpjson"
+	"navplane/internal/org"
+	"navplane/internal/requestlog"
+
+	"github.com/google/uuid"
+)
+
+// captureInserter collects entries persisted by a requestlog.Writer.
+type captureInserter struct {
+	mu      sync.Mutex
+	entries []*requestlog.Entry
+}
+
+func (c *captureInserter) Insert(_ context.Context, e *requestlog.Entry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = append(c.entries, e)
+	return nil
+}
+
+func TestPersistRequests(t *testing.T) {
+	orgID := uuid.New()
+
+	tests := []struct {
+		name          string
+		org           *org.Org
+		handler       http.HandlerFunc
+		wantStatus    int
+		wantErrorType string
+		wantModel     string
+	}{
+		{
+			name: "success with handler fields",
+			org:  &org.Org{ID: orgID},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if e := requestlog.FromContext(r.Context()); e != nil {
+					e.Model = "gpt-4o"
+				}
+				w.WriteHeader(http.StatusOK)
+			},
+			wantStatus: http.StatusOK,
+			wantModel:  "gpt-4o",
+		},
+		{
+			name:       "implicit 200",
+			handler:    func(w http.ResponseWriter, r *http.Request) {},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "error type derived from status",
+			org:  &org.Org{ID: orgID},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				httpjson.WriteStatusError(w, http.StatusTooManyRequests, "slow down")
+			},
+			wantStatus:    http.StatusTooManyRequests,
+			wantErrorType: httpjson.TypeRateLimit,
+		},
+		{
+			name: "handler error type kept",
+			org:  &org.Org{ID: orgID},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				requestlog.FromContext(r.Context()).ErrorType = requestlog.ErrorTypeUpstream
+				w.WriteHeader(http.StatusBadGateway)
+			},
+			wantStatus:    http.StatusBadGateway,
+			wantErrorType: requestlog.ErrorTypeUpstream,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &captureInserter{}
+			writer := requestlog.NewWriter(store, 10)
+			handler := PersistRequests(writer)(tt.handler)
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			req.Pattern = "POST /v1/chat/completions"
+			if tt.org != nil {
+				req = req.WithContext(context.WithValue(req.Context(), OrgContextKey, tt.org))
+			}
+			rec := httptest.NewRecorder()
+			rec.Header().Set(httpjson.RequestIDHeader, "req-123")
+
+			handler.ServeHTTP(rec, req)
+			if err := writer.Close(context.Background()); err != nil {
+				t.Fatalf("failed to close writer: %v", err)
+			}
+
+			if len(store.entries) != 1 {
+				t.Fatalf("expected 1 entry, got %d", len(store.entries))
+			}
+			e := store.entries[0]
+			if e.Status != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, e.Status)
+			}
+			if e.ErrorType != tt.wantErrorType {
+				t.Errorf("expected error type %q, got %q", tt.wantErrorType, e.ErrorType)
+			}
+			if e.Model != tt.wantModel {
+				t.Errorf("expected model %q, got %q", tt.wantModel, e.Model)
+			}
+			if e.Route != "POST /v1/chat/completions" {
+				t.Errorf("expected route to be recorded, got %q", e.Route)
+			}
+			if e.RequestID != "req-123" {
+				t.Errorf("expected request ID req-123, got %q", e.RequestID)
+			}
+			if tt.org == nil && e.OrgID != nil {
+				t.Errorf("expected no org ID, got %v", e.OrgID)
+			}
+			if tt.org != nil && (e.OrgID == nil || *e.OrgID != orgID) {
+				t.Errorf("expected org ID %s, got %v", orgID, e.OrgID)
+			}
+		})
+	}
+}
+
+func TestPersistRequests_NilWriter(t *testing.T) {
+	called := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		if requestlog.FromContext(r.Context()) != nil {
+			t.Error("expected no entry in context when persistence is disabled")
+		}
+	})
+
+	PersistRequests(nil)(next).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !called {
+		t.Error("expected next handler to be called")
+	}
+}
@@ -0,0 +1,184 @@
+package openai
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrLimitExceeded wraps every parse error caused by Limits, so handlers
+// can tell hostile or oversized input from ordinary malformed JSON.
+var ErrLimitExceeded = errors.New("request exceeds parsing limits")
+
+// Limits bounds what parsing materializes from untrusted request bodies.
+type Limits struct {
+	MaxDepth       int // Deepest nesting of objects and arrays, the top-level object included
+	MaxExtraFields int // Unknown top-level fields kept in Extra
+	MaxExtraBytes  int // Serialized size of a single Extra value
+}
+
+// DefaultLimits are generous enough for large tool schemas and metadata.
+var DefaultLimits = Limits{
+	MaxDepth:       128,
+	MaxExtraFields: 128,
+	MaxExtraBytes:  1 << 20, // 1 MiB
+}
+
+var limits atomic.Pointer[Limits]
+
+// SetLimits replaces the limits used by UnmarshalJSON, CheckDepth and
+// CheckRequest. Zero
+// fields keep their DefaultLimits value. Call it at startup, before
+// requests are served.
+func SetLimits(l Limits) {
+	if l.MaxDepth <= 0 {
+		l.MaxDepth = DefaultLimits.MaxDepth
+	}
+	if l.MaxExtraFields <= 0 {
+		l.MaxExtraFields = DefaultLimits.MaxExtraFields
+	}
+	if l.MaxExtraBytes <= 0 {
+		l.MaxExtraBytes = DefaultLimits.MaxExtraBytes
+	}
+	limits.Store(&l)
+}
+
+// CurrentLimits returns the limits in effect.
+func CurrentLimits() Limits {
+	if l := limits.Load(); l != nil {
+		return *l
+	}
+	return DefaultLimits
+}
+
+// CheckDepth rejects data nested deeper than the configured MaxDepth.
+func CheckDepth(data []byte) error {
+	return checkDepth(data, CurrentLimits().MaxDepth)
+}
+
+// checkDepth scans the raw bytes without decoding them, so pathological
+// nesting is rejected in linear time before anything is allocated. Invalid
+// JSON is left for the decoder to report.
+func checkDepth(data []byte, maxDepth int) error {
+	depth := 0
+	inString, escaped := false, false
+	for _, c := range data {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+			if depth > maxDepth {
+				return fmt.Errorf("%w: nesting depth exceeds %d", ErrLimitExceeded, maxDepth)
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+	return nil
+}
+
+// CheckRequest applies every limit to a chat completions body without
+// decoding it: nesting depth, the number of top-level fields the
+// ChatCompletionsRequest type doesn't know, and the serialized size of
+// each. It is the proxy's check, which forwards unknown fields as bytes.
+func CheckRequest(data []byte) error {
+	return checkRequest(data, CurrentLimits())
+}
+
+// checkRequest extends checkDepth's scan to track top-level keys and the
+// span of their values. Keys containing escapes are compared raw, so at
+// worst they count as unknown. Invalid JSON is left for the upstream to
+// report.
+func checkRequest(data []byte, limits Limits) error {
+	var (
+		depth             int
+		inString, escaped bool
+		expectKey         bool // The next string at depth 1 is a key
+		keyStart          = -1
+		key               []byte
+		valueStart        = -1
+		extra             int
+	)
+
+	endValue := func(end int) error {
+		if valueStart < 0 {
+			return nil
+		}
+		value := bytes.TrimSpace(data[valueStart:end])
+		valueStart = -1
+		if knownRequestFields[string(key)] {
+			return nil
+		}
+		if extra++; extra > limits.MaxExtraFields {
+			return fmt.Errorf("%w: more than %d unknown fields", ErrLimitExceeded, limits.MaxExtraFields)
+		}
+		if len(value) > limits.MaxExtraBytes {
+			return fmt.Errorf("%w: field %q is %d bytes, over the %d byte limit", ErrLimitExceeded, key, len(value), limits.MaxExtraBytes)
+		}
+		return nil
+	}
+
+	for i, c := range data {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+				if keyStart >= 0 {
+					key, keyStart = data[keyStart:i], -1
+				}
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+			if depth == 1 && expectKey {
+				keyStart, expectKey = i+1, false
+			}
+		case ':':
+			if depth == 1 {
+				valueStart = i + 1
+			}
+		case '{', '[':
+			depth++
+			if depth > limits.MaxDepth {
+				return fmt.Errorf("%w: nesting depth exceeds %d", ErrLimitExceeded, limits.MaxDepth)
+			}
+			if depth == 1 && c == '{' {
+				expectKey = true
+			}
+		case ',':
+			if depth == 1 {
+				if err := endValue(i); err != nil {
+					return err
+				}
+				expectKey = true
+			}
+		case '}', ']':
+			if depth == 1 {
+				if err := endValue(i); err != nil {
+					return err
+				}
+			}
+			depth--
+		}
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package openai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withLimits applies l for the duration of a test.
+func withLimits(t *testing.T, l Limits) {
+	t.Helper()
+	previous := CurrentLimits()
+	SetLimits(l)
+	t.Cleanup(func() { SetLimits(previous) })
+}
+
+func TestCheckDepth(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{"flat object", `{"model": "gpt-4"}`, false},
+		{"at the limit", `{"a": [{"b": 1}]}`, false},
+		{"over the limit", `{"a": [{"b": [1]}]}`, true},
+		{"brackets inside strings", `{"a": "[[[[[[{{{{"}`, false},
+		{"escaped quote in string", `{"a": "\"[[[[[[", "b": 1}`, false},
+		{"escaped backslash before quote", `{"a": "\\", "b": [[[[1]]]]}`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkDepth([]byte(tt.input), 3)
+			if tt.wantErr {
+				if !errors.Is(err, ErrLimitExceeded) {
+					t.Fatalf("expected ErrLimitExceeded, got %v", err)
+				}
+				if !strings.Contains(err.Error(), "nesting depth exceeds 3") {
+					t.Errorf("expected descriptive error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestChatCompletionsRequest_UnmarshalJSON_Limits(t *testing.T) {
+	withLimits(t, Limits{MaxDepth: 8, MaxExtraFields: 2, MaxExtraBytes: 64})
+	messages := `"model": "gpt-4", "messages": [{"role": "user", "content": "Hello"}]`
+
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{"within limits", `{` + messages + `, "seed": 1, "metadata": {"k": "v"}}`, ""},
+		{"too deep", `{` + messages + `, "tools": [[[[[[[[1]]]]]]]]}`, "nesting depth exceeds 8"},
+		{"too many unknown fields", `{` + messages + `, "a": 1, "b": 2, "c": 3}`, "more than 2 unknown fields"},
+		{"oversized unknown field", `{` + messages + `, "metadata": "` + strings.Repeat("x", 100) + `"}`, `field "metadata" is 102 bytes, over the 64 byte limit`},
+		{"known fields are not counted", `{` + messages + `, "stream": true, "temperature": 1, "top_p": 1, "max_tokens": 5}`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req ChatCompletionsRequest
+			err := json.Unmarshal([]byte(tt.input), &req)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrLimitExceeded) {
+				t.Fatalf("expected ErrLimitExceeded, got %v", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCheckRequest(t *testing.T) {
+	limits := Limits{MaxDepth: 8, MaxExtraFields: 2, MaxExtraBytes: 64}
+	messages := `"model": "gpt-4", "messages": [{"role": "user", "content": "Hello"}]`
+
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{"within limits", `{` + messages + `, "seed": 1, "metadata": {"k": "v"}}`, ""},
+		{"array content is not decoded", `{"model": "gpt-4", "messages": [{"role": "user", "content": [{"type": "text", "text": "Hi"}]}]}`, ""},
+		{"too deep", `{` + messages + `, "tools": [[[[[[[[1]]]]]]]]}`, "nesting depth exceeds 8"},
+		{"too many unknown fields", `{` + messages + `, "a": 1, "b": 2, "c": 3}`, "more than 2 unknown fields"},
+		{"oversized unknown field", `{` + messages + `, "metadata": "` + strings.Repeat("x", 100) + `"}`, `field "metadata" is 102 bytes, over the 64 byte limit`},
+		{"oversized last field", `{"metadata": {"k": "` + strings.Repeat("x", 100) + `"}}`, `field "metadata" is 109 bytes`},
+		{"known fields are not counted", `{` + messages + `, "stream": true, "temperature": 1, "top_p": 1, "max_tokens": 5}`, ""},
+		{"known field size is not bounded", `{"model": "gpt-4", "messages": [{"role": "user", "content": "` + strings.Repeat("x", 100) + `"}]}`, ""},
+		{"nested keys are not counted", `{"model": "gpt-4", "metadata": {"a": 1, "b": 2, "c": 3}}`, ""},
+		{"separators inside strings", `{"model": "a,b:c}", "seed": "x,\"y\":1,"}`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRequest([]byte(tt.input), limits)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrLimitExceeded) {
+				t.Fatalf("expected ErrLimitExceeded, got %v", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCheckRequest_NoAllocations(t *testing.T) {
+	body := []byte(`{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}], "seed": 1, "tools": [{"type": "function"}]}`)
+	if allocs := testing.AllocsPerRun(100, func() { _ = checkRequest(body, DefaultLimits) }); allocs != 0 {
+		t.Errorf("expected no allocations, got %v", allocs)
+	}
+}
+
+func TestCheckRequest_PathologicalInputFailsFast(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"deep nesting", `{"x": ` + strings.Repeat("[", 200_000) + strings.Repeat("]", 200_000) + `}`},
+		{"huge unknown field", `{"model": "gpt-4", "blob": "` + strings.Repeat("A", 9<<20) + `"}`},
+		{"many unknown fields", `{` + manyFields(200_000) + `}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			if err := checkRequest([]byte(tt.input), DefaultLimits); !errors.Is(err, ErrLimitExceeded) {
+				t.Fatalf("expected ErrLimitExceeded, got %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("rejection took %s", elapsed)
+			}
+		})
+	}
+}
+
+func TestSetLimits_ZeroFieldsKeepDefaults(t *testing.T) {
+	withLimits(t, Limits{MaxDepth: 10})
+	got := CurrentLimits()
+	want := Limits{MaxDepth: 10, MaxExtraFields: DefaultLimits.MaxExtraFields, MaxExtraBytes: DefaultLimits.MaxExtraBytes}
+	if got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestChatCompletionsRequest_UnmarshalJSON_PathologicalInputFailsFast(t *testing.T) {
+	// Test: Hostile payloads near the proxy's 10 MB body cap are rejected
+	// quickly, without materializing Extra. Nesting stays under the 10000
+	// levels encoding/json refuses on its own.
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"deep nesting", `{"x": ` + strings.Repeat("[", 9_000) + strings.Repeat("]", 9_000) + `}`},
+		{"deep nesting in an unknown object", `{"x": ` + strings.Repeat(`{"a":`, 9_000) + `1` + strings.Repeat("}", 9_000) + `}`},
+		{"huge unknown field", `{"model": "gpt-4", "blob": "` + strings.Repeat("A", 9<<20) + `"}`},
+		{"many unknown fields", `{` + manyFields(200_000) + `}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			var req ChatCompletionsRequest
+			err := json.Unmarshal([]byte(tt.input), &req)
+			if !errors.Is(err, ErrLimitExceeded) {
+				t.Fatalf("expected ErrLimitExceeded, got %v", err)
+			}
+			if req.Extra != nil {
+				t.Error("expected Extra not to be populated")
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("rejection took %s", elapsed)
+			}
+		})
+	}
+}
+
+func manyFields(n int) string {
+	fields := make([]string, n)
+	for i := range fields {
+		fields[i] = fmt.Sprintf(`"f%d": %d`, i, i)
+	}
+	return strings.Join(fields, ",")
+}
+
+func FuzzChatCompletionsRequest_UnmarshalJSON(f *testing.F) {
+	f.Add([]byte(`{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}]}`))
+	f.Add([]byte(`{"model": "gpt-4", "tools": [{"function": {"parameters": {"type": "object"}}}]}`))
+	f.Add([]byte(`{"a": "\"[[[", "b": [[[[[[[[[[[[[[[[1]]]]]]]]]]]]]]]]}`))
+	f.Add([]byte(`{"model": "gpt-4", "seed": 1, "metadata": {"k": "v"}, "x": null}`))
+	f.Add([]byte(`[[[[`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		limits := Limits{MaxDepth: 4, MaxExtraFields: 3, MaxExtraBytes: 32}
+		var req ChatCompletionsRequest
+		err := req.unmarshal(data, limits)
+		if err != nil {
+			return
+		}
+		// Anything accepted must honour the limits
+		if checkDepth(data, limits.MaxDepth) != nil {
+			t.Errorf("accepted input nested deeper than %d: %q", limits.MaxDepth, data)
+		}
+		if len(req.Extra) > limits.MaxExtraFields {
+			t.Errorf("accepted %d unknown fields: %q", len(req.Extra), data)
+		}
+	})
+}
+
+func FuzzCheckRequest(f *testing.F) {
+	f.Add([]byte(`{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}]}`))
+	f.Add([]byte(`{"model": "gpt-4", "tools": [{"function": {"parameters": {"type": "object"}}}]}`))
+	f.Add([]byte(`{"a": "\"[[[", "b": [[[[[[[[[[[[[[[[1]]]]]]]]]]]]]]]]}`))
+	f.Add([]byte(`{"model": "gpt-4", "seed": 1, "metadata": {"k": "v"}, "x": null}`))
+	f.Add([]byte(`{"\u006dodel": "gpt-4", "a": 1, "a": 2}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		limits := Limits{MaxDepth: 4, MaxExtraFields: 3, MaxExtraBytes: 32}
+		if checkRequest(data, limits) != nil {
+			return
+		}
+		// The proxy's check must never be laxer than the decoder's
+		var req ChatCompletionsRequest
+		if err := req.unmarshal(data, limits); errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("checkRequest accepted input the decoder rejects (%v): %q", err, data)
+		}
+	})
+}
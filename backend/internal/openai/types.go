@@ -26,6 +26,8 @@ type ChatMessage struct {
 //
 // Example unknown fields that might be passed through: response_format, tools, metadata,
 // seed, logprobs, etc. These won't break parsing and will be available in Extra.
+// How many there are, how large each is and how deep the payload nests are
+// bounded by Limits.
 type ChatCompletionsRequest struct {
 	// Model is the ID of the model to use (e.g., "gpt-4", "gpt-3.5-turbo").
 	Model string `json:"model"`
@@ -116,13 +118,39 @@ var knownRequestFields = map[string]bool{
 // It unmarshals into a raw map once, then selectively unmarshals known fields
 // from the map and stores unknown fields in Extra.
 // This approach ensures we never lose data from the original request.
+//
+// Input beyond the configured Limits is rejected with an error wrapping
+// ErrLimitExceeded before unknown fields are materialized.
 func (r *ChatCompletionsRequest) UnmarshalJSON(data []byte) error {
+	return r.unmarshal(data, CurrentLimits())
+}
+
+// unmarshal is UnmarshalJSON with explicit limits.
+func (r *ChatCompletionsRequest) unmarshal(data []byte, limits Limits) error {
+	if err := checkDepth(data, limits.MaxDepth); err != nil {
+		return err
+	}
+
 	// Unmarshal everything into a raw map (single parse)
 	var raw map[string]json.RawMessage
 	if err := json.Unmarshal(data, &raw); err != nil {
 		return err
 	}
 
+	// Bound unknown fields before decoding anything
+	extra := 0
+	for key, rawValue := range raw {
+		if knownRequestFields[key] {
+			continue
+		}
+		if extra++; extra > limits.MaxExtraFields {
+			return fmt.Errorf("%w: more than %d unknown fields", ErrLimitExceeded, limits.MaxExtraFields)
+		}
+		if len(rawValue) > limits.MaxExtraBytes {
+			return fmt.Errorf("%w: field %q is %d bytes, over the %d byte limit", ErrLimitExceeded, key, len(rawValue), limits.MaxExtraBytes)
+		}
+	}
+
 	// Unmarshal known fields from the raw map
 	if modelRaw, ok := raw["model"]; ok {
 		if err := json.Unmarshal(modelRaw, &r.Model); err != nil {
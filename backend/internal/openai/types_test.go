@@ -1046,3 +1046,54 @@ func TestChatCompletionsRequest_UnmarshalJSON_InvalidExtraField(t *testing.T) {
 		t.Error("expected custom_field in Extra")
 	}
 }
+
+// ========================================================================
+// Benchmarks
+// ========================================================================
+
+// largeRequestBody is a chat request with a long conversation history and
+// a few passthrough fields, sized like the payloads agents send.
+func largeRequestBody(b *testing.B, messages int) []byte {
+	b.Helper()
+	req := ChatCompletionsRequest{
+		Model: "gpt-4o",
+		Extra: map[string]any{"seed": 42, "response_format": map[string]any{"type": "json_object"}},
+	}
+	for i := range messages {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		req.Messages = append(req.Messages, ChatMessage{Role: role, Content: strings.Repeat("lorem ipsum dolor sit amet ", 20)})
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		b.Fatalf("failed to build request: %v", err)
+	}
+	return body
+}
+
+func BenchmarkChatCompletionsRequest_UnmarshalJSON(b *testing.B) {
+	body := largeRequestBody(b, 500)
+	b.SetBytes(int64(len(body)))
+	b.ReportAllocs()
+	for b.Loop() {
+		var req ChatCompletionsRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkChatCompletionsRequest_MarshalJSON(b *testing.B) {
+	var req ChatCompletionsRequest
+	if err := json.Unmarshal(largeRequestBody(b, 500), &req); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := json.Marshal(req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -3,42 +3,108 @@ package org
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+
+	"navplane/internal/database"
 )
 
 // Datastore handles persistence operations for organizations.
 // It performs only database operations and returns raw errors.
 // Business logic and error translation belong in the Manager.
 type Datastore struct {
-	db *sql.DB
+	db     database.DBTX
+	reader database.DBTX // Lag-tolerant lookups; the replica router or db
+	pool   *sql.DB       // Nil when bound to a transaction
+}
+
+// orgColumns is the column list selected for a full Org row.
+const orgColumns = `id, name, api_key_hash, enabled, created_at, updated_at, metadata, key_hash_version`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanOrg scans a row selected with orgColumns into an Org.
+func scanOrg(row rowScanner) (*Org, error) {
+	org := &Org{}
+	var metadata []byte
+	if err := row.Scan(
+		&org.ID, &org.Name, &org.APIKeyHash, &org.Enabled, &org.CreatedAt, &org.UpdatedAt, &metadata,
+		&org.KeyHashVersion,
+	); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(metadata, &org.Metadata); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// extendedRow scans orgColumns followed by extra columns, so queries that
+// select more than an Org can still use scanOrg.
+type extendedRow struct {
+	row   rowScanner
+	extra []any
+}
+
+func (r extendedRow) Scan(dest ...any) error {
+	return r.row.Scan(append(dest, r.extra...)...)
 }
 
 // NewDatastore creates a new organization datastore.
 func NewDatastore(db *sql.DB) *Datastore {
-	return &Datastore{db: db}
+	return &Datastore{db: db, reader: db, pool: db}
 }
 
-// Create inserts a new organization into the database.
+// NewDatastoreWithReader creates a datastore that sends API key lookups to
+// reader, typically a read replica, and everything else to db.
+func NewDatastoreWithReader(db *sql.DB, reader database.DBTX) *Datastore {
+	return &Datastore{db: db, reader: reader, pool: db}
+}
+
+// WithTx returns a datastore whose operations, reads included, run in tx.
+func (ds *Datastore) WithTx(tx *sql.Tx) *Datastore {
+	return &Datastore{db: tx, reader: tx}
+}
+
+// InTx runs fn with a datastore bound to a new transaction, committing if
+// fn returns nil. A datastore already bound to a transaction runs fn in it.
+func (ds *Datastore) InTx(ctx context.Context, fn func(ds *Datastore) error) error {
+	if ds.pool == nil {
+		return fn(ds)
+	}
+	return database.WithTx(ctx, ds.pool, func(tx *sql.Tx) error {
+		return fn(ds.WithTx(tx))
+	})
+}
+
+// Create inserts a new organization into the database, storing the hash,
+// hash scheme and prefix of key (never its plaintext).
 // Returns the created org or raw database error.
-func (ds *Datastore) Create(ctx context.Context, name, apiKeyHash string) (*Org, error) {
+func (ds *Datastore) Create(ctx context.Context, name string, key APIKey) (*Org, error) {
 	org := &Org{
-		ID:         uuid.New(),
-		Name:       name,
-		APIKeyHash: apiKeyHash,
-		Enabled:    true,
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
+		ID:             uuid.New(),
+		Name:           name,
+		APIKeyHash:     key.Hash,
+		KeyHashVersion: key.HashVersion,
+		Enabled:        true,
+		Metadata:       map[string]string{},
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
 	}
 
 	query := `
-		INSERT INTO organizations (id, name, api_key_hash, enabled, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO organizations (id, name, api_key_hash, enabled, created_at, updated_at, key_hash_version, api_key_prefix)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		RETURNING created_at, updated_at`
 
 	err := ds.db.QueryRowContext(ctx, query,
-		org.ID, org.Name, org.APIKeyHash, org.Enabled, org.CreatedAt, org.UpdatedAt,
+		org.ID, org.Name, org.APIKeyHash, org.Enabled, org.CreatedAt, org.UpdatedAt, org.KeyHashVersion, key.Prefix,
 	).Scan(&org.CreatedAt, &org.UpdatedAt)
 
 	if err != nil {
@@ -48,55 +114,226 @@ func (ds *Datastore) Create(ctx context.Context, name, apiKeyHash string) (*Org,
 	return org, nil
 }
 
-// GetByID retrieves an organization by its ID.
+// GetByID retrieves an organization by its ID, retrying transient errors.
 // Returns sql.ErrNoRows if not found.
 func (ds *Datastore) GetByID(ctx context.Context, id uuid.UUID) (*Org, error) {
 	query := `
-		SELECT id, name, api_key_hash, enabled, created_at, updated_at
+		SELECT ` + orgColumns + `
 		FROM organizations
 		WHERE id = $1`
 
-	org := &Org{}
-	err := ds.db.QueryRowContext(ctx, query, id).Scan(
-		&org.ID, &org.Name, &org.APIKeyHash, &org.Enabled, &org.CreatedAt, &org.UpdatedAt,
-	)
+	return database.RetryRead(ctx, "org.get_by_id", func() (*Org, error) {
+		return scanOrg(ds.db.QueryRowContext(ctx, query, id))
+	})
+}
 
-	if err != nil {
-		return nil, err
-	}
+// GetByIDForUpdate is GetByID with a row lock held until the surrounding
+// transaction ends, for read-modify-write updates. Use only inside InTx.
+// Returns sql.ErrNoRows if not found.
+func (ds *Datastore) GetByIDForUpdate(ctx context.Context, id uuid.UUID) (*Org, error) {
+	query := `
+		SELECT ` + orgColumns + `
+		FROM organizations
+		WHERE id = $1
+		FOR UPDATE`
 
-	return org, nil
+	return scanOrg(ds.db.QueryRowContext(ctx, query, id))
 }
 
-// GetByAPIKeyHash retrieves an organization by its API key hash.
+// GetByAPIKeyHash retrieves an organization by its API key hash, retrying
+// transient errors since every proxied request goes through it. It reads
+// from the replica when one is configured, so a just-rotated key may take
+// up to the replica lag to start (or stop) authenticating.
 // Returns sql.ErrNoRows if not found.
 func (ds *Datastore) GetByAPIKeyHash(ctx context.Context, apiKeyHash string) (*Org, error) {
 	query := `
-		SELECT id, name, api_key_hash, enabled, created_at, updated_at
+		SELECT ` + orgColumns + `
 		FROM organizations
 		WHERE api_key_hash = $1`
 
-	org := &Org{}
-	err := ds.db.QueryRowContext(ctx, query, apiKeyHash).Scan(
-		&org.ID, &org.Name, &org.APIKeyHash, &org.Enabled, &org.CreatedAt, &org.UpdatedAt,
-	)
+	return database.RetryRead(ctx, "org.get_by_api_key_hash", func() (*Org, error) {
+		return scanOrg(ds.reader.QueryRowContext(ctx, query, apiKeyHash))
+	})
+}
+
+// GetByPreviousKeyHash retrieves the organization whose last rotated-out key
+// has the given hash and scheme, together with when that key stops
+// authenticating. It reads from the replica like GetByAPIKeyHash.
+// Returns sql.ErrNoRows if not found.
+func (ds *Datastore) GetByPreviousKeyHash(ctx context.Context, hash string, version int) (*Org, time.Time, error) {
+	query := `
+		SELECT ` + orgColumns + `, previous_key_expires_at
+		FROM organizations
+		WHERE previous_key_hash = $1 AND previous_key_hash_version = $2`
 
+	var expiresAt time.Time
+	org, err := database.RetryRead(ctx, "org.get_by_previous_key_hash", func() (*Org, error) {
+		return scanOrg(extendedRow{ds.reader.QueryRowContext(ctx, query, hash, version), []any{&expiresAt}})
+	})
 	if err != nil {
-		return nil, err
+		return nil, time.Time{}, err
 	}
-
-	return org, nil
+	return org, expiresAt, nil
 }
 
 // Update modifies an existing organization.
 // Returns sql.ErrNoRows equivalent via RowsAffected check.
 func (ds *Datastore) Update(ctx context.Context, org *Org) (int64, error) {
+	metadata := []byte(`{}`)
+	if len(org.Metadata) > 0 {
+		var err error
+		if metadata, err = json.Marshal(org.Metadata); err != nil {
+			return 0, err
+		}
+	}
+
+	query := `
+		UPDATE organizations
+		SET name = $2, api_key_hash = $3, enabled = $4, metadata = $5, key_hash_version = $6, updated_at = NOW()
+		WHERE id = $1`
+
+	result, err := ds.db.ExecContext(ctx, query, org.ID, org.Name, org.APIKeyHash, org.Enabled, metadata, org.KeyHashVersion)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// RecordRotation moves the current key's hash, scheme, prefix and last use
+// to the previous key columns, overwriting any earlier previous key, and
+// stores newPrefix for the key replacing it. Call it in the rotation's
+// transaction before Update writes the new hash.
+// Returns rows affected count for caller to interpret.
+func (ds *Datastore) RecordRotation(ctx context.Context, id uuid.UUID, newPrefix string, previousExpiresAt time.Time) (int64, error) {
 	query := `
 		UPDATE organizations
-		SET name = $2, api_key_hash = $3, enabled = $4, updated_at = NOW()
+		SET previous_key_hash = api_key_hash,
+			previous_key_hash_version = key_hash_version,
+			previous_key_prefix = api_key_prefix,
+			previous_key_last_used_at = api_key_last_used_at,
+			previous_key_expires_at = $3,
+			api_key_prefix = $2,
+			api_key_last_used_at = NULL
 		WHERE id = $1`
 
-	result, err := ds.db.ExecContext(ctx, query, org.ID, org.Name, org.APIKeyHash, org.Enabled)
+	result, err := ds.db.ExecContext(ctx, query, id, newPrefix, previousExpiresAt)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// ClearExpiredPreviousKeys forgets previous keys that expired before cutoff.
+// Returns the number of organizations cleared.
+func (ds *Datastore) ClearExpiredPreviousKeys(ctx context.Context, cutoff time.Time) (int64, error) {
+	query := `
+		UPDATE organizations
+		SET previous_key_hash = NULL, previous_key_hash_version = NULL, previous_key_expires_at = NULL,
+			previous_key_prefix = NULL, previous_key_last_used_at = NULL
+		WHERE previous_key_expires_at < $1`
+
+	result, err := ds.db.ExecContext(ctx, query, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// TouchAPIKey sets the last use of an organization's current key, or of its
+// previous key when previous is true, to now.
+// Returns rows affected count for caller to interpret.
+func (ds *Datastore) TouchAPIKey(ctx context.Context, id uuid.UUID, previous bool) (int64, error) {
+	query := `UPDATE organizations SET api_key_last_used_at = NOW() WHERE id = $1`
+	if previous {
+		query = `UPDATE organizations SET previous_key_last_used_at = NOW() WHERE id = $1`
+	}
+
+	result, err := ds.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// keyMatchQuery selects the current keys matching condition %[1]s and the
+// previous keys matching %[2]s, newest organizations first. Both conditions
+// compare against $1; $2 is the row limit.
+const keyMatchQuery = `
+		SELECT ` + orgColumns + `, false, api_key_prefix, api_key_last_used_at, NULL::timestamptz
+		FROM organizations
+		WHERE %[1]s
+		UNION ALL
+		SELECT ` + orgColumns + `, true, previous_key_prefix, previous_key_last_used_at, previous_key_expires_at
+		FROM organizations
+		WHERE %[2]s
+		ORDER BY created_at DESC
+		LIMIT $2`
+
+// FindKeysByPrefix retrieves current and previous keys whose prefix matches
+// the LIKE pattern, at most limit of them, newest organizations first.
+func (ds *Datastore) FindKeysByPrefix(ctx context.Context, pattern string, limit int) ([]*KeyMatch, error) {
+	query := fmt.Sprintf(keyMatchQuery, "api_key_prefix LIKE $1", "previous_key_prefix LIKE $1")
+	return ds.findKeys(ctx, query, pattern, limit)
+}
+
+// FindKeysByHash retrieves the current or previous key stored with hash,
+// at most limit of them.
+func (ds *Datastore) FindKeysByHash(ctx context.Context, hash string, limit int) ([]*KeyMatch, error) {
+	query := fmt.Sprintf(keyMatchQuery, "api_key_hash = $1", "previous_key_hash = $1")
+	return ds.findKeys(ctx, query, hash, limit)
+}
+
+func (ds *Datastore) findKeys(ctx context.Context, query, arg string, limit int) ([]*KeyMatch, error) {
+	rows, err := ds.db.QueryContext(ctx, query, arg, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var matches []*KeyMatch
+	for rows.Next() {
+		m := &KeyMatch{}
+		var prefix sql.NullString
+		var lastUsedAt, expiresAt sql.NullTime
+		m.Org, err = scanOrg(extendedRow{rows, []any{&m.Previous, &prefix, &lastUsedAt, &expiresAt}})
+		if err != nil {
+			return nil, err
+		}
+		m.Prefix = prefix.String
+		if lastUsedAt.Valid {
+			m.LastUsedAt = &lastUsedAt.Time
+		}
+		if expiresAt.Valid {
+			m.ExpiresAt = &expiresAt.Time
+		}
+		matches = append(matches, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return matches, nil
+}
+
+// UpgradeAPIKeyHash replaces a SHA-256 key hash with its HMAC equivalent.
+// It matches on the old hash and version, so it does nothing if the key was
+// rotated or already upgraded since it was read, and leaves updated_at alone
+// since the key itself is unchanged.
+// Returns rows affected count for caller to interpret.
+func (ds *Datastore) UpgradeAPIKeyHash(ctx context.Context, id uuid.UUID, oldHash, newHash string) (int64, error) {
+	query := `
+		UPDATE organizations
+		SET api_key_hash = $3, key_hash_version = $4
+		WHERE id = $1 AND api_key_hash = $2 AND key_hash_version = $5`
+
+	result, err := ds.db.ExecContext(ctx, query, id, oldHash, newHash, KeyHashHMAC, KeyHashSHA256)
 	if err != nil {
 		return 0, err
 	}
@@ -136,7 +373,7 @@ func (ds *Datastore) SetEnabled(ctx context.Context, id uuid.UUID, enabled bool)
 // List retrieves all organizations with pagination.
 func (ds *Datastore) List(ctx context.Context, limit, offset int) ([]*Org, error) {
 	query := `
-		SELECT id, name, api_key_hash, enabled, created_at, updated_at
+		SELECT ` + orgColumns + `
 		FROM organizations
 		ORDER BY created_at DESC
 		LIMIT $1 OFFSET $2`
@@ -151,10 +388,8 @@ func (ds *Datastore) List(ctx context.Context, limit, offset int) ([]*Org, error
 
 	var orgs []*Org
 	for rows.Next() {
-		org := &Org{}
-		if err := rows.Scan(
-			&org.ID, &org.Name, &org.APIKeyHash, &org.Enabled, &org.CreatedAt, &org.UpdatedAt,
-		); err != nil {
+		org, err := scanOrg(rows)
+		if err != nil {
 			return nil, err
 		}
 		orgs = append(orgs, org)
@@ -166,3 +401,100 @@ func (ds *Datastore) List(ctx context.Context, limit, offset int) ([]*Org, error
 
 	return orgs, nil
 }
+
+// summaryQuery selects full Org rows plus provider key aggregates.
+// Callers append WHERE/ORDER/LIMIT clauses after the GROUP BY.
+const summaryQuery = `
+		SELECT o.id, o.name, o.api_key_hash, o.enabled, o.created_at, o.updated_at, o.metadata,
+			o.key_hash_version,
+			COUNT(pk.id) AS provider_key_count,
+			COUNT(pk.id) FILTER (WHERE pk.is_active) AS active_provider_key_count
+		FROM organizations o
+		LEFT JOIN provider_keys pk ON pk.org_id = o.id`
+
+// scanSummary scans a row selected with summaryQuery into an OrgSummary.
+func scanSummary(row rowScanner) (*OrgSummary, error) {
+	s := &OrgSummary{}
+	var metadata []byte
+	if err := row.Scan(
+		&s.ID, &s.Name, &s.APIKeyHash, &s.Enabled, &s.CreatedAt, &s.UpdatedAt, &metadata,
+		&s.KeyHashVersion, &s.ProviderKeyCount, &s.ActiveProviderKeyCount,
+	); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(metadata, &s.Metadata); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetSummaryByID retrieves an organization with its provider key counts.
+// Returns sql.ErrNoRows if not found.
+func (ds *Datastore) GetSummaryByID(ctx context.Context, id uuid.UUID) (*OrgSummary, error) {
+	query := summaryQuery + `
+		WHERE o.id = $1
+		GROUP BY o.id`
+
+	return scanSummary(ds.db.QueryRowContext(ctx, query, id))
+}
+
+// ListSummaries retrieves organizations with provider key counts using a
+// single aggregate query (no per-org lookups).
+func (ds *Datastore) ListSummaries(ctx context.Context, limit, offset int) ([]*OrgSummary, error) {
+	query := summaryQuery + `
+		GROUP BY o.id
+		ORDER BY o.created_at DESC
+		LIMIT $1 OFFSET $2`
+
+	rows, err := ds.db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var summaries []*OrgSummary
+	for rows.Next() {
+		s, err := scanSummary(rows)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return summaries, nil
+}
+
+// Export streams every organization with its provider key counts to fn,
+// oldest first. Rows are scanned one at a time so the full table is never
+// held in memory. Iteration stops at the first error returned by fn.
+func (ds *Datastore) Export(ctx context.Context, fn func(*OrgSummary) error) error {
+	query := summaryQuery + `
+		GROUP BY o.id
+		ORDER BY o.created_at ASC`
+
+	rows, err := ds.db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		s, err := scanSummary(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(s); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
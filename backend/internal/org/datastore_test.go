@@ -3,13 +3,21 @@ package org
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+// orgRowColumns is the column set returned by organization SELECT queries.
+var orgRowColumns = []string{"id", "name", "api_key_hash", "enabled", "created_at", "updated_at", "metadata", "key_hash_version"}
+
+// summaryRowColumns is the column set returned by organization aggregate queries.
+var summaryRowColumns = append(append([]string{}, orgRowColumns...), "provider_key_count", "active_provider_key_count")
+
 func TestDatastore_Create(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -22,10 +30,10 @@ func TestDatastore_Create(t *testing.T) {
 	now := time.Now()
 
 	mock.ExpectQuery(`INSERT INTO organizations`).
-		WithArgs(sqlmock.AnyArg(), "Test Org", "hash123", true, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), "Test Org", "hash123", true, sqlmock.AnyArg(), sqlmock.AnyArg(), KeyHashHMAC, "np_0123abcd").
 		WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at"}).AddRow(now, now))
 
-	org, err := ds.Create(ctx, "Test Org", "hash123")
+	org, err := ds.Create(ctx, "Test Org", APIKey{Prefix: "np_0123abcd", Hash: "hash123", HashVersion: KeyHashHMAC})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -36,6 +44,9 @@ func TestDatastore_Create(t *testing.T) {
 	if org.APIKeyHash != "hash123" {
 		t.Errorf("expected hash 'hash123', got %q", org.APIKeyHash)
 	}
+	if org.KeyHashVersion != KeyHashHMAC {
+		t.Errorf("expected key hash version %d, got %d", KeyHashHMAC, org.KeyHashVersion)
+	}
 	if !org.Enabled {
 		t.Error("expected org to be enabled")
 	}
@@ -58,7 +69,7 @@ func TestDatastore_Create_Error(t *testing.T) {
 	mock.ExpectQuery(`INSERT INTO organizations`).
 		WillReturnError(sql.ErrConnDone)
 
-	_, err = ds.Create(ctx, "Test Org", "hash123")
+	_, err = ds.Create(ctx, "Test Org", APIKey{Hash: "hash123", HashVersion: KeyHashSHA256})
 	if err == nil {
 		t.Error("expected error, got nil")
 	}
@@ -80,8 +91,8 @@ func TestDatastore_GetByID(t *testing.T) {
 	id := uuid.New()
 	now := time.Now()
 
-	rows := sqlmock.NewRows([]string{"id", "name", "api_key_hash", "enabled", "created_at", "updated_at"}).
-		AddRow(id, "Test Org", "hash123", true, now, now)
+	rows := sqlmock.NewRows(orgRowColumns).
+		AddRow(id, "Test Org", "hash123", true, now, now, []byte(`{}`), 1)
 
 	mock.ExpectQuery(`SELECT .+ FROM organizations WHERE id = \$1`).
 		WithArgs(id).
@@ -141,8 +152,8 @@ func TestDatastore_GetByAPIKeyHash(t *testing.T) {
 	id := uuid.New()
 	now := time.Now()
 
-	rows := sqlmock.NewRows([]string{"id", "name", "api_key_hash", "enabled", "created_at", "updated_at"}).
-		AddRow(id, "Test Org", "hash123", true, now, now)
+	rows := sqlmock.NewRows(orgRowColumns).
+		AddRow(id, "Test Org", "hash123", true, now, now, []byte(`{}`), 1)
 
 	mock.ExpectQuery(`SELECT .+ FROM organizations WHERE api_key_hash = \$1`).
 		WithArgs("hash123").
@@ -198,14 +209,15 @@ func TestDatastore_Update(t *testing.T) {
 	id := uuid.New()
 
 	org := &Org{
-		ID:         id,
-		Name:       "Updated Org",
-		APIKeyHash: "hash456",
-		Enabled:    false,
+		ID:             id,
+		Name:           "Updated Org",
+		APIKeyHash:     "hash456",
+		KeyHashVersion: KeyHashSHA256,
+		Enabled:        false,
 	}
 
 	mock.ExpectExec(`UPDATE organizations`).
-		WithArgs(id, "Updated Org", "hash456", false).
+		WithArgs(id, "Updated Org", "hash456", false, []byte(`{}`), 1).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
 	rowsAffected, err := ds.Update(ctx, org)
@@ -234,14 +246,15 @@ func TestDatastore_Update_NotFound(t *testing.T) {
 	id := uuid.New()
 
 	org := &Org{
-		ID:         id,
-		Name:       "Updated Org",
-		APIKeyHash: "hash456",
-		Enabled:    false,
+		ID:             id,
+		Name:           "Updated Org",
+		APIKeyHash:     "hash456",
+		KeyHashVersion: KeyHashSHA256,
+		Enabled:        false,
 	}
 
 	mock.ExpectExec(`UPDATE organizations`).
-		WithArgs(id, "Updated Org", "hash456", false).
+		WithArgs(id, "Updated Org", "hash456", false, []byte(`{}`), 1).
 		WillReturnResult(sqlmock.NewResult(0, 0))
 
 	rowsAffected, err := ds.Update(ctx, org)
@@ -316,6 +329,106 @@ func TestDatastore_SetEnabled(t *testing.T) {
 	}
 }
 
+func TestDatastore_GetByPreviousKeyHash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	ds := NewDatastore(db)
+	id := uuid.New()
+	now := time.Now()
+	expiresAt := now.Add(time.Hour)
+
+	mock.ExpectQuery(`SELECT .+, previous_key_expires_at FROM organizations WHERE previous_key_hash = \$1 AND previous_key_hash_version = \$2`).
+		WithArgs("oldhash", KeyHashHMAC).
+		WillReturnRows(sqlmock.NewRows(append(append([]string{}, orgRowColumns...), "previous_key_expires_at")).
+			AddRow(id, "Test Org", "newhash", true, now, now, []byte(`{}`), KeyHashHMAC, expiresAt))
+
+	org, gotExpiry, err := ds.GetByPreviousKeyHash(context.Background(), "oldhash", KeyHashHMAC)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if org.ID != id || org.APIKeyHash != "newhash" {
+		t.Errorf("unexpected org: %+v", org)
+	}
+	if !gotExpiry.Equal(expiresAt) {
+		t.Errorf("expected expiry %v, got %v", expiresAt, gotExpiry)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDatastore_FindKeysByPrefix(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	ds := NewDatastore(db)
+	id1, id2 := uuid.New(), uuid.New()
+	now := time.Now()
+	expiresAt := now.Add(time.Hour)
+
+	columns := append(append([]string{}, orgRowColumns...), "previous", "prefix", "last_used_at", "expires_at")
+	mock.ExpectQuery(`WHERE api_key_prefix LIKE \$1 UNION ALL .+ WHERE previous_key_prefix LIKE \$1 ORDER BY created_at DESC LIMIT \$2`).
+		WithArgs(`np\_0123%`, 20).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(id1, "Org 1", "hash1", true, now, now, []byte(`{}`), 1, false, "np_0123abcd", now, nil).
+			AddRow(id2, "Org 2", "hash2", true, now, now, []byte(`{}`), 1, true, "np_0123ef01", nil, expiresAt))
+
+	matches, err := ds.FindKeysByPrefix(context.Background(), `np\_0123%`, 20)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(matches) != 2 {
+		t.Fatalf("expected 2 matches, got %d", len(matches))
+	}
+	if m := matches[0]; m.Org.ID != id1 || m.Previous || m.Prefix != "np_0123abcd" || m.LastUsedAt == nil || m.ExpiresAt != nil {
+		t.Errorf("unexpected current key match: %+v", m)
+	}
+	if m := matches[1]; m.Org.ID != id2 || !m.Previous || m.LastUsedAt != nil || m.ExpiresAt == nil || !m.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("unexpected previous key match: %+v", m)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDatastore_UpgradeAPIKeyHash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	ds := NewDatastore(db)
+	ctx := context.Background()
+	id := uuid.New()
+
+	mock.ExpectExec(`UPDATE organizations SET api_key_hash = \$3, key_hash_version = \$4 WHERE id = \$1 AND api_key_hash = \$2 AND key_hash_version = \$5`).
+		WithArgs(id, "oldhash", "newhash", KeyHashHMAC, KeyHashSHA256).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rowsAffected, err := ds.UpgradeAPIKeyHash(ctx, id, "oldhash", "newhash")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if rowsAffected != 1 {
+		t.Errorf("expected 1 row affected, got %d", rowsAffected)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
 func TestDatastore_List(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -328,9 +441,9 @@ func TestDatastore_List(t *testing.T) {
 	id1, id2 := uuid.New(), uuid.New()
 	now := time.Now()
 
-	rows := sqlmock.NewRows([]string{"id", "name", "api_key_hash", "enabled", "created_at", "updated_at"}).
-		AddRow(id1, "Org 1", "hash1", true, now, now).
-		AddRow(id2, "Org 2", "hash2", false, now, now)
+	rows := sqlmock.NewRows(orgRowColumns).
+		AddRow(id1, "Org 1", "hash1", true, now, now, []byte(`{}`), 1).
+		AddRow(id2, "Org 2", "hash2", false, now, now, []byte(`{}`), 1)
 
 	mock.ExpectQuery(`SELECT .+ FROM organizations ORDER BY created_at DESC LIMIT \$1 OFFSET \$2`).
 		WithArgs(10, 0).
@@ -364,7 +477,7 @@ func TestDatastore_List_Empty(t *testing.T) {
 	ds := NewDatastore(db)
 	ctx := context.Background()
 
-	rows := sqlmock.NewRows([]string{"id", "name", "api_key_hash", "enabled", "created_at", "updated_at"})
+	rows := sqlmock.NewRows(orgRowColumns)
 
 	mock.ExpectQuery(`SELECT .+ FROM organizations ORDER BY created_at DESC LIMIT \$1 OFFSET \$2`).
 		WithArgs(10, 0).
@@ -383,3 +496,290 @@ func TestDatastore_List_Empty(t *testing.T) {
 		t.Errorf("unfulfilled expectations: %v", err)
 	}
 }
+
+func TestDatastore_Export(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	ds := NewDatastore(db)
+	ctx := context.Background()
+	id1, id2 := uuid.New(), uuid.New()
+	now := time.Now()
+
+	rows := sqlmock.NewRows(summaryRowColumns).
+		AddRow(id1, "Org 1", "hash1", true, now, now, []byte(`{}`), 1, 2, 2).
+		AddRow(id2, "Org 2", "hash2", false, now, now, []byte(`{}`), 1, 0, 0)
+
+	mock.ExpectQuery(`SELECT .+ FROM organizations o LEFT JOIN provider_keys pk ON pk.org_id = o.id GROUP BY o.id ORDER BY o.created_at ASC`).
+		WillReturnRows(rows)
+
+	var got []*OrgSummary
+	err = ds.Export(ctx, func(s *OrgSummary) error {
+		got = append(got, s)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("expected 2 orgs, got %d", len(got))
+	}
+	if got[0].ID != id1 || got[0].ProviderKeyCount != 2 || got[0].ActiveProviderKeyCount != 2 {
+		t.Errorf("unexpected first row: %+v", got[0])
+	}
+	if got[1].Enabled {
+		t.Error("expected second org to be disabled")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDatastore_Export_StopsOnCallbackError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	ds := NewDatastore(db)
+	ctx := context.Background()
+	now := time.Now()
+
+	rows := sqlmock.NewRows(summaryRowColumns).
+		AddRow(uuid.New(), "Org 1", "hash1", true, now, now, []byte(`{}`), 1, 0, 0).
+		AddRow(uuid.New(), "Org 2", "hash2", true, now, now, []byte(`{}`), 1, 0, 0)
+
+	mock.ExpectQuery(`SELECT .+ FROM organizations o`).WillReturnRows(rows)
+
+	stop := errors.New("client went away")
+	calls := 0
+	err = ds.Export(ctx, func(s *OrgSummary) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("expected callback error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected iteration to stop after 1 call, got %d", calls)
+	}
+}
+
+func TestDatastore_ListSummaries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	ds := NewDatastore(db)
+	ctx := context.Background()
+	id1, id2 := uuid.New(), uuid.New()
+	now := time.Now()
+
+	rows := sqlmock.NewRows(summaryRowColumns).
+		AddRow(id1, "Org 1", "hash1", true, now, now, []byte(`{"tier":"gold"}`), 1, 3, 1).
+		AddRow(id2, "Org 2", "hash2", true, now, now, []byte(`{}`), 1, 0, 0)
+
+	// Aggregates must come from a single grouped LEFT JOIN, not N+1 lookups
+	mock.ExpectQuery(`SELECT .+ COUNT\(pk.id\) AS provider_key_count, COUNT\(pk.id\) FILTER \(WHERE pk.is_active\) AS active_provider_key_count FROM organizations o LEFT JOIN provider_keys pk ON pk.org_id = o.id GROUP BY o.id ORDER BY o.created_at DESC LIMIT \$1 OFFSET \$2`).
+		WithArgs(10, 0).
+		WillReturnRows(rows)
+
+	summaries, err := ds.ListSummaries(ctx, 10, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(summaries) != 2 {
+		t.Fatalf("expected 2 summaries, got %d", len(summaries))
+	}
+	if summaries[0].ProviderKeyCount != 3 || summaries[0].ActiveProviderKeyCount != 1 {
+		t.Errorf("unexpected counts: %+v", summaries[0])
+	}
+	if summaries[0].Metadata["tier"] != "gold" {
+		t.Errorf("expected metadata to be decoded, got %v", summaries[0].Metadata)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDatastore_GetSummaryByID_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	ds := NewDatastore(db)
+	ctx := context.Background()
+	id := uuid.New()
+
+	mock.ExpectQuery(`SELECT .+ FROM organizations o LEFT JOIN provider_keys pk ON pk.org_id = o.id WHERE o.id = \$1 GROUP BY o.id`).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(summaryRowColumns))
+
+	_, err = ds.GetSummaryByID(ctx, id)
+	if err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDatastore_Create_DuplicateNameReturnsRawError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	ds := NewDatastore(db)
+	ctx := context.Background()
+
+	violation := &pq.Error{Code: "23505", Constraint: "idx_organizations_name_lower"}
+	mock.ExpectQuery(`INSERT INTO organizations`).
+		WillReturnError(violation)
+
+	_, err = ds.Create(ctx, "Acme", APIKey{Hash: "hash", HashVersion: KeyHashSHA256})
+
+	// Datastore returns the raw driver error; translation is the manager's job
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Constraint != "idx_organizations_name_lower" {
+		t.Errorf("expected raw pq unique violation, got %v", err)
+	}
+}
+
+func TestDatastore_InTx_NestedRunsInOuterTransaction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	ds := NewDatastore(db)
+	ctx := context.Background()
+	id := uuid.New()
+
+	// One BEGIN/COMMIT pair even though InTx is called twice
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM organizations WHERE id = \$1`).
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = ds.InTx(ctx, func(tx *Datastore) error {
+		return tx.InTx(ctx, func(inner *Datastore) error {
+			_, err := inner.Delete(ctx, id)
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDatastore_GetByAPIKeyHash_RetriesTransientErrors(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	ds := NewDatastore(db)
+	id := uuid.New()
+	now := time.Now()
+
+	// Primary goes away mid-failover, then the query succeeds on the new one
+	mock.ExpectQuery(`SELECT .+ FROM organizations WHERE api_key_hash = \$1`).
+		WithArgs("hash123").
+		WillReturnError(&pq.Error{Code: "57P01"})
+	mock.ExpectQuery(`SELECT .+ FROM organizations WHERE api_key_hash = \$1`).
+		WithArgs("hash123").
+		WillReturnRows(sqlmock.NewRows(orgRowColumns).
+			AddRow(id, "Acme", "hash123", true, now, now, []byte(`{}`), 1))
+
+	org, err := ds.GetByAPIKeyHash(context.Background(), "hash123")
+	if err != nil {
+		t.Fatalf("expected retry to succeed, got %v", err)
+	}
+	if org.ID != id {
+		t.Errorf("expected org %s, got %s", id, org.ID)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDatastore_GetByAPIKeyHash_NoRowsNotRetried(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT .+ FROM organizations WHERE api_key_hash = \$1`).
+		WithArgs("missing").
+		WillReturnError(sql.ErrNoRows)
+
+	if _, err := NewDatastore(db).GetByAPIKeyHash(context.Background(), "missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDatastore_WithReader_RoutesLookupsToReader(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+	reader, readerMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer reader.Close()
+
+	ds := NewDatastoreWithReader(db, reader)
+	ctx := context.Background()
+	id := uuid.New()
+	now := time.Now()
+
+	// API key lookups use the reader; by-ID reads stay on the primary so
+	// admin read-after-write is consistent
+	readerMock.ExpectQuery(`SELECT .+ FROM organizations WHERE api_key_hash = \$1`).
+		WithArgs("hash123").
+		WillReturnRows(sqlmock.NewRows(orgRowColumns).AddRow(id, "Test Org", "hash123", true, now, now, []byte(`{}`), 1))
+	mock.ExpectQuery(`SELECT .+ FROM organizations WHERE id = \$1`).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(orgRowColumns).AddRow(id, "Test Org", "hash123", true, now, now, []byte(`{}`), 1))
+
+	if _, err := ds.GetByAPIKeyHash(ctx, "hash123"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := ds.GetByID(ctx, id); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := readerMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled reader expectations: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
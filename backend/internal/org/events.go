@@ -0,0 +1,25 @@
+package org
+
+import "github.com/google/uuid"
+
+// Lifecycle event types emitted by the Manager.
+const (
+	EventCreated  = "org.created"
+	EventEnabled  = "org.enabled"
+	EventDisabled = "org.disabled"
+	EventDeleted  = "org.deleted"
+)
+
+// Notifier receives organization lifecycle events.
+// Implementations must not block; delivery is expected to be asynchronous.
+type Notifier interface {
+	Notify(eventType string, data any)
+}
+
+// EventData is the payload attached to organization lifecycle events.
+// It never includes the API key hash.
+type EventData struct {
+	OrgID   uuid.UUID `json:"org_id"`
+	Name    string    `json:"name,omitempty"`
+	Enabled bool      `json:"enabled"`
+}
@@ -5,28 +5,123 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"log/slog"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 // Domain errors returned by the Manager.
 var (
-	ErrNotFound    = errors.New("organization not found")
-	ErrInvalidName = errors.New("organization name is required")
-	ErrInvalidKey  = errors.New("invalid API key format")
-	ErrOrgDisabled = errors.New("organization is disabled")
+	ErrNotFound           = errors.New("organization not found")
+	ErrInvalidName        = errors.New("organization name is required")
+	ErrInvalidKey         = errors.New("invalid API key format")
+	ErrOrgDisabled        = errors.New("organization is disabled")
+	ErrInvalidMetadata    = errors.New("metadata keys must be non-empty (max 50 entries)")
+	ErrNameTaken          = errors.New("organization name is already taken")
+	ErrKeyRotated         = errors.New("API key has been rotated")
+	ErrInvalidGracePeriod = errors.New("grace period must be between 0 and 30 days")
+	ErrInvalidKeyPrefix   = errors.New(`key prefix must be "np_" followed by at least 4 characters of the key`)
+	ErrInvalidKeyHash     = errors.New("key hash must be 64 hex characters")
+	ErrKeyHashPeppered    = errors.New("key hashes can't be matched while an API key pepper is set; look up the full key instead")
 )
 
+// MaxKeyGracePeriod bounds how long a rotated-out API key keeps working.
+const MaxKeyGracePeriod = 30 * 24 * time.Hour
+
+// rotatedKeyRetention is how long a rotated-out key is remembered after it
+// expires, so clients still using it get ErrKeyRotated rather than
+// ErrNotFound. The sweeper forgets it after that.
+const rotatedKeyRetention = 7 * 24 * time.Hour
+
+// keyUseInterval limits how often a key's last use is written: at most once
+// per key per interval on each instance, so busy keys don't write per request.
+const keyUseInterval = time.Minute
+
+// Key use writes happen off the auth path (RunKeyUseWriter). Uses arriving
+// while the buffer is full are dropped and retried on the key's next use.
+const (
+	keyUseBuffer  = 1024
+	keyUseTimeout = 5 * time.Second
+)
+
+// Key lookup bounds. Shorter prefixes match too many keys to be useful.
+const (
+	minKeyPrefixLength  = 7 // "np_" plus 4 characters
+	maxKeyLookupResults = 20
+)
+
+// nameUniqueIndex is the unique index enforcing case-insensitive org names.
+const nameUniqueIndex = "idx_organizations_name_lower"
+
+// isNameTaken reports whether err is a unique violation on the org name index.
+func isNameTaken(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505" && pqErr.Constraint == nameUniqueIndex
+	}
+	return false
+}
+
 // Manager handles business logic for organizations.
 // It coordinates operations and translates datastore errors to domain errors.
 type Manager struct {
-	ds *Datastore
+	ds       *Datastore
+	notifier Notifier
+	pepper   []byte // HMAC key for API key hashes; nil hashes with bare SHA-256
+
+	uses     chan keyUse // Queued last-use writes
+	usesMu   sync.Mutex
+	lastUses map[keyUse]time.Time // Last queued write of each key's last use
+}
+
+// keyUse identifies an organization's current or previous key.
+type keyUse struct {
+	orgID    uuid.UUID
+	previous bool
 }
 
 // NewManager creates a new organization manager.
 func NewManager(ds *Datastore) *Manager {
-	return &Manager{ds: ds}
+	return &Manager{ds: ds, uses: make(chan keyUse, keyUseBuffer)}
+}
+
+// SetNotifier registers a receiver for lifecycle events.
+// Must be called before the manager is used concurrently.
+func (m *Manager) SetNotifier(n Notifier) {
+	m.notifier = n
+}
+
+// SetAPIKeyPepper switches new and rotated API keys to HMAC-SHA256 hashes
+// keyed with pepper. Keys stored as bare SHA-256 keep authenticating and are
+// rehashed on their next successful use. Changing the pepper invalidates
+// every HMAC hash, so it must stay fixed once set.
+// Must be called before the manager is used concurrently.
+func (m *Manager) SetAPIKeyPepper(pepper string) {
+	m.pepper = nil
+	if pepper != "" {
+		m.pepper = []byte(pepper)
+	}
+}
+
+// newAPIKey generates an API key hashed with the manager's current scheme.
+func (m *Manager) newAPIKey() APIKey {
+	key := GenerateAPIKey()
+	if m.pepper != nil {
+		key.Hash = HashAPIKeyHMAC(key.Plaintext, m.pepper)
+		key.HashVersion = KeyHashHMAC
+	}
+	return key
+}
+
+// notify emits a lifecycle event if a notifier is registered.
+func (m *Manager) notify(eventType string, data EventData) {
+	if m.notifier != nil {
+		m.notifier.Notify(eventType, data)
+	}
 }
 
 // CreateOrgResult contains the result of creating an organization.
@@ -44,13 +139,18 @@ func (m *Manager) Create(ctx context.Context, name string) (*CreateOrgResult, er
 		return nil, ErrInvalidName
 	}
 
-	apiKey := GenerateAPIKey()
+	apiKey := m.newAPIKey()
 
-	org, err := m.ds.Create(ctx, name, apiKey.Hash)
+	org, err := m.ds.Create(ctx, name, apiKey)
 	if err != nil {
+		if isNameTaken(err) {
+			return nil, ErrNameTaken
+		}
 		return nil, fmt.Errorf("failed to create organization: %w", err)
 	}
 
+	m.notify(EventCreated, EventData{OrgID: org.ID, Name: org.Name, Enabled: org.Enabled})
+
 	return &CreateOrgResult{
 		Org:    org,
 		APIKey: apiKey,
@@ -70,22 +170,34 @@ func (m *Manager) GetByID(ctx context.Context, id uuid.UUID) (*Org, error) {
 }
 
 // Authenticate validates an API key and returns the associated organization.
-// Returns ErrNotFound if key doesn't exist, ErrOrgDisabled if org is disabled.
+// A key replaced by a rotation still authenticates during its grace period.
+// Returns ErrNotFound if key doesn't exist, ErrKeyRotated if it was rotated
+// out and its grace period is over, ErrOrgDisabled if org is disabled.
 func (m *Manager) Authenticate(ctx context.Context, apiKey string) (*Org, error) {
 	apiKey = strings.TrimSpace(apiKey)
 	if apiKey == "" || !strings.HasPrefix(apiKey, "np_") {
 		return nil, ErrInvalidKey
 	}
 
-	hash := HashAPIKey(apiKey)
-	org, err := m.ds.GetByAPIKeyHash(ctx, hash)
+	org, err := m.lookupAPIKey(ctx, apiKey)
+	previous := false
+	if errors.Is(err, sql.ErrNoRows) {
+		org, err = m.lookupPreviousKey(ctx, apiKey)
+		previous = true
+	}
 	if err != nil {
+		if errors.Is(err, ErrKeyRotated) {
+			return nil, err
+		}
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotFound
 		}
 		return nil, fmt.Errorf("authentication failed: %w", err)
 	}
 
+	// Recorded for disabled orgs too: support wants to see the key in use
+	m.recordUse(org.ID, previous)
+
 	if !org.Enabled {
 		return nil, ErrOrgDisabled
 	}
@@ -93,6 +205,123 @@ func (m *Manager) Authenticate(ctx context.Context, apiKey string) (*Org, error)
 	return org, nil
 }
 
+// lookupAPIKey finds the organization for a key, trying the current hash
+// scheme first. With a pepper set, a miss falls back to the bare SHA-256
+// hash of keys created before the pepper, and a match is upgraded to HMAC.
+func (m *Manager) lookupAPIKey(ctx context.Context, apiKey string) (*Org, error) {
+	if m.pepper == nil {
+		return m.ds.GetByAPIKeyHash(ctx, HashAPIKey(apiKey))
+	}
+
+	hash := HashAPIKeyHMAC(apiKey, m.pepper)
+	org, err := m.ds.GetByAPIKeyHash(ctx, hash)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return org, err
+	}
+
+	legacyHash := HashAPIKey(apiKey)
+	org, err = m.ds.GetByAPIKeyHash(ctx, legacyHash)
+	if err != nil {
+		return nil, err
+	}
+	if org.KeyHashVersion != KeyHashSHA256 {
+		return nil, sql.ErrNoRows
+	}
+
+	// Best effort: a failed upgrade is retried on the key's next use
+	rowsAffected, err := m.ds.UpgradeAPIKeyHash(ctx, org.ID, legacyHash, hash)
+	if err != nil {
+		slog.Warn("failed to upgrade API key hash", "org_id", org.ID, "error", err)
+	} else if rowsAffected > 0 {
+		org.APIKeyHash = hash
+		org.KeyHashVersion = KeyHashHMAC
+	}
+	return org, nil
+}
+
+// recordUse queues a write of a key's last use, at most once per
+// keyUseInterval. It never blocks authentication: RunKeyUseWriter does the
+// write, and a use that finds the queue full is dropped.
+func (m *Manager) recordUse(orgID uuid.UUID, previous bool) {
+	key, now := keyUse{orgID: orgID, previous: previous}, time.Now()
+	m.usesMu.Lock()
+	defer m.usesMu.Unlock()
+	if last, ok := m.lastUses[key]; ok && now.Sub(last) < keyUseInterval {
+		return
+	}
+	select {
+	case m.uses <- key:
+	default:
+		return // Not remembered, so the next use tries again
+	}
+	if m.lastUses == nil {
+		m.lastUses = make(map[keyUse]time.Time)
+	}
+	m.lastUses[key] = now
+}
+
+// RunKeyUseWriter writes queued key uses until ctx is done, and every
+// keyUseInterval forgets uses old enough that they no longer throttle
+// writes, so lastUses only holds keys seen within the interval. Uses still
+// queued when ctx is done are dropped: last use is advisory.
+func (m *Manager) RunKeyUseWriter(ctx context.Context) {
+	ticker := time.NewTicker(keyUseInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case key := <-m.uses:
+			m.writeKeyUse(ctx, key)
+		case now := <-ticker.C:
+			m.pruneKeyUses(now)
+		}
+	}
+}
+
+// writeKeyUse sets a key's last use to now. Failures are logged.
+func (m *Manager) writeKeyUse(ctx context.Context, key keyUse) {
+	ctx, cancel := context.WithTimeout(ctx, keyUseTimeout)
+	defer cancel()
+	if _, err := m.ds.TouchAPIKey(ctx, key.orgID, key.previous); err != nil {
+		slog.Warn("failed to record API key use", "org_id", key.orgID, "error", err)
+	}
+}
+
+// pruneKeyUses forgets uses queued keyUseInterval or more before now.
+func (m *Manager) pruneKeyUses(now time.Time) {
+	m.usesMu.Lock()
+	defer m.usesMu.Unlock()
+	for key, last := range m.lastUses {
+		if now.Sub(last) >= keyUseInterval {
+			delete(m.lastUses, key)
+		}
+	}
+}
+
+// lookupPreviousKey finds the organization whose last rotated-out key is
+// apiKey. It returns the org while the key's grace period lasts and
+// ErrKeyRotated after it.
+func (m *Manager) lookupPreviousKey(ctx context.Context, apiKey string) (*Org, error) {
+	hash, version := HashAPIKey(apiKey), KeyHashSHA256
+	if m.pepper != nil {
+		hash, version = HashAPIKeyHMAC(apiKey, m.pepper), KeyHashHMAC
+	}
+	org, expiresAt, err := m.ds.GetByPreviousKeyHash(ctx, hash, version)
+	if errors.Is(err, sql.ErrNoRows) && m.pepper != nil {
+		// Replaced before it was ever rehashed
+		org, expiresAt, err = m.ds.GetByPreviousKeyHash(ctx, HashAPIKey(apiKey), KeyHashSHA256)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !time.Now().Before(expiresAt) {
+		return nil, ErrKeyRotated
+	}
+	return org, nil
+}
+
 // Enable enables an organization.
 func (m *Manager) Enable(ctx context.Context, id uuid.UUID) error {
 	rowsAffected, err := m.ds.SetEnabled(ctx, id, true)
@@ -102,6 +331,7 @@ func (m *Manager) Enable(ctx context.Context, id uuid.UUID) error {
 	if rowsAffected == 0 {
 		return ErrNotFound
 	}
+	m.notify(EventEnabled, EventData{OrgID: id, Enabled: true})
 	return nil
 }
 
@@ -114,28 +344,100 @@ func (m *Manager) Disable(ctx context.Context, id uuid.UUID) error {
 	if rowsAffected == 0 {
 		return ErrNotFound
 	}
+	m.notify(EventDisabled, EventData{OrgID: id, Enabled: false})
 	return nil
 }
 
-// Update updates an organization's name.
-func (m *Manager) Update(ctx context.Context, id uuid.UUID, name string) error {
-	name = strings.TrimSpace(name)
-	if name == "" {
-		return ErrInvalidName
+// UpdateInput holds the fields to change on an organization.
+// Nil fields are left untouched, so callers can apply partial updates.
+type UpdateInput struct {
+	Name    *string
+	Enabled *bool
+	// Metadata replaces the organization's labels when non-nil.
+	// An empty map clears them.
+	Metadata map[string]string
+}
+
+// maxMetadataEntries bounds the number of labels stored on an organization.
+const maxMetadataEntries = 50
+
+// Update applies the non-nil fields of input to an organization.
+func (m *Manager) Update(ctx context.Context, id uuid.UUID, input UpdateInput) error {
+	if input.Name != nil {
+		name := strings.TrimSpace(*input.Name)
+		if name == "" {
+			return ErrInvalidName
+		}
+		input.Name = &name
+	}
+	if input.Metadata != nil {
+		if err := validateMetadata(input.Metadata); err != nil {
+			return err
+		}
 	}
 
-	org, err := m.GetByID(ctx, id)
-	if err != nil {
+	if input.Name == nil && input.Enabled == nil && input.Metadata == nil {
+		_, err := m.GetByID(ctx, id)
 		return err
 	}
 
-	org.Name = name
-	rowsAffected, err := m.ds.Update(ctx, org)
+	// Read and write under a row lock so concurrent partial updates (or a
+	// key rotation) can't overwrite each other's fields
+	var org *Org
+	var wasEnabled bool
+	err := m.ds.InTx(ctx, func(ds *Datastore) error {
+		var err error
+		org, err = getForUpdate(ctx, ds, id)
+		if err != nil {
+			return err
+		}
+
+		wasEnabled = org.Enabled
+		if input.Name != nil {
+			org.Name = *input.Name
+		}
+		if input.Enabled != nil {
+			org.Enabled = *input.Enabled
+		}
+		if input.Metadata != nil {
+			org.Metadata = input.Metadata
+		}
+
+		rowsAffected, err := ds.Update(ctx, org)
+		if err != nil {
+			if isNameTaken(err) {
+				return ErrNameTaken
+			}
+			return fmt.Errorf("failed to update organization: %w", err)
+		}
+		if rowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to update organization: %w", err)
+		return err
 	}
-	if rowsAffected == 0 {
-		return ErrNotFound
+
+	if org.Enabled != wasEnabled {
+		eventType := EventDisabled
+		if org.Enabled {
+			eventType = EventEnabled
+		}
+		m.notify(eventType, EventData{OrgID: org.ID, Name: org.Name, Enabled: org.Enabled})
+	}
+	return nil
+}
+
+// validateMetadata checks label keys are non-empty and within limits.
+func validateMetadata(metadata map[string]string) error {
+	if len(metadata) > maxMetadataEntries {
+		return ErrInvalidMetadata
+	}
+	for k := range metadata {
+		if strings.TrimSpace(k) == "" {
+			return ErrInvalidMetadata
+		}
 	}
 	return nil
 }
@@ -149,11 +451,56 @@ func (m *Manager) Delete(ctx context.Context, id uuid.UUID) error {
 	if rowsAffected == 0 {
 		return ErrNotFound
 	}
+	m.notify(EventDeleted, EventData{OrgID: id})
 	return nil
 }
 
 // List retrieves organizations with pagination.
 func (m *Manager) List(ctx context.Context, limit, offset int) ([]*Org, error) {
+	limit, offset = normalizePagination(limit, offset)
+
+	orgs, err := m.ds.List(ctx, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+	return orgs, nil
+}
+
+// GetSummary retrieves an organization by ID with its provider key counts.
+func (m *Manager) GetSummary(ctx context.Context, id uuid.UUID) (*OrgSummary, error) {
+	s, err := m.ds.GetSummaryByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	return s, nil
+}
+
+// ListSummaries retrieves organizations with provider key counts.
+// Pagination is normalized the same way as List.
+func (m *Manager) ListSummaries(ctx context.Context, limit, offset int) ([]*OrgSummary, error) {
+	limit, offset = normalizePagination(limit, offset)
+
+	summaries, err := m.ds.ListSummaries(ctx, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+	return summaries, nil
+}
+
+// Export calls fn for every organization, oldest first, without pagination.
+// Callers stream each summary to their output; an error from fn aborts the export.
+func (m *Manager) Export(ctx context.Context, fn func(*OrgSummary) error) error {
+	if err := m.ds.Export(ctx, fn); err != nil {
+		return fmt.Errorf("failed to export organizations: %w", err)
+	}
+	return nil
+}
+
+// normalizePagination applies the default page size and bounds.
+func normalizePagination(limit, offset int) (int, int) {
 	if limit <= 0 {
 		limit = 20
 	}
@@ -163,32 +510,175 @@ func (m *Manager) List(ctx context.Context, limit, offset int) ([]*Org, error) {
 	if offset < 0 {
 		offset = 0
 	}
+	return limit, offset
+}
 
-	orgs, err := m.ds.List(ctx, limit, offset)
+// RotateAPIKey generates a new API key for an organization, invalidating the
+// old one immediately. Returns the new plaintext key (only available once).
+func (m *Manager) RotateAPIKey(ctx context.Context, id uuid.UUID) (*APIKey, error) {
+	result, err := m.RotateAPIKeyWithGrace(ctx, id, 0)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list organizations: %w", err)
+		return nil, err
 	}
-	return orgs, nil
+	return &result.APIKey, nil
 }
 
-// RotateAPIKey generates a new API key for an organization.
-// Returns the new plaintext key (only available once).
-func (m *Manager) RotateAPIKey(ctx context.Context, id uuid.UUID) (*APIKey, error) {
-	org, err := m.GetByID(ctx, id)
+// RotateAPIKeyResult contains the result of rotating an API key.
+type RotateAPIKeyResult struct {
+	APIKey               APIKey
+	PreviousKeyExpiresAt time.Time // When the replaced key stops authenticating
+}
+
+// RotateAPIKeyWithGrace generates a new API key for an organization. The old
+// key keeps authenticating for grace (zero ends it at once, at most
+// MaxKeyGracePeriod) and is then rejected with ErrKeyRotated. Only one
+// previous key is kept, so rotating again ends any running grace period.
+func (m *Manager) RotateAPIKeyWithGrace(ctx context.Context, id uuid.UUID, grace time.Duration) (*RotateAPIKeyResult, error) {
+	if grace < 0 || grace > MaxKeyGracePeriod {
+		return nil, ErrInvalidGracePeriod
+	}
+
+	newKey := m.newAPIKey()
+	expiresAt := time.Now().Add(grace)
+
+	err := m.ds.InTx(ctx, func(ds *Datastore) error {
+		org, err := getForUpdate(ctx, ds, id)
+		if err != nil {
+			return err
+		}
+
+		// Copies the old key's columns, so it must run before Update
+		if _, err := ds.RecordRotation(ctx, id, newKey.Prefix, expiresAt); err != nil {
+			return fmt.Errorf("failed to rotate API key: %w", err)
+		}
+
+		org.APIKeyHash = newKey.Hash
+		org.KeyHashVersion = newKey.HashVersion
+		rowsAffected, err := ds.Update(ctx, org)
+		if err != nil {
+			return fmt.Errorf("failed to rotate API key: %w", err)
+		}
+		if rowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	newKey := GenerateAPIKey()
-	org.APIKeyHash = newKey.Hash
+	return &RotateAPIKeyResult{APIKey: newKey, PreviousKeyExpiresAt: expiresAt}, nil
+}
+
+// LookupByKeyPrefix finds the current and previous keys starting with
+// prefix, for support to identify a key from its first characters. A full
+// key is accepted and cut to its stored prefix. Returns ErrInvalidKeyPrefix
+// for prefixes too short or not shaped like a NavPlane key.
+func (m *Manager) LookupByKeyPrefix(ctx context.Context, prefix string) ([]*KeyMatch, error) {
+	prefix = strings.ToLower(strings.TrimSpace(prefix))
+	if len(prefix) > KeyPrefixLength {
+		prefix = prefix[:KeyPrefixLength]
+	}
+	if len(prefix) < minKeyPrefixLength || !strings.HasPrefix(prefix, "np_") ||
+		strings.Trim(prefix[3:], "0123456789abcdef-") != "" {
+		return nil, ErrInvalidKeyPrefix
+	}
 
-	rowsAffected, err := m.ds.Update(ctx, org)
+	// The checks above leave "_" from "np_" as the only LIKE metacharacter
+	matches, err := m.ds.FindKeysByPrefix(ctx, `np\_`+prefix[3:]+"%", maxKeyLookupResults)
 	if err != nil {
-		return nil, fmt.Errorf("failed to rotate API key: %w", err)
+		return nil, fmt.Errorf("failed to look up API keys: %w", err)
 	}
-	if rowsAffected == 0 {
-		return nil, ErrNotFound
+	return matches, nil
+}
+
+// LookupByKeyHash finds the current or previous key stored with hash, for
+// when support has the full key and hashes it client-side instead of sending
+// it. Hashes are compared as stored, so this only works without a pepper:
+// HMAC hashes can't be computed without it.
+// Returns ErrKeyHashPeppered with a pepper set, and ErrInvalidKeyHash
+// unless hash is 64 hex characters.
+func (m *Manager) LookupByKeyHash(ctx context.Context, hash string) ([]*KeyMatch, error) {
+	if m.pepper != nil {
+		return nil, ErrKeyHashPeppered
+	}
+	hash = strings.ToLower(strings.TrimSpace(hash))
+	if len(hash) != 64 || strings.Trim(hash, "0123456789abcdef") != "" {
+		return nil, ErrInvalidKeyHash
+	}
+
+	matches, err := m.ds.FindKeysByHash(ctx, hash, maxKeyLookupResults)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up API keys: %w", err)
 	}
+	return matches, nil
+}
 
-	return &newKey, nil
+// LookupByKey finds the current or previous key matching a full API key,
+// hashing it here with every scheme a stored key may use: HMAC with the
+// pepper, then bare SHA-256 for keys not yet rehashed.
+// Returns ErrInvalidKey unless apiKey is shaped like a NavPlane key.
+func (m *Manager) LookupByKey(ctx context.Context, apiKey string) ([]*KeyMatch, error) {
+	apiKey = strings.TrimSpace(apiKey)
+	if !strings.HasPrefix(apiKey, "np_") {
+		return nil, ErrInvalidKey
+	}
+
+	hashes := []string{HashAPIKey(apiKey)}
+	if m.pepper != nil {
+		hashes = []string{HashAPIKeyHMAC(apiKey, m.pepper), HashAPIKey(apiKey)}
+	}
+	var matches []*KeyMatch
+	for _, hash := range hashes {
+		found, err := m.ds.FindKeysByHash(ctx, hash, maxKeyLookupResults)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up API keys: %w", err)
+		}
+		matches = append(matches, found...)
+	}
+	return matches, nil
+}
+
+// SweepRotatedKeys forgets rotated-out keys that expired more than
+// rotatedKeyRetention ago; after that they fail as unknown keys.
+// Returns the number of keys removed.
+func (m *Manager) SweepRotatedKeys(ctx context.Context) (int64, error) {
+	n, err := m.ds.ClearExpiredPreviousKeys(ctx, time.Now().Add(-rotatedKeyRetention))
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear expired API keys: %w", err)
+	}
+	return n, nil
+}
+
+// RunRotatedKeySweep sweeps on start and then every interval until ctx is done.
+func (m *Manager) RunRotatedKeySweep(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := m.SweepRotatedKeys(ctx)
+		if err != nil {
+			slog.Error("rotated API key sweep failed", "error", err)
+		} else if n > 0 {
+			slog.Info("rotated API key sweep complete", "cleared", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// getForUpdate locks and loads an organization inside a transaction.
+func getForUpdate(ctx context.Context, ds *Datastore, id uuid.UUID) (*Org, error) {
+	org, err := ds.GetByIDForUpdate(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	return org, nil
 }
@@ -4,11 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 func TestManager_Create_Success(t *testing.T) {
@@ -24,7 +26,7 @@ func TestManager_Create_Success(t *testing.T) {
 	now := time.Now()
 
 	mock.ExpectQuery(`INSERT INTO organizations`).
-		WithArgs(sqlmock.AnyArg(), "Test Org", sqlmock.AnyArg(), true, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), "Test Org", sqlmock.AnyArg(), true, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at"}).AddRow(now, now))
 
 	result, err := m.Create(ctx, "Test Org")
@@ -47,6 +49,39 @@ func TestManager_Create_Success(t *testing.T) {
 	}
 }
 
+func TestManager_Create_WithPepper(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	const pepper = "pepper-0123456789abcdef0123456789"
+	m := NewManager(NewDatastore(db))
+	m.SetAPIKeyPepper(pepper)
+	now := time.Now()
+
+	mock.ExpectQuery(`INSERT INTO organizations`).
+		WithArgs(sqlmock.AnyArg(), "Test Org", sqlmock.AnyArg(), true, sqlmock.AnyArg(), sqlmock.AnyArg(), KeyHashHMAC, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at"}).AddRow(now, now))
+
+	result, err := m.Create(context.Background(), "Test Org")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := HashAPIKeyHMAC(result.APIKey.Plaintext, []byte(pepper)); result.APIKey.Hash != want {
+		t.Errorf("expected HMAC hash %q, got %q", want, result.APIKey.Hash)
+	}
+	if result.Org.APIKeyHash != result.APIKey.Hash || result.Org.KeyHashVersion != KeyHashHMAC {
+		t.Errorf("expected org to store the HMAC hash, got %q version %d", result.Org.APIKeyHash, result.Org.KeyHashVersion)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
 func TestManager_Create_InvalidName(t *testing.T) {
 	m := &Manager{ds: nil}
 
@@ -82,7 +117,7 @@ func TestManager_Create_TrimsWhitespace(t *testing.T) {
 	now := time.Now()
 
 	mock.ExpectQuery(`INSERT INTO organizations`).
-		WithArgs(sqlmock.AnyArg(), "Trimmed Name", sqlmock.AnyArg(), true, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), "Trimmed Name", sqlmock.AnyArg(), true, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at"}).AddRow(now, now))
 
 	result, err := m.Create(ctx, "  Trimmed Name  ")
@@ -108,8 +143,8 @@ func TestManager_GetByID_Success(t *testing.T) {
 	id := uuid.New()
 	now := time.Now()
 
-	rows := sqlmock.NewRows([]string{"id", "name", "api_key_hash", "enabled", "created_at", "updated_at"}).
-		AddRow(id, "Test Org", "hash123", true, now, now)
+	rows := sqlmock.NewRows(orgRowColumns).
+		AddRow(id, "Test Org", "hash123", true, now, now, []byte(`{}`), 1)
 
 	mock.ExpectQuery(`SELECT .+ FROM organizations WHERE id = \$1`).
 		WithArgs(id).
@@ -147,6 +182,18 @@ func TestManager_GetByID_NotFound(t *testing.T) {
 	}
 }
 
+// drainKeyUses writes the key uses queued so far, as RunKeyUseWriter would.
+func drainKeyUses(m *Manager) {
+	for {
+		select {
+		case key := <-m.uses:
+			m.writeKeyUse(context.Background(), key)
+		default:
+			return
+		}
+	}
+}
+
 func TestManager_Authenticate_Success(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -163,14 +210,18 @@ func TestManager_Authenticate_Success(t *testing.T) {
 	apiKey := "np_test-key-12345"
 	hash := HashAPIKey(apiKey)
 
-	rows := sqlmock.NewRows([]string{"id", "name", "api_key_hash", "enabled", "created_at", "updated_at"}).
-		AddRow(id, "Test Org", hash, true, now, now)
+	rows := sqlmock.NewRows(orgRowColumns).
+		AddRow(id, "Test Org", hash, true, now, now, []byte(`{}`), 1)
 
 	mock.ExpectQuery(`SELECT .+ FROM organizations WHERE api_key_hash = \$1`).
 		WithArgs(hash).
 		WillReturnRows(rows)
+	mock.ExpectExec(`UPDATE organizations SET api_key_last_used_at = NOW\(\) WHERE id = \$1`).
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
 
 	org, err := m.Authenticate(ctx, apiKey)
+	drainKeyUses(m)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -178,6 +229,53 @@ func TestManager_Authenticate_Success(t *testing.T) {
 	if org.Name != "Test Org" {
 		t.Errorf("expected name 'Test Org', got %q", org.Name)
 	}
+
+	// Within keyUseInterval the last use isn't written again
+	mock.ExpectQuery(`SELECT .+ FROM organizations WHERE api_key_hash = \$1`).
+		WithArgs(hash).
+		WillReturnRows(sqlmock.NewRows(orgRowColumns).
+			AddRow(id, "Test Org", hash, true, now, now, []byte(`{}`), 1))
+	if _, err := m.Authenticate(ctx, apiKey); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	drainKeyUses(m)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestManager_RecordUse_NeverBlocks(t *testing.T) {
+	m := NewManager(nil) // The writer isn't running, so nothing is written
+	for i := 0; i < keyUseBuffer+10; i++ {
+		m.recordUse(uuid.New(), false)
+	}
+	if len(m.uses) != keyUseBuffer {
+		t.Errorf("expected a full queue of %d uses, got %d", keyUseBuffer, len(m.uses))
+	}
+	// Dropped uses aren't remembered, so their keys try again on next use
+	if len(m.lastUses) != keyUseBuffer {
+		t.Errorf("expected only queued uses remembered, got %d", len(m.lastUses))
+	}
+}
+
+func TestManager_PruneKeyUses(t *testing.T) {
+	m := NewManager(nil)
+	recent, stale := uuid.New(), uuid.New()
+	now := time.Now()
+	m.lastUses = map[keyUse]time.Time{
+		{orgID: recent}: now.Add(-keyUseInterval / 2),
+		{orgID: stale}:  now.Add(-keyUseInterval),
+	}
+
+	m.pruneKeyUses(now)
+
+	if _, ok := m.lastUses[keyUse{orgID: recent}]; !ok {
+		t.Error("expected a use within keyUseInterval to be kept")
+	}
+	if _, ok := m.lastUses[keyUse{orgID: stale}]; ok {
+		t.Error("expected a use keyUseInterval old to be forgotten")
+	}
 }
 
 func TestManager_Authenticate_InvalidKey(t *testing.T) {
@@ -217,6 +315,9 @@ func TestManager_Authenticate_NotFound(t *testing.T) {
 	mock.ExpectQuery(`SELECT .+ FROM organizations WHERE api_key_hash = \$1`).
 		WithArgs(sqlmock.AnyArg()).
 		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`SELECT .+ FROM organizations WHERE previous_key_hash = \$1`).
+		WithArgs(sqlmock.AnyArg(), KeyHashSHA256).
+		WillReturnError(sql.ErrNoRows)
 
 	_, err = m.Authenticate(ctx, "np_nonexistent")
 	if !errors.Is(err, ErrNotFound) {
@@ -240,19 +341,256 @@ func TestManager_Authenticate_OrgDisabled(t *testing.T) {
 	apiKey := "np_test-key-12345"
 	hash := HashAPIKey(apiKey)
 
-	rows := sqlmock.NewRows([]string{"id", "name", "api_key_hash", "enabled", "created_at", "updated_at"}).
-		AddRow(id, "Test Org", hash, false, now, now) // enabled = false
+	rows := sqlmock.NewRows(orgRowColumns).
+		AddRow(id, "Test Org", hash, false, now, now, []byte(`{}`), 1) // enabled = false
 
 	mock.ExpectQuery(`SELECT .+ FROM organizations WHERE api_key_hash = \$1`).
 		WithArgs(hash).
 		WillReturnRows(rows)
+	mock.ExpectExec(`UPDATE organizations SET api_key_last_used_at`).
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
 
 	_, err = m.Authenticate(ctx, apiKey)
+	drainKeyUses(m)
 	if !errors.Is(err, ErrOrgDisabled) {
 		t.Errorf("expected ErrOrgDisabled, got %v", err)
 	}
 }
 
+func TestManager_Authenticate_HashGenerations(t *testing.T) {
+	const pepper = "pepper-0123456789abcdef0123456789"
+	apiKey := "np_test-key-12345"
+	legacyHash := HashAPIKey(apiKey)
+	hmacHash := HashAPIKeyHMAC(apiKey, []byte(pepper))
+	id := uuid.New()
+	now := time.Now()
+
+	orgRow := func(hash string, version int) *sqlmock.Rows {
+		return sqlmock.NewRows(orgRowColumns).
+			AddRow(id, "Test Org", hash, true, now, now, []byte(`{}`), version)
+	}
+	lookup := `SELECT .+ FROM organizations WHERE api_key_hash = \$1`
+	previous := `SELECT .+ FROM organizations WHERE previous_key_hash = \$1`
+	upgrade := `UPDATE organizations SET api_key_hash = \$3, key_hash_version = \$4 WHERE id = \$1 AND api_key_hash = \$2 AND key_hash_version = \$5`
+
+	tests := []struct {
+		name        string
+		pepper      string
+		expect      func(mock sqlmock.Sqlmock)
+		wantErr     error
+		wantHash    string
+		wantVersion int
+	}{
+		{
+			name:   "hmac key",
+			pepper: pepper,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(lookup).WithArgs(hmacHash).WillReturnRows(orgRow(hmacHash, KeyHashHMAC))
+			},
+			wantHash:    hmacHash,
+			wantVersion: KeyHashHMAC,
+		},
+		{
+			name:   "legacy key is rehashed",
+			pepper: pepper,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(lookup).WithArgs(hmacHash).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery(lookup).WithArgs(legacyHash).WillReturnRows(orgRow(legacyHash, KeyHashSHA256))
+				mock.ExpectExec(upgrade).
+					WithArgs(id, legacyHash, hmacHash, KeyHashHMAC, KeyHashSHA256).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			wantHash:    hmacHash,
+			wantVersion: KeyHashHMAC,
+		},
+		{
+			name:   "legacy key authenticates when rehash fails",
+			pepper: pepper,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(lookup).WithArgs(hmacHash).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery(lookup).WithArgs(legacyHash).WillReturnRows(orgRow(legacyHash, KeyHashSHA256))
+				mock.ExpectExec(upgrade).WillReturnError(errors.New("connection reset"))
+			},
+			wantHash:    legacyHash,
+			wantVersion: KeyHashSHA256,
+		},
+		{
+			name:   "legacy key already rehashed concurrently",
+			pepper: pepper,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(lookup).WithArgs(hmacHash).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery(lookup).WithArgs(legacyHash).WillReturnRows(orgRow(legacyHash, KeyHashSHA256))
+				mock.ExpectExec(upgrade).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			wantHash:    legacyHash,
+			wantVersion: KeyHashSHA256,
+		},
+		{
+			// Bare SHA-256 matches count only on rows not yet upgraded
+			name:   "sha256 match on hmac row rejected",
+			pepper: pepper,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(lookup).WithArgs(hmacHash).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery(lookup).WithArgs(legacyHash).WillReturnRows(orgRow(legacyHash, KeyHashHMAC))
+				mock.ExpectQuery(previous).WithArgs(hmacHash, KeyHashHMAC).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery(previous).WithArgs(legacyHash, KeyHashSHA256).WillReturnError(sql.ErrNoRows)
+			},
+			wantErr: ErrNotFound,
+		},
+		{
+			name:   "unknown key",
+			pepper: pepper,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(lookup).WithArgs(hmacHash).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery(lookup).WithArgs(legacyHash).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery(previous).WithArgs(hmacHash, KeyHashHMAC).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery(previous).WithArgs(legacyHash, KeyHashSHA256).WillReturnError(sql.ErrNoRows)
+			},
+			wantErr: ErrNotFound,
+		},
+		{
+			name: "no pepper uses sha256 only",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(lookup).WithArgs(legacyHash).WillReturnRows(orgRow(legacyHash, KeyHashSHA256))
+			},
+			wantHash:    legacyHash,
+			wantVersion: KeyHashSHA256,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to create mock: %v", err)
+			}
+			defer db.Close()
+
+			m := NewManager(NewDatastore(db))
+			m.SetAPIKeyPepper(tt.pepper)
+			tt.expect(mock)
+			if tt.wantErr == nil {
+				mock.ExpectExec(`UPDATE organizations SET api_key_last_used_at = NOW\(\) WHERE id = \$1`).
+					WithArgs(id).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			org, err := m.Authenticate(context.Background(), apiKey)
+			drainKeyUses(m)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if org.APIKeyHash != tt.wantHash || org.KeyHashVersion != tt.wantVersion {
+					t.Errorf("expected hash %q version %d, got %q version %d",
+						tt.wantHash, tt.wantVersion, org.APIKeyHash, org.KeyHashVersion)
+				}
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestManager_Authenticate_PreviousKey(t *testing.T) {
+	const pepper = "pepper-0123456789abcdef0123456789"
+	apiKey := "np_old-key-12345"
+	legacyHash := HashAPIKey(apiKey)
+	hmacHash := HashAPIKeyHMAC(apiKey, []byte(pepper))
+	id := uuid.New()
+	now := time.Now()
+
+	previousRow := func(expiresAt time.Time) *sqlmock.Rows {
+		return sqlmock.NewRows(append(append([]string{}, orgRowColumns...), "previous_key_expires_at")).
+			AddRow(id, "Test Org", "new-hash", true, now, now, []byte(`{}`), KeyHashHMAC, expiresAt)
+	}
+	lookup := `SELECT .+ FROM organizations WHERE api_key_hash = \$1`
+	previous := `SELECT .+ FROM organizations WHERE previous_key_hash = \$1 AND previous_key_hash_version = \$2`
+
+	tests := []struct {
+		name    string
+		pepper  string
+		expect  func(mock sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name: "within grace period",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(lookup).WithArgs(legacyHash).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery(previous).WithArgs(legacyHash, KeyHashSHA256).WillReturnRows(previousRow(now.Add(time.Hour)))
+			},
+		},
+		{
+			name: "grace period over",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(lookup).WithArgs(legacyHash).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery(previous).WithArgs(legacyHash, KeyHashSHA256).WillReturnRows(previousRow(now.Add(-time.Minute)))
+			},
+			wantErr: ErrKeyRotated,
+		},
+		{
+			name:   "hmac previous key",
+			pepper: pepper,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(lookup).WithArgs(hmacHash).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery(lookup).WithArgs(legacyHash).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery(previous).WithArgs(hmacHash, KeyHashHMAC).WillReturnRows(previousRow(now.Add(time.Hour)))
+			},
+		},
+		{
+			name:   "sha256 previous key with pepper set",
+			pepper: pepper,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(lookup).WithArgs(hmacHash).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery(lookup).WithArgs(legacyHash).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery(previous).WithArgs(hmacHash, KeyHashHMAC).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery(previous).WithArgs(legacyHash, KeyHashSHA256).WillReturnRows(previousRow(now.Add(-time.Minute)))
+			},
+			wantErr: ErrKeyRotated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to create mock: %v", err)
+			}
+			defer db.Close()
+
+			m := NewManager(NewDatastore(db))
+			m.SetAPIKeyPepper(tt.pepper)
+			tt.expect(mock)
+			if tt.wantErr == nil {
+				mock.ExpectExec(`UPDATE organizations SET previous_key_last_used_at = NOW\(\) WHERE id = \$1`).
+					WithArgs(id).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			org, err := m.Authenticate(context.Background(), apiKey)
+			drainKeyUses(m)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+			} else if err != nil || org.ID != id {
+				t.Fatalf("expected org %s, got org=%v err=%v", id, org, err)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
 func TestManager_Enable_Success(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -390,7 +728,7 @@ func TestManager_List_NormalizesPagination(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			rows := sqlmock.NewRows([]string{"id", "name", "api_key_hash", "enabled", "created_at", "updated_at"})
+			rows := sqlmock.NewRows(orgRowColumns)
 
 			mock.ExpectQuery(`SELECT .+ FROM organizations ORDER BY created_at DESC LIMIT \$1 OFFSET \$2`).
 				WithArgs(tt.expectedLimit, tt.expectedOff).
@@ -421,10 +759,535 @@ func TestManager_Update_InvalidName(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := m.Update(context.Background(), uuid.New(), tt.orgName)
+			err := m.Update(context.Background(), uuid.New(), UpdateInput{Name: &tt.orgName})
 			if !errors.Is(err, ErrInvalidName) {
 				t.Errorf("expected ErrInvalidName, got %v", err)
 			}
 		})
 	}
 }
+
+func TestManager_Update_InvalidMetadata(t *testing.T) {
+	m := &Manager{ds: nil}
+
+	tooMany := make(map[string]string)
+	for i := 0; i <= maxMetadataEntries; i++ {
+		tooMany[uuid.NewString()] = "v"
+	}
+
+	tests := []struct {
+		name     string
+		metadata map[string]string
+	}{
+		{"empty key", map[string]string{"": "v"}},
+		{"whitespace key", map[string]string{"  ": "v"}},
+		{"too many entries", tooMany},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := m.Update(context.Background(), uuid.New(), UpdateInput{Metadata: tt.metadata})
+			if !errors.Is(err, ErrInvalidMetadata) {
+				t.Errorf("expected ErrInvalidMetadata, got %v", err)
+			}
+		})
+	}
+}
+
+func TestManager_Update_PartialFields(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	ds := NewDatastore(db)
+	m := NewManager(ds)
+	ctx := context.Background()
+	id := uuid.New()
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT .+ FROM organizations WHERE id = \$1 FOR UPDATE`).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(orgRowColumns).
+			AddRow(id, "Original", "hash123", true, now, now, []byte(`{"team":"core"}`), 1))
+
+	// Only enabled changes; name and metadata are carried over unchanged.
+	mock.ExpectExec(`UPDATE organizations`).
+		WithArgs(id, "Original", "hash123", false, []byte(`{"team":"core"}`), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	enabled := false
+	if err := m.Update(ctx, id, UpdateInput{Enabled: &enabled}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestManager_Update_NoFieldsIsNoop(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	ds := NewDatastore(db)
+	m := NewManager(ds)
+	ctx := context.Background()
+	id := uuid.New()
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT .+ FROM organizations WHERE id = \$1`).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(orgRowColumns).
+			AddRow(id, "Original", "hash123", true, now, now, []byte(`{}`), 1))
+
+	if err := m.Update(ctx, id, UpdateInput{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// No UPDATE expected
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestManager_GetSummary_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	m := NewManager(NewDatastore(db))
+	id := uuid.New()
+
+	mock.ExpectQuery(`SELECT .+ FROM organizations o`).
+		WithArgs(id).
+		WillReturnError(sql.ErrNoRows)
+
+	_, err = m.GetSummary(context.Background(), id)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestManager_Create_NameTaken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	m := NewManager(NewDatastore(db))
+
+	mock.ExpectQuery(`INSERT INTO organizations`).
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "idx_organizations_name_lower"})
+
+	_, err = m.Create(context.Background(), "acme")
+	if !errors.Is(err, ErrNameTaken) {
+		t.Errorf("expected ErrNameTaken, got %v", err)
+	}
+}
+
+func TestManager_Create_OtherUniqueViolationNotNameTaken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	m := NewManager(NewDatastore(db))
+
+	mock.ExpectQuery(`INSERT INTO organizations`).
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "organizations_api_key_hash_key"})
+
+	_, err = m.Create(context.Background(), "Acme")
+	if err == nil || errors.Is(err, ErrNameTaken) {
+		t.Errorf("expected wrapped database error, got %v", err)
+	}
+}
+
+func TestManager_Update_NameTaken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	m := NewManager(NewDatastore(db))
+	id := uuid.New()
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT .+ FROM organizations WHERE id = \$1 FOR UPDATE`).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(orgRowColumns).
+			AddRow(id, "Old Name", "hash123", true, now, now, []byte(`{}`), 1))
+	mock.ExpectExec(`UPDATE organizations`).
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "idx_organizations_name_lower"})
+	mock.ExpectRollback()
+
+	name := "ACME"
+	err = m.Update(context.Background(), id, UpdateInput{Name: &name})
+	if !errors.Is(err, ErrNameTaken) {
+		t.Errorf("expected ErrNameTaken, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestManager_Update_NotFoundRollsBack(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	n := &recordingNotifier{}
+	m := NewManager(NewDatastore(db))
+	m.SetNotifier(n)
+	id := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT .+ FROM organizations WHERE id = \$1 FOR UPDATE`).
+		WithArgs(id).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	enabled := false
+	if err := m.Update(context.Background(), id, UpdateInput{Enabled: &enabled}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if len(n.events) != 0 {
+		t.Errorf("expected no events for a rolled back update, got %v", n.events)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestManager_RotateAPIKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		updateErr error
+		wantErr   bool
+	}{
+		{name: "commits the new hash"},
+		{name: "rolls back on update failure", updateErr: errors.New("connection reset by peer"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to create mock: %v", err)
+			}
+			defer db.Close()
+
+			m := NewManager(NewDatastore(db))
+			id := uuid.New()
+			now := time.Now()
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT .+ FROM organizations WHERE id = \$1 FOR UPDATE`).
+				WithArgs(id).
+				WillReturnRows(sqlmock.NewRows(orgRowColumns).
+					AddRow(id, "Acme", "old-hash", true, now, now, []byte(`{}`), 1))
+			// Without a grace period the old key expires at once
+			mock.ExpectExec(`UPDATE organizations SET previous_key_hash = api_key_hash`).
+				WithArgs(id, sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			update := mock.ExpectExec(`UPDATE organizations SET name`).
+				WithArgs(id, "Acme", sqlmock.AnyArg(), true, sqlmock.AnyArg(), KeyHashSHA256)
+			if tt.updateErr != nil {
+				update.WillReturnError(tt.updateErr)
+				mock.ExpectRollback()
+			} else {
+				update.WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			key, err := m.RotateAPIKey(context.Background(), id)
+			if tt.wantErr {
+				if err == nil || key != nil {
+					t.Errorf("expected error and no key, got key=%v err=%v", key, err)
+				}
+			} else if err != nil || key == nil || key.Plaintext == "" {
+				t.Errorf("expected a new key, got key=%v err=%v", key, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestManager_RotateAPIKeyWithGrace(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	m := NewManager(NewDatastore(db))
+	m.SetAPIKeyPepper("pepper-0123456789abcdef0123456789")
+	id := uuid.New()
+	now := time.Now()
+
+	for _, grace := range []time.Duration{-time.Second, MaxKeyGracePeriod + time.Second} {
+		if _, err := m.RotateAPIKeyWithGrace(context.Background(), id, grace); !errors.Is(err, ErrInvalidGracePeriod) {
+			t.Errorf("grace %v: expected ErrInvalidGracePeriod, got %v", grace, err)
+		}
+	}
+
+	// The old key's hash and scheme are copied before the new hash is written
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT .+ FROM organizations WHERE id = \$1 FOR UPDATE`).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(orgRowColumns).
+			AddRow(id, "Acme", "old-hash", true, now, now, []byte(`{}`), KeyHashSHA256))
+	mock.ExpectExec(`UPDATE organizations SET previous_key_hash = api_key_hash, previous_key_hash_version = key_hash_version`).
+		WithArgs(id, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE organizations SET name`).
+		WithArgs(id, "Acme", sqlmock.AnyArg(), true, sqlmock.AnyArg(), KeyHashHMAC).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	result, err := m.RotateAPIKeyWithGrace(context.Background(), id, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.APIKey.HashVersion != KeyHashHMAC {
+		t.Errorf("expected the new key to use HMAC, got version %d", result.APIKey.HashVersion)
+	}
+	if d := time.Until(result.PreviousKeyExpiresAt); d < 23*time.Hour || d > 24*time.Hour {
+		t.Errorf("expected the old key to expire in 24h, got %v", d)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestManager_SweepRotatedKeys(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	m := NewManager(NewDatastore(db))
+
+	mock.ExpectExec(`UPDATE organizations SET previous_key_hash = NULL`).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	n, err := m.SweepRotatedKeys(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 keys cleared, got %d", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestManager_LookupByKey(t *testing.T) {
+	const key = "np_test-key-12345"
+	hash := HashAPIKey(key)
+	pepper := "test-pepper"
+
+	tests := []struct {
+		name        string
+		pepper      string
+		lookup      func(m *Manager) ([]*KeyMatch, error)
+		wantQueries []string // Arguments the datastore is called with, in order
+		wantErr     error
+	}{
+		{
+			name:        "prefix",
+			lookup:      func(m *Manager) ([]*KeyMatch, error) { return m.LookupByKeyPrefix(context.Background(), "np_0123") },
+			wantQueries: []string{`np\_0123%`},
+		},
+		{
+			name: "full key cut to stored prefix",
+			lookup: func(m *Manager) ([]*KeyMatch, error) {
+				return m.LookupByKeyPrefix(context.Background(), " NP_0123ABCD-ef01-4000 ")
+			},
+			wantQueries: []string{`np\_0123abcd%`},
+		},
+		{
+			name:    "prefix too short",
+			lookup:  func(m *Manager) ([]*KeyMatch, error) { return m.LookupByKeyPrefix(context.Background(), "np_012") },
+			wantErr: ErrInvalidKeyPrefix,
+		},
+		{
+			name:    "prefix with LIKE wildcards",
+			lookup:  func(m *Manager) ([]*KeyMatch, error) { return m.LookupByKeyPrefix(context.Background(), "np_%%%%") },
+			wantErr: ErrInvalidKeyPrefix,
+		},
+		{
+			name:    "not a NavPlane key",
+			lookup:  func(m *Manager) ([]*KeyMatch, error) { return m.LookupByKeyPrefix(context.Background(), "sk-01234567") },
+			wantErr: ErrInvalidKeyPrefix,
+		},
+		{
+			name: "hash",
+			lookup: func(m *Manager) ([]*KeyMatch, error) {
+				return m.LookupByKeyHash(context.Background(), strings.ToUpper(hash))
+			},
+			wantQueries: []string{hash},
+		},
+		{
+			name: "hash not hex",
+			lookup: func(m *Manager) ([]*KeyMatch, error) {
+				return m.LookupByKeyHash(context.Background(), strings.Repeat("z", 64))
+			},
+			wantErr: ErrInvalidKeyHash,
+		},
+		{
+			name:    "hash wrong length",
+			lookup:  func(m *Manager) ([]*KeyMatch, error) { return m.LookupByKeyHash(context.Background(), hash[:40]) },
+			wantErr: ErrInvalidKeyHash,
+		},
+		{
+			name:    "hash with pepper",
+			pepper:  pepper,
+			lookup:  func(m *Manager) ([]*KeyMatch, error) { return m.LookupByKeyHash(context.Background(), hash) },
+			wantErr: ErrKeyHashPeppered,
+		},
+		{
+			name:        "full key",
+			lookup:      func(m *Manager) ([]*KeyMatch, error) { return m.LookupByKey(context.Background(), " "+key+" ") },
+			wantQueries: []string{hash},
+		},
+		{
+			name:        "full key with pepper",
+			pepper:      pepper,
+			lookup:      func(m *Manager) ([]*KeyMatch, error) { return m.LookupByKey(context.Background(), key) },
+			wantQueries: []string{HashAPIKeyHMAC(key, []byte(pepper)), hash},
+		},
+		{
+			name:    "full key not a NavPlane key",
+			lookup:  func(m *Manager) ([]*KeyMatch, error) { return m.LookupByKey(context.Background(), "sk-01234567") },
+			wantErr: ErrInvalidKey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to create mock: %v", err)
+			}
+			defer db.Close()
+
+			for _, query := range tt.wantQueries {
+				mock.ExpectQuery(`UNION ALL`).
+					WithArgs(query, maxKeyLookupResults).
+					WillReturnRows(sqlmock.NewRows(append(append([]string{}, orgRowColumns...), "previous", "prefix", "last_used_at", "expires_at")))
+			}
+
+			m := NewManager(NewDatastore(db))
+			m.SetAPIKeyPepper(tt.pepper)
+			_, err = tt.lookup(m)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+// recordingNotifier captures emitted lifecycle events.
+type recordingNotifier struct {
+	events []string
+	data   []any
+}
+
+func (n *recordingNotifier) Notify(eventType string, data any) {
+	n.events = append(n.events, eventType)
+	n.data = append(n.data, data)
+}
+
+func TestManager_Notify_LifecycleEvents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	n := &recordingNotifier{}
+	m := NewManager(NewDatastore(db))
+	m.SetNotifier(n)
+	ctx := context.Background()
+	id := uuid.New()
+
+	mock.ExpectExec(`UPDATE organizations`).
+		WithArgs(id, false).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE organizations`).
+		WithArgs(id, true).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM organizations WHERE id = \$1`).
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := m.Disable(ctx, id); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := m.Enable(ctx, id); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := m.Delete(ctx, id); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{EventDisabled, EventEnabled, EventDeleted}
+	if len(n.events) != len(want) {
+		t.Fatalf("expected events %v, got %v", want, n.events)
+	}
+	for i := range want {
+		if n.events[i] != want[i] {
+			t.Errorf("event %d: expected %q, got %q", i, want[i], n.events[i])
+		}
+		if data, ok := n.data[i].(EventData); !ok || data.OrgID != id {
+			t.Errorf("event %d: expected data for org %s, got %#v", i, id, n.data[i])
+		}
+	}
+}
+
+func TestManager_Notify_SkippedOnFailure(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	n := &recordingNotifier{}
+	m := NewManager(NewDatastore(db))
+	m.SetNotifier(n)
+	id := uuid.New()
+
+	mock.ExpectExec(`DELETE FROM organizations WHERE id = \$1`).
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := m.Delete(context.Background(), id); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if len(n.events) != 0 {
+		t.Errorf("expected no events, got %v", n.events)
+	}
+}
@@ -1,6 +1,7 @@
 package org
 
 import (
+	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
 	"time"
@@ -14,30 +15,84 @@ type Org struct {
 	ID         uuid.UUID
 	Name       string
 	APIKeyHash string
-	Enabled    bool
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
+	// KeyHashVersion is the scheme APIKeyHash was computed with
+	KeyHashVersion int
+	Enabled        bool
+	Metadata       map[string]string // Free-form labels set by admins
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
 }
 
+// API key hash schemes, stored in organizations.key_hash_version.
+const (
+	KeyHashSHA256 = 1 // Bare SHA-256; accepted until rehashed on next use
+	KeyHashHMAC   = 2 // HMAC-SHA256 keyed with API_KEY_PEPPER
+)
+
+// OrgSummary is an organization together with aggregate counts of
+// resources that belong to it. Used for admin listings and exports.
+type OrgSummary struct {
+	Org
+	ProviderKeyCount       int
+	ActiveProviderKeyCount int
+}
+
+// Key statuses reported by admin key lookups.
+const (
+	KeyStatusActive  = "active"  // Authenticates: the current key, or a previous key in its grace period
+	KeyStatusExpired = "expired" // Rotated out and past its grace period
+	KeyStatusRevoked = "revoked" // Belongs to a disabled organization
+)
+
+// KeyMatch is an organization API key found by an admin lookup.
+type KeyMatch struct {
+	Org        *Org
+	Previous   bool       // The key replaced by the last rotation
+	Prefix     string     // Empty for keys created before prefixes were stored
+	LastUsedAt *time.Time // Nil if the key hasn't authenticated since tracking began
+	ExpiresAt  *time.Time // When a previous key stops authenticating; nil for current keys
+}
+
+// Status reports whether the matched key authenticates at now.
+func (k *KeyMatch) Status(now time.Time) string {
+	switch {
+	case !k.Org.Enabled:
+		return KeyStatusRevoked
+	case k.ExpiresAt != nil && !now.Before(*k.ExpiresAt):
+		return KeyStatusExpired
+	default:
+		return KeyStatusActive
+	}
+}
+
+// KeyPrefixLength is the length of APIKey.Prefix: "np_" plus the first 8
+// characters of the UUID. Prefixes are stored to identify keys, and are
+// not secret.
+const KeyPrefixLength = 11
+
 // APIKey represents a generated API key before hashing.
 // The plaintext key is only available at creation time.
 type APIKey struct {
-	Prefix    string // First 8 chars for identification (e.g., "np_live_")
+	Prefix    string // First KeyPrefixLength chars for identification (e.g., "np_550e8400")
 	Plaintext string // Full key - only shown once at creation
-	Hash      string // SHA-256 hash stored in database
+	Hash      string // Hash stored in database
+	// HashVersion is the scheme Hash was computed with
+	HashVersion int
 }
 
 // GenerateAPIKey creates a new API key with a NavPlane prefix.
-// Returns the full key (to show user once) and the hash (to store).
+// Returns the full key (to show user once) and its SHA-256 hash (to store).
+// Managers with a pepper hash with HMAC instead; see Manager.SetAPIKeyPepper.
 func GenerateAPIKey() APIKey {
 	id := uuid.New()
 	plaintext := "np_" + id.String()
 	hash := HashAPIKey(plaintext)
 
 	return APIKey{
-		Prefix:    plaintext[:11], // "np_" + first 8 chars of UUID
-		Plaintext: plaintext,
-		Hash:      hash,
+		Prefix:      plaintext[:KeyPrefixLength],
+		Plaintext:   plaintext,
+		Hash:        hash,
+		HashVersion: KeyHashSHA256,
 	}
 }
 
@@ -46,3 +101,12 @@ func HashAPIKey(key string) string {
 	h := sha256.Sum256([]byte(key))
 	return hex.EncodeToString(h[:])
 }
+
+// HashAPIKeyHMAC creates an HMAC-SHA256 of an API key keyed with pepper.
+// Unlike HashAPIKey, the stored hashes are useless without the pepper, so a
+// database leak alone doesn't allow offline guessing or forged lookups.
+func HashAPIKeyHMAC(key string, pepper []byte) string {
+	mac := hmac.New(sha256.New, pepper)
+	mac.Write([]byte(key))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -3,6 +3,7 @@ package org
 import (
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestGenerateAPIKey(t *testing.T) {
@@ -73,3 +74,46 @@ func TestHashAPIKey(t *testing.T) {
 		t.Errorf("expected hash length 64, got %d", len(hash1))
 	}
 }
+
+func TestHashAPIKeyHMAC(t *testing.T) {
+	key := "np_test-key-12345"
+	pepper := []byte("pepper-0123456789abcdef0123456789")
+	hash := HashAPIKeyHMAC(key, pepper)
+
+	if hash != HashAPIKeyHMAC(key, pepper) {
+		t.Error("same key and pepper should produce same hash")
+	}
+	if hash == HashAPIKey(key) {
+		t.Error("HMAC hash should differ from the bare SHA-256 hash")
+	}
+	if hash == HashAPIKeyHMAC(key, []byte("another-pepper-0123456789abcdef01")) {
+		t.Error("different peppers should produce different hashes")
+	}
+	if len(hash) != 64 {
+		t.Errorf("expected hash length 64, got %d", len(hash))
+	}
+}
+
+func TestKeyMatch_Status(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+
+	tests := []struct {
+		name  string
+		match KeyMatch
+		want  string
+	}{
+		{name: "current key", match: KeyMatch{Org: &Org{Enabled: true}}, want: KeyStatusActive},
+		{name: "previous key in grace period", match: KeyMatch{Org: &Org{Enabled: true}, Previous: true, ExpiresAt: &future}, want: KeyStatusActive},
+		{name: "previous key expired", match: KeyMatch{Org: &Org{Enabled: true}, Previous: true, ExpiresAt: &past}, want: KeyStatusExpired},
+		{name: "disabled org", match: KeyMatch{Org: &Org{Enabled: false}, Previous: true, ExpiresAt: &past}, want: KeyStatusRevoked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.match.Status(now); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
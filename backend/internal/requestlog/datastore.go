@@ -0,0 +1,134 @@
+package requestlog
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Datastore handles persistence operations for request logs.
+// It performs only database operations and returns raw errors.
+type Datastore struct {
+	db *sql.DB
+}
+
+// NewDatastore creates a new request log datastore.
+func NewDatastore(db *sql.DB) *Datastore {
+	return &Datastore{db: db}
+}
+
+const entryColumns = `id, org_id, request_id, route, model, provider, status, latency_ms,
+	prompt_tokens, completion_tokens, error_type, client_ip, created_at`
+
+// listQuery holds resolved List parameters; the manager validates the
+// filter and decodes the cursor before calling the datastore.
+type listQuery struct {
+	orgID       *uuid.UUID
+	statusClass int
+	model       string
+	from        time.Time
+	until       time.Time
+	afterTime   time.Time // Keyset cursor: rows strictly older than (afterTime, afterID)
+	afterID     int64
+	limit       int
+}
+
+// Insert stores an entry.
+func (ds *Datastore) Insert(ctx context.Context, e *Entry) error {
+	query := `
+		INSERT INTO request_logs (org_id, request_id, route, model, provider, status, latency_ms,
+			prompt_tokens, completion_tokens, error_type, client_ip, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+
+	_, err := ds.db.ExecContext(ctx, query,
+		e.OrgID, e.RequestID, e.Route, e.Model, e.Provider, e.Status, e.LatencyMS,
+		e.PromptTokens, e.CompletionTokens, e.ErrorType, e.ClientIP, e.CreatedAt)
+	return err
+}
+
+// List retrieves entries matching q, newest first.
+func (ds *Datastore) List(ctx context.Context, q listQuery) ([]*Entry, error) {
+	var (
+		where []string
+		args  []any
+	)
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if q.orgID != nil {
+		add("org_id = $%d", *q.orgID)
+	}
+	if q.statusClass != 0 {
+		add("status / 100 = $%d", q.statusClass)
+	}
+	if q.model != "" {
+		add("model = $%d", q.model)
+	}
+	if !q.from.IsZero() {
+		add("created_at >= $%d", q.from)
+	}
+	if !q.until.IsZero() {
+		add("created_at < $%d", q.until)
+	}
+	if !q.afterTime.IsZero() {
+		args = append(args, q.afterTime, q.afterID)
+		where = append(where, fmt.Sprintf("(created_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	query := `SELECT ` + entryColumns + ` FROM request_logs`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	args = append(args, q.limit)
+	query += fmt.Sprintf(` ORDER BY created_at DESC, id DESC LIMIT $%d`, len(args))
+
+	rows, err := ds.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var entries []*Entry
+	for rows.Next() {
+		e := &Entry{}
+		var orgID uuid.NullUUID
+		if err := rows.Scan(
+			&e.ID, &orgID, &e.RequestID, &e.Route, &e.Model, &e.Provider, &e.Status, &e.LatencyMS,
+			&e.PromptTokens, &e.CompletionTokens, &e.ErrorType, &e.ClientIP, &e.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if orgID.Valid {
+			e.OrgID = &orgID.UUID
+		}
+		entries = append(entries, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// DeleteBefore removes up to limit entries created before cutoff.
+// Returns rows affected so the caller can loop until a batch comes up short.
+func (ds *Datastore) DeleteBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	query := `
+		DELETE FROM request_logs
+		WHERE id IN (SELECT id FROM request_logs WHERE created_at < $1 LIMIT $2)`
+
+	result, err := ds.db.ExecContext(ctx, query, cutoff, limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
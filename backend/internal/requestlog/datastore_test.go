@@ -0,0 +1,128 @@
+package requestlog
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+// entryRowColumns is the column set returned by request log SELECT queries.
+var entryRowColumns = []string{"id", "org_id", "request_id", "route", "model", "provider", "status", "latency_ms",
+	"prompt_tokens", "completion_tokens", "error_type", "client_ip", "created_at"}
+
+func TestDatastore_Insert(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	ds := NewDatastore(db)
+	orgID := uuid.New()
+	now := time.Now()
+	e := &Entry{
+		OrgID: &orgID, RequestID: "req-1", Route: "POST /v1/chat/completions", Model: "gpt-4o",
+		Provider: "api.openai.com", Status: 200, LatencyMS: 120, PromptTokens: 10, CompletionTokens: 5, ClientIP: "203.0.113.7", CreatedAt: now,
+	}
+
+	mock.ExpectExec(`INSERT INTO request_logs`).
+		WithArgs(&orgID, "req-1", "POST /v1/chat/completions", "gpt-4o", "api.openai.com", 200, int64(120), 10, 5, "", "203.0.113.7", now).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	if err := ds.Insert(context.Background(), e); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDatastore_List(t *testing.T) {
+	orgID := uuid.New()
+	from := time.Date(2026, 3, 1, 14, 0, 0, 0, time.UTC)
+	until := from.Add(time.Hour)
+	cursorTime := from.Add(30 * time.Minute)
+
+	tests := []struct {
+		name      string
+		query     listQuery
+		wantWhere string
+		wantArgs  []driver.Value
+	}{
+		{
+			name:      "no filters",
+			query:     listQuery{limit: 51},
+			wantWhere: `FROM request_logs ORDER BY created_at DESC, id DESC LIMIT \$1`,
+			wantArgs:  []driver.Value{51},
+		},
+		{
+			name: "all filters with cursor",
+			query: listQuery{
+				orgID: &orgID, statusClass: 5, model: "gpt-4o", from: from, until: until,
+				afterTime: cursorTime, afterID: 42, limit: 11,
+			},
+			wantWhere: `WHERE org_id = \$1 AND status / 100 = \$2 AND model = \$3 AND created_at >= \$4 AND created_at < \$5 ` +
+				`AND \(created_at, id\) < \(\$6, \$7\) ORDER BY created_at DESC, id DESC LIMIT \$8`,
+			wantArgs: []driver.Value{orgID, 5, "gpt-4o", from, until, cursorTime, int64(42), 11},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to create mock: %v", err)
+			}
+			defer db.Close()
+
+			now := time.Now()
+			mock.ExpectQuery(tt.wantWhere).
+				WithArgs(tt.wantArgs...).
+				WillReturnRows(sqlmock.NewRows(entryRowColumns).
+					AddRow(int64(1), orgID, "req-1", "POST /v1/chat/completions", "gpt-4o", "api.openai.com", 500, 80, 0, 0, "server_error", "203.0.113.7", now).
+					AddRow(int64(2), nil, "req-2", "POST /v1/chat/completions", "", "", 401, 1, 0, 0, "authentication_error", "198.51.100.2", now))
+
+			entries, err := NewDatastore(db).List(context.Background(), tt.query)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(entries) != 2 {
+				t.Fatalf("expected 2 entries, got %d", len(entries))
+			}
+			if entries[0].OrgID == nil || *entries[0].OrgID != orgID {
+				t.Errorf("expected org ID %s, got %v", orgID, entries[0].OrgID)
+			}
+			if entries[1].OrgID != nil {
+				t.Errorf("expected NULL org ID to scan as nil, got %v", entries[1].OrgID)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestDatastore_DeleteBefore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	cutoff := time.Now().Add(-30 * 24 * time.Hour)
+	mock.ExpectExec(`DELETE FROM request_logs\s+WHERE id IN \(SELECT id FROM request_logs WHERE created_at < \$1 LIMIT \$2\)`).
+		WithArgs(cutoff, 100).
+		WillReturnResult(sqlmock.NewResult(0, 7))
+
+	n, err := NewDatastore(db).DeleteBefore(context.Background(), cutoff, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 7 {
+		t.Errorf("expected 7 rows deleted, got %d", n)
+	}
+}
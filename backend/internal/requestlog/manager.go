@@ -0,0 +1,144 @@
+package requestlog
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+
+	// sweepBatchSize bounds each retention DELETE so a large backlog
+	// doesn't hold one long transaction.
+	sweepBatchSize = 10000
+)
+
+// Domain errors returned by the Manager.
+var (
+	ErrInvalidFilter = errors.New("invalid request log filter")
+	ErrInvalidCursor = errors.New("invalid pagination cursor")
+)
+
+// Manager handles business logic for request logs.
+type Manager struct {
+	ds *Datastore
+}
+
+// NewManager creates a new request log manager.
+func NewManager(ds *Datastore) *Manager {
+	return &Manager{ds: ds}
+}
+
+// List retrieves one page of entries matching f, newest first.
+func (m *Manager) List(ctx context.Context, f Filter) (*Page, error) {
+	if f.StatusClass != 0 && (f.StatusClass < 1 || f.StatusClass > 5) {
+		return nil, fmt.Errorf("%w: status class must be 1xx through 5xx", ErrInvalidFilter)
+	}
+	if !f.From.IsZero() && !f.Until.IsZero() && !f.From.Before(f.Until) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidFilter)
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	limit = min(limit, maxListLimit)
+
+	q := listQuery{
+		orgID:       f.OrgID,
+		statusClass: f.StatusClass,
+		model:       f.Model,
+		from:        f.From,
+		until:       f.Until,
+		limit:       limit + 1, // One extra row tells us whether there is a next page
+	}
+	if f.Cursor != "" {
+		var err error
+		if q.afterTime, q.afterID, err = decodeCursor(f.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	entries, err := m.ds.List(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list request logs: %w", err)
+	}
+
+	page := &Page{Entries: entries}
+	if len(entries) > limit {
+		page.Entries = entries[:limit]
+		last := page.Entries[limit-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+	return page, nil
+}
+
+// Sweep deletes entries older than retention, in batches.
+// Returns the number of rows removed.
+func (m *Manager) Sweep(ctx context.Context, retention time.Duration) (int64, error) {
+	cutoff := time.Now().Add(-retention)
+	var total int64
+	for {
+		n, err := m.ds.DeleteBefore(ctx, cutoff, sweepBatchSize)
+		if err != nil {
+			return total, fmt.Errorf("failed to delete expired request logs: %w", err)
+		}
+		total += n
+		if n < sweepBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// RunRetention sweeps on start and then every interval until ctx is done.
+func (m *Manager) RunRetention(ctx context.Context, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := m.Sweep(ctx, retention)
+		if err != nil {
+			slog.Error("request log retention sweep failed", "error", err)
+		} else if n > 0 {
+			slog.Info("request log retention sweep complete", "deleted", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// encodeCursor builds an opaque keyset cursor from the last row of a page.
+func encodeCursor(createdAt time.Time, id int64) string {
+	raw := strconv.FormatInt(createdAt.UnixNano(), 10) + ":" + strconv.FormatInt(id, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (time.Time, int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	ts, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	rowID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	return time.Unix(0, ts).UTC(), rowID, nil
+}
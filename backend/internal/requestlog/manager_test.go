@@ -0,0 +1,136 @@
+package requestlog
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestManager_List_Validation(t *testing.T) {
+	from := time.Now()
+
+	tests := []struct {
+		name    string
+		filter  Filter
+		wantErr error
+	}{
+		{name: "status class out of range", filter: Filter{StatusClass: 6}, wantErr: ErrInvalidFilter},
+		{name: "from after to", filter: Filter{From: from, Until: from.Add(-time.Minute)}, wantErr: ErrInvalidFilter},
+		{name: "from equals to", filter: Filter{From: from, Until: from}, wantErr: ErrInvalidFilter},
+		{name: "cursor not base64", filter: Filter{Cursor: "!!!"}, wantErr: ErrInvalidCursor},
+		{name: "cursor missing separator", filter: Filter{Cursor: "MTIz"}, wantErr: ErrInvalidCursor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to create mock: %v", err)
+			}
+			defer db.Close()
+
+			_, err = NewManager(NewDatastore(db)).List(context.Background(), tt.filter)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestManager_List_Pagination(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	base := time.Date(2026, 3, 1, 14, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows(entryRowColumns)
+	for i := range 3 {
+		rows.AddRow(int64(10-i), nil, "req", "POST /v1/chat/completions", "", "", 200, 1, 0, 0, "", "", base.Add(-time.Duration(i)*time.Second))
+	}
+	mock.ExpectQuery(`FROM request_logs ORDER BY created_at DESC, id DESC LIMIT \$1`).
+		WithArgs(3).
+		WillReturnRows(rows)
+
+	m := NewManager(NewDatastore(db))
+	page, err := m.List(context.Background(), Filter{Limit: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(page.Entries))
+	}
+	if page.NextCursor == "" {
+		t.Fatal("expected a next cursor when more rows exist")
+	}
+
+	// The cursor resumes after the last returned row.
+	mock.ExpectQuery(`WHERE \(created_at, id\) < \(\$1, \$2\) ORDER BY created_at DESC, id DESC LIMIT \$3`).
+		WithArgs(base.Add(-time.Second), int64(9), 3).
+		WillReturnRows(sqlmock.NewRows(entryRowColumns).
+			AddRow(int64(8), nil, "req", "POST /v1/chat/completions", "", "", 200, 1, 0, 0, "", "", base.Add(-2*time.Second)))
+
+	page, err = m.List(context.Background(), Filter{Limit: 2, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page.Entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(page.Entries))
+	}
+	if page.NextCursor != "" {
+		t.Errorf("expected no next cursor on the last page, got %q", page.NextCursor)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestManager_List_ClampsLimit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`LIMIT \$1`).
+		WithArgs(maxListLimit + 1).
+		WillReturnRows(sqlmock.NewRows(entryRowColumns))
+
+	if _, err := NewManager(NewDatastore(db)).List(context.Background(), Filter{Limit: 10000}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestManager_Sweep(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	// A full batch triggers another pass; a short one ends the sweep.
+	mock.ExpectExec(`DELETE FROM request_logs`).
+		WithArgs(sqlmock.AnyArg(), sweepBatchSize).
+		WillReturnResult(sqlmock.NewResult(0, sweepBatchSize))
+	mock.ExpectExec(`DELETE FROM request_logs`).
+		WithArgs(sqlmock.AnyArg(), sweepBatchSize).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	n, err := NewManager(NewDatastore(db)).Sweep(context.Background(), 24*time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != sweepBatchSize+3 {
+		t.Errorf("expected %d rows deleted, got %d", sweepBatchSize+3, n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+// Package requestlog persists per-request metadata for support lookups
+// ("everything org X sent between 2pm and 3pm"). Only metadata is stored:
+// never request or response bodies, and never keys.
+package requestlog
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrorTypeUpstream marks error responses passed through from the provider,
+// as opposed to errors NavPlane generated (which use the httpjson types).
+const ErrorTypeUpstream = "upstream_error"
+
+// Entry is one persisted proxy request.
+type Entry struct {
+	ID               int64
+	OrgID            *uuid.UUID // Nil when the request failed authentication
+	RequestID        string
+	Route            string
+	Model            string
+	Provider         string
+	Status           int
+	LatencyMS        int64
+	PromptTokens     int
+	CompletionTokens int
+	ErrorType        string // Empty on success
+	ClientIP         string // Resolved through trusted proxies; see middleware.RealIP
+	CreatedAt        time.Time
+}
+
+// Filter narrows a List query. Zero values match everything.
+type Filter struct {
+	OrgID       *uuid.UUID
+	StatusClass int // 2, 4 or 5 for 2xx, 4xx or 5xx; 0 for any
+	Model       string
+	From        time.Time // Inclusive
+	Until       time.Time // Exclusive
+	Cursor      string    // NextCursor from a previous page
+	Limit       int
+}
+
+// Page is one page of entries, newest first.
+type Page struct {
+	Entries    []*Entry
+	NextCursor string // Empty on the last page
+}
+
+type entryKey struct{}
+
+// WithEntry attaches an in-progress entry to ctx so downstream handlers can
+// fill in fields only they know (model, provider, tokens).
+func WithEntry(ctx context.Context, e *Entry) context.Context {
+	return context.WithValue(ctx, entryKey{}, e)
+}
+
+// FromContext returns the in-progress entry, or nil if the request is not
+// being persisted.
+func FromContext(ctx context.Context) *Entry {
+	e, _ := ctx.Value(entryKey{}).(*Entry)
+	return e
+}
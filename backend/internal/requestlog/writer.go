@@ -0,0 +1,100 @@
+package requestlog
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"navplane/internal/metrics"
+)
+
+// insertTimeout bounds each asynchronous insert.
+const insertTimeout = 5 * time.Second
+
+// EntryInserter persists entries. Implemented by *Datastore.
+type EntryInserter interface {
+	Insert(ctx context.Context, e *Entry) error
+}
+
+// Writer persists entries off the request path. Record never blocks: when
+// the buffer is full the entry is dropped and counted, so a slow database
+// can't add latency to proxied requests.
+type Writer struct {
+	store EntryInserter
+	queue chan *Entry
+	wg    sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewWriter creates a writer with a buffer of bufferSize entries and
+// starts its background goroutine. Call Close on shutdown.
+func NewWriter(store EntryInserter, bufferSize int) *Writer {
+	w := &Writer{
+		store: store,
+		queue: make(chan *Entry, bufferSize),
+	}
+	w.wg.Add(1)
+	go w.run()
+	return w
+}
+
+// Record enqueues an entry. It is safe to call on a nil Writer (persistence
+// disabled) and after Close.
+func (w *Writer) Record(e *Entry) {
+	if w == nil {
+		return
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		metrics.RequestLogsDropped.Inc()
+		return
+	}
+	select {
+	case w.queue <- e:
+	default:
+		metrics.RequestLogsDropped.Inc()
+	}
+}
+
+// Close stops accepting entries and waits for buffered ones to be written.
+// Entries still queued when ctx expires are abandoned.
+func (w *Writer) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *Writer) run() {
+	defer w.wg.Done()
+	for e := range w.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), insertTimeout)
+		if err := w.store.Insert(ctx, e); err != nil {
+			metrics.RequestLogsDropped.Inc()
+			slog.Warn("failed to persist request log", "request_id", e.RequestID, "error", err)
+		}
+		cancel()
+	}
+}
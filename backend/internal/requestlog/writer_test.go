@@ -0,0 +1,111 @@
+package requestlog
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"navplane/internal/metrics"
+)
+
+// fakeInserter records inserted entries. When gate is non-nil each Insert
+// waits on it, which lets tests fill the writer's buffer.
+type fakeInserter struct {
+	mu      sync.Mutex
+	entries []*Entry
+	gate    chan struct{}
+	err     error
+}
+
+func (f *fakeInserter) Insert(_ context.Context, e *Entry) error {
+	if f.gate != nil {
+		<-f.gate
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.entries = append(f.entries, e)
+	return f.err
+}
+
+func (f *fakeInserter) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.entries)
+}
+
+func TestWriter_CloseDrainsBuffer(t *testing.T) {
+	store := &fakeInserter{}
+	w := NewWriter(store, 10)
+	for range 5 {
+		w.Record(&Entry{RequestID: "req"})
+	}
+
+	if err := w.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := store.count(); got != 5 {
+		t.Errorf("expected 5 entries persisted, got %d", got)
+	}
+}
+
+func TestWriter_DropsWhenFull(t *testing.T) {
+	store := &fakeInserter{gate: make(chan struct{})}
+	w := NewWriter(store, 1)
+	before := testutil.ToFloat64(metrics.RequestLogsDropped)
+
+	// The first entry is picked up by the (blocked) goroutine, the second
+	// fills the buffer, and the rest are dropped.
+	w.Record(&Entry{RequestID: "1"})
+	deadline := time.Now().Add(time.Second)
+	for len(w.queue) != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	w.Record(&Entry{RequestID: "2"})
+	w.Record(&Entry{RequestID: "3"})
+	w.Record(&Entry{RequestID: "4"})
+
+	if got := testutil.ToFloat64(metrics.RequestLogsDropped) - before; got != 2 {
+		t.Errorf("expected 2 dropped entries, got %v", got)
+	}
+
+	close(store.gate)
+	if err := w.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := store.count(); got != 2 {
+		t.Errorf("expected 2 entries persisted, got %d", got)
+	}
+}
+
+func TestWriter_InsertFailureCountsAsDrop(t *testing.T) {
+	store := &fakeInserter{err: errors.New("connection refused")}
+	w := NewWriter(store, 1)
+	before := testutil.ToFloat64(metrics.RequestLogsDropped)
+
+	w.Record(&Entry{RequestID: "req"})
+	if err := w.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := testutil.ToFloat64(metrics.RequestLogsDropped) - before; got != 1 {
+		t.Errorf("expected 1 dropped entry, got %v", got)
+	}
+}
+
+func TestWriter_NilAndClosed(t *testing.T) {
+	var nilWriter *Writer
+	nilWriter.Record(&Entry{}) // Must not panic
+
+	store := &fakeInserter{}
+	w := NewWriter(store, 1)
+	if err := w.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w.Record(&Entry{}) // Must not panic on a closed channel
+	if got := store.count(); got != 0 {
+		t.Errorf("expected no entries after close, got %d", got)
+	}
+}
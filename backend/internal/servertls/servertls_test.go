@@ -0,0 +1,193 @@
+package servertls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"navplane/internal/config"
+)
+
+// writeKeyPair writes a self-signed certificate with the given serial
+// number and returns the cert and key paths.
+func writeKeyPair(t *testing.T, dir string, serial int64) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	certPath := filepath.Join(dir, "tls.crt")
+	keyPath := filepath.Join(dir, "tls.key")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("failed to write cert: %v", err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	return certPath, keyPath
+}
+
+func servedSerial(t *testing.T, r *CertReloader) int64 {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("GetCertificate failed: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("failed to parse served certificate: %v", err)
+	}
+	return leaf.SerialNumber.Int64()
+}
+
+func TestCertReloader_Reload(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := writeKeyPair(t, dir, 1)
+
+	r, err := NewCertReloader(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := servedSerial(t, r); got != 1 {
+		t.Fatalf("expected serial 1, got %d", got)
+	}
+
+	writeKeyPair(t, dir, 2)
+	if err := r.Reload(); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if got := servedSerial(t, r); got != 2 {
+		t.Errorf("expected reloaded serial 2, got %d", got)
+	}
+
+	// A broken file on disk keeps the last good certificate in service
+	if err := os.WriteFile(keyPath, []byte("not a key"), 0o600); err != nil {
+		t.Fatalf("failed to corrupt key: %v", err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Error("expected reload of a corrupt key to fail")
+	}
+	if got := servedSerial(t, r); got != 2 {
+		t.Errorf("expected previous certificate to stay in use, got serial %d", got)
+	}
+}
+
+func TestNewCertReloader_Missing(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewCertReloader(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")); err == nil {
+		t.Error("expected error for missing key pair")
+	}
+}
+
+func TestNewConfig(t *testing.T) {
+	certPath, keyPath := writeKeyPair(t, t.TempDir(), 1)
+	reloader, err := NewCertReloader(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Run("server only", func(t *testing.T) {
+		cfg, err := NewConfig(config.TLSConfig{CertFile: certPath, KeyFile: keyPath}, reloader)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.MinVersion != tls.VersionTLS12 {
+			t.Errorf("expected TLS 1.2 minimum, got %x", cfg.MinVersion)
+		}
+		if cfg.ClientAuth != tls.NoClientCert {
+			t.Errorf("expected no client auth, got %v", cfg.ClientAuth)
+		}
+	})
+
+	t.Run("client CA", func(t *testing.T) {
+		cfg, err := NewConfig(config.TLSConfig{CertFile: certPath, KeyFile: keyPath, ClientCAFile: certPath}, reloader)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.ClientAuth != tls.VerifyClientCertIfGiven || cfg.ClientCAs == nil {
+			t.Errorf("expected optional client cert verification, got %v", cfg.ClientAuth)
+		}
+	})
+
+	t.Run("invalid client CA", func(t *testing.T) {
+		if _, err := NewConfig(config.TLSConfig{ClientCAFile: keyPath}, reloader); err == nil {
+			t.Error("expected error for a CA file without certificates")
+		}
+	})
+}
+
+func TestNewConfig_ServesReloadedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := writeKeyPair(t, dir, 1)
+	reloader, err := NewCertReloader(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tlsConfig, err := NewConfig(config.TLSConfig{CertFile: certPath, KeyFile: keyPath}, reloader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// httptest.Server installs its own certificate, so serve on a plain TLS listener
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	srv := &http.Server{Handler: http.NotFoundHandler(), ErrorLog: log.New(io.Discard, "", 0)}
+	go func() { _ = srv.Serve(ln) }()
+	defer srv.Close()
+
+	handshakeSerial := func() int64 {
+		t.Helper()
+		conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatalf("handshake failed: %v", err)
+		}
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+	}
+
+	if got := handshakeSerial(); got != 1 {
+		t.Fatalf("expected serial 1, got %d", got)
+	}
+	writeKeyPair(t, dir, 2)
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if got := handshakeSerial(); got != 2 {
+		t.Errorf("expected new connections to get serial 2, got %d", got)
+	}
+}
@@ -0,0 +1,97 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Datastore handles persistence operations for webhooks.
+// It performs only database operations and returns raw errors.
+type Datastore struct {
+	db *sql.DB
+}
+
+// NewDatastore creates a new webhook datastore.
+func NewDatastore(db *sql.DB) *Datastore {
+	return &Datastore{db: db}
+}
+
+const webhookColumns = `id, url, secret, events, enabled, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanWebhook(row rowScanner) (*Webhook, error) {
+	w := &Webhook{}
+	if err := row.Scan(
+		&w.ID, &w.URL, &w.Secret, pq.Array(&w.Events), &w.Enabled, &w.CreatedAt, &w.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Create inserts a new webhook.
+// Returns the created webhook or raw database error.
+func (ds *Datastore) Create(ctx context.Context, url, secret string, events []string) (*Webhook, error) {
+	query := `
+		INSERT INTO webhooks (id, url, secret, events, enabled)
+		VALUES ($1, $2, $3, $4, true)
+		RETURNING ` + webhookColumns
+
+	return scanWebhook(ds.db.QueryRowContext(ctx, query, uuid.New(), url, secret, pq.Array(events)))
+}
+
+// List retrieves all webhooks, newest first.
+func (ds *Datastore) List(ctx context.Context) ([]*Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks ORDER BY created_at DESC`
+	return ds.query(ctx, query)
+}
+
+// ListByEvent retrieves enabled webhooks subscribed to eventType.
+func (ds *Datastore) ListByEvent(ctx context.Context, eventType string) ([]*Webhook, error) {
+	query := `
+		SELECT ` + webhookColumns + `
+		FROM webhooks
+		WHERE enabled = true AND $1 = ANY(events)`
+	return ds.query(ctx, query, eventType)
+}
+
+// Delete removes a webhook.
+// Returns rows affected count for caller to interpret.
+func (ds *Datastore) Delete(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := ds.db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (ds *Datastore) query(ctx context.Context, query string, args ...any) ([]*Webhook, error) {
+	rows, err := ds.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var webhooks []*Webhook
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
@@ -0,0 +1,98 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+// webhookRowColumns is the column set returned by webhook SELECT queries.
+var webhookRowColumns = []string{"id", "url", "secret", "events", "enabled", "created_at", "updated_at"}
+
+func TestDatastore_Create(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	ds := NewDatastore(db)
+	now := time.Now()
+	id := uuid.New()
+
+	mock.ExpectQuery(`INSERT INTO webhooks`).
+		WithArgs(sqlmock.AnyArg(), "https://example.com/hook", "whsec_abc", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(webhookRowColumns).
+			AddRow(id, "https://example.com/hook", "whsec_abc", "{org.created,org.deleted}", true, now, now))
+
+	w, err := ds.Create(context.Background(), "https://example.com/hook", "whsec_abc", []string{"org.created", "org.deleted"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if w.ID != id {
+		t.Errorf("expected ID %s, got %s", id, w.ID)
+	}
+	if len(w.Events) != 2 || w.Events[0] != "org.created" || w.Events[1] != "org.deleted" {
+		t.Errorf("expected scanned events, got %v", w.Events)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDatastore_ListByEvent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	ds := NewDatastore(db)
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT .+ FROM webhooks WHERE enabled = true AND \$1 = ANY\(events\)`).
+		WithArgs("org.disabled").
+		WillReturnRows(sqlmock.NewRows(webhookRowColumns).
+			AddRow(uuid.New(), "https://a.example.com", "s1", "{org.disabled}", true, now, now).
+			AddRow(uuid.New(), "https://b.example.com", "s2", "{org.created,org.disabled}", true, now, now))
+
+	webhooks, err := ds.ListByEvent(context.Background(), "org.disabled")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(webhooks) != 2 {
+		t.Errorf("expected 2 webhooks, got %d", len(webhooks))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDatastore_Delete(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	ds := NewDatastore(db)
+	id := uuid.New()
+
+	mock.ExpectExec(`DELETE FROM webhooks WHERE id = \$1`).
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rows, err := ds.Delete(context.Background(), id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rows != 1 {
+		t.Errorf("expected 1 row affected, got %d", rows)
+	}
+}
@@ -0,0 +1,317 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SubscriberLister resolves the webhooks subscribed to an event type.
+// Implemented by *Manager.
+type SubscriberLister interface {
+	ListSubscribers(ctx context.Context, eventType string) ([]*Webhook, error)
+}
+
+// DispatcherConfig controls delivery behaviour.
+type DispatcherConfig struct {
+	Workers       int           // Concurrent delivery workers
+	QueueSize     int           // Buffered events before Notify starts dropping
+	MaxAttempts   int           // Delivery attempts per destination, including the first
+	BaseBackoff   time.Duration // Delay before the first retry; doubled each attempt
+	MaxBackoff    time.Duration // Upper bound on the retry delay
+	Timeout       time.Duration // Per-attempt HTTP timeout
+	LookupTimeout time.Duration // Timeout for resolving subscribers
+}
+
+// DefaultDispatcherConfig returns production defaults.
+func DefaultDispatcherConfig() DispatcherConfig {
+	return DispatcherConfig{
+		Workers:       4,
+		QueueSize:     1000,
+		MaxAttempts:   5,
+		BaseBackoff:   time.Second,
+		MaxBackoff:    time.Minute,
+		Timeout:       10 * time.Second,
+		LookupTimeout: 5 * time.Second,
+	}
+}
+
+// Dispatcher delivers events to subscribed webhooks asynchronously.
+// Notify never blocks the caller; events are dropped (and logged) when the
+// queue is full. Each event is fanned out into one delivery job per
+// subscriber, and workers make a single attempt per job: a retryable
+// failure waits out its backoff off the workers and is then queued again,
+// so a slow or failing endpoint never delays other webhooks' deliveries.
+type Dispatcher struct {
+	subscribers SubscriberLister
+	client      *http.Client
+	cfg         DispatcherConfig
+
+	queue      chan Event
+	deliveries chan delivery
+	ctx        context.Context // Parent of every lookup and send; cancelled when Close gives up
+	cancel     context.CancelFunc
+	fanOutDone chan struct{}
+	workers    sync.WaitGroup
+	pending    sync.WaitGroup // Deliveries queued, in flight or waiting to retry
+	mu         sync.RWMutex
+	closed     bool
+	started    bool
+}
+
+// delivery is one event bound for one webhook.
+type delivery struct {
+	webhook *Webhook
+	event   Event
+	body    []byte
+	attempt int // 1-based attempt the next send makes
+}
+
+// NewDispatcher creates a dispatcher. Call Start before publishing events.
+func NewDispatcher(subscribers SubscriberLister, client *http.Client, cfg DispatcherConfig) *Dispatcher {
+	if client == nil {
+		client = &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		subscribers: subscribers,
+		client:      client,
+		cfg:         cfg,
+		queue:       make(chan Event, cfg.QueueSize),
+		deliveries:  make(chan delivery, cfg.QueueSize),
+		ctx:         ctx,
+		cancel:      cancel,
+		fanOutDone:  make(chan struct{}),
+	}
+}
+
+// Start launches the fan-out goroutine and the delivery workers.
+func (d *Dispatcher) Start() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.started {
+		return
+	}
+	d.started = true
+	go d.fanOut()
+	for range max(d.cfg.Workers, 1) {
+		d.workers.Add(1)
+		go d.worker()
+	}
+}
+
+// Notify enqueues an event for delivery. It implements org.Notifier.
+func (d *Dispatcher) Notify(eventType string, data any) {
+	event := Event{
+		ID:        uuid.NewString(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		slog.Warn("webhook dispatcher closed, dropping event", "event_id", event.ID, "event_type", eventType)
+		return
+	}
+	select {
+	case d.queue <- event:
+	default:
+		slog.Warn("webhook queue full, dropping event", "event_id", event.ID, "event_type", eventType)
+	}
+}
+
+// Close stops accepting events and waits for queued deliveries to finish.
+// When ctx expires, in-flight sends are cancelled and every queued event,
+// delivery and pending retry is dropped, so Close returns promptly.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closed = true
+	close(d.queue)
+	started := d.started
+	d.mu.Unlock()
+	if !started {
+		d.cancel()
+		return nil
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		// Every delivery is counted in pending before fanOut returns, and
+		// retries are sent on d.deliveries only while still counted, so it
+		// is safe to close once pending drains
+		<-d.fanOutDone
+		d.pending.Wait()
+		close(d.deliveries)
+		d.workers.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-finished
+		return ctx.Err()
+	}
+}
+
+// fanOut turns each queued event into one delivery per subscriber.
+func (d *Dispatcher) fanOut() {
+	defer close(d.fanOutDone)
+	for event := range d.queue {
+		d.dispatch(event)
+	}
+}
+
+// dispatch resolves subscribers and queues a delivery for each of them.
+func (d *Dispatcher) dispatch(event Event) {
+	if d.ctx.Err() != nil {
+		slog.Warn("webhook event dropped on shutdown", "event_id", event.ID, "event_type", event.Type)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(d.ctx, d.cfg.LookupTimeout)
+	webhooks, err := d.subscribers.ListSubscribers(ctx, event.Type)
+	cancel()
+	if err != nil {
+		slog.Error("failed to resolve webhook subscribers", "event_type", event.Type, "error", err)
+		return
+	}
+
+	var body []byte
+	for _, w := range webhooks {
+		if !w.Subscribes(event.Type) {
+			continue
+		}
+		if body == nil {
+			if body, err = json.Marshal(event); err != nil {
+				slog.Error("failed to encode webhook event", "event_id", event.ID, "error", err)
+				return
+			}
+		}
+		d.pending.Add(1)
+		select {
+		case d.deliveries <- delivery{webhook: w, event: event, body: body, attempt: 1}:
+		case <-d.ctx.Done():
+			d.abandon(delivery{webhook: w, event: event})
+		}
+	}
+}
+
+func (d *Dispatcher) worker() {
+	defer d.workers.Done()
+	for job := range d.deliveries {
+		d.deliver(job)
+	}
+}
+
+// deliver makes one attempt at job, scheduling a retry for transient
+// failures.
+func (d *Dispatcher) deliver(job delivery) {
+	if d.ctx.Err() != nil {
+		d.abandon(job)
+		return
+	}
+
+	retryable, err := d.send(job.webhook, job.event, job.body, job.attempt)
+	if err == nil {
+		d.pending.Done()
+		return
+	}
+	if !retryable || job.attempt >= d.cfg.MaxAttempts {
+		slog.Warn("webhook delivery failed", "webhook_id", job.webhook.ID, "event_id", job.event.ID, "attempt", job.attempt, "error", err)
+		d.pending.Done()
+		return
+	}
+
+	delay := d.backoff(job.attempt)
+	job.attempt++
+	go d.retry(job, delay)
+}
+
+// retry queues job again after delay, unless shutdown abandons it first.
+func (d *Dispatcher) retry(job delivery, delay time.Duration) {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		select {
+		case d.deliveries <- job:
+			return
+		case <-d.ctx.Done():
+		}
+	case <-d.ctx.Done():
+	}
+	d.abandon(job)
+}
+
+// abandon drops a pending delivery once Close has given up waiting.
+func (d *Dispatcher) abandon(job delivery) {
+	slog.Warn("webhook delivery abandoned on shutdown", "webhook_id", job.webhook.ID, "event_id", job.event.ID)
+	d.pending.Done()
+}
+
+// send performs one delivery attempt. The returned bool reports whether a
+// failure is worth retrying (network errors, 429, and 5xx responses).
+func (d *Dispatcher) send(w *Webhook, event Event, body []byte, attempt int) (bool, error) {
+	ctx, cancel := context.WithTimeout(d.ctx, d.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "NavPlane-Webhook/1.0")
+	req.Header.Set("X-NavPlane-Event", event.Type)
+	req.Header.Set("X-NavPlane-Delivery", event.ID)
+	req.Header.Set("X-NavPlane-Attempt", fmt.Sprint(attempt))
+	req.Header.Set(SignatureHeader, Sign(w.Secret, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+		if err := resp.Body.Close(); err != nil {
+			slog.Error("failed to close webhook response body", "error", err)
+		}
+	}()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retryable, fmt.Errorf("unexpected status %d", resp.StatusCode)
+}
+
+// backoff returns the delay before retry number attempt (1-based).
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.cfg.BaseBackoff << (attempt - 1)
+	if delay <= 0 || delay > d.cfg.MaxBackoff {
+		return d.cfg.MaxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,240 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// staticLister returns every webhook for every event, leaving the
+// subscription check to the dispatcher.
+type staticLister struct {
+	webhooks []*Webhook
+}
+
+func (l *staticLister) ListSubscribers(ctx context.Context, eventType string) ([]*Webhook, error) {
+	return l.webhooks, nil
+}
+
+func testDispatcherConfig() DispatcherConfig {
+	return DispatcherConfig{
+		Workers:       1,
+		QueueSize:     10,
+		MaxAttempts:   3,
+		BaseBackoff:   time.Millisecond,
+		MaxBackoff:    5 * time.Millisecond,
+		Timeout:       time.Second,
+		LookupTimeout: time.Second,
+	}
+}
+
+func TestDispatcher_DeliversSignedEvent(t *testing.T) {
+	const secret = "whsec_test"
+
+	var (
+		mu       sync.Mutex
+		received []Event
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if got := r.Header.Get(SignatureHeader); got != Sign(secret, body) {
+			t.Errorf("signature mismatch: got %q", got)
+		}
+		if got := r.Header.Get("X-NavPlane-Event"); got != "org.created" {
+			t.Errorf("expected event header org.created, got %q", got)
+		}
+
+		var event Event
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Errorf("invalid payload: %v", err)
+		}
+		mu.Lock()
+		received = append(received, event)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	lister := &staticLister{webhooks: []*Webhook{
+		{ID: uuid.New(), URL: srv.URL, Secret: secret, Events: []string{"org.created"}, Enabled: true},
+		{ID: uuid.New(), URL: srv.URL, Secret: "whsec_other", Events: []string{"org.created"}, Enabled: false},
+	}}
+	d := NewDispatcher(lister, srv.Client(), testDispatcherConfig())
+	d.Start()
+
+	d.Notify("org.created", map[string]string{"org_id": "abc"})
+	d.Notify("org.deleted", map[string]string{"org_id": "abc"}) // not subscribed
+
+	if err := d.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 1 {
+		t.Fatalf("expected 1 delivery, got %d", len(received))
+	}
+	if received[0].Type != "org.created" || received[0].ID == "" {
+		t.Errorf("unexpected event envelope: %+v", received[0])
+	}
+}
+
+func TestDispatcher_Retries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantAttempts int32
+	}{
+		{"success first try", []int{http.StatusOK}, 1},
+		{"retries server errors", []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK}, 3},
+		{"retries rate limit", []int{http.StatusTooManyRequests, http.StatusOK}, 2},
+		{"gives up after max attempts", []int{500, 500, 500, 500}, 3},
+		{"no retry on client error", []int{http.StatusBadRequest, http.StatusOK}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := attempts.Add(1)
+				w.WriteHeader(tt.statuses[n-1])
+			}))
+			defer srv.Close()
+
+			lister := &staticLister{webhooks: []*Webhook{
+				{ID: uuid.New(), URL: srv.URL, Secret: "s", Events: []string{"org.enabled"}, Enabled: true},
+			}}
+			d := NewDispatcher(lister, srv.Client(), testDispatcherConfig())
+			d.Start()
+			d.Notify("org.enabled", nil)
+
+			if err := d.Close(context.Background()); err != nil {
+				t.Fatalf("unexpected close error: %v", err)
+			}
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("expected %d attempts, got %d", tt.wantAttempts, got)
+			}
+		})
+	}
+}
+
+func TestDispatcher_RetriesDoNotBlockOtherWebhooks(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	delivered := make(chan struct{}, 10)
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered <- struct{}{}
+	}))
+	defer healthy.Close()
+
+	events := []string{"org.created"}
+	lister := &staticLister{webhooks: []*Webhook{
+		{ID: uuid.New(), URL: failing.URL, Secret: "s", Events: events, Enabled: true},
+		{ID: uuid.New(), URL: healthy.URL, Secret: "s", Events: events, Enabled: true},
+	}}
+	cfg := testDispatcherConfig()
+	cfg.BaseBackoff = time.Minute
+	cfg.MaxBackoff = time.Minute
+	d := NewDispatcher(lister, nil, cfg)
+	d.Start()
+
+	d.Notify("org.created", nil)
+	d.Notify("org.created", nil)
+
+	// One worker, and the failing hook is first in line for both events:
+	// the healthy hook still gets both without waiting out a retry
+	for i := range 2 {
+		select {
+		case <-delivered:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("delivery %d to the healthy webhook was held up by retries", i+1)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := d.Close(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected pending retries to be abandoned at the deadline, got %v", err)
+	}
+}
+
+func TestDispatcher_CloseHonoursDeadline(t *testing.T) {
+	var attempts atomic.Int32
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		<-release // Hang well past the Close deadline
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	lister := &staticLister{webhooks: []*Webhook{
+		{ID: uuid.New(), URL: slow.URL, Secret: "s", Events: []string{"org.created"}, Enabled: true},
+	}}
+	cfg := testDispatcherConfig()
+	cfg.Timeout = 10 * time.Second
+	d := NewDispatcher(lister, nil, cfg)
+	d.Start()
+
+	for range cfg.QueueSize {
+		d.Notify("org.created", nil)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := d.Close(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Close took %v, past its 100ms deadline", elapsed)
+	}
+	if got := attempts.Load(); got > 1 {
+		t.Errorf("expected the backlog to be dropped, got %d attempts", got)
+	}
+}
+
+func TestDispatcher_NotifyAfterCloseIsDropped(t *testing.T) {
+	d := NewDispatcher(&staticLister{}, nil, testDispatcherConfig())
+	d.Start()
+	if err := d.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+
+	// Must not panic on the closed queue
+	d.Notify("org.created", nil)
+}
+
+func TestDispatcher_Backoff(t *testing.T) {
+	d := NewDispatcher(&staticLister{}, nil, DispatcherConfig{
+		BaseBackoff: time.Second,
+		MaxBackoff:  5 * time.Second,
+	})
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 5 * time.Second},
+		{80, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := d.backoff(tt.attempt); got != tt.want {
+			t.Errorf("backoff(%d): expected %v, got %v", tt.attempt, tt.want, got)
+		}
+	}
+}
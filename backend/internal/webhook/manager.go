@@ -0,0 +1,113 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Domain errors returned by the Manager.
+var (
+	ErrNotFound     = errors.New("webhook not found")
+	ErrInvalidURL   = errors.New("webhook URL must be an absolute http or https URL")
+	ErrInvalidEvent = errors.New("unknown webhook event type")
+	ErrNoEvents     = errors.New("at least one event type is required")
+)
+
+// Manager handles business logic for webhooks.
+type Manager struct {
+	ds *Datastore
+}
+
+// NewManager creates a new webhook manager.
+func NewManager(ds *Datastore) *Manager {
+	return &Manager{ds: ds}
+}
+
+// Create registers a new webhook destination with a generated signing secret.
+// The secret is only returned here; list and get responses never include it.
+func (m *Manager) Create(ctx context.Context, rawURL string, events []string) (*Webhook, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if err := validateURL(rawURL); err != nil {
+		return nil, err
+	}
+
+	events = normalizeEvents(events)
+	if len(events) == 0 {
+		return nil, ErrNoEvents
+	}
+	for _, e := range events {
+		if !slices.Contains(KnownEvents, e) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidEvent, e)
+		}
+	}
+
+	secret, err := GenerateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+
+	w, err := m.ds.Create(ctx, rawURL, secret, events)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return w, nil
+}
+
+// List retrieves all registered webhooks.
+func (m *Manager) List(ctx context.Context) ([]*Webhook, error) {
+	webhooks, err := m.ds.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	return webhooks, nil
+}
+
+// ListSubscribers retrieves enabled webhooks subscribed to eventType.
+func (m *Manager) ListSubscribers(ctx context.Context, eventType string) ([]*Webhook, error) {
+	webhooks, err := m.ds.ListByEvent(ctx, eventType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook subscribers: %w", err)
+	}
+	return webhooks, nil
+}
+
+// Delete removes a webhook.
+func (m *Manager) Delete(ctx context.Context, id uuid.UUID) error {
+	rowsAffected, err := m.ds.Delete(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func validateURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ErrInvalidURL
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
+// normalizeEvents trims, drops empties, and de-duplicates event names.
+func normalizeEvents(events []string) []string {
+	var out []string
+	for _, e := range events {
+		e = strings.TrimSpace(e)
+		if e != "" && !slices.Contains(out, e) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
@@ -0,0 +1,97 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+func TestManager_Create_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		events  []string
+		wantErr error
+	}{
+		{"empty URL", "", []string{"org.created"}, ErrInvalidURL},
+		{"relative URL", "/hook", []string{"org.created"}, ErrInvalidURL},
+		{"unsupported scheme", "ftp://example.com/hook", []string{"org.created"}, ErrInvalidURL},
+		{"no events", "https://example.com/hook", nil, ErrNoEvents},
+		{"blank events", "https://example.com/hook", []string{" ", ""}, ErrNoEvents},
+		{"unknown event", "https://example.com/hook", []string{"org.renamed"}, ErrInvalidEvent},
+	}
+
+	m := &Manager{ds: nil} // validation fails before the datastore is used
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := m.Create(context.Background(), tt.url, tt.events)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestManager_Create_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	m := NewManager(NewDatastore(db))
+	now := time.Now()
+
+	mock.ExpectQuery(`INSERT INTO webhooks`).
+		WithArgs(sqlmock.AnyArg(), "https://example.com/hook", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(webhookRowColumns).
+			AddRow(uuid.New(), "https://example.com/hook", "whsec_generated", "{org.created}", true, now, now))
+
+	w, err := m.Create(context.Background(), "  https://example.com/hook ", []string{"org.created", "org.created"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w.Secret == "" {
+		t.Error("expected secret to be returned on creation")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestManager_Delete_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	m := NewManager(NewDatastore(db))
+	id := uuid.New()
+
+	mock.ExpectExec(`DELETE FROM webhooks`).
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := m.Delete(context.Background(), id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestNormalizeEvents(t *testing.T) {
+	got := normalizeEvents([]string{" org.created ", "", "org.deleted", "org.created"})
+	want := []string{"org.created", "org.deleted"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("index %d: expected %q, got %q", i, want[i], got[i])
+		}
+	}
+}
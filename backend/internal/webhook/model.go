@@ -0,0 +1,97 @@
+// Package webhook delivers signed event notifications to external
+// destinations (billing, alerting) registered by admins.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"navplane/internal/logging"
+	"navplane/internal/org"
+)
+
+// SignatureHeader carries the HMAC-SHA256 signature of the request body.
+const SignatureHeader = "X-NavPlane-Signature"
+
+// KnownEvents lists the event types a webhook may subscribe to.
+var KnownEvents = []string{
+	org.EventCreated,
+	org.EventEnabled,
+	org.EventDisabled,
+	org.EventDeleted,
+}
+
+// Webhook is a registered delivery destination.
+type Webhook struct {
+	ID        uuid.UUID
+	URL       string
+	Secret    string // Shared secret used to sign payloads
+	Events    []string
+	Enabled   bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// String masks the signing secret so webhooks can be printed with %v or %+v.
+// Only the URL host is shown: hook URLs often carry tokens in the path.
+func (w *Webhook) String() string {
+	return fmt.Sprintf("{ID:%s Host:%s Secret:%s Events:%v Enabled:%t}",
+		w.ID, w.host(), logging.Redacted, w.Events, w.Enabled)
+}
+
+// LogValue keeps the secret and URL path out of structured logs.
+func (w *Webhook) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("id", w.ID.String()),
+		slog.String("host", w.host()),
+		slog.Any("events", w.Events),
+		slog.Bool("enabled", w.Enabled),
+	)
+}
+
+func (w *Webhook) host() string {
+	parsed, err := url.Parse(w.URL)
+	if err != nil {
+		return logging.Redacted
+	}
+	return parsed.Host
+}
+
+// Subscribes reports whether the webhook should receive the given event type.
+func (w *Webhook) Subscribes(eventType string) bool {
+	return w.Enabled && slices.Contains(w.Events, eventType)
+}
+
+// Event is the JSON envelope POSTed to webhook destinations.
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// GenerateSecret creates a random signing secret with a "whsec_" prefix.
+func GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign returns the signature header value for body: "sha256=<hex hmac>".
+// Receivers recompute the HMAC over the raw request body and compare.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,88 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"type":"org.created"}`)
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write(body)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if got := Sign("whsec_test", body); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if Sign("other", body) == want {
+		t.Error("expected different secrets to produce different signatures")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.HasPrefix(a, "whsec_") {
+		t.Errorf("expected whsec_ prefix, got %q", a)
+	}
+	if len(a) != len("whsec_")+64 {
+		t.Errorf("expected 70 character secret, got %d", len(a))
+	}
+	if a == b {
+		t.Error("expected unique secrets")
+	}
+}
+
+func TestWebhook_Subscribes(t *testing.T) {
+	tests := []struct {
+		name    string
+		webhook Webhook
+		event   string
+		want    bool
+	}{
+		{"subscribed", Webhook{Enabled: true, Events: []string{"org.created"}}, "org.created", true},
+		{"not subscribed", Webhook{Enabled: true, Events: []string{"org.created"}}, "org.deleted", false},
+		{"disabled", Webhook{Enabled: false, Events: []string{"org.created"}}, "org.created", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.webhook.Subscribes(tt.event); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWebhook_StringRedactsSecret(t *testing.T) {
+	w := &Webhook{
+		URL:    "https://hooks.example.com/services/T000/B000/tok123",
+		Secret: "whsec_test",
+		Events: []string{"org.created"},
+	}
+
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("webhook", "webhook", w)
+
+	for _, out := range []string{fmt.Sprintf("%+v", w), fmt.Sprint(w), buf.String()} {
+		if strings.Contains(out, "whsec_test") || strings.Contains(out, "tok123") {
+			t.Errorf("output leaked secret material: %s", out)
+		}
+		if !strings.Contains(out, "hooks.example.com") {
+			t.Errorf("output dropped the destination host: %s", out)
+		}
+	}
+}
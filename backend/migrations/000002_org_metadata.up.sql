@@ -0,0 +1,2 @@
+-- Free-form key/value labels on organizations, editable via PATCH /admin/orgs/{id}
+ALTER TABLE organizations ADD COLUMN metadata JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_organizations_name_lower;
@@ -0,0 +1,14 @@
+-- Enforce case-insensitive unique organization names.
+-- Existing duplicates keep the oldest row's name; later rows get a short
+-- ID suffix so the index can be created safely.
+WITH ranked AS (
+    SELECT id,
+           ROW_NUMBER() OVER (PARTITION BY LOWER(name) ORDER BY created_at, id) AS rn
+    FROM organizations
+)
+UPDATE organizations o
+SET name = LEFT(o.name, 244) || ' (' || LEFT(o.id::text, 8) || ')'
+FROM ranked r
+WHERE o.id = r.id AND r.rn > 1;
+
+CREATE UNIQUE INDEX idx_organizations_name_lower ON organizations (LOWER(name));
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS trg_webhooks_updated_at ON webhooks;
+DROP TABLE IF EXISTS webhooks;
@@ -0,0 +1,23 @@
+-- Restore the request_logs table as 000001 created it.
+DROP TABLE IF EXISTS request_logs;
+
+CREATE TABLE request_logs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    request_id VARCHAR(100),
+    provider VARCHAR(50) NOT NULL,
+    model VARCHAR(100),
+    endpoint VARCHAR(255) NOT NULL,
+    method VARCHAR(10) NOT NULL,
+    status_code INTEGER NOT NULL,
+    latency_ms INTEGER NOT NULL,
+    prompt_tokens INTEGER,
+    completion_tokens INTEGER,
+    total_tokens INTEGER,
+    error_message TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_request_logs_org_id ON request_logs(org_id);
+CREATE INDEX idx_request_logs_created_at ON request_logs(created_at DESC);
+CREATE INDEX idx_request_logs_org_created ON request_logs(org_id, created_at DESC);
@@ -0,0 +1,25 @@
+-- Reshape the request_logs table from 000001 for support lookups. Nothing
+-- ever wrote the original shape, and its UUID id can't become the BIGSERIAL
+-- keyset cursor in place, so it is dropped and recreated.
+DROP TABLE request_logs;
+
+-- Request metadata for support lookups. Never stores bodies or keys.
+-- org_id has no foreign key: entries outlive deleted orgs until retention
+-- removes them, and async inserts must not fail on a concurrent delete.
+CREATE TABLE request_logs (
+    id BIGSERIAL PRIMARY KEY,
+    org_id UUID,
+    request_id VARCHAR(128) NOT NULL DEFAULT '',
+    route TEXT NOT NULL,
+    model TEXT NOT NULL DEFAULT '',
+    provider TEXT NOT NULL DEFAULT '',
+    status INTEGER NOT NULL,
+    latency_ms INTEGER NOT NULL,
+    prompt_tokens INTEGER NOT NULL DEFAULT 0,
+    completion_tokens INTEGER NOT NULL DEFAULT 0,
+    error_type TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_request_logs_created_at ON request_logs (created_at DESC, id DESC);
+CREATE INDEX idx_request_logs_org_created_at ON request_logs (org_id, created_at DESC, id DESC);
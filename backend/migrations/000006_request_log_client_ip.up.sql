@@ -0,0 +1 @@
+ALTER TABLE request_logs ADD COLUMN client_ip TEXT NOT NULL DEFAULT '';
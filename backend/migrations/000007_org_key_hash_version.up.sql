@@ -0,0 +1,3 @@
+-- Scheme used for api_key_hash: 1 = bare SHA-256, 2 = HMAC-SHA256 with API_KEY_PEPPER.
+-- Version 1 keys are rehashed to 2 on their next successful authentication.
+ALTER TABLE organizations ADD COLUMN key_hash_version SMALLINT NOT NULL DEFAULT 1;
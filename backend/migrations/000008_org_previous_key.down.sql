@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_organizations_previous_key_hash;
+ALTER TABLE organizations
+    DROP COLUMN IF EXISTS previous_key_hash,
+    DROP COLUMN IF EXISTS previous_key_hash_version,
+    DROP COLUMN IF EXISTS previous_key_expires_at;
@@ -0,0 +1,9 @@
+-- The key replaced by the last rotation. It keeps authenticating until
+-- previous_key_expires_at, then is rejected as rotated until swept.
+ALTER TABLE organizations
+    ADD COLUMN previous_key_hash VARCHAR(64),
+    ADD COLUMN previous_key_hash_version SMALLINT,
+    ADD COLUMN previous_key_expires_at TIMESTAMPTZ;
+
+CREATE INDEX idx_organizations_previous_key_hash ON organizations(previous_key_hash)
+    WHERE previous_key_hash IS NOT NULL;
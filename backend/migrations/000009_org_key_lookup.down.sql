@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_organizations_previous_key_prefix;
+DROP INDEX IF EXISTS idx_organizations_api_key_prefix;
+ALTER TABLE organizations
+    DROP COLUMN IF EXISTS api_key_prefix,
+    DROP COLUMN IF EXISTS api_key_last_used_at,
+    DROP COLUMN IF EXISTS previous_key_prefix,
+    DROP COLUMN IF EXISTS previous_key_last_used_at;
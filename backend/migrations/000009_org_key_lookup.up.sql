@@ -0,0 +1,12 @@
+-- Non-secret key prefixes ("np_" plus 8 characters) so support can find a key
+-- without its plaintext, and when each key last authenticated. Keys created
+-- before this migration have an empty prefix until they are rotated.
+ALTER TABLE organizations
+    ADD COLUMN api_key_prefix VARCHAR(16) NOT NULL DEFAULT '',
+    ADD COLUMN api_key_last_used_at TIMESTAMPTZ,
+    ADD COLUMN previous_key_prefix VARCHAR(16),
+    ADD COLUMN previous_key_last_used_at TIMESTAMPTZ;
+
+CREATE INDEX idx_organizations_api_key_prefix ON organizations(api_key_prefix text_pattern_ops);
+CREATE INDEX idx_organizations_previous_key_prefix ON organizations(previous_key_prefix text_pattern_ops)
+    WHERE previous_key_prefix IS NOT NULL;
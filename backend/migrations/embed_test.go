@@ -0,0 +1,58 @@
+package migrations
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+func TestFS_LoadsWithIOFSDriver(t *testing.T) {
+	source, err := iofs.New(FS, ".")
+	if err != nil {
+		t.Fatalf("failed to load embedded migrations: %v", err)
+	}
+	defer source.Close()
+
+	version, err := source.First()
+	if err != nil {
+		t.Fatalf("expected a first migration, got %v", err)
+	}
+	if version != 1 {
+		t.Errorf("expected first version 1, got %d", version)
+	}
+
+	// Every version must have both an up and a down migration
+	count := 0
+	for {
+		count++
+		up, _, err := source.ReadUp(version)
+		if err != nil {
+			t.Fatalf("failed to read up migration %d: %v", version, err)
+		}
+		_ = up.Close()
+		down, _, err := source.ReadDown(version)
+		if err != nil {
+			t.Fatalf("failed to read down migration %d: %v", version, err)
+		}
+		_ = down.Close()
+
+		next, err := source.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to find migration after %d: %v", version, err)
+		}
+		version = next
+	}
+
+	ups, err := fs.Glob(FS, "*.up.sql")
+	if err != nil {
+		t.Fatalf("failed to list migrations: %v", err)
+	}
+	if count != len(ups) {
+		t.Errorf("expected %d migrations, got %d", len(ups), count)
+	}
+}